@@ -0,0 +1,510 @@
+package stagelinq
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// mixerChannelCount is the number of mixer channels tracked by Aggregator,
+// matching the four decks a device can report at most.
+const mixerChannelCount = 4
+
+// DeckRef identifies a deck of a specific device, for example layer B of an
+// SC6000 is deck 2 of that device.
+type DeckRef struct {
+	Device Token
+	Deck   int
+}
+
+func (r DeckRef) String() string {
+	return fmt.Sprintf("%s/Deck%d", formatToken(r.Device), r.Deck)
+}
+
+// DeckMappingRule assigns a logical deck number to a deck of a device.
+type DeckMappingRule struct {
+	// Device matches the device by token formatted as UUID, see ParseToken,
+	// or by the name it announces, for example "sc6000". Empty matches any
+	// device.
+	Device string
+
+	// Deck is the deck of the device, starting at 1.
+	Deck int
+
+	// Number is the logical deck number to assign.
+	Number int
+}
+
+// AggregatorConfiguration contains configurable values for an Aggregator.
+type AggregatorConfiguration struct {
+	// DeckMapping assigns fixed logical deck numbers to decks. Decks without
+	// a matching rule take the number of the mixer channel they are assigned
+	// to or else the next free number.
+	DeckMapping []DeckMappingRule
+
+	// DeckValues are the deck-relative state paths subscribed to for every
+	// deck, for example "Track/ArtistName". Defaults to DefaultDeckValues.
+	DeckValues []string
+}
+
+// DefaultDeckValues are the deck-relative state paths an Aggregator subscribes
+// to unless configured otherwise.
+var DefaultDeckValues = []string{
+	"Play",
+	"PlayState",
+	"ExternalMixerVolume",
+	"CurrentBPM",
+	"Track/ArtistName",
+	"Track/SongName",
+	"Track/SongLoaded",
+	"Track/CurrentBPM",
+	"Track/TrackLength",
+	"Track/TrackNetworkPath",
+}
+
+// AggregatedDeck is the unified view of a single logical deck.
+type AggregatedDeck struct {
+	// Number is the logical deck number.
+	Number int
+
+	// Source is the deck of the device behind this logical deck.
+	Source DeckRef
+
+	// DeviceName is the name the source device announces.
+	DeviceName string
+
+	// MixerChannel is the mixer channel the deck is assigned to, or 0 if
+	// unknown.
+	MixerChannel int
+
+	// FaderPosition holds the last received position of the fader of the
+	// mixer channel the deck is assigned to, from 0 (closed) to 1 (open), if
+	// any.
+	FaderPosition *float64
+
+	// Values holds the last received values by deck-relative path, for
+	// example "Track/ArtistName".
+	Values map[string]map[string]interface{}
+
+	// Beat holds the last received beat info of the deck, if any.
+	Beat *PlayerInfo
+
+	// Timeline holds the last received timeline position of the deck.
+	Timeline float64
+}
+
+// DeckUpdate reports a change of a logical deck.
+type DeckUpdate struct {
+	// Deck is a snapshot of the deck after the change.
+	Deck *AggregatedDeck
+
+	// Name is the deck-relative path of the changed value, the absolute path
+	// of the mixer state if the deck was renumbered by a channel assignment or
+	// the fader of its mixer channel moved, or empty if the beat info changed.
+	Name string
+}
+
+// MixerUpdate reports a state of a mixer, for example the crossfader
+// position.
+type MixerUpdate struct {
+	Device Token
+	State  *State
+}
+
+type aggregatedDeckState struct {
+	values   map[string]map[string]interface{}
+	beat     *PlayerInfo
+	timeline float64
+}
+
+// Aggregator merges StateMap and BeatInfo data of several devices, such as
+// multiple players and a mixer, into a single set of logical decks.
+//
+// Mixers report which deck of which player is routed to each of their
+// channels via MixerChannelAssignment1 to MixerChannelAssignment4, which the
+// aggregator uses to number decks by mixer channel.
+type Aggregator struct {
+	config AggregatorConfiguration
+
+	lock        sync.Mutex
+	deviceNames map[Token]string
+	decks       map[DeckRef]*aggregatedDeckState
+	channels    [mixerChannelCount + 1]*DeckRef
+	faders      [mixerChannelCount + 1]*float64
+	autoNumbers map[DeckRef]int
+
+	updateC chan *DeckUpdate
+	mixerC  chan *MixerUpdate
+}
+
+// NewAggregator creates an empty aggregator. Feed it using Track or
+// AddState and AddBeatInfo.
+func NewAggregator(config *AggregatorConfiguration) *Aggregator {
+	if config == nil {
+		config = new(AggregatorConfiguration)
+	}
+	c := *config
+	if len(c.DeckValues) == 0 {
+		c.DeckValues = DefaultDeckValues
+	}
+	return &Aggregator{
+		config:      c,
+		deviceNames: map[Token]string{},
+		decks:       map[DeckRef]*aggregatedDeckState{},
+		autoNumbers: map[DeckRef]int{},
+		updateC:     make(chan *DeckUpdate, 16),
+		mixerC:      make(chan *MixerUpdate, 16),
+	}
+}
+
+// UpdateC returns the channel via which changes of logical decks are
+// reported. It must be read from, or feeding the aggregator blocks.
+func (a *Aggregator) UpdateC() <-chan *DeckUpdate {
+	return a.updateC
+}
+
+// MixerC returns the channel via which all mixer states are reported. Fader
+// positions are reported as part of the assigned deck on UpdateC as well. Updates are dropped if the channel is not read from.
+func (a *Aggregator) MixerC() <-chan *MixerUpdate {
+	return a.mixerC
+}
+
+// Track subscribes to the deck and mixer values of the device behind the given
+// session and feeds everything it receives into the aggregator until the
+// session is closed. The aggregator becomes the sole reader of the session's
+// StateC and BeatInfoC.
+func (a *Aggregator) Track(session *Session) (err error) {
+	device := session.Device()
+	a.AddDevice(device)
+
+	for deck := 1; deck <= mixerChannelCount; deck++ {
+		for _, value := range a.config.DeckValues {
+			if err = session.Subscribe(fmt.Sprintf("/Engine/Deck%d/%s", deck, value)); err != nil {
+				return
+			}
+		}
+	}
+	for _, value := range []string{
+		EngineDeckCount,
+		MixerChannelAssignment1,
+		MixerChannelAssignment2,
+		MixerChannelAssignment3,
+		MixerChannelAssignment4,
+		MixerCH1faderPosition,
+		MixerCH2faderPosition,
+		MixerCH3faderPosition,
+		MixerCH4faderPosition,
+		MixerCrossfaderPosition,
+		MixerNumberOfChannels,
+	} {
+		if err = session.Subscribe(value); err != nil {
+			return
+		}
+	}
+	if err = session.StartBeatInfo(); err != nil {
+		return
+	}
+
+	token := session.Token()
+	go func() {
+		stateC := session.StateC()
+		beatInfoC := session.BeatInfoC()
+		for stateC != nil || beatInfoC != nil {
+			select {
+			case state, ok := <-stateC:
+				if !ok {
+					stateC = nil
+					continue
+				}
+				a.AddState(token, state)
+			case beatInfo, ok := <-beatInfoC:
+				if !ok {
+					beatInfoC = nil
+					continue
+				}
+				a.AddBeatInfo(token, beatInfo)
+			}
+		}
+	}()
+	return
+}
+
+// AddDevice makes the aggregator aware of a device's name so that
+// DeckMappingRule can match it by name.
+func (a *Aggregator) AddDevice(device *Device) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.deviceNames[device.token] = device.Name
+}
+
+var deckStatePathRegexp = regexp.MustCompile(`^/Engine/Deck(\d+)/(.+)$`)
+
+// AddState feeds a state received from the given device into the aggregator.
+func (a *Aggregator) AddState(device Token, state *State) {
+	if m := deckStatePathRegexp.FindStringSubmatch(state.Name); m != nil {
+		deck, _ := strconv.Atoi(m[1])
+		ref := DeckRef{Device: device, Deck: deck}
+
+		a.lock.Lock()
+		d := a.deck(ref)
+		d.values[m[2]] = state.Value
+		update := &DeckUpdate{
+			Deck: a.snapshot(ref),
+			Name: m[2],
+		}
+		a.lock.Unlock()
+
+		a.updateC <- update
+		return
+	}
+
+	for channel := 1; channel <= mixerChannelCount; channel++ {
+		if state.Name != fmt.Sprintf("/Mixer/ChannelAssignment%d", channel) {
+			continue
+		}
+		ref, ok := parseChannelAssignment(state.Value)
+		a.lock.Lock()
+		before := make(map[DeckRef]*AggregatedDeck, len(a.decks))
+		for known := range a.decks {
+			before[known] = a.snapshot(known)
+		}
+		if ok {
+			a.channels[channel] = &ref
+		} else {
+			a.channels[channel] = nil
+		}
+		// decks moving to another channel or number are reported
+		updates := []*DeckUpdate{}
+		for known, old := range before {
+			deck := a.snapshot(known)
+			if deck.Number != old.Number || deck.MixerChannel != old.MixerChannel {
+				updates = append(updates, &DeckUpdate{Deck: deck, Name: state.Name})
+			}
+		}
+		a.lock.Unlock()
+		sort.Slice(updates, func(i, j int) bool {
+			return updates[i].Deck.Number < updates[j].Deck.Number
+		})
+		for _, update := range updates {
+			a.updateC <- update
+		}
+		break
+	}
+
+	for channel := 1; channel <= mixerChannelCount; channel++ {
+		if state.Name != fmt.Sprintf("/Mixer/CH%dfaderPosition", channel) {
+			continue
+		}
+		position, ok := state.Value["value"].(float64)
+		if !ok {
+			break
+		}
+		a.lock.Lock()
+		a.faders[channel] = &position
+		var update *DeckUpdate
+		if ref := a.channels[channel]; ref != nil {
+			a.deck(*ref)
+			update = &DeckUpdate{
+				Deck: a.snapshot(*ref),
+				Name: state.Name,
+			}
+		}
+		a.lock.Unlock()
+		if update != nil {
+			a.updateC <- update
+		}
+		break
+	}
+
+	select {
+	case a.mixerC <- &MixerUpdate{Device: device, State: state}:
+	default:
+	}
+}
+
+// AddBeatInfo feeds beat info received from the given device into the
+// aggregator. Players are numbered by deck in the order the device sends
+// them.
+func (a *Aggregator) AddBeatInfo(device Token, beatInfo *BeatInfo) {
+	updates := make([]*DeckUpdate, 0, len(beatInfo.Players))
+
+	a.lock.Lock()
+	for i := range beatInfo.Players {
+		ref := DeckRef{Device: device, Deck: i + 1}
+		d := a.deck(ref)
+		player := beatInfo.Players[i]
+		d.beat = &player
+		if i < len(beatInfo.Timelines) {
+			d.timeline = beatInfo.Timelines[i]
+		}
+		updates = append(updates, &DeckUpdate{Deck: a.snapshot(ref)})
+	}
+	a.lock.Unlock()
+
+	for _, update := range updates {
+		a.updateC <- update
+	}
+}
+
+// Decks returns a snapshot of all logical decks ordered by number.
+func (a *Aggregator) Decks() []*AggregatedDeck {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	decks := make([]*AggregatedDeck, 0, len(a.decks))
+	for ref := range a.decks {
+		decks = append(decks, a.snapshot(ref))
+	}
+	sort.Slice(decks, func(i, j int) bool {
+		return decks[i].Number < decks[j].Number
+	})
+	return decks
+}
+
+// Deck returns a snapshot of the logical deck with the given number.
+func (a *Aggregator) Deck(number int) (deck *AggregatedDeck, ok bool) {
+	for _, d := range a.Decks() {
+		if d.Number == number {
+			return d, true
+		}
+	}
+	return
+}
+
+// deck returns the state of a deck, creating it if needed. Must be called
+// with a.lock held.
+func (a *Aggregator) deck(ref DeckRef) *aggregatedDeckState {
+	d, ok := a.decks[ref]
+	if !ok {
+		d = &aggregatedDeckState{
+			values: map[string]map[string]interface{}{},
+		}
+		a.decks[ref] = d
+	}
+	return d
+}
+
+// snapshot returns a copy of the current view of a deck. Must be called with
+// a.lock held.
+func (a *Aggregator) snapshot(ref DeckRef) *AggregatedDeck {
+	d := a.decks[ref]
+	deck := &AggregatedDeck{
+		Number:       a.number(ref),
+		Source:       ref,
+		DeviceName:   a.deviceNames[ref.Device],
+		MixerChannel: a.mixerChannel(ref),
+		Values:       make(map[string]map[string]interface{}, len(d.values)),
+		Timeline:     d.timeline,
+	}
+	for k, v := range d.values {
+		deck.Values[k] = v
+	}
+	if d.beat != nil {
+		beat := *d.beat
+		deck.Beat = &beat
+	}
+	if deck.MixerChannel != 0 && a.faders[deck.MixerChannel] != nil {
+		position := *a.faders[deck.MixerChannel]
+		deck.FaderPosition = &position
+	}
+	return deck
+}
+
+func (a *Aggregator) mixerChannel(ref DeckRef) int {
+	for channel := 1; channel <= mixerChannelCount; channel++ {
+		if assigned := a.channels[channel]; assigned != nil && *assigned == ref {
+			return channel
+		}
+	}
+	return 0
+}
+
+func (a *Aggregator) ruleNumber(ref DeckRef) (number int, ok bool) {
+	for _, rule := range a.config.DeckMapping {
+		if rule.Deck != ref.Deck {
+			continue
+		}
+		if rule.Device != "" && rule.Device != a.deviceNames[ref.Device] {
+			if token, err := ParseToken(rule.Device); err != nil || token != ref.Device {
+				continue
+			}
+		}
+		return rule.Number, true
+	}
+	return
+}
+
+// number works out the logical number of a deck. Must be called with a.lock
+// held.
+func (a *Aggregator) number(ref DeckRef) int {
+	if number, ok := a.ruleNumber(ref); ok {
+		return number
+	}
+	if channel := a.mixerChannel(ref); channel != 0 {
+		return channel
+	}
+	if number, ok := a.autoNumbers[ref]; ok && !a.numberTaken(number, ref) {
+		return number
+	}
+
+	// pick the lowest number nobody else uses
+	number := 1
+	for a.numberTaken(number, ref) {
+		number++
+	}
+	a.autoNumbers[ref] = number
+	return number
+}
+
+// numberTaken tells whether a logical deck number is used by a deck other than
+// the given one through a mapping rule, mixer channel or earlier automatic
+// assignment.
+func (a *Aggregator) numberTaken(number int, except DeckRef) bool {
+	for _, rule := range a.config.DeckMapping {
+		if rule.Number == number {
+			return true
+		}
+	}
+	for channel := 1; channel <= mixerChannelCount; channel++ {
+		if assigned := a.channels[channel]; channel == number && assigned != nil && *assigned != except {
+			if _, ok := a.ruleNumber(*assigned); !ok {
+				return true
+			}
+		}
+	}
+	for ref, n := range a.autoNumbers {
+		if n == number && ref != except {
+			if _, ok := a.ruleNumber(ref); ok {
+				continue
+			}
+			if a.mixerChannel(ref) != 0 {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+var channelAssignmentRegexp = regexp.MustCompile(`\{?([0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12})\}?\D*(\d+)`)
+
+// parseChannelAssignment parses the value of a MixerChannelAssignment state,
+// which names the token of the player and its deck, for example
+// "{f405dc14-0223-47f5-8b79-2c8c49335276},1".
+func parseChannelAssignment(value map[string]interface{}) (ref DeckRef, ok bool) {
+	s, _ := value["string"].(string)
+	m := channelAssignmentRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return
+	}
+	token, err := ParseToken(m[1])
+	if err != nil {
+		return
+	}
+	deck, err := strconv.Atoi(m[2])
+	if err != nil || deck < 1 {
+		return
+	}
+	return DeckRef{Device: token, Deck: deck}, true
+}
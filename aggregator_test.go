@@ -0,0 +1,166 @@
+package stagelinq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseToken(t *testing.T) {
+	token := Token{0xf4, 0x05, 0xdc, 0x14, 0x02, 0x23, 0x47, 0xf5, 0x8b, 0x79, 0x2c, 0x8c, 0x49, 0x33, 0x52, 0x76}
+	require.Equal(t, "f405dc14-0223-47f5-8b79-2c8c49335276", formatToken(token))
+
+	for _, s := range []string{
+		"f405dc14-0223-47f5-8b79-2c8c49335276",
+		"{F405DC14-0223-47F5-8B79-2C8C49335276}",
+		"f405dc14022347f58b792c8c49335276",
+	} {
+		parsed, err := ParseToken(s)
+		require.NoError(t, err, s)
+		require.Equal(t, token, parsed, s)
+	}
+
+	_, err := ParseToken("f405dc14")
+	require.Error(t, err)
+}
+
+func Test_Aggregator(t *testing.T) {
+	playerA := Token{0xa}
+	playerB := Token{0xb}
+	mixer := Token{0xc}
+
+	a := NewAggregator(&AggregatorConfiguration{
+		DeckMapping: []DeckMappingRule{
+			{Device: "player-b", Deck: 2, Number: 4},
+		},
+	})
+	a.AddDevice(&Device{token: playerA, Name: "player-a"})
+	a.AddDevice(&Device{token: playerB, Name: "player-b"})
+	a.AddDevice(&Device{token: mixer, Name: "mixer"})
+
+	addState := func(device Token, name string, value map[string]interface{}) *DeckUpdate {
+		a.AddState(device, &State{Name: name, Value: value})
+		select {
+		case update := <-a.UpdateC():
+			return update
+		default:
+			return nil
+		}
+	}
+
+	// without mixer information decks are numbered in order of appearance
+	update := addState(playerB, EngineDeck1.Play(), map[string]interface{}{"state": true})
+	require.NotNil(t, update)
+	require.Equal(t, 1, update.Deck.Number)
+	require.Equal(t, "Play", update.Name)
+	require.Equal(t, DeckRef{Device: playerB, Deck: 1}, update.Deck.Source)
+	require.Equal(t, "player-b", update.Deck.DeviceName)
+
+	// explicit mapping rules win
+	update = addState(playerB, EngineDeck2.Play(), map[string]interface{}{"state": false})
+	require.Equal(t, 4, update.Deck.Number)
+
+	// mixer channel assignments take precedence over automatic numbering,
+	// decks giving way to them are renumbered
+	update = addState(mixer, MixerChannelAssignment1, map[string]interface{}{
+		"string": "{" + formatToken(playerA) + "},1",
+		"type":   8,
+	})
+	require.NotNil(t, update)
+	require.Equal(t, DeckRef{Device: playerB, Deck: 1}, update.Deck.Source)
+	require.Equal(t, 2, update.Deck.Number)
+	require.Equal(t, MixerChannelAssignment1, update.Name)
+	<-a.MixerC()
+	update = addState(mixer, MixerChannelAssignment2, map[string]interface{}{
+		"string": "{" + formatToken(playerB) + "},1",
+		"type":   8,
+	})
+	require.NotNil(t, update)
+	require.Equal(t, 2, update.Deck.Number)
+	require.Equal(t, 2, update.Deck.MixerChannel)
+	<-a.MixerC()
+
+	update = addState(playerA, EngineDeck1.TrackArtistName(), map[string]interface{}{"string": "Artist", "type": 8})
+	require.Equal(t, 1, update.Deck.Number)
+	require.Equal(t, 1, update.Deck.MixerChannel)
+	require.Equal(t, "Track/ArtistName", update.Name)
+	require.Nil(t, update.Deck.FaderPosition)
+
+	// fader positions end up on the deck assigned to the channel
+	update = addState(mixer, MixerCH1faderPosition, map[string]interface{}{"value": 0.5, "type": 0})
+	require.NotNil(t, update)
+	require.Equal(t, 1, update.Deck.Number)
+	require.Equal(t, MixerCH1faderPosition, update.Name)
+	require.Equal(t, 0.5, *update.Deck.FaderPosition)
+	<-a.MixerC()
+	require.Nil(t, addState(mixer, MixerCH3faderPosition, map[string]interface{}{"value": 1.0, "type": 0}))
+	<-a.MixerC()
+
+	a.AddBeatInfo(playerB, &BeatInfo{
+		Players:   []PlayerInfo{{Beat: 1, TotalBeats: 100, Bpm: 128}},
+		Timelines: []float64{42},
+	})
+	update = <-a.UpdateC()
+	require.Equal(t, 2, update.Deck.Number)
+	require.Equal(t, 2, update.Deck.MixerChannel)
+	require.Equal(t, 128.0, update.Deck.Beat.Bpm)
+	require.Equal(t, 42.0, update.Deck.Timeline)
+
+	decks := a.Decks()
+	require.Len(t, decks, 3)
+	require.Equal(t, []int{1, 2, 4}, []int{decks[0].Number, decks[1].Number, decks[2].Number})
+	deck, ok := a.Deck(2)
+	require.True(t, ok)
+	require.Equal(t, map[string]interface{}{"state": true}, deck.Values["Play"])
+	_, ok = a.Deck(3)
+	require.False(t, ok)
+}
+
+func Test_Aggregator_Reassignment(t *testing.T) {
+	playerA := Token{0xa}
+	playerB := Token{0xb}
+	mixer := Token{0xc}
+	a := NewAggregator(nil)
+	assign := func(name string, player Token) {
+		a.AddState(mixer, &State{Name: name, Value: map[string]interface{}{
+			"string": "{" + formatToken(player) + "},1",
+			"type":   8,
+		}})
+		<-a.MixerC()
+	}
+
+	assign(MixerChannelAssignment1, playerA)
+	assign(MixerChannelAssignment2, playerB)
+	for _, player := range []Token{playerA, playerB} {
+		a.AddState(player, &State{Name: EngineDeck1.Play(), Value: map[string]interface{}{"state": true}})
+		<-a.UpdateC()
+	}
+	a.AddState(mixer, &State{Name: MixerCH1faderPosition, Value: map[string]interface{}{"value": 0.0, "type": 0}})
+	require.Equal(t, 1, (<-a.UpdateC()).Deck.Number)
+	<-a.MixerC()
+
+	// swapping the players reports both decks with their new numbers
+	assign(MixerChannelAssignment1, playerB)
+	update := <-a.UpdateC()
+	require.Equal(t, DeckRef{Device: playerB, Deck: 1}, update.Deck.Source)
+	require.Equal(t, 1, update.Deck.Number)
+	require.Equal(t, 1, update.Deck.MixerChannel)
+	require.Equal(t, 0.0, *update.Deck.FaderPosition)
+	require.Equal(t, MixerChannelAssignment1, update.Name)
+	update = <-a.UpdateC()
+	require.Equal(t, DeckRef{Device: playerA, Deck: 1}, update.Deck.Source)
+	require.Equal(t, 0, update.Deck.MixerChannel)
+	require.Nil(t, update.Deck.FaderPosition)
+	require.Len(t, a.UpdateC(), 0)
+
+	assign(MixerChannelAssignment2, playerA)
+	update = <-a.UpdateC()
+	require.Equal(t, DeckRef{Device: playerA, Deck: 1}, update.Deck.Source)
+	require.Equal(t, 2, update.Deck.Number)
+	require.Equal(t, 2, update.Deck.MixerChannel)
+	require.Len(t, a.UpdateC(), 0)
+
+	// reassigning a channel to the same deck changes nothing
+	assign(MixerChannelAssignment2, playerA)
+	require.Len(t, a.UpdateC(), 0)
+}
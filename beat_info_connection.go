@@ -21,10 +21,10 @@ type BeatInfoConnection struct {
 	beatInfoC chan *BeatInfo
 }
 
-var beatInfoConnectionMessageSet = newDeviceConnMessageSet([]messages.Message{&beatEmitMessage{}})
+var beatInfoConnectionMessageSet = newServiceMessageSet("BeatInfo", []messages.Message{&beatEmitMessage{}})
 
-func NewBeatInfoConnection(conn net.Conn, token Token) (bic *BeatInfoConnection, err error) {
-	msgConn := newMessageConnection(conn, beatInfoConnectionMessageSet)
+func NewBeatInfoConnection(conn net.Conn, token Token, opts ...ConnectionOption) (bic *BeatInfoConnection, err error) {
+	msgConn := newMessageConnection(conn, beatInfoConnectionMessageSet, opts...)
 
 	errC := make(chan error, 1)
 	beatInfoC := make(chan *BeatInfo, 1)
@@ -0,0 +1,385 @@
+package stagelinq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownDevice is returned by Client methods if no device with the given
+// token has been discovered.
+var ErrUnknownDevice = errors.New("unknown device")
+
+// ErrClientClosed is returned by Client methods after the client has been
+// closed.
+var ErrClientClosed = errors.New("client closed")
+
+const (
+	defaultClientAnnounceInterval = time.Second
+	defaultClientDeviceTimeout    = 5 * time.Second
+)
+
+// ClientConfiguration contains configurable values for a Client.
+type ClientConfiguration struct {
+	// ListenerConfiguration configures how the client announces itself to
+	// the network.
+	ListenerConfiguration
+
+	// AnnounceInterval is the interval at which the client announces itself.
+	// Defaults to 1 second.
+	AnnounceInterval time.Duration
+
+	// DeviceTimeout is how long a device may stop announcing itself before
+	// it is considered gone. Defaults to 5 seconds.
+	DeviceTimeout time.Duration
+
+	// Session configures the sessions the client keeps with devices. Token and
+	// Resolver are set by the client.
+	Session SessionConfiguration
+}
+
+// DeviceEvent reports a device appearing on or leaving the network.
+type DeviceEvent struct {
+	Device *Device
+	State  DeviceState
+}
+
+type clientDevice struct {
+	device   *Device
+	lastSeen time.Time
+}
+
+// Client discovers StagelinQ devices on the network and manages connections to
+// their services. It takes care of announcing ourselves, connecting to the main
+// connection of a device, requesting its services and reconnecting whenever a
+// connection is lost.
+//
+// A Client is safe for concurrent use and handles any number of devices.
+type Client struct {
+	config   ClientConfiguration
+	listener *Listener
+
+	lock     sync.Mutex
+	closed   bool
+	devices  map[Token]*clientDevice
+	sessions map[Token]*Session
+	// changed is closed and replaced whenever the list of devices changes
+	changed chan struct{}
+
+	deviceC chan *DeviceEvent
+	done    chan struct{}
+}
+
+// NewClient starts listening for devices on the network and announces the
+// client to them.
+func NewClient(config *ClientConfiguration) (client *Client, err error) {
+	if config == nil {
+		config = new(ClientConfiguration)
+	}
+	c := *config
+	if c.AnnounceInterval <= 0 {
+		c.AnnounceInterval = defaultClientAnnounceInterval
+	}
+	if c.DeviceTimeout <= 0 {
+		c.DeviceTimeout = defaultClientDeviceTimeout
+	}
+
+	listener, err := ListenWithConfiguration(&c.ListenerConfiguration)
+	if err != nil {
+		return
+	}
+	listener.AnnounceEvery(c.AnnounceInterval)
+
+	client = &Client{
+		config:   c,
+		listener: listener,
+		devices:  map[Token]*clientDevice{},
+		sessions: map[Token]*Session{},
+		changed:  make(chan struct{}),
+		deviceC:  make(chan *DeviceEvent, 16),
+		done:     make(chan struct{}),
+	}
+	go client.discover()
+	return
+}
+
+// Token returns the token the client announces to the network.
+func (c *Client) Token() Token {
+	return c.listener.Token()
+}
+
+// DeviceC returns the channel via which devices appearing on and leaving the
+// network are reported. Events are dropped if the channel is not read from
+// quickly enough, use Devices to get the current list of devices instead.
+// It is closed when the client is closed.
+func (c *Client) DeviceC() <-chan *DeviceEvent {
+	return c.deviceC
+}
+
+// Devices returns the devices currently present on the network.
+func (c *Client) Devices() []*Device {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	devices := make([]*Device, 0, len(c.devices))
+	for _, d := range c.devices {
+		devices = append(devices, d.device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return bytes.Compare(devices[i].token[:], devices[j].token[:]) < 0
+	})
+	return devices
+}
+
+// Device returns the device with the given token if it is present on the
+// network.
+func (c *Client) Device(token Token) (device *Device, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	d, ok := c.devices[token]
+	if ok {
+		device = d.device
+	}
+	return
+}
+
+// ResolveDevice waits until the device with the given token is present on the
+// network or ctx is done.
+func (c *Client) ResolveDevice(ctx context.Context, token Token) (*Device, error) {
+	for {
+		c.lock.Lock()
+		if c.closed {
+			c.lock.Unlock()
+			return nil, ErrClientClosed
+		}
+		d, ok := c.devices[token]
+		changed := c.changed
+		c.lock.Unlock()
+		if ok {
+			return d.device, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Session returns the session keeping the device with the given token
+// connected, starting it if needed. Sessions stay around while their device
+// is gone and pick it up again once it reappears.
+func (c *Client) Session(token Token) (*Session, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	if session, ok := c.sessions[token]; ok {
+		return session, nil
+	}
+	d, ok := c.devices[token]
+	if !ok {
+		return nil, ErrUnknownDevice
+	}
+	config := c.config.Session
+	config.Token = c.listener.Token()
+	config.Resolver = c
+	session := NewSession(d.device, &config)
+	c.sessions[token] = session
+	return session, nil
+}
+
+// StateMap returns access to the StateMap service of the device with the
+// given token.
+func (c *Client) StateMap(token Token) (*ClientStateMap, error) {
+	session, err := c.Session(token)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientStateMap{session: session}, nil
+}
+
+// BeatInfo returns access to the BeatInfo service of the device with the
+// given token.
+func (c *Client) BeatInfo(token Token) (*ClientBeatInfo, error) {
+	session, err := c.Session(token)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientBeatInfo{session: session}, nil
+}
+
+// Close disconnects from all devices, announces that we are leaving the
+// network and stops listening.
+func (c *Client) Close() (err error) {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return ErrClientClosed
+	}
+	c.closed = true
+	sessions := make([]*Session, 0, len(c.sessions))
+	for _, session := range c.sessions {
+		sessions = append(sessions, session)
+	}
+	close(c.changed)
+	c.lock.Unlock()
+
+	for _, session := range sessions {
+		session.Close()
+	}
+	err = c.listener.Close()
+	<-c.done
+	return
+}
+
+func (c *Client) notify(event *DeviceEvent) {
+	select {
+	case c.deviceC <- event:
+	default:
+	}
+}
+
+// discover keeps the list of devices up to date until the listener is closed.
+func (c *Client) discover() {
+	defer close(c.done)
+	defer close(c.deviceC)
+
+	for {
+		device, deviceState, err := c.listener.Discover(time.Second)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
+		c.lock.Lock()
+		if c.closed {
+			c.lock.Unlock()
+			return
+		}
+		changed := false
+		events := []*DeviceEvent{}
+		now := time.Now()
+
+		if err == nil && device != nil {
+			d, known := c.devices[device.token]
+			switch deviceState {
+			case DevicePresent:
+				if !known {
+					d = &clientDevice{}
+					c.devices[device.token] = d
+					events = append(events, &DeviceEvent{Device: device, State: DevicePresent})
+				}
+				if !known || !d.device.IsEqual(device) || !d.device.IP.Equal(device.IP) || d.device.port != device.port {
+					changed = true
+				}
+				d.device = device
+				d.lastSeen = now
+			case DeviceLeaving:
+				if known {
+					delete(c.devices, device.token)
+					changed = true
+					events = append(events, &DeviceEvent{Device: device, State: DeviceLeaving})
+				}
+			}
+		}
+
+		// forget devices that silently went away
+		for token, d := range c.devices {
+			if now.Sub(d.lastSeen) > c.config.DeviceTimeout {
+				delete(c.devices, token)
+				changed = true
+				events = append(events, &DeviceEvent{Device: d.device, State: DeviceLeaving})
+			}
+		}
+
+		if changed {
+			close(c.changed)
+			c.changed = make(chan struct{})
+		}
+		c.lock.Unlock()
+
+		for _, event := range events {
+			c.notify(event)
+		}
+	}
+}
+
+// ClientStateMap gives access to the StateMap service of a device managed by
+// a Client. Subscriptions are kept across reconnects.
+type ClientStateMap struct {
+	session *Session
+}
+
+// Subscribe tells the device to send us updates for the given state value
+// path.
+func (m *ClientStateMap) Subscribe(event string, opts ...StateMapSubscriptionOption) error {
+	return m.session.Subscribe(event, opts...)
+}
+
+// SubscribeContext subscribes to the given state value path and waits for the
+// device to acknowledge it, see Session.SubscribeContext.
+func (m *ClientStateMap) SubscribeContext(ctx context.Context, event string, opts ...StateMapSubscriptionOption) (*StateSubscription, error) {
+	return m.session.SubscribeContext(ctx, event, opts...)
+}
+
+// SubscribeAll subscribes to all state value paths starting with the given
+// prefix.
+func (m *ClientStateMap) SubscribeAll(prefix string, opts ...StateMapSubscriptionOption) error {
+	return m.session.SubscribeAll(prefix, opts...)
+}
+
+// SubscribeGlob subscribes to all state value paths matching the given glob
+// pattern, for example "/Engine/Deck*/Track/*".
+func (m *ClientStateMap) SubscribeGlob(pattern string, opts ...StateMapSubscriptionOption) error {
+	return m.session.SubscribeGlob(pattern, opts...)
+}
+
+// StateC returns the channel via which state changes of the device are
+// returned.
+func (m *ClientStateMap) StateC() <-chan *State {
+	return m.session.StateC()
+}
+
+// Set writes a state value to the device, see StateMapConnection.Set.
+func (m *ClientStateMap) Set(name string, value interface{}) error {
+	return m.session.Set(name, value)
+}
+
+// SetContext writes a state value to the device and waits until the device
+// echoes it back, see StateMapConnection.SetContext.
+func (m *ClientStateMap) SetContext(ctx context.Context, name string, value interface{}) error {
+	return m.session.SetContext(ctx, name, value)
+}
+
+// NewStateConsumer returns a consumer that receives all states of the device
+// accepted by the given filter, see Session.NewStateConsumer.
+func (m *ClientStateMap) NewStateConsumer(filter StateFilter, opts ...StateConsumerOption) *StateConsumer {
+	return m.session.NewStateConsumer(filter, opts...)
+}
+
+// ClientBeatInfo gives access to the BeatInfo service of a device managed by
+// a Client. The stream is restarted after reconnects.
+type ClientBeatInfo struct {
+	session *Session
+}
+
+// StartStream tells the device to start publishing the BeatInfo data stream.
+func (b *ClientBeatInfo) StartStream() error {
+	return b.session.StartBeatInfo()
+}
+
+// StopStream tells the device to stop publishing the BeatInfo data stream.
+func (b *ClientBeatInfo) StopStream() error {
+	return b.session.StopBeatInfo()
+}
+
+// BeatInfoC returns the channel via which the BeatInfo data stream of the
+// device is returned.
+func (b *ClientBeatInfo) BeatInfoC() <-chan *BeatInfo {
+	return b.session.BeatInfoC()
+}
@@ -0,0 +1,92 @@
+package stagelinq
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/icedream/go-stagelinq/internal/messages"
+	"github.com/icedream/go-stagelinq/internal/socket"
+	"github.com/stretchr/testify/require"
+)
+
+// announce makes the fake device announce itself to a listener on the local
+// host until the test ends.
+func (d *fakeDevice) announce(t *testing.T) {
+	b := new(bytes.Buffer)
+	require.NoError(t, (&discoveryMessage{
+		TokenPrefixedMessage: messages.TokenPrefixedMessage{Token: messages.Token(d.token)},
+		Source:               "fake",
+		Action:               discovererHowdy,
+		SoftwareName:         "fake",
+		SoftwareVersion:      "1.0.0",
+		Port:                 socket.GetPort(d.mainListener.Addr()),
+	}).WriteMessageTo(b))
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 51337})
+	require.NoError(t, err)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+		conn.Close()
+	})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			_, _ = conn.Write(b.Bytes())
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func Test_Client(t *testing.T) {
+	client, err := NewClient(&ClientConfiguration{
+		ListenerConfiguration: ListenerConfiguration{
+			Name:            "test",
+			SoftwareName:    "go-stagelinq test",
+			SoftwareVersion: "0.0.0",
+		},
+	})
+	if err != nil {
+		t.Skipf("can't listen for discovery messages: %s", err)
+	}
+
+	d := newFakeDevice(t)
+	_, err = client.StateMap(d.token)
+	require.ErrorIs(t, err, ErrUnknownDevice)
+
+	d.announce(t)
+	select {
+	case event := <-client.DeviceC():
+		require.Equal(t, d.token, event.Device.Token())
+		require.Equal(t, DevicePresent, event.State)
+	case <-time.After(5 * time.Second):
+		t.Fatal("device has not been discovered")
+	}
+	require.Len(t, client.Devices(), 1)
+
+	stateMap, err := client.StateMap(d.token)
+	require.NoError(t, err)
+	require.NoError(t, stateMap.Subscribe(EngineDeck1.Play()))
+	select {
+	case state := <-stateMap.StateC():
+		require.Equal(t, EngineDeck1.Play(), state.Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no state received")
+	}
+
+	require.NoError(t, client.Close())
+	require.ErrorIs(t, client.Close(), ErrClientClosed)
+	_, ok := <-stateMap.StateC()
+	require.False(t, ok)
+}
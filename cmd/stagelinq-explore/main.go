@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/icedream/go-stagelinq"
+)
+
+const (
+	appName    = "Icedream StagelinQ Explorer"
+	appVersion = "0.0.0"
+)
+
+var (
+	fDevice      = flag.String("device", "", "name, IP address or token of the device to explore (default: first device found)")
+	fTimeout     = flag.Duration("timeout", 10*time.Second, "how long to look for the device")
+	fWait        = flag.Duration("wait", 5*time.Second, "how long to wait for values after subscribing")
+	fAckTimeout  = flag.Duration("ack-timeout", 2*time.Second, "how long to wait for each subscription to be acknowledged")
+	fConcurrency = flag.Int("concurrency", 32, "number of subscriptions waiting for acknowledgement at the same time")
+	fPaths       = flag.String("paths", "", "file with additional paths to try, one per line")
+	fNoVariants  = flag.Bool("no-variants", false, "only try catalog paths, no deck/channel/slot variants")
+	fOutput      = flag.String("output", "text", "output format: text|json")
+)
+
+// pathResult is what we found out about a single path.
+type pathResult struct {
+	Path         string                 `json:"path"`
+	Known        bool                   `json:"known"`
+	Acknowledged bool                   `json:"acknowledged"`
+	Answered     bool                   `json:"answered"`
+	Type         string                 `json:"type,omitempty"`
+	CatalogType  string                 `json:"catalogType,omitempty"`
+	Sample       map[string]interface{} `json:"sample,omitempty"`
+}
+
+// report is the result of exploring a device.
+type report struct {
+	Device          string        `json:"device"`
+	SoftwareName    string        `json:"softwareName"`
+	SoftwareVersion string        `json:"softwareVersion"`
+	Paths           []*pathResult `json:"paths"`
+}
+
+func matchesDevice(device *stagelinq.Device, s string) bool {
+	if s == "" {
+		return true
+	}
+	if device.Name == s || device.IP.String() == s {
+		return true
+	}
+	token, err := stagelinq.ParseToken(s)
+	return err == nil && token == device.Token()
+}
+
+func findDevice(listener *stagelinq.Listener) (*stagelinq.Device, error) {
+	deadline := time.Now().Add(*fTimeout)
+	for time.Now().Before(deadline) {
+		device, deviceState, err := listener.Discover(time.Until(deadline))
+		if err != nil {
+			log.Printf("WARNING: %s", err.Error())
+			continue
+		}
+		if device == nil || deviceState != stagelinq.DevicePresent {
+			continue
+		}
+		if matchesDevice(device, *fDevice) {
+			return device, nil
+		}
+	}
+	return nil, errors.New("no matching device found")
+}
+
+func readPaths(name string) (paths []string, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	err = scanner.Err()
+	return
+}
+
+func valueType(value map[string]interface{}) string {
+	t, ok := value["type"].(float64)
+	if !ok {
+		return ""
+	}
+	return stagelinq.StateValueType(t).String()
+}
+
+func explore(listener *stagelinq.Listener, device *stagelinq.Device, paths []string) (r *report, err error) {
+	deviceConn, err := device.Connect(listener.Token(), []*stagelinq.Service{})
+	if err != nil {
+		return
+	}
+	defer deviceConn.Close()
+	services, err := deviceConn.RequestServices()
+	if err != nil {
+		return
+	}
+	var port uint16
+	for _, service := range services {
+		if service.Name == "StateMap" {
+			port = service.Port
+		}
+	}
+	if port == 0 {
+		return nil, errors.New("device does not offer StateMap")
+	}
+	conn, err := device.Dial(port)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	stateMapConn, err := stagelinq.NewStateMapConnection(conn, listener.Token())
+	if err != nil {
+		return
+	}
+
+	r = &report{
+		Device:          device.Name,
+		SoftwareName:    device.SoftwareName,
+		SoftwareVersion: device.SoftwareVersion,
+	}
+	results := map[string]*pathResult{}
+	for _, path := range paths {
+		result := &pathResult{Path: path}
+		if info, ok := stagelinq.LookupStateValue(path); ok {
+			result.Known = true
+			result.CatalogType = info.Type.String()
+		}
+		results[path] = result
+		r.Paths = append(r.Paths, result)
+	}
+
+	var lock sync.Mutex
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for state := range stateMapConn.StateC() {
+			lock.Lock()
+			result, ok := results[state.Name]
+			if !ok {
+				// the device sent something we did not ask for
+				result = &pathResult{Path: state.Name}
+				if info, ok := stagelinq.LookupStateValue(state.Name); ok {
+					result.Known = true
+					result.CatalogType = info.Type.String()
+				}
+				results[state.Name] = result
+				r.Paths = append(r.Paths, result)
+			}
+			if !result.Answered {
+				result.Answered = true
+				result.Type = valueType(state.Value)
+				result.Sample = state.Value
+			}
+			lock.Unlock()
+		}
+	}()
+
+	log.Printf("subscribing to %d paths…", len(paths))
+	pathC := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < *fConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range pathC {
+				ctx, cancel := context.WithTimeout(context.Background(), *fAckTimeout)
+				_, err := stateMapConn.SubscribeContext(ctx, path)
+				cancel()
+				lock.Lock()
+				results[path].Acknowledged = err == nil
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, path := range paths {
+		pathC <- path
+	}
+	close(pathC)
+	wg.Wait()
+
+	log.Printf("waiting %s for values…", *fWait)
+	time.Sleep(*fWait)
+	conn.Close()
+	<-received
+
+	lock.Lock()
+	defer lock.Unlock()
+	sort.Slice(r.Paths, func(i, j int) bool {
+		return r.Paths[i].Path < r.Paths[j].Path
+	})
+	return
+}
+
+func printText(r *report) {
+	fmt.Printf("Device: %s (%s %s)\n", r.Device, r.SoftwareName, r.SoftwareVersion)
+
+	var answered, unknown, silent, mismatched []*pathResult
+	for _, result := range r.Paths {
+		switch {
+		case result.Answered && !result.Known:
+			unknown = append(unknown, result)
+			answered = append(answered, result)
+		case result.Answered:
+			answered = append(answered, result)
+			if result.Type != "" && result.Type != result.CatalogType {
+				mismatched = append(mismatched, result)
+			}
+		case result.Known:
+			silent = append(silent, result)
+		}
+	}
+
+	fmt.Printf("\nAnswered paths (%d):\n", len(answered))
+	for _, result := range answered {
+		sample, _ := json.Marshal(result.Sample)
+		fmt.Printf("  %-60s %-8s %s\n", result.Path, result.Type, sample)
+	}
+	fmt.Printf("\nAnswered paths missing from the catalog (%d):\n", len(unknown))
+	for _, result := range unknown {
+		fmt.Printf("  %s (%s)\n", result.Path, result.Type)
+	}
+	fmt.Printf("\nAnswered paths with a different type than in the catalog (%d):\n", len(mismatched))
+	for _, result := range mismatched {
+		fmt.Printf("  %s: %s, catalog says %s\n", result.Path, result.Type, result.CatalogType)
+	}
+	fmt.Printf("\nCatalog paths the device did not answer (%d):\n", len(silent))
+	for _, result := range silent {
+		acknowledged := ""
+		if result.Acknowledged {
+			acknowledged = " (acknowledged)"
+		}
+		fmt.Printf("  %s%s\n", result.Path, acknowledged)
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	switch *fOutput {
+	case "text", "json":
+	default:
+		log.Fatalf("unknown format: %s", *fOutput)
+	}
+
+	// try unverified catalog paths too, verifying them is what this is for
+	paths := []string{}
+	for _, info := range stagelinq.StateValueCatalog() {
+		paths = append(paths, info.Path)
+	}
+	if *fPaths != "" {
+		extra, err := readPaths(*fPaths)
+		if err != nil {
+			log.Fatal(err)
+		}
+		paths = append(paths, extra...)
+	}
+	if *fNoVariants {
+		paths = unique(paths)
+	} else {
+		paths = variants(paths)
+	}
+
+	listener, err := stagelinq.ListenWithConfiguration(&stagelinq.ListenerConfiguration{
+		DiscoveryTimeout: *fTimeout,
+		SoftwareName:     appName,
+		SoftwareVersion:  appVersion,
+		Name:             "explorer",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer listener.Close()
+	listener.AnnounceEvery(time.Second)
+
+	log.Printf("Looking for devices for %s", *fTimeout)
+	device, err := findDevice(listener)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("exploring %s %q %q %q", device.IP.String(), device.Name, device.SoftwareName, device.SoftwareVersion)
+
+	r, err := explore(listener, device, paths)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch *fOutput {
+	case "text":
+		printText(r)
+	case "json":
+		je := json.NewEncoder(os.Stdout)
+		je.SetIndent("", "  ")
+		if err := je.Encode(r); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
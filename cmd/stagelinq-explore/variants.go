@@ -0,0 +1,62 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// numberRegexp finds the numbers embedded in path segments, such as the deck
+// in /Engine/Deck1/Play.
+var numberRegexp = regexp.MustCompile(`([A-Za-z]*)(\d+)`)
+
+// variantCount tells how many variants to try for a number based on the word
+// in front of it. Slot-like things go up to 8, decks and channels up to 4.
+func variantCount(word string) int {
+	lower := strings.ToLower(word)
+	for _, slotWord := range []string{"slot", "loop", "label", "cue", "pad", "sample"} {
+		if strings.Contains(lower, slotWord) {
+			return 8
+		}
+	}
+	return 4
+}
+
+// variants returns the given paths plus heuristic variants of them with the
+// embedded numbers replaced by the numbers commonly used by devices, in all
+// combinations. /Engine/Deck1/Track/Loop1 yields /Engine/Deck2/Track/Loop3,
+// among others.
+func variants(paths []string) []string {
+	result := []string{}
+	for _, path := range paths {
+		result = append(result, path)
+		result = append(result, expandNumbers(path, numberRegexp.FindAllStringSubmatchIndex(path, -1))...)
+	}
+	return unique(result)
+}
+
+// expandNumbers returns the cross product of the variants of all numbers found
+// at the given matches, which have to be in order.
+func expandNumbers(path string, matches [][]int) []string {
+	if len(matches) == 0 {
+		return []string{path}
+	}
+	// replace from the end so the indexes of earlier matches stay valid
+	last := matches[len(matches)-1]
+	// last[2]:last[3] is the word, last[4]:last[5] the number
+	count := variantCount(path[last[2]:last[3]])
+	result := []string{}
+	for i := 1; i <= count; i++ {
+		variant := path[:last[4]] + strconv.Itoa(i) + path[last[5]:]
+		result = append(result, expandNumbers(variant, matches[:len(matches)-1])...)
+	}
+	return result
+}
+
+// unique returns the given paths sorted and without duplicates.
+func unique(paths []string) []string {
+	paths = slices.Clone(paths)
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/icedream/go-stagelinq/eaas"
+	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
+)
+
+var errNoArtwork = errors.New("no artwork")
+
+// decodeAlbumArt turns the value of an AlbumArt state into image bytes. The
+// blob arrives inside a JSON string, either base64 encoded or as is.
+func decodeAlbumArt(value map[string]interface{}) (b []byte, err error) {
+	s, _ := value["string"].(string)
+	if s == "" {
+		err = errNoArtwork
+		return
+	}
+	if b, err = base64.StdEncoding.DecodeString(s); err == nil {
+		return
+	}
+	return []byte(s), nil
+}
+
+// convertArtwork re-encodes image bytes in the given format, png or jpeg.
+// Missing artwork becomes a transparent pixel so that image sources in
+// streaming tools turn empty.
+func convertArtwork(b []byte, format string) (out []byte, err error) {
+	var img image.Image
+	if len(b) == 0 {
+		img = image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	} else if img, _, err = image.Decode(bytes.NewReader(b)); err != nil {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	switch format {
+	case "png":
+		err = png.Encode(buf, img)
+	case "jpeg", "jpg":
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: 90})
+	default:
+		err = fmt.Errorf("unsupported artwork format %q", format)
+	}
+	out = buf.Bytes()
+	return
+}
+
+// fetchPreviewArtwork searches the libraries served via EAAS for the track
+// and returns its preview artwork.
+func fetchPreviewArtwork(ctx context.Context, conn *eaas.EngineLibraryConnection, artist, title string) (b []byte, err error) {
+	if title == "" {
+		err = errNoArtwork
+		return
+	}
+	libraries, err := conn.GetLibraries(ctx, &enginelibrary.GetLibrariesRequest{})
+	if err != nil {
+		return
+	}
+	for _, library := range libraries.GetLibraries() {
+		var result *enginelibrary.SearchTracksResponse
+		result, err = conn.SearchTracks(ctx, &enginelibrary.SearchTracksRequest{
+			LibraryId:   library.Id,
+			Query:       &title,
+			QueryFields: []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_TITLE},
+		})
+		if err != nil {
+			return
+		}
+		for _, track := range result.GetTracks() {
+			metadata := track.GetMetadata()
+			if !strings.EqualFold(metadata.GetTitle(), title) ||
+				(artist != "" && !strings.EqualFold(metadata.GetArtist(), artist)) {
+				continue
+			}
+			if artwork := track.GetPreviewArtwork(); len(artwork) > 0 {
+				return artwork, nil
+			}
+		}
+	}
+	err = errNoArtwork
+	return
+}
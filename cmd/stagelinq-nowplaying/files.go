@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// fileWriter writes output files atomically and skips files whose content
+// did not change, so tools polling them never see partial content.
+type fileWriter struct {
+	dir     string
+	written map[string][]byte
+}
+
+func newFileWriter(dir string) *fileWriter {
+	return &fileWriter{
+		dir:     dir,
+		written: map[string][]byte{},
+	}
+}
+
+func (w *fileWriter) write(name string, content []byte) (err error) {
+	if last, ok := w.written[name]; ok && bytes.Equal(last, content) {
+		return
+	}
+	path := filepath.Join(w.dir, name)
+
+	f, err := os.CreateTemp(w.dir, "."+name+".*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	// CreateTemp creates files only readable by us, but OBS and friends may
+	// run as another user
+	mode := fs.FileMode(0o644)
+	if fi, statErr := os.Stat(path); statErr == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err = f.Chmod(mode); err != nil {
+		f.Close()
+		return
+	}
+	if _, err = f.Write(content); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return
+	}
+	w.written[name] = append([]byte(nil), content...)
+	return
+}
@@ -0,0 +1,13 @@
+package main
+
+import "github.com/icedream/go-stagelinq/eaas/musickey"
+
+// keyName returns the name of a key as published in the KeyIndex state value,
+// or an empty string if the key is unknown.
+func keyName(index int) string {
+	k, ok := musickey.FromEngineIndex(index)
+	if !ok {
+		return ""
+	}
+	return k.String()
+}
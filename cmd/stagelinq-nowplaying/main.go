@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/icedream/go-stagelinq"
+	"github.com/icedream/go-stagelinq/eaas"
+)
+
+const (
+	appName    = "Icedream StagelinQ Now Playing"
+	appVersion = "0.0.0"
+)
+
+const defaultTemplate = "{{.Artist}}{{if and .Artist .Title}} - {{end}}{{.Title}}"
+
+var (
+	fName           = flag.String("name", "nowplaying", "name to announce ourselves with")
+	fDir            = flag.String("dir", ".", "directory to write the files to")
+	fDeck           = flag.Int("deck", 0, "logical deck to follow (default: the live deck)")
+	fTemplate       = flag.String("template", defaultTemplate, "text/template for the combined file")
+	fTemplateFile   = flag.String("template-file", "", "file with a text/template for the combined file, overrides -template")
+	fTemplateOutput = flag.String("template-output", "nowplaying.txt", "name of the combined file")
+	fArt            = flag.String("art", "cover", "name of the album art file without extension, empty to disable")
+	fArtFormat      = flag.String("art-format", "png", "album art image format: png|jpeg")
+	fEAAS           = flag.String("eaas", "", "gRPC address of an EAAS library to fetch preview artwork from if the device sends no album art")
+	fIgnoreFaders   = flag.Bool("ignore-faders", false, "consider playing decks live no matter the mixer volume")
+)
+
+// nowPlaying is what templates are executed with.
+type nowPlaying struct {
+	Deck     int
+	Device   string
+	Playing  bool
+	Artist   string
+	Title    string
+	BPM      float64
+	Key      string
+	KeyIndex int
+	Path     string
+}
+
+func stringValue(deck *stagelinq.AggregatedDeck, name string) string {
+	s, _ := deck.Values[name]["string"].(string)
+	return s
+}
+
+func numberValue(deck *stagelinq.AggregatedDeck, name string) (f float64, ok bool) {
+	f, ok = deck.Values[name]["value"].(float64)
+	return
+}
+
+func boolValue(deck *stagelinq.AggregatedDeck, name string) bool {
+	b, _ := deck.Values[name]["state"].(bool)
+	return b
+}
+
+// newNowPlaying collects the template data and album art of a deck.
+func newNowPlaying(deck *stagelinq.AggregatedDeck) (n *nowPlaying, art []byte) {
+	n = &nowPlaying{
+		Deck:     deck.Number,
+		Device:   deck.DeviceName,
+		Playing:  boolValue(deck, "Play"),
+		Artist:   stringValue(deck, "Track/ArtistName"),
+		Title:    stringValue(deck, "Track/SongName"),
+		Path:     stringValue(deck, "Track/TrackNetworkPath"),
+		KeyIndex: -1,
+	}
+	if bpm, ok := numberValue(deck, "CurrentBPM"); ok && bpm > 0 {
+		n.BPM = bpm
+	} else if bpm, ok := numberValue(deck, "Track/CurrentBPM"); ok {
+		n.BPM = bpm
+	}
+	if index, ok := numberValue(deck, "Track/CurrentKeyIndex"); ok {
+		n.KeyIndex = int(index)
+		n.Key = keyName(n.KeyIndex)
+	}
+	art, _ = decodeAlbumArt(deck.Values["AlbumArt"])
+	return
+}
+
+// liveDeck picks the deck the audience hears: of all playing decks, the one
+// with the highest mixer volume, preferring lower deck numbers on ties.
+func liveDeck(decks []*stagelinq.AggregatedDeck) (live *stagelinq.AggregatedDeck) {
+	liveVolume := -1.0
+	for _, deck := range decks {
+		if *fDeck != 0 {
+			if deck.Number == *fDeck {
+				return deck
+			}
+			continue
+		}
+		if !boolValue(deck, "Play") || !boolValue(deck, "Track/SongLoaded") {
+			continue
+		}
+		volume, ok := numberValue(deck, "ExternalMixerVolume")
+		if !ok || *fIgnoreFaders {
+			volume = 1
+		}
+		if volume > 0 && volume > liveVolume {
+			live, liveVolume = deck, volume
+		}
+	}
+	return
+}
+
+type output struct {
+	files    *fileWriter
+	template *template.Template
+	eaas     *eaas.EngineLibraryConnection
+
+	current    *nowPlaying
+	currentArt []byte
+}
+
+func (o *output) update(n *nowPlaying, art []byte) {
+	if o.current != nil && *o.current == *n && bytes.Equal(o.currentArt, art) {
+		return
+	}
+	trackChanged := o.current == nil || o.current.Path != n.Path ||
+		o.current.Artist != n.Artist || o.current.Title != n.Title ||
+		!bytes.Equal(o.currentArt, art)
+	o.current = n
+	o.currentArt = art
+
+	bpm := ""
+	if n.BPM > 0 {
+		bpm = strconv.FormatFloat(n.BPM, 'f', 2, 64)
+	}
+	o.write("artist.txt", []byte(n.Artist))
+	o.write("title.txt", []byte(n.Title))
+	o.write("bpm.txt", []byte(bpm))
+	o.write("key.txt", []byte(n.Key))
+
+	buf := new(bytes.Buffer)
+	if err := o.template.Execute(buf, n); err != nil {
+		log.Printf("WARNING: %s", err.Error())
+	} else {
+		o.write(*fTemplateOutput, buf.Bytes())
+	}
+
+	if trackChanged && *fArt != "" {
+		o.writeArt(n, art)
+	}
+}
+
+func (o *output) writeArt(n *nowPlaying, art []byte) {
+	if len(art) == 0 && o.eaas != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		artwork, err := fetchPreviewArtwork(ctx, o.eaas, n.Artist, n.Title)
+		cancel()
+		if err != nil {
+			log.Printf("No preview artwork for %q: %s", n.Title, err.Error())
+		}
+		art = artwork
+	}
+	img, err := convertArtwork(art, *fArtFormat)
+	if err != nil {
+		log.Printf("WARNING: album art: %s", err.Error())
+		if img, err = convertArtwork(nil, *fArtFormat); err != nil {
+			return
+		}
+	}
+	extension := "." + *fArtFormat
+	if extension == ".jpeg" {
+		extension = ".jpg"
+	}
+	o.write(*fArt+extension, img)
+}
+
+func (o *output) write(name string, content []byte) {
+	if err := o.files.write(name, content); err != nil {
+		log.Printf("WARNING: %s", err.Error())
+	}
+}
+
+func loadTemplate() (*template.Template, error) {
+	text := *fTemplate
+	if *fTemplateFile != "" {
+		b, err := os.ReadFile(*fTemplateFile)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+	return template.New("nowplaying").Parse(text)
+}
+
+func main() {
+	flag.Parse()
+
+	switch *fArtFormat {
+	case "png", "jpeg", "jpg":
+	default:
+		log.Fatalf("unsupported artwork format %q", *fArtFormat)
+	}
+	tmpl, err := loadTemplate()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	o := &output{
+		files:    newFileWriter(*fDir),
+		template: tmpl,
+	}
+	if *fEAAS != "" {
+		if o.eaas, err = eaas.Dial(*fEAAS); err != nil {
+			log.Fatal(err)
+		}
+		defer o.eaas.Close()
+	}
+
+	client, err := stagelinq.NewClient(&stagelinq.ClientConfiguration{
+		ListenerConfiguration: stagelinq.ListenerConfiguration{
+			Name:            *fName,
+			SoftwareName:    appName,
+			SoftwareVersion: appVersion,
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	aggregator := stagelinq.NewAggregator(&stagelinq.AggregatorConfiguration{
+		DeckValues: append(append([]string{}, stagelinq.DefaultDeckValues...),
+			"Track/CurrentKeyIndex",
+			"AlbumArt",
+		),
+	})
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	tracked := map[stagelinq.Token]bool{}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	log.Printf("Looking for devices, writing files to %s", *fDir)
+	for {
+		select {
+		case <-interrupt:
+			return
+		case <-aggregator.UpdateC():
+			// keep showing the last track while nothing is live
+			if live := liveDeck(aggregator.Decks()); live != nil {
+				o.update(newNowPlaying(live))
+			}
+		case <-aggregator.MixerC():
+		case <-ticker.C:
+			for _, device := range client.Devices() {
+				token := device.Token()
+				if tracked[token] {
+					continue
+				}
+				session, err := client.Session(token)
+				if err != nil {
+					log.Printf("WARNING: %s", err.Error())
+					continue
+				}
+				if err := aggregator.Track(session); err != nil {
+					log.Printf("WARNING: %s: %s", device.Name, err.Error())
+					continue
+				}
+				tracked[token] = true
+				log.Printf("Tracking %s (%s %s)", device.Name, device.SoftwareName, device.SoftwareVersion)
+			}
+		}
+	}
+}
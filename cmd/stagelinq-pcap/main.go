@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/icedream/go-stagelinq/pcap"
+)
+
+var fOutput = flag.String("output", "text", "output format: text|json")
+
+// serviceFlags collects -service port=name flags.
+type serviceFlags map[uint16]string
+
+func (s serviceFlags) String() string {
+	parts := []string{}
+	for port, name := range s {
+		parts = append(parts, fmt.Sprintf("%d=%s", port, name))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s serviceFlags) Set(v string) error {
+	portString, name, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("expected port=service, got %q", v)
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return err
+	}
+	s[uint16(port)] = name
+	return nil
+}
+
+var fServices = serviceFlags{}
+
+func init() {
+	flag.Var(fServices, "service", "treat TCP `port=service` as the given service, e.g. 33795=main, if the capture misses the announcement (can be repeated)")
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] capture.pcap[ng]...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var display func(*pcap.Event) error
+
+	switch *fOutput {
+	case "text":
+		display = func(e *pcap.Event) error {
+			prefix := fmt.Sprintf("%s %s -> %s [%s]",
+				e.Time.Format("2006-01-02 15:04:05.000000"),
+				e.Source, e.Destination, e.Service)
+			if e.Error != "" {
+				_, err := fmt.Printf("%s ERROR: %s\n", prefix, e.Error)
+				return err
+			}
+			_, err := fmt.Printf("%s %s %+v\n", prefix, e.Type, e.Message)
+			return err
+		}
+	case "json":
+		je := json.NewEncoder(os.Stdout)
+		display = func(e *pcap.Event) error {
+			return je.Encode(e)
+		}
+	default:
+		log.Fatalf("unknown format: %s", *fOutput)
+	}
+
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		d := pcap.NewDecoder(display)
+		for port, name := range fServices {
+			d.AddService(port, name)
+		}
+		err = d.Decode(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %s", path, err)
+		}
+	}
+}
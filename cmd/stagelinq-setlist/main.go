@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/icedream/go-stagelinq"
+	"github.com/icedream/go-stagelinq/history"
+)
+
+const (
+	appName    = "Icedream StagelinQ Setlist"
+	appVersion = "0.0.0"
+)
+
+var (
+	fName           = flag.String("name", "setlist", "name to announce ourselves with")
+	fState          = flag.String("state", "setlist.json", "file the setlist is kept in, read back to resume a set")
+	fResume         = flag.Bool("resume", true, "continue the setlist in the state file if it exists instead of starting a new one")
+	fOutput         = flag.String("output", "setlist", "file name prefix of the generated setlists, the format's extension is appended")
+	fFormats        = flag.String("formats", "text,csv,cue,mixcloud", "comma-separated setlist formats to write after every track: "+formatNames())
+	fPrint          = flag.String("print", "", "write the setlist in the state file to stdout in the given format and exit")
+	fRecordingStart = flag.String("recording-start", "", "when the recording of the set started (RFC 3339 or 15:04:05 today) for cue sheets and Mixcloud timestamps (default: start of the set)")
+	fAudioFile      = flag.String("audio-file", "recording.wav", "recording referenced by cue sheets")
+	fPerformer      = flag.String("performer", "", "performer of the set for cue sheets")
+	fTitle          = flag.String("title", "", "title of the set for cue sheets")
+	fMinPlay        = flag.Duration("min-play", 30*time.Second, "how long a track has to be audible to count as played")
+	fIgnoreFaders   = flag.Bool("ignore-faders", false, "count playing decks as audible no matter the mixer volume")
+)
+
+func formatNames() string {
+	names := make([]string, 0, len(history.Formats))
+	for _, format := range history.Formats {
+		names = append(names, string(format))
+	}
+	return strings.Join(names, "|")
+}
+
+func parseFormats(s string) (formats []history.Format, err error) {
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var format history.Format
+		if format, err = history.ParseFormat(name); err != nil {
+			return
+		}
+		formats = append(formats, format)
+	}
+	return
+}
+
+func parseRecordingStart(s string) (t time.Time, err error) {
+	if s == "" {
+		return
+	}
+	if t, err = time.Parse(time.RFC3339, s); err == nil {
+		return
+	}
+	clock, err := time.ParseInLocation("15:04:05", s, time.Local)
+	if err != nil {
+		err = fmt.Errorf("invalid recording start %q", s)
+		return
+	}
+	now := time.Now()
+	t = time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.Local)
+	return
+}
+
+func loadSetlist() (setlist *history.Setlist, err error) {
+	if *fResume {
+		setlist, err = history.LoadSetlist(*fState)
+		if err == nil {
+			log.Printf("Resuming set started %s with %d tracks", setlist.Start.Format(time.RFC1123), len(setlist.Entries))
+			return
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+	}
+	return history.NewSetlist(time.Now()), nil
+}
+
+// save writes the state file and all requested setlists.
+func save(setlist *history.Setlist, formats []history.Format, opts *history.WriteOptions) {
+	if err := setlist.WriteFile(*fState, history.FormatJSON, nil); err != nil {
+		log.Printf("WARNING: %s", err.Error())
+	}
+	for _, format := range formats {
+		if err := setlist.WriteFile(*fOutput+format.Extension(), format, opts); err != nil {
+			log.Printf("WARNING: %s", err.Error())
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	formats, err := parseFormats(*fFormats)
+	if err != nil {
+		log.Fatal(err)
+	}
+	recordingStart, err := parseRecordingStart(*fRecordingStart)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := &history.WriteOptions{
+		RecordingStart: recordingStart,
+		AudioFile:      *fAudioFile,
+		Performer:      *fPerformer,
+		Title:          *fTitle,
+	}
+
+	if *fPrint != "" {
+		format, err := history.ParseFormat(*fPrint)
+		if err != nil {
+			log.Fatal(err)
+		}
+		setlist, err := history.LoadSetlist(*fState)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := setlist.Write(os.Stdout, format, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	setlist, err := loadSetlist()
+	if err != nil {
+		log.Fatal(err)
+	}
+	save(setlist, formats, opts)
+
+	client, err := stagelinq.NewClient(&stagelinq.ClientConfiguration{
+		ListenerConfiguration: stagelinq.ListenerConfiguration{
+			Name:            *fName,
+			SoftwareName:    appName,
+			SoftwareVersion: appVersion,
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	aggregator := stagelinq.NewAggregator(nil)
+	tracker := history.NewTracker(&history.TrackerConfiguration{
+		MinPlayTime:  *fMinPlay,
+		IgnoreFaders: *fIgnoreFaders,
+	})
+
+	add := func(entry *history.Entry) {
+		if !setlist.Add(entry) {
+			return
+		}
+		log.Printf("%2d. %s  %s (deck %d)", len(setlist.Entries), entry.Time.Format("15:04:05"), entry, entry.Deck)
+		save(setlist, formats, opts)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	tracked := map[stagelinq.Token]bool{}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	log.Printf("Looking for devices, writing setlist to %s", *fState)
+	for {
+		select {
+		case <-interrupt:
+			save(setlist, formats, opts)
+			return
+		case update := <-aggregator.UpdateC():
+			if entry := tracker.Update(update.Deck, time.Now()); entry != nil {
+				add(entry)
+			}
+		case <-aggregator.MixerC():
+		case now := <-ticker.C:
+			for _, entry := range tracker.Tick(now) {
+				add(entry)
+			}
+			for _, device := range client.Devices() {
+				token := device.Token()
+				if tracked[token] {
+					continue
+				}
+				session, err := client.Session(token)
+				if err != nil {
+					log.Printf("WARNING: %s", err.Error())
+					continue
+				}
+				if err := aggregator.Track(session); err != nil {
+					log.Printf("WARNING: %s: %s", device.Name, err.Error())
+					continue
+				}
+				tracked[token] = true
+				log.Printf("Tracking %s (%s %s)", device.Name, device.SoftwareName, device.SoftwareVersion)
+			}
+		}
+	}
+}
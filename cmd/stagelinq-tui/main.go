@@ -0,0 +1,266 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/icedream/go-stagelinq"
+	"github.com/rivo/tview"
+)
+
+const (
+	appName    = "Icedream StagelinQ TUI"
+	appVersion = "0.0.0"
+)
+
+var (
+	fName    = flag.String("name", "tui", "name to announce ourselves with")
+	fRefresh = flag.Duration("refresh", 66*time.Millisecond, "screen refresh interval")
+)
+
+const helpText = "[yellow]Tab[-]/[yellow]n[-] next device  [yellow]Shift+Tab[-]/[yellow]p[-] previous device  [yellow]r[-] raw StateMap stream  [yellow]q[-] quit"
+
+// subscribedValues returns the state values shown by the dashboard.
+func subscribedValues() []string {
+	values := []string{
+		stagelinq.EngineDeckCount,
+		stagelinq.MixerCH1faderPosition,
+		stagelinq.MixerCH2faderPosition,
+		stagelinq.MixerCH3faderPosition,
+		stagelinq.MixerCH4faderPosition,
+		stagelinq.MixerCrossfaderPosition,
+	}
+	for _, deck := range []stagelinq.DeckValueNames{
+		stagelinq.EngineDeck1,
+		stagelinq.EngineDeck2,
+		stagelinq.EngineDeck3,
+		stagelinq.EngineDeck4,
+	} {
+		values = append(values,
+			deck.Play(),
+			deck.CurrentBPM(),
+			deck.Speed(),
+			deck.TrackSongLoaded(),
+			deck.TrackSongName(),
+			deck.TrackArtistName(),
+			deck.TrackCurrentKeyIndex(),
+		)
+	}
+	return values
+}
+
+// track feeds everything the client receives from a device into its model.
+func track(client *stagelinq.Client, model *deviceModel) {
+	token := model.device.Token()
+	session, err := client.Session(token)
+	if err != nil {
+		model.setStatus(err.Error())
+		return
+	}
+	for _, value := range subscribedValues() {
+		if err := session.Subscribe(value); err != nil {
+			model.setStatus(err.Error())
+			return
+		}
+	}
+	if err := session.StartBeatInfo(); err != nil {
+		model.setStatus(err.Error())
+		return
+	}
+
+	go func() {
+		for status := range session.StatusC() {
+			text := status.State.String()
+			if status.Err != nil {
+				text += ": " + status.Err.Error()
+			}
+			model.setStatus(text)
+		}
+	}()
+	go func() {
+		for beatInfo := range session.BeatInfoC() {
+			model.setBeatInfo(beatInfo)
+		}
+	}()
+	for state := range session.StateC() {
+		model.addState(state)
+	}
+}
+
+type ui struct {
+	app    *tview.Application
+	client *stagelinq.Client
+
+	pages   *tview.Pages
+	devices *tview.List
+	decks   []*tview.TextView
+	mixer   *tview.TextView
+	status  *tview.TextView
+	raw     *tview.TextView
+
+	models   map[stagelinq.Token]*deviceModel
+	order    []stagelinq.Token
+	selected int
+}
+
+func newUI(client *stagelinq.Client) *ui {
+	u := &ui{
+		app:     tview.NewApplication(),
+		client:  client,
+		pages:   tview.NewPages(),
+		devices: tview.NewList(),
+		mixer:   tview.NewTextView().SetDynamicColors(true),
+		status:  tview.NewTextView().SetDynamicColors(true),
+		raw:     tview.NewTextView().SetDynamicColors(true).SetScrollable(true),
+		models:  map[stagelinq.Token]*deviceModel{},
+	}
+
+	u.devices.SetBorder(true).SetTitle(" Devices ")
+	u.devices.SetChangedFunc(func(index int, _, _ string, _ rune) {
+		u.selected = index
+	})
+	u.mixer.SetBorder(true).SetTitle(" Mixer ")
+	u.raw.SetBorder(true).SetTitle(" StateMap stream ")
+
+	deckGrid := tview.NewGrid().SetRows(0, 0).SetColumns(0, 0)
+	for i := 0; i < 4; i++ {
+		deck := tview.NewTextView().SetDynamicColors(true)
+		deck.SetBorder(true)
+		u.decks = append(u.decks, deck)
+		deckGrid.AddItem(deck, i/2, i%2, 1, 1, 0, 0, false)
+	}
+
+	dashboard := tview.NewFlex().
+		AddItem(u.devices, 32, 0, true).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(deckGrid, 0, 3, false).
+			AddItem(u.mixer, 8, 0, false), 0, 1, false)
+
+	u.pages.AddPage("dashboard", dashboard, true, true)
+	u.pages.AddPage("raw", u.raw, true, false)
+
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(u.pages, 0, 1, true).
+		AddItem(u.status, 1, 0, false)
+	u.app.SetRoot(root, true).SetInputCapture(u.handleKey)
+	return u
+}
+
+func (u *ui) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	switch {
+	case event.Key() == tcell.KeyTab || event.Rune() == 'n':
+		u.selectDevice(u.selected + 1)
+	case event.Key() == tcell.KeyBacktab || event.Rune() == 'p':
+		u.selectDevice(u.selected - 1)
+	case event.Rune() == 'r':
+		if name, _ := u.pages.GetFrontPage(); name == "raw" {
+			u.pages.SwitchToPage("dashboard")
+		} else {
+			u.pages.SwitchToPage("raw")
+		}
+	case event.Rune() == 'q' || event.Key() == tcell.KeyEscape:
+		u.app.Stop()
+	default:
+		return event
+	}
+	u.render()
+	return nil
+}
+
+func (u *ui) selectDevice(index int) {
+	if len(u.order) == 0 {
+		return
+	}
+	u.selected = (index + len(u.order)) % len(u.order)
+	u.devices.SetCurrentItem(u.selected)
+}
+
+// syncDevices adds newly discovered devices. Devices that left stay listed
+// with their session status as sessions keep waiting for them.
+func (u *ui) syncDevices(devices []*stagelinq.Device) {
+	for _, device := range devices {
+		token := device.Token()
+		if _, ok := u.models[token]; ok {
+			continue
+		}
+		model := newDeviceModel(device)
+		u.models[token] = model
+		u.order = append(u.order, token)
+		u.devices.AddItem(device.Name, fmt.Sprintf("%s %s", device.SoftwareName, device.SoftwareVersion), 0, nil)
+		go track(u.client, model)
+	}
+}
+
+func (u *ui) render() {
+	if len(u.order) == 0 {
+		u.status.SetText("[gray]looking for devices…[-]  " + helpText)
+		for _, deck := range u.decks {
+			deck.SetTitle("")
+			deck.SetText("")
+		}
+		return
+	}
+	s := u.models[u.order[u.selected]].snapshot()
+	u.status.SetText(fmt.Sprintf("%s %s [gray](%s)[-]  %s",
+		tview.Escape(s.device.Name), s.device.IP, tview.Escape(s.status), helpText))
+
+	deckCount := s.deckCount()
+	for i, deck := range u.decks {
+		if i >= deckCount {
+			deck.SetTitle("")
+			deck.SetText("")
+			continue
+		}
+		deck.SetTitle(fmt.Sprintf(" Deck %d ", i+1))
+		deck.SetText(renderDeck(s, i+1))
+	}
+	u.mixer.SetText(renderMixer(s))
+	if name, _ := u.pages.GetFrontPage(); name == "raw" {
+		u.raw.SetText(renderRaw(s)).ScrollToEnd()
+	}
+}
+
+func (u *ui) run() error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(*fRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			devices := u.client.Devices()
+			u.app.QueueUpdateDraw(func() {
+				u.syncDevices(devices)
+				u.render()
+			})
+		}
+	}()
+	return u.app.Run()
+}
+
+func main() {
+	flag.Parse()
+
+	client, err := stagelinq.NewClient(&stagelinq.ClientConfiguration{
+		ListenerConfiguration: stagelinq.ListenerConfiguration{
+			Name:            *fName,
+			SoftwareName:    appName,
+			SoftwareVersion: appVersion,
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := newUI(client).run(); err != nil {
+		log.Fatal(err)
+	}
+}
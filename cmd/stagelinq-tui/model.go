@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/icedream/go-stagelinq"
+)
+
+const rawStateLines = 500
+
+// rawState is a state as shown in the raw StateMap stream view.
+type rawState struct {
+	Time  time.Time
+	State *stagelinq.State
+}
+
+// deviceModel holds everything we know about a device.
+type deviceModel struct {
+	device *stagelinq.Device
+
+	lock     sync.Mutex
+	values   map[string]map[string]interface{}
+	beatInfo *stagelinq.BeatInfo
+	raw      []rawState
+	status   string
+}
+
+func newDeviceModel(device *stagelinq.Device) *deviceModel {
+	return &deviceModel{
+		device: device,
+		values: map[string]map[string]interface{}{},
+		status: "connecting",
+	}
+}
+
+func (m *deviceModel) addState(state *stagelinq.State) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.values[state.Name] = state.Value
+	m.raw = append(m.raw, rawState{Time: time.Now(), State: state})
+	if len(m.raw) > rawStateLines {
+		m.raw = m.raw[len(m.raw)-rawStateLines:]
+	}
+}
+
+func (m *deviceModel) setBeatInfo(beatInfo *stagelinq.BeatInfo) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.beatInfo = beatInfo
+}
+
+func (m *deviceModel) setStatus(status string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.status = status
+}
+
+// snapshot is a consistent copy of a deviceModel for rendering.
+type snapshot struct {
+	device   *stagelinq.Device
+	values   map[string]map[string]interface{}
+	beatInfo *stagelinq.BeatInfo
+	raw      []rawState
+	status   string
+}
+
+func (m *deviceModel) snapshot() *snapshot {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	values := make(map[string]map[string]interface{}, len(m.values))
+	for k, v := range m.values {
+		values[k] = v
+	}
+	return &snapshot{
+		device:   m.device,
+		values:   values,
+		beatInfo: m.beatInfo,
+		raw:      append([]rawState(nil), m.raw...),
+		status:   m.status,
+	}
+}
+
+func (s *snapshot) number(name string) (float64, bool) {
+	v, ok := s.values[name]["value"].(float64)
+	return v, ok
+}
+
+func (s *snapshot) boolean(name string) bool {
+	v, _ := s.values[name]["state"].(bool)
+	return v
+}
+
+func (s *snapshot) string(name string) string {
+	v, _ := s.values[name]["string"].(string)
+	return v
+}
+
+func (s *snapshot) deckCount() int {
+	if n, ok := s.number(stagelinq.EngineDeckCount); ok && n >= 1 && n <= 4 {
+		return int(n)
+	}
+	if s.beatInfo != nil && len(s.beatInfo.Players) > 0 && len(s.beatInfo.Players) <= 4 {
+		return len(s.beatInfo.Players)
+	}
+	return 4
+}
+
+// player returns the beat info of a deck starting at 1.
+func (s *snapshot) player(deck int) (player stagelinq.PlayerInfo, ok bool) {
+	if s.beatInfo == nil || deck > len(s.beatInfo.Players) {
+		return
+	}
+	return s.beatInfo.Players[deck-1], true
+}
+
+func (s *snapshot) hasMixer() bool {
+	_, ok := s.values[stagelinq.MixerCrossfaderPosition]
+	return ok
+}
+
+func formatState(state *stagelinq.State) string {
+	value := state.Value
+	for _, key := range []string{"string", "state", "value", "color"} {
+		if v, ok := value[key]; ok {
+			return fmt.Sprintf("%v", v)
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
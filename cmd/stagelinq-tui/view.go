@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/icedream/go-stagelinq"
+	"github.com/rivo/tview"
+)
+
+const (
+	barWidth      = 20
+	beatsPerBar   = 4
+	cellsPerBeat  = 4
+	deckTextWidth = 40
+)
+
+// bar draws a horizontal level meter for a value from 0 to 1.
+func bar(v float64, width int) string {
+	v = math.Max(0, math.Min(1, v))
+	filled := int(math.Round(v * float64(width)))
+	return "[green]" + strings.Repeat("█", filled) + "[gray]" + strings.Repeat("░", width-filled) + "[-]"
+}
+
+// beatBar shows the position within the current bar, one block per beat
+// subdivision, with the current beat highlighted.
+func beatBar(beat float64) string {
+	if beat < 0 {
+		beat = 0
+	}
+	position := math.Mod(beat, beatsPerBar)
+	current := int(position * cellsPerBeat)
+	var b strings.Builder
+	for i := 0; i < beatsPerBar*cellsPerBeat; i++ {
+		switch {
+		case i == current:
+			b.WriteString("[yellow]█")
+		case i/cellsPerBeat == current/cellsPerBeat:
+			b.WriteString("[orange]▒")
+		case i%cellsPerBeat == 0:
+			b.WriteString("[white]│")
+		default:
+			b.WriteString("[gray]░")
+		}
+	}
+	b.WriteString("[-]")
+	return b.String()
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+func renderDeck(s *snapshot, deck int) string {
+	names := stagelinq.DeckValueNames{DeckIndex: deck}
+	var b strings.Builder
+
+	playing := "[gray]■ stopped[-]"
+	if s.boolean(names.Play()) {
+		playing = "[green]▶ playing[-]"
+	}
+	fmt.Fprintf(&b, "[::b]Deck %d[::-]  %s\n", deck, playing)
+
+	if !s.boolean(names.TrackSongLoaded()) && s.string(names.TrackSongName()) == "" {
+		b.WriteString("[gray]no track loaded[-]\n")
+	} else {
+		fmt.Fprintf(&b, "%s\n", tview.Escape(truncate(s.string(names.TrackSongName()), deckTextWidth)))
+		fmt.Fprintf(&b, "[gray]%s[-]\n", tview.Escape(truncate(s.string(names.TrackArtistName()), deckTextWidth)))
+	}
+
+	bpm, _ := s.number(names.CurrentBPM())
+	player, hasPlayer := s.player(deck)
+	if hasPlayer && player.Bpm > 0 {
+		bpm = player.Bpm
+	}
+	pitch := "-"
+	if speed, ok := s.number(names.Speed()); ok {
+		pitch = fmt.Sprintf("%+.2f%%", (speed-1)*100)
+	}
+	key := "-"
+	if index, ok := s.number(names.TrackCurrentKeyIndex()); ok {
+		key = fmt.Sprintf("%d", int(index))
+	}
+	fmt.Fprintf(&b, "BPM %6.2f  Pitch %s  Key %s\n", bpm, pitch, key)
+
+	if hasPlayer {
+		fmt.Fprintf(&b, "%s  %.1f/%.0f\n", beatBar(player.Beat), player.Beat, player.TotalBeats)
+	} else {
+		b.WriteString("[gray]no beat info[-]\n")
+	}
+	return b.String()
+}
+
+func renderMixer(s *snapshot) string {
+	if !s.hasMixer() {
+		return "[gray]no mixer values[-]"
+	}
+	var b strings.Builder
+	for i, name := range []string{
+		stagelinq.MixerCH1faderPosition,
+		stagelinq.MixerCH2faderPosition,
+		stagelinq.MixerCH3faderPosition,
+		stagelinq.MixerCH4faderPosition,
+	} {
+		v, _ := s.number(name)
+		fmt.Fprintf(&b, "CH%d  %s\n", i+1, bar(v, barWidth))
+	}
+	v, _ := s.number(stagelinq.MixerCrossfaderPosition)
+	position := int(math.Round(math.Max(0, math.Min(1, v)) * barWidth))
+	fmt.Fprintf(&b, "X    [gray]%s[white]┃[gray]%s[-]\n", strings.Repeat("─", position), strings.Repeat("─", barWidth-position))
+	return b.String()
+}
+
+func renderRaw(s *snapshot) string {
+	var b strings.Builder
+	for _, raw := range s.raw {
+		fmt.Fprintf(&b, "[gray]%s[-] %s = %s\n",
+			raw.Time.Format("15:04:05.000"),
+			tview.Escape(raw.State.Name),
+			tview.Escape(formatState(raw.State)))
+	}
+	return b.String()
+}
@@ -2,19 +2,21 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"fmt"
+	"flag"
 	"log"
-	"net"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/icedream/go-stagelinq/eaas"
-	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
-	"github.com/icedream/go-stagelinq/eaas/proto/networktrust"
+	"github.com/icedream/go-stagelinq/eaas/enginedb"
+	"github.com/icedream/go-stagelinq/eaas/rekordbox"
+	"github.com/icedream/go-stagelinq/eaas/server"
+	"github.com/icedream/go-stagelinq/eaas/server/fslibrary"
+	"github.com/icedream/go-stagelinq/eaas/smartlist"
+	"github.com/icedream/go-stagelinq/eaas/traktor"
 	"google.golang.org/grpc"
 )
 
@@ -24,6 +26,14 @@ const (
 	timeout    = 5 * time.Second
 )
 
+var (
+	fRoot      = flag.String("root", "", "folder of audio files to serve as a library instead of the demo track")
+	fEngine    = flag.String("engine", "", "Engine Library folder to serve instead of the demo track")
+	fRekordbox = flag.String("rekordbox", "", "Rekordbox XML export to serve instead of the demo track")
+	fTraktor   = flag.String("traktor", "", "Traktor collection.nml to serve instead of the demo track")
+	fSmart     = flag.String("smart", "", "JSON file of smart playlists to add to the library")
+)
+
 var hostname string
 
 func init() {
@@ -34,77 +44,97 @@ func init() {
 	}
 }
 
+func logRequests(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	log.Printf("%s: %+v", info.FullMethod, req)
+	return handler(ctx, req)
+}
+
 func main() {
-	// Generate random token to identify with.
-	//
-	// Engine uses the token to know whether you just logged onto the network or
-	// whether you're a library that just restarted. For our demo purposes this
-	// doesn't matter too much though, so we just regenerate on bootup.
-	var token [16]byte
-	if _, err := rand.Read(token[:]); err != nil {
-		panic(err)
+	flag.Parse()
+
+	var provider server.LibraryProvider = &demoProvider{}
+	token := demoToken
+	sources := 0
+	for _, source := range []string{*fRoot, *fEngine, *fRekordbox, *fTraktor} {
+		if source != "" {
+			sources++
+		}
 	}
-
-	ctx := context.Background()
-
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	ctx, stopNotify := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
-	defer stopNotify()
-
-	var s http.Server
-	grpcServer := grpc.NewServer()
-	go func() {
-		<-ctx.Done()
-
-		grpcServer.Stop()
-
-		timeout, cancel := context.WithTimeout(context.Background(), time.Second*5)
-		defer cancel()
-		s.Shutdown(timeout)
-	}()
-
-	// Set up gRPC API
-	grpcPort := eaas.DefaultEAASGRPCPort
-	grpcListener, err := net.ListenTCP("tcp", &net.TCPAddr{
-		Port: int(grpcPort),
-	})
-	if err != nil {
-		panic(err)
+	switch {
+	case sources > 1:
+		log.Fatal("only one of -root, -engine, -rekordbox and -traktor can be used")
+	case *fRoot != "":
+		library, err := fslibrary.NewProvider(*fRoot, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer library.Close()
+		provider = library
+	case *fEngine != "":
+		library, err := enginedb.NewProvider(*fEngine, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer library.Close()
+		provider = library
+	case *fRekordbox != "":
+		library, err := rekordbox.NewProvider(*fRekordbox, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		provider = library
+	case *fTraktor != "":
+		library, err := traktor.NewProvider(*fTraktor, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		provider = library
+	}
+	if *fSmart != "" {
+		definitions, err := smartlist.Load(*fSmart)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if provider, err = smartlist.NewProvider(provider, &smartlist.ProviderConfiguration{
+			Playlists: definitions,
+		}); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if sources > 0 {
+		// keep the token stable per library, derived from the library ID
+		libraries, _ := provider.Libraries(context.Background())
+		id, err := uuid.Parse(libraries[0].ID)
+		if err != nil {
+			id = uuid.NewSHA1(uuid.NameSpaceOID, []byte(libraries[0].ID))
+		}
+		token = eaas.Token(id)
 	}
-	enginelibrary.RegisterEngineLibraryServiceServer(grpcServer, &EngineLibraryServiceServer{})
-	networktrust.RegisterNetworkTrustServiceServer(grpcServer, &NetworkTrustServiceServer{})
-	go func() {
-		log.Println("Listening on GRPC")
-		_ = grpcServer.Serve(grpcListener)
-	}()
 
-	// Set up HTTP server
-	s.Addr = fmt.Sprintf(":%d", eaas.DefaultEAASHTTPPort)
-	s.Handler = eaasHTTPHandler()
-	go func() {
-		log.Println("Listening on HTTP")
-		_ = s.ListenAndServe()
-	}()
+	ctx, stopNotify := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stopNotify()
 
-	// Listen for beacon UDP broadcasts
-	log.Println("Beacon starting")
-	beacon, err := eaas.StartBeaconWithConfiguration(&eaas.BeaconConfiguration{
-		Name:            hostname,
-		SoftwareVersion: appVersion,
-		GRPCPort:        grpcPort,
-		Token:           demoToken,
+	// We announce ourselves with a fixed token. Engine uses the token to know
+	// whether you just logged onto the network or whether you're a library
+	// that just restarted.
+	s := server.NewServer(provider, &server.ServerConfiguration{
+		Name:              hostname,
+		SoftwareVersion:   appVersion,
+		Token:             token,
+		GRPCServerOptions: []grpc.ServerOption{grpc.UnaryInterceptor(logRequests)},
 	})
-	if err != nil {
-		panic(err)
+	if err := s.Start(); err != nil {
+		log.Fatal(err)
 	}
-	defer func() {
-		log.Println("Beacon shutting down")
-		beacon.Shutdown()
-	}()
+	log.Printf("Running, gRPC on %s, HTTP on %s", s.GRPCAddr(), s.HTTPAddr())
 
 	// Wait for interrupt/term
-	log.Println("Running")
 	<-ctx.Done()
+
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		log.Println(err)
+	}
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+var _ server.LibraryProvider = &demoProvider{}
+
+// demoProvider provides the demo audio file as if contained in a library with
+// a playlist.
+type demoProvider struct{}
+
+type demoBlob struct {
+	*bytes.Reader
+}
+
+func (demoBlob) Close() error { return nil }
+
+// Libraries implements server.LibraryProvider.
+func (p *demoProvider) Libraries(ctx context.Context) ([]*server.Library, error) {
+	return []*server.Library{demoLibrary}, nil
+}
+
+// Playlists implements server.LibraryProvider.
+func (p *demoProvider) Playlists(ctx context.Context, libraryID string) ([]*server.Playlist, error) {
+	if libraryID != demoLibrary.ID {
+		return nil, server.ErrNotFound
+	}
+	return []*server.Playlist{demoPlaylist}, nil
+}
+
+// Tracks implements server.LibraryProvider.
+func (p *demoProvider) Tracks(ctx context.Context, libraryID, playlistID string) ([]*server.Track, error) {
+	if libraryID != demoLibrary.ID || (playlistID != "" && playlistID != demoPlaylist.ID) {
+		return nil, server.ErrNotFound
+	}
+	return []*server.Track{demoTrack}, nil
+}
+
+// Track implements server.LibraryProvider.
+func (p *demoProvider) Track(ctx context.Context, libraryID, trackID string) (*server.Track, error) {
+	if libraryID != demoLibrary.ID || trackID != demoTrack.ID {
+		return nil, server.ErrNotFound
+	}
+	log.Printf("=> Found demo track ID: %s", trackID)
+	return demoTrack, nil
+}
+
+// PerformanceData implements server.LibraryProvider.
+func (p *demoProvider) PerformanceData(ctx context.Context, libraryID, trackID string) (*server.PerformanceData, error) {
+	if libraryID != demoLibrary.ID || trackID != demoTrack.ID {
+		return nil, server.ErrNotFound
+	}
+	return &server.PerformanceData{
+		BPM:              demoTrack.BPM,
+		BeatGrid:         demoBeatGrid,
+		OverviewWaveform: demoOverviewWaveform,
+	}, nil
+}
+
+// OpenBlob implements server.LibraryProvider.
+func (p *demoProvider) OpenBlob(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	if url != demoTrackURL {
+		log.Println("HTTP: Download, not found:", url)
+		return nil, server.ErrNotFound
+	}
+	log.Println("HTTP: Download, OK:", url)
+	return demoBlob{bytes.NewReader(demoTrackBytes)}, nil
+}
@@ -0,0 +1,88 @@
+package stagelinq
+
+import "github.com/icedream/go-stagelinq/internal/messages"
+
+// ErrFrameTooLarge is returned by connections if a device announces a message
+// frame or string that is larger than the configured maximum size.
+// This would indicate a misbehaving or malicious device on the network.
+var ErrFrameTooLarge = messages.ErrFrameTooLarge
+
+// ErrTruncatedFrame is returned by connections and Listener.Discover if a
+// message ends before all of the data it announced could be read.
+var ErrTruncatedFrame = messages.ErrTruncatedFrame
+
+const (
+	// DefaultMaxFrameSize is the maximum size of a length-prefixed message frame
+	// accepted from a device unless configured otherwise with WithMaxFrameSize.
+	DefaultMaxFrameSize = messages.DefaultMaxFrameSize
+
+	// DefaultMaxStringSize is the maximum encoded size of a string accepted
+	// from a device unless configured otherwise with WithMaxStringSize.
+	DefaultMaxStringSize = messages.DefaultMaxStringSize
+)
+
+// Limits bounds the sizes of message frames and strings accepted from devices.
+// Zero values are replaced with DefaultMaxFrameSize and DefaultMaxStringSize.
+type Limits = messages.Limits
+
+type connectionConfiguration struct {
+	limits   messages.Limits
+	recorder *Recorder
+	writable map[string]StateValueType
+}
+
+func newConnectionConfiguration(opts []ConnectionOption) *connectionConfiguration {
+	c := &connectionConfiguration{
+		limits:   messages.DefaultLimits,
+		writable: DefaultWritableStates,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// ConnectionOption represents an option for connections to a StagelinQ device.
+type ConnectionOption func(*connectionConfiguration)
+
+// WithMaxFrameSize sets the maximum size in bytes of a length-prefixed message
+// frame a device may send. Larger frames fail with ErrFrameTooLarge before any
+// memory is allocated for them. A value of 0 restores DefaultMaxFrameSize.
+func WithMaxFrameSize(n uint32) ConnectionOption {
+	return func(c *connectionConfiguration) {
+		c.limits.MaxFrameSize = n
+	}
+}
+
+// WithMaxStringSize sets the maximum encoded size in bytes of a string a device
+// may send. Larger strings fail with ErrFrameTooLarge before any memory is
+// allocated for them. A value of 0 restores DefaultMaxStringSize.
+func WithMaxStringSize(n uint32) ConnectionOption {
+	return func(c *connectionConfiguration) {
+		c.limits.MaxStringSize = n
+	}
+}
+
+// WithRecorder makes the connection record all traffic to the given recorder.
+// See Recorder for details.
+func WithRecorder(rec *Recorder) ConnectionOption {
+	return func(c *connectionConfiguration) {
+		c.recorder = rec
+	}
+}
+
+// WithWritableStates adds state value paths to the allowlist of paths that
+// may be set on a StateMap connection, on top of DefaultWritableStates. The
+// type of each path determines how values are encoded.
+func WithWritableStates(states map[string]StateValueType) ConnectionOption {
+	return func(c *connectionConfiguration) {
+		writable := make(map[string]StateValueType, len(c.writable)+len(states))
+		for name, t := range c.writable {
+			writable[name] = t
+		}
+		for name, t := range states {
+			writable[name] = t
+		}
+		c.writable = writable
+	}
+}
@@ -0,0 +1,152 @@
+package stagelinq
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/icedream/go-stagelinq/internal/messages"
+)
+
+// DecodedMessage is a StagelinQ message decoded from raw traffic, for example
+// from a recording.
+type DecodedMessage struct {
+	// Type is a short name of the message type, for example "stateEmit".
+	Type string
+
+	// Message holds the decoded message fields.
+	Message interface{}
+}
+
+// decoderMessageSets contains the messages that can be sent in either
+// direction on connections to the respective services.
+var decoderMessageSets = map[string]*messageSet{
+	"main": mainConnectionMessageSet,
+	"StateMap": newServiceMessageSet("StateMap", []messages.Message{
+		&stateEmitMessage{},
+		&stateEmitResponseMessage{},
+		&stateSubscribeMessage{},
+		&serviceAnnouncementMessage{},
+	}),
+	"BeatInfo": newServiceMessageSet("BeatInfo", []messages.Message{
+		&beatEmitMessage{},
+		&beatInfoStartStreamMessage{},
+		&beatInfoStopStreamMessage{},
+		&serviceAnnouncementMessage{},
+	}),
+}
+
+// StreamDecoder decodes StagelinQ messages from one direction of a TCP
+// connection. Feed it with data using Write and fetch decoded messages using
+// Next.
+type StreamDecoder struct {
+	expectedMessages *messageSet
+	buf              []byte
+}
+
+// NewStreamDecoder returns a decoder for messages of the given service, which
+// is either the name of a StagelinQ service such as "StateMap" or "main" for
+// the main connection of a device. Unsupported services return nil.
+func NewStreamDecoder(service string) *StreamDecoder {
+	expectedMessages, ok := decoderMessageSets[service]
+	if !ok {
+		return nil
+	}
+	return &StreamDecoder{
+		expectedMessages: expectedMessages,
+	}
+}
+
+// Write appends data to the stream. It never fails.
+func (d *StreamDecoder) Write(b []byte) (n int, err error) {
+	d.buf = append(d.buf, b...)
+	return len(b), nil
+}
+
+// Buffered returns the number of bytes written but not yet decoded.
+func (d *StreamDecoder) Buffered() int {
+	return len(d.buf)
+}
+
+// Next decodes the next message from the data written so far. If more data is
+// needed to decode the next message, nil is returned for both the message and
+// the error. If the data can not be decoded, all buffered data is discarded
+// so decoding can continue with the next write.
+func (d *StreamDecoder) Next() (decoded *DecodedMessage, err error) {
+	if len(d.buf) == 0 {
+		return
+	}
+
+	br := bytes.NewReader(d.buf)
+	bufferedReader := bufio.NewReader(br)
+	msg, err := readMessage(bufferedReader, messages.WithLimits(bufferedReader, messages.DefaultLimits), d.expectedMessages)
+	consumed := len(d.buf) - br.Len() - bufferedReader.Buffered()
+	if err != nil {
+		// running out of data at the end of the buffer just means the
+		// message is not complete yet, unless the message ended before
+		// and the data following it was just invalid
+		if isIncompleteMessageError(err) && br.Len() == 0 &&
+			(consumed == 0 || consumed == len(d.buf)) {
+			err = nil
+			return
+		}
+		d.buf = nil
+		return
+	}
+
+	d.buf = d.buf[consumed:]
+	decoded = &DecodedMessage{
+		Type:    messageTypeName(msg),
+		Message: msg,
+	}
+	return
+}
+
+func isIncompleteMessageError(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, ErrTruncatedFrame)
+}
+
+// DecodeDatagram decodes a StagelinQ discovery message as sent to UDP port
+// 51337.
+func DecodeDatagram(b []byte) (decoded *DecodedMessage, err error) {
+	if len(b) < len(magicBytes) || !bytes.Equal(b[:len(magicBytes)], magicBytes) {
+		err = fmt.Errorf("%w: not a discovery message", ErrInvalidMessageReceived)
+		return
+	}
+	msg := new(discoveryMessage)
+	if err = msg.ReadMessageFrom(messages.NewFrameReader(b, messages.DefaultLimits)); err != nil {
+		return
+	}
+	decoded = &DecodedMessage{
+		Type:    messageTypeName(msg),
+		Message: msg,
+	}
+	return
+}
+
+// AnnouncedService returns the service announced by a service announcement
+// message. For discovery messages of devices joining the network, it returns
+// a Service named "main" describing the port of the device's main connection.
+// For any other messages, nil is returned.
+func (m *DecodedMessage) AnnouncedService() *Service {
+	switch v := m.Message.(type) {
+	case *serviceAnnouncementMessage:
+		return &Service{
+			Name: v.Service,
+			Port: v.Port,
+		}
+	case *discoveryMessage:
+		if v.Action != discovererHowdy || v.Port == 0 {
+			return nil
+		}
+		return &Service{
+			Name: "main",
+			Port: v.Port,
+		}
+	}
+	return nil
+}
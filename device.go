@@ -1,7 +1,9 @@
 package stagelinq
 
 import (
+	"context"
 	"net"
+	"strconv"
 )
 
 // DeviceState represents a device's state in the network.
@@ -29,11 +31,13 @@ type Device struct {
 
 // Dial starts a TCP connection with the device on the given port.
 func (device *Device) Dial(port uint16) (conn net.Conn, err error) {
-	ip := device.IP
-	conn, err = net.DialTCP("tcp", nil, &net.TCPAddr{
-		IP:   ip,
-		Port: int(port),
-	})
+	return device.DialContext(context.Background(), port)
+}
+
+// DialContext works like Dial but aborts connecting once ctx is done.
+func (device *Device) DialContext(ctx context.Context, port uint16) (conn net.Conn, err error) {
+	var dialer net.Dialer
+	conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(device.IP.String(), strconv.Itoa(int(port))))
 
 	return
 }
@@ -41,12 +45,17 @@ func (device *Device) Dial(port uint16) (conn net.Conn, err error) {
 // Connect starts a new main connection with the device.
 // You need to pass the StagelinQ token announced for your own device.
 // You also need to pass services you want to provide; if you don't have any, pass an empty array.
-func (device *Device) Connect(token Token, offeredServices []*Service) (conn *MainConnection, err error) {
-	tcpConn, err := device.Dial(device.port)
+func (device *Device) Connect(token Token, offeredServices []*Service, opts ...ConnectionOption) (conn *MainConnection, err error) {
+	return device.ConnectContext(context.Background(), token, offeredServices, opts...)
+}
+
+// ConnectContext works like Connect but aborts connecting once ctx is done.
+func (device *Device) ConnectContext(ctx context.Context, token Token, offeredServices []*Service, opts ...ConnectionOption) (conn *MainConnection, err error) {
+	tcpConn, err := device.DialContext(ctx, device.port)
 	if err != nil {
 		return
 	}
-	conn, err = newMainConnection(tcpConn, token, device.token, offeredServices)
+	conn, err = newMainConnection(tcpConn, token, device.token, offeredServices, opts...)
 	return
 }
 
@@ -23,6 +23,7 @@ type Beacon struct {
 	token             Token
 	grpcHost          string
 	grpcPort          uint16
+	limits            Limits
 	shutdownWaitGroup sync.WaitGroup
 }
 
@@ -154,7 +155,7 @@ func (l *Beacon) replyIPv4(controlMessage *ipv4.ControlMessage, srcAddr net.Addr
 
 func (l *Beacon) handleIncomingIPv4Packet(b []byte, cm *ipv4.ControlMessage, srcAddr net.Addr) error {
 	// decode message
-	r := bytes.NewReader(b)
+	r := messages.NewFrameReader(b, l.limits)
 	m := new(eaasDiscoveryRequestMessage)
 	if err := m.ReadMessageFrom(r); err != nil {
 		return err
@@ -235,6 +236,7 @@ func StartBeaconWithConfiguration(beaconConfig *BeaconConfiguration) (beacon *Be
 		token:           token,
 		grpcHost:        beaconConfig.GRPCHost,
 		grpcPort:        grpcPort,
+		limits:          beaconConfig.Limits,
 	}
 	go b.listen()
 
@@ -38,4 +38,8 @@ type BeaconConfiguration struct {
 	//
 	// If left zero, defaults to the default EAAS gRPC API port (50010).
 	GRPCPort uint16
+
+	// Limits bounds the sizes of strings accepted in discovery requests. Zero
+	// values use the defaults.
+	Limits Limits
 }
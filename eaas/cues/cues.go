@@ -0,0 +1,85 @@
+package cues
+
+import (
+	"image/color"
+	"sort"
+
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+// Cues are the cue points of a track, with positions in samples.
+type Cues struct {
+	// MainCue is nil if the track has none.
+	MainCue *server.MainCue
+
+	// QuickCues and Loops are keyed by pad, starting at 0.
+	QuickCues map[int]*server.QuickCue
+	Loops     map[int]*server.Loop
+}
+
+// FromPerformanceData returns the cue points of performance data.
+func FromPerformanceData(data *server.PerformanceData) *Cues {
+	return &Cues{
+		MainCue:   data.MainCue,
+		QuickCues: data.QuickCues,
+		Loops:     data.Loops,
+	}
+}
+
+// Apply sets the cue points of performance data.
+func (c *Cues) Apply(data *server.PerformanceData) {
+	data.MainCue = c.MainCue
+	data.QuickCues = c.QuickCues
+	data.Loops = c.Loops
+}
+
+// IsEmpty returns whether there are no cue points at all.
+func (c *Cues) IsEmpty() bool {
+	return c.MainCue == nil && len(c.QuickCues) == 0 && len(c.Loops) == 0
+}
+
+func (c *Cues) setQuickCue(pad int, cue *server.QuickCue) {
+	if c.QuickCues == nil {
+		c.QuickCues = map[int]*server.QuickCue{}
+	}
+	c.QuickCues[pad] = cue
+}
+
+func (c *Cues) setLoop(pad int, loop *server.Loop) {
+	if c.Loops == nil {
+		c.Loops = map[int]*server.Loop{}
+	}
+	c.Loops[pad] = loop
+}
+
+// addLoop puts a loop on the given pad, or on the first free pad if pad is
+// negative or taken.
+func (c *Cues) addLoop(pad int, loop *server.Loop) {
+	if _, taken := c.Loops[pad]; pad < 0 || taken {
+		for pad = 0; c.Loops[pad] != nil; pad++ {
+		}
+	}
+	c.setLoop(pad, loop)
+}
+
+// rgb returns an opaque color.
+func rgb(r, g, b uint8) color.RGBA {
+	return color.RGBA{R: r, G: g, B: b, A: 0xff}
+}
+
+func toSamples(seconds, sampleRate float64) float64 {
+	return seconds * sampleRate
+}
+
+func toSeconds(samples, sampleRate float64) float64 {
+	return samples / sampleRate
+}
+
+func sortedPads[V any](m map[int]V) []int {
+	pads := make([]int, 0, len(m))
+	for pad := range m {
+		pads = append(pads, pad)
+	}
+	sort.Ints(pads)
+	return pads
+}
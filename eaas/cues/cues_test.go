@@ -0,0 +1,179 @@
+package cues
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"image/color"
+	"testing"
+
+	"github.com/icedream/go-stagelinq/eaas/server"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleRate = 44100
+
+func testCues() *Cues {
+	return &Cues{
+		MainCue: &server.MainCue{Position: 44100, InitialPosition: 44100, SetManually: true},
+		QuickCues: map[int]*server.QuickCue{
+			0: {Name: "Drop", Position: 88200, Color: rgb(0xcc, 0, 0)},
+			3: {Position: 441000, Color: rgb(0, 0xcc, 0)},
+		},
+		Loops: map[int]*server.Loop{
+			1: {Name: "Break", In: 44100, Out: 132300, Color: seratoLoopColor},
+		},
+	}
+}
+
+func Test_Serato(t *testing.T) {
+	// a track color, a hot cue and a loop as Serato DJ writes them
+	entry := func(kind string, data []byte) []byte {
+		b := append([]byte(kind), 0)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+		return append(b, data...)
+	}
+	payload := []byte{1, 1}
+	payload = append(payload, entry("COLOR", []byte{0, 0xff, 0xff, 0xff})...)
+	payload = append(payload, entry("CUE", []byte{
+		0, 2, 0, 0, 0x03, 0xe8, 0, 0xcc, 0x88, 0, 0, 0, 'I', 'n', 't', 'r', 'o', 0,
+	})...)
+	payload = append(payload, entry("LOOP", []byte{
+		0, 0, 0, 0, 0x07, 0xd0, 0, 0, 0x0f, 0xa0, 0xff, 0xff, 0xff, 0xff,
+		0, 0x27, 0xaa, 0xe1, 0, 0, 0,
+	})...)
+	payload = append(payload, entry("BPMLOCK", []byte{0})...)
+	payload = append(payload, 0)
+	encoded := []byte(base64.StdEncoding.EncodeToString(payload))
+	object := append([]byte{1, 1}, encoded[:20]...)
+	object = append(object, '\n')
+	object = append(object, encoded[20:]...)
+	object = append(object, 'A', 0, 0, 0)
+
+	c, err := DecodeSeratoMarkers2(object, sampleRate)
+	require.NoError(t, err)
+	require.Equal(t, &Cues{
+		QuickCues: map[int]*server.QuickCue{
+			2: {Name: "Intro", Position: 44100, Color: rgb(0xcc, 0x88, 0)},
+		},
+		Loops: map[int]*server.Loop{
+			0: {In: 88200, Out: 176400, Color: seratoLoopColor},
+		},
+	}, c)
+
+	// Serato DJ has no main cue
+	want := testCues()
+	want.MainCue = nil
+	object, err = EncodeSeratoMarkers2(testCues(), sampleRate)
+	require.NoError(t, err)
+	require.Len(t, object, seratoMarkers2MinSize)
+	c, err = DecodeSeratoMarkers2(object, sampleRate)
+	require.NoError(t, err)
+	require.Equal(t, want, c)
+
+	_, err = DecodeSeratoMarkers2([]byte{2, 1}, sampleRate)
+	require.ErrorIs(t, err, ErrInvalidSeratoMarkers)
+	_, err = DecodeSeratoMarkers2(append([]byte{1, 1}, encoded[:30]...), sampleRate)
+	require.ErrorIs(t, err, ErrInvalidSeratoMarkers)
+	_, err = EncodeSeratoMarkers2(&Cues{QuickCues: map[int]*server.QuickCue{256: {}}}, sampleRate)
+	require.Error(t, err)
+}
+
+func Test_GEOB(t *testing.T) {
+	description, object, err := DecodeGEOB(EncodeGEOB(SeratoMarkers2, []byte{1, 1, 0}))
+	require.NoError(t, err)
+	require.Equal(t, SeratoMarkers2, description)
+	require.Equal(t, []byte{1, 1, 0}, object)
+
+	// UTF-16 with byte order mark
+	frame := []byte{1}
+	frame = append(frame, "application/octet-stream\x00"...)
+	frame = append(frame, 0xff, 0xfe, 0, 0)
+	frame = append(frame, 0xff, 0xfe, 'S', 0, 'e', 0, 0, 0)
+	frame = append(frame, 0, 1)
+	description, object, err = DecodeGEOB(frame)
+	require.NoError(t, err)
+	require.Equal(t, "Se", description)
+	require.Equal(t, []byte{0, 1}, object)
+
+	_, _, err = DecodeGEOB([]byte{0, 'x'})
+	require.Error(t, err)
+}
+
+func Test_Rekordbox(t *testing.T) {
+	var track struct {
+		Marks []RekordboxPositionMark `xml:"POSITION_MARK"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(`<TRACK>
+		<POSITION_MARK Name="" Type="0" Start="0.500" Num="-1"/>
+		<POSITION_MARK Name="" Type="0" Start="9.000" Num="-1"/>
+		<POSITION_MARK Name="Drop" Type="0" Start="2.000" Num="1" Red="230" Green="40" Blue="40"/>
+		<POSITION_MARK Name="" Type="4" Start="4.000" End="6.000" Num="-1"/>
+		<POSITION_MARK Name="Hot" Type="4" Start="8.000" End="9.000" Num="0" Red="0" Green="0" Blue="255"/>
+	</TRACK>`), &track))
+
+	c := FromRekordbox(track.Marks, sampleRate)
+	require.Equal(t, &Cues{
+		MainCue: &server.MainCue{Position: 22050, InitialPosition: 22050, SetManually: true},
+		QuickCues: map[int]*server.QuickCue{
+			1: {Name: "Drop", Position: 88200, Color: rgb(230, 40, 40)},
+		},
+		Loops: map[int]*server.Loop{
+			0: {Name: "Hot", In: 352800, Out: 396900, Color: rgb(0, 0, 255)},
+			1: {In: 176400, Out: 264600},
+		},
+	}, c)
+
+	// loops lose their color, Rekordbox has none for memory loops
+	want := testCues()
+	want.Loops[1].Color = color.RGBA{}
+	b, err := xml.Marshal(testCues().Rekordbox(sampleRate))
+	require.NoError(t, err)
+	track.Marks = nil
+	require.NoError(t, xml.Unmarshal(append(append([]byte("<TRACK>"), b...), "</TRACK>"...), &track))
+	// memory loops take the first free pad
+	got := FromRekordbox(track.Marks, sampleRate)
+	require.Equal(t, want.Loops[1], got.Loops[0])
+	got.Loops = want.Loops
+	require.Equal(t, want, got)
+}
+
+func Test_Traktor(t *testing.T) {
+	var entry struct {
+		Cues []TraktorCueV2 `xml:"CUE_V2"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(`<ENTRY>
+		<CUE_V2 NAME="AutoGrid" DISPL_ORDER="0" TYPE="4" START="12.5" LEN="0" REPEATS="-1" HOTCUE="0"/>
+		<CUE_V2 NAME="n.n." DISPL_ORDER="0" TYPE="0" START="3000" LEN="0" REPEATS="-1" HOTCUE="-1"/>
+		<CUE_V2 NAME="Beat" DISPL_ORDER="0" TYPE="0" START="1000" LEN="0" REPEATS="-1" HOTCUE="1"/>
+		<CUE_V2 NAME="n.n." DISPL_ORDER="0" TYPE="5" START="2000" LEN="1000" REPEATS="-1" HOTCUE="-1"/>
+		<CUE_V2 NAME="Start" DISPL_ORDER="0" TYPE="3" START="500" LEN="0" REPEATS="-1" HOTCUE="2"/>
+		<CUE_V2 NAME="n.n." DISPL_ORDER="0" TYPE="3" START="250" LEN="0" REPEATS="-1" HOTCUE="-1"/>
+	</ENTRY>`), &entry))
+
+	c := FromTraktor(entry.Cues, sampleRate)
+	require.Equal(t, &Cues{
+		MainCue: &server.MainCue{Position: 11025, InitialPosition: 11025, SetManually: true},
+		QuickCues: map[int]*server.QuickCue{
+			1: {Name: "Beat", Position: 44100},
+			2: {Name: "Start", Position: 22050},
+		},
+		Loops: map[int]*server.Loop{
+			0: {In: 88200, Out: 132300},
+		},
+	}, c)
+
+	// Traktor has no colors
+	want := testCues()
+	for _, cue := range want.QuickCues {
+		cue.Color = color.RGBA{}
+	}
+	want.Loops[1].Color = color.RGBA{}
+	cues := testCues().Traktor(sampleRate)
+	require.Equal(t, traktorUnnamed, cues[0].Name)
+	require.Equal(t, len(cues)-1, cues[len(cues)-1].DisplayOrder)
+	got := FromTraktor(cues, sampleRate)
+	require.Equal(t, want.Loops[1], got.Loops[0])
+	got.Loops = want.Loops
+	require.Equal(t, want, got)
+}
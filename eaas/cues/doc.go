@@ -0,0 +1,23 @@
+/*
+This package converts the cues, hot cues and loops of tracks between the
+formats of other DJ software and the types of eaas/server, so providers can
+serve tracks with the cues DJs already set up.
+
+Supported are the "Serato Markers2" GEOB tags Serato DJ writes to files,
+POSITION_MARK elements of Rekordbox XML exports and CUE_V2 elements of
+Traktor NML collections. All of them store positions in time, so converting
+needs the sample rate of the track.
+
+	description, object, err := cues.DecodeGEOB(frame)
+	if err != nil {
+		return err
+	}
+	if description == cues.SeratoMarkers2 {
+		c, err := cues.DecodeSeratoMarkers2(object, sampleRate)
+		if err != nil {
+			return err
+		}
+		c.Apply(data)
+	}
+*/
+package cues
@@ -0,0 +1,134 @@
+package cues
+
+import (
+	"encoding/xml"
+	"image/color"
+	"math"
+
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+// Types of Rekordbox position marks.
+const (
+	RekordboxCue     = 0
+	RekordboxFadeIn  = 1
+	RekordboxFadeOut = 2
+	RekordboxLoad    = 3
+	RekordboxLoop    = 4
+)
+
+// RekordboxPositionMark is a POSITION_MARK element of a TRACK in a Rekordbox
+// XML export. Positions are in seconds.
+type RekordboxPositionMark struct {
+	XMLName xml.Name `xml:"POSITION_MARK"`
+	Name    string   `xml:"Name,attr"`
+	Type    int      `xml:"Type,attr"`
+	Start   float64  `xml:"Start,attr"`
+	End     *float64 `xml:"End,attr,omitempty"`
+
+	// Num is the hot cue pad, or -1 for memory cues and loops.
+	Num int `xml:"Num,attr"`
+
+	// The color of hot cues. Memory cues have none.
+	Red   *uint8 `xml:"Red,attr,omitempty"`
+	Green *uint8 `xml:"Green,attr,omitempty"`
+	Blue  *uint8 `xml:"Blue,attr,omitempty"`
+}
+
+func (m *RekordboxPositionMark) color() color.RGBA {
+	if m.Red == nil || m.Green == nil || m.Blue == nil {
+		return color.RGBA{}
+	}
+	return rgb(*m.Red, *m.Green, *m.Blue)
+}
+
+// FromRekordbox converts the position marks of a track.
+//
+// Hot cues become quick cues on the same pad and the first memory cue
+// becomes the main cue. Hot loops keep their pad where possible, memory
+// loops take the free loop pads in the order given.
+func FromRekordbox(marks []RekordboxPositionMark, sampleRate float64) *Cues {
+	c := &Cues{}
+	var memoryLoops []*server.Loop
+	for _, m := range marks {
+		switch {
+		case m.Type == RekordboxLoop && m.End != nil:
+			loop := &server.Loop{
+				Name:  m.Name,
+				In:    toSamples(m.Start, sampleRate),
+				Out:   toSamples(*m.End, sampleRate),
+				Color: m.color(),
+			}
+			if m.Num < 0 {
+				memoryLoops = append(memoryLoops, loop)
+			} else {
+				c.addLoop(m.Num, loop)
+			}
+		case m.Type == RekordboxCue && m.Num >= 0:
+			c.setQuickCue(m.Num, &server.QuickCue{
+				Name:     m.Name,
+				Position: toSamples(m.Start, sampleRate),
+				Color:    m.color(),
+			})
+		case (m.Type == RekordboxCue || m.Type == RekordboxLoad) && c.MainCue == nil:
+			position := toSamples(m.Start, sampleRate)
+			c.MainCue = &server.MainCue{
+				Position:        position,
+				InitialPosition: position,
+				SetManually:     true,
+			}
+		}
+	}
+	for _, loop := range memoryLoops {
+		c.addLoop(-1, loop)
+	}
+	return c
+}
+
+// Rekordbox returns the position marks of the cues.
+//
+// Quick cues become hot cues on the same pad and the main cue a memory cue.
+// Loops become memory loops, as Rekordbox shares its pads between hot cues
+// and hot loops.
+func (c *Cues) Rekordbox(sampleRate float64) []RekordboxPositionMark {
+	var marks []RekordboxPositionMark
+	if c.MainCue != nil {
+		marks = append(marks, RekordboxPositionMark{
+			Type:  RekordboxCue,
+			Start: seconds(c.MainCue.Position, sampleRate),
+			Num:   -1,
+		})
+	}
+	for _, pad := range sortedPads(c.QuickCues) {
+		cue := c.QuickCues[pad]
+		m := RekordboxPositionMark{
+			Name:  cue.Name,
+			Type:  RekordboxCue,
+			Start: seconds(cue.Position, sampleRate),
+			Num:   pad,
+		}
+		if cue.Color.A != 0 {
+			r, g, b := cue.Color.R, cue.Color.G, cue.Color.B
+			m.Red, m.Green, m.Blue = &r, &g, &b
+		}
+		marks = append(marks, m)
+	}
+	for _, pad := range sortedPads(c.Loops) {
+		loop := c.Loops[pad]
+		end := seconds(loop.Out, sampleRate)
+		marks = append(marks, RekordboxPositionMark{
+			Name:  loop.Name,
+			Type:  RekordboxLoop,
+			Start: seconds(loop.In, sampleRate),
+			End:   &end,
+			Num:   -1,
+		})
+	}
+	return marks
+}
+
+// seconds converts samples to seconds with the millisecond precision
+// Rekordbox exports.
+func seconds(samples, sampleRate float64) float64 {
+	return math.Round(toSeconds(samples, sampleRate)*1000) / 1000
+}
@@ -0,0 +1,254 @@
+package cues
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf16"
+
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+// SeratoMarkers2 is the description of the GEOB frame Serato DJ keeps hot
+// cues and saved loops in.
+const SeratoMarkers2 = "Serato Markers2"
+
+const (
+	// seratoMarkers2MinSize is the size Serato DJ pads its markers to.
+	seratoMarkers2MinSize = 470
+
+	seratoLineLength = 72
+)
+
+// seratoLoopColor is the color Serato DJ shows saved loops in.
+var seratoLoopColor = rgb(0x27, 0xaa, 0xe1)
+
+// ErrInvalidSeratoMarkers is returned for Serato markers that can't be
+// decoded.
+var ErrInvalidSeratoMarkers = errors.New("invalid Serato markers")
+
+// DecodeGEOB splits the content of an ID3v2 GEOB frame, as found in the raw
+// tags read by github.com/dhowden/tag, into its description and object.
+func DecodeGEOB(frame []byte) (description string, object []byte, err error) {
+	if len(frame) < 1 {
+		return "", nil, errors.New("empty GEOB frame")
+	}
+	encoding, rest := frame[0], frame[1:]
+	// the MIME type is always ISO-8859-1
+	mime := bytes.IndexByte(rest, 0)
+	if mime < 0 {
+		return "", nil, errors.New("GEOB frame without MIME type")
+	}
+	rest = rest[mime+1:]
+	var fields [2][]byte
+	for i := range fields {
+		if fields[i], rest, err = splitText(rest, encoding); err != nil {
+			return "", nil, err
+		}
+	}
+	return decodeText(fields[1], encoding), rest, nil
+}
+
+// EncodeGEOB returns the content of an ID3v2 GEOB frame holding object.
+func EncodeGEOB(description string, object []byte) []byte {
+	// ISO-8859-1 with an empty file name, like Serato DJ writes it
+	b := append([]byte{0}, "application/octet-stream\x00\x00"...)
+	for _, r := range description {
+		if r > 0xff {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	b = append(b, 0)
+	return append(b, object...)
+}
+
+// splitText splits a terminated string off b.
+func splitText(b []byte, encoding byte) (text, rest []byte, err error) {
+	if encoding == 1 || encoding == 2 {
+		// UTF-16 strings end with two zero bytes at an even offset
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return b[:i], b[i+2:], nil
+			}
+		}
+	} else if i := bytes.IndexByte(b, 0); i >= 0 {
+		return b[:i], b[i+1:], nil
+	}
+	return nil, nil, errors.New("unterminated GEOB text")
+}
+
+func decodeText(b []byte, encoding byte) string {
+	switch encoding {
+	case 0:
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+	case 1, 2:
+		var order binary.ByteOrder = binary.BigEndian
+		if len(b) >= 2 && b[0] == 0xff && b[1] == 0xfe {
+			order, b = binary.LittleEndian, b[2:]
+		} else if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+			b = b[2:]
+		}
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = order.Uint16(b[2*i:])
+		}
+		return string(utf16.Decode(units))
+	}
+	return string(b)
+}
+
+// DecodeSeratoMarkers2 decodes the object of a "Serato Markers2" GEOB frame.
+// Serato DJ has no main cue, so the returned cues never have one.
+//
+// The object holds a base64 encoded list of entries, each made up of a
+// type, its length as big endian 32-bit integer and its data. CUE and LOOP
+// entries are decoded, other entries such as the track color are skipped.
+func DecodeSeratoMarkers2(object []byte, sampleRate float64) (*Cues, error) {
+	if sampleRate <= 0 {
+		return nil, errors.New("invalid sample rate")
+	}
+	if len(object) < 2 || object[0] != 1 || object[1] != 1 {
+		return nil, fmt.Errorf("%w: unknown version", ErrInvalidSeratoMarkers)
+	}
+	payload := object[2:]
+	if i := bytes.IndexByte(payload, 0); i >= 0 {
+		payload = payload[:i]
+	}
+	payload = bytes.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '=' {
+			return -1
+		}
+		return r
+	}, payload)
+	// Serato DJ leaves a single dangling character at times
+	if len(payload)%4 == 1 {
+		payload = payload[:len(payload)-1]
+	}
+	b := make([]byte, base64.RawStdEncoding.DecodedLen(len(payload)))
+	n, err := base64.RawStdEncoding.Decode(b, payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSeratoMarkers, err)
+	}
+	b = b[:n]
+	if len(b) < 2 || b[0] != 1 || b[1] != 1 {
+		return nil, fmt.Errorf("%w: unknown version", ErrInvalidSeratoMarkers)
+	}
+	b = b[2:]
+
+	c := &Cues{}
+	for len(b) > 0 && b[0] != 0 {
+		end := bytes.IndexByte(b, 0)
+		if end < 0 || len(b) < end+5 {
+			return nil, fmt.Errorf("%w: truncated entry", ErrInvalidSeratoMarkers)
+		}
+		kind := string(b[:end])
+		size := binary.BigEndian.Uint32(b[end+1:])
+		b = b[end+5:]
+		if uint64(size) > uint64(len(b)) {
+			return nil, fmt.Errorf("%w: truncated %s entry", ErrInvalidSeratoMarkers, kind)
+		}
+		data := b[:size]
+		b = b[size:]
+		switch kind {
+		case "CUE":
+			if len(data) < 13 {
+				return nil, fmt.Errorf("%w: short CUE entry", ErrInvalidSeratoMarkers)
+			}
+			c.setQuickCue(int(data[1]), &server.QuickCue{
+				Name:     cString(data[12:]),
+				Position: toSamples(float64(binary.BigEndian.Uint32(data[2:]))/1000, sampleRate),
+				Color:    rgb(data[7], data[8], data[9]),
+			})
+		case "LOOP":
+			if len(data) < 20 {
+				return nil, fmt.Errorf("%w: short LOOP entry", ErrInvalidSeratoMarkers)
+			}
+			c.setLoop(int(data[1]), &server.Loop{
+				Name:  cString(data[20:]),
+				In:    toSamples(float64(binary.BigEndian.Uint32(data[2:]))/1000, sampleRate),
+				Out:   toSamples(float64(binary.BigEndian.Uint32(data[6:]))/1000, sampleRate),
+				Color: rgb(data[15], data[16], data[17]),
+			})
+		}
+	}
+	return c, nil
+}
+
+// cString returns the zero terminated string at the start of b.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func milliseconds(samples, sampleRate float64) uint32 {
+	ms := math.Round(toSeconds(samples, sampleRate) * 1000)
+	return uint32(math.Max(0, math.Min(ms, math.MaxUint32)))
+}
+
+// EncodeSeratoMarkers2 returns the object of a "Serato Markers2" GEOB frame
+// holding the hot cues and loops. Serato DJ has no main cue, so it is left
+// out.
+func EncodeSeratoMarkers2(c *Cues, sampleRate float64) ([]byte, error) {
+	if sampleRate <= 0 {
+		return nil, errors.New("invalid sample rate")
+	}
+	b := []byte{1, 1}
+	entry := func(kind string, data []byte) {
+		b = append(b, kind...)
+		b = append(b, 0)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+		b = append(b, data...)
+	}
+	for _, pad := range sortedPads(c.QuickCues) {
+		cue := c.QuickCues[pad]
+		if pad < 0 || pad > math.MaxUint8 {
+			return nil, fmt.Errorf("hot cue pad %d out of range", pad)
+		}
+		data := []byte{0, byte(pad)}
+		data = binary.BigEndian.AppendUint32(data, milliseconds(cue.Position, sampleRate))
+		data = append(data, 0, cue.Color.R, cue.Color.G, cue.Color.B, 0, 0)
+		data = append(data, cue.Name...)
+		entry("CUE", append(data, 0))
+	}
+	for _, pad := range sortedPads(c.Loops) {
+		loop := c.Loops[pad]
+		if pad < 0 || pad > math.MaxUint8 {
+			return nil, fmt.Errorf("loop pad %d out of range", pad)
+		}
+		loopColor := loop.Color
+		if loopColor.A == 0 {
+			loopColor = seratoLoopColor
+		}
+		data := []byte{0, byte(pad)}
+		data = binary.BigEndian.AppendUint32(data, milliseconds(loop.In, sampleRate))
+		data = binary.BigEndian.AppendUint32(data, milliseconds(loop.Out, sampleRate))
+		data = append(data, 0xff, 0xff, 0xff, 0xff, 0, loopColor.R, loopColor.G, loopColor.B, 0, 0)
+		data = append(data, loop.Name...)
+		entry("LOOP", append(data, 0))
+	}
+	b = append(b, 0)
+
+	encoded := base64.StdEncoding.EncodeToString(b)
+	object := []byte{1, 1}
+	for len(encoded) > seratoLineLength {
+		object = append(object, encoded[:seratoLineLength]...)
+		object = append(object, '\n')
+		encoded = encoded[seratoLineLength:]
+	}
+	object = append(object, encoded...)
+	object = append(object, 0)
+	if len(object) < seratoMarkers2MinSize {
+		object = append(object, make([]byte, seratoMarkers2MinSize-len(object))...)
+	}
+	return object, nil
+}
@@ -0,0 +1,141 @@
+package cues
+
+import (
+	"encoding/xml"
+	"math"
+
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+// Types of Traktor cues.
+const (
+	TraktorCue     = 0
+	TraktorFadeIn  = 1
+	TraktorFadeOut = 2
+	TraktorLoad    = 3
+	TraktorGrid    = 4
+	TraktorLoop    = 5
+)
+
+// traktorUnnamed is the name Traktor gives cues without one.
+const traktorUnnamed = "n.n."
+
+// TraktorCueV2 is a CUE_V2 element of an ENTRY in a Traktor NML collection.
+// Positions and lengths are in milliseconds.
+type TraktorCueV2 struct {
+	XMLName      xml.Name `xml:"CUE_V2"`
+	Name         string   `xml:"NAME,attr"`
+	DisplayOrder int      `xml:"DISPL_ORDER,attr"`
+	Type         int      `xml:"TYPE,attr"`
+	Start        float64  `xml:"START,attr"`
+	Length       float64  `xml:"LEN,attr"`
+	Repeats      int      `xml:"REPEATS,attr"`
+
+	// HotCue is the hot cue pad, or -1 for stored cues and loops.
+	HotCue int `xml:"HOTCUE,attr"`
+}
+
+func (c *TraktorCueV2) name() string {
+	if c.Name == traktorUnnamed {
+		return ""
+	}
+	return c.Name
+}
+
+// FromTraktor converts the cues of a collection entry. Traktor has no cue
+// colors, so the converted cues have none either.
+//
+// Hot cues become quick cues on the same pad and the load marker, or the
+// first stored cue if there is none, becomes the main cue. Loops on hot cue
+// pads keep their pad where possible, stored loops take the free loop pads
+// in the order given. Grid markers are skipped.
+func FromTraktor(cues []TraktorCueV2, sampleRate float64) *Cues {
+	c := &Cues{}
+	var storedLoops []*server.Loop
+	var load, stored *server.MainCue
+	for _, cue := range cues {
+		position := toSamples(cue.Start/1000, sampleRate)
+		switch {
+		case cue.Type == TraktorLoop:
+			loop := &server.Loop{
+				Name: cue.name(),
+				In:   position,
+				Out:  toSamples((cue.Start+cue.Length)/1000, sampleRate),
+			}
+			if cue.HotCue < 0 {
+				storedLoops = append(storedLoops, loop)
+			} else {
+				c.addLoop(cue.HotCue, loop)
+			}
+		case cue.Type == TraktorGrid:
+		case cue.HotCue >= 0:
+			c.setQuickCue(cue.HotCue, &server.QuickCue{
+				Name:     cue.name(),
+				Position: position,
+			})
+		case cue.Type == TraktorLoad && load == nil:
+			load = &server.MainCue{Position: position, InitialPosition: position, SetManually: true}
+		case cue.Type == TraktorCue && stored == nil:
+			stored = &server.MainCue{Position: position, InitialPosition: position, SetManually: true}
+		}
+	}
+	for _, loop := range storedLoops {
+		c.addLoop(-1, loop)
+	}
+	c.MainCue = load
+	if c.MainCue == nil {
+		c.MainCue = stored
+	}
+	return c
+}
+
+// Traktor returns the CUE_V2 elements of the cues.
+//
+// The main cue becomes the load marker and quick cues become hot cues on the
+// same pad. Loops are stored without a pad, as Traktor shares its pads
+// between hot cues and loops.
+func (c *Cues) Traktor(sampleRate float64) []TraktorCueV2 {
+	var cues []TraktorCueV2
+	add := func(cue TraktorCueV2) {
+		if cue.Name == "" {
+			cue.Name = traktorUnnamed
+		}
+		cue.DisplayOrder = len(cues)
+		cue.Repeats = -1
+		cues = append(cues, cue)
+	}
+	if c.MainCue != nil {
+		add(TraktorCueV2{
+			Type:   TraktorLoad,
+			Start:  traktorMilliseconds(c.MainCue.Position, sampleRate),
+			HotCue: -1,
+		})
+	}
+	for _, pad := range sortedPads(c.QuickCues) {
+		cue := c.QuickCues[pad]
+		add(TraktorCueV2{
+			Name:   cue.Name,
+			Type:   TraktorCue,
+			Start:  traktorMilliseconds(cue.Position, sampleRate),
+			HotCue: pad,
+		})
+	}
+	for _, pad := range sortedPads(c.Loops) {
+		loop := c.Loops[pad]
+		start := traktorMilliseconds(loop.In, sampleRate)
+		add(TraktorCueV2{
+			Name:   loop.Name,
+			Type:   TraktorLoop,
+			Start:  start,
+			Length: traktorMilliseconds(loop.Out, sampleRate) - start,
+			HotCue: -1,
+		})
+	}
+	return cues
+}
+
+// traktorMilliseconds converts samples to milliseconds with the microsecond
+// precision Traktor exports.
+func traktorMilliseconds(samples, sampleRate float64) float64 {
+	return math.Round(toSeconds(samples, sampleRate)*1e6) / 1e3
+}
@@ -0,0 +1,46 @@
+package eaas
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/icedream/go-stagelinq/internal/messages"
+)
+
+// DecodedMessage is an EAAS discovery message decoded from raw traffic, for
+// example from a packet capture.
+type DecodedMessage struct {
+	// Type is a short name of the message type, for example
+	// "eaasDiscoveryResponse".
+	Type string
+
+	// Message holds the decoded message fields.
+	Message interface{}
+}
+
+// DecodeDatagram decodes an EAAS discovery request or response as sent to UDP
+// port 11224.
+func DecodeDatagram(b []byte) (decoded *DecodedMessage, err error) {
+	for _, msg := range []messages.Message{
+		&eaasDiscoveryRequestMessage{},
+		&eaasDiscoveryResponseMessage{},
+	} {
+		var ok bool
+		if ok, err = msg.CheckMatch(bufio.NewReader(bytes.NewReader(b))); err != nil || !ok {
+			continue
+		}
+		if err = msg.ReadMessageFrom(messages.NewFrameReader(b, messages.DefaultLimits)); err != nil {
+			return
+		}
+		decoded = &DecodedMessage{
+			Type:    strings.TrimSuffix(reflect.TypeOf(msg).Elem().Name(), "Message"),
+			Message: msg,
+		}
+		return
+	}
+	err = fmt.Errorf("%w: not an EAAS discovery message", ErrInvalidMessageReceived)
+	return
+}
@@ -27,6 +27,18 @@ var ErrTooShortDiscoveryMessageReceived = errors.New("too short discovery messag
 // for broadcasts.
 var ErrInvalidMessageReceived = errors.New("invalid message received")
 
+// ErrFrameTooLarge is returned by Discoverer.Discover if a device announces a
+// string that is larger than the maximum accepted size.
+var ErrFrameTooLarge = messages.ErrFrameTooLarge
+
+// ErrTruncatedFrame is returned by Discoverer.Discover if a message ends before
+// all of the data it announced could be read.
+var ErrTruncatedFrame = messages.ErrTruncatedFrame
+
+// Limits bounds the sizes of strings accepted in discovery messages. Zero
+// values are replaced with the defaults.
+type Limits = messages.Limits
+
 const (
 	eaasDiscoveryNetwork       = "udp"
 	eaasDiscoveryAddressString = ":11224"
@@ -43,6 +55,7 @@ func makeEAASDiscoveryAddress(ip net.IP) *net.UDPAddr {
 type Discoverer struct {
 	packetConn        net.PacketConn
 	token             Token
+	limits            Limits
 	shutdownCond      *sync.Cond
 	shutdownWaitGroup sync.WaitGroup
 }
@@ -161,7 +174,7 @@ func (l *Discoverer) Discover(timeout time.Duration) (device *Device, err error)
 	}
 
 	// decode message
-	r := bytes.NewReader(b)
+	r := messages.NewFrameReader(b[:n], l.limits)
 	m := new(eaasDiscoveryResponseMessage)
 	if err = m.ReadMessageFrom(r); err != nil {
 		return
@@ -203,6 +216,7 @@ func NewDiscovererWithConfiguration(
 
 	discoverer = &Discoverer{
 		packetConn:   packetConn,
+		limits:       discovererConfig.Limits,
 		shutdownCond: sync.NewCond(&sync.Mutex{}),
 	}
 
@@ -16,4 +16,8 @@ type DiscovererConfiguration struct {
 	// for EAAS devices to announce themselves. If this is not set, no timeout
 	// will occur.
 	DiscoveryTimeout time.Duration
+
+	// Limits bounds the sizes of strings accepted in discovery responses.
+	// Zero values use the defaults.
+	Limits Limits
 }
@@ -0,0 +1,215 @@
+package enginedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// registers the pure Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// ErrNotFound is returned for rows that do not exist.
+var ErrNotFound = errors.New("not found")
+
+// busyTimeout is how long reads wait for Engine DJ to finish writing to a
+// database.
+const busyTimeout = 5 * time.Second
+
+// Information describes a database.
+type Information struct {
+	// UUID identifies the database. Engine DJ uses it to tell libraries
+	// apart, for example when tracks are copied between drives.
+	UUID string
+
+	SchemaVersionMajor int
+	SchemaVersionMinor int
+	SchemaVersionPatch int
+}
+
+// SchemaVersion returns the schema version as a string like "2.18.0".
+func (i *Information) SchemaVersion() string {
+	return fmt.Sprintf("%d.%d.%d", i.SchemaVersionMajor, i.SchemaVersionMinor, i.SchemaVersionPatch)
+}
+
+// DB is an Engine Library database opened for reading.
+type DB struct {
+	db *sql.DB
+
+	// columns holds the names of the columns of each table, as they differ
+	// between schema versions.
+	columns map[string]map[string]bool
+
+	versionLock sync.Mutex
+	// versionConn is the connection asking for the data version, which only
+	// changes between queries on the same connection.
+	versionConn *sql.Conn
+}
+
+// dsn returns the data source name opening a database file read-only.
+func dsn(name string) string {
+	p := filepath.ToSlash(name)
+	if !strings.HasPrefix(p, "/") {
+		// Windows paths like C:/...
+		p = "/" + p
+	}
+	u := &url.URL{
+		Scheme:   "file",
+		Path:     p,
+		RawQuery: "mode=ro&_busy_timeout=" + strconv.Itoa(int(busyTimeout.Milliseconds())),
+	}
+	return u.String()
+}
+
+// Open opens the database file with the given name for reading.
+func Open(name string) (d *DB, err error) {
+	if name, err = filepath.Abs(name); err != nil {
+		return
+	}
+	// SQLite reports missing files in a less helpful way
+	if _, err = os.Stat(name); err != nil {
+		return
+	}
+	db, err := sql.Open("sqlite", dsn(name))
+	if err != nil {
+		return
+	}
+	d = &DB{
+		db:      db,
+		columns: map[string]map[string]bool{},
+	}
+	if err = d.readColumns(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if !d.hasTable("Information") || !d.hasTable("Track") {
+		db.Close()
+		return nil, fmt.Errorf("%s: not an Engine Library database", name)
+	}
+	return
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	d.versionLock.Lock()
+	if d.versionConn != nil {
+		d.versionConn.Close()
+		d.versionConn = nil
+	}
+	d.versionLock.Unlock()
+	return d.db.Close()
+}
+
+// DataVersion returns a number that changes whenever the database is changed,
+// for example by Engine DJ.
+func (d *DB) DataVersion(ctx context.Context) (version uint64, err error) {
+	d.versionLock.Lock()
+	defer d.versionLock.Unlock()
+	if d.versionConn == nil {
+		if d.versionConn, err = d.db.Conn(ctx); err != nil {
+			return
+		}
+	}
+	err = d.versionConn.QueryRowContext(ctx, `PRAGMA data_version`).Scan(&version)
+	return
+}
+
+// readColumns learns which tables and columns the database has.
+func (d *DB) readColumns(ctx context.Context) error {
+	rows, err := d.db.QueryContext(ctx,
+		`SELECT m.name, p.name FROM sqlite_master m, pragma_table_info(m.name) p WHERE m.type = 'table'`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return err
+		}
+		if d.columns[table] == nil {
+			d.columns[table] = map[string]bool{}
+		}
+		d.columns[table][column] = true
+	}
+	return rows.Err()
+}
+
+func (d *DB) hasTable(table string) bool {
+	return d.columns[table] != nil
+}
+
+// selectColumns returns the select list for the given columns of a table.
+// Columns the table lacks in this schema version are selected as NULL.
+func (d *DB) selectColumns(table string, columns ...string) string {
+	exprs := make([]string, len(columns))
+	for i, column := range columns {
+		if d.columns[table][column] {
+			exprs[i] = `"` + column + `"`
+		} else {
+			exprs[i] = "NULL"
+		}
+	}
+	return strings.Join(exprs, ", ")
+}
+
+// Information returns what the database says about itself.
+func (d *DB) Information(ctx context.Context) (*Information, error) {
+	var (
+		uuid                sql.NullString
+		major, minor, patch sql.NullInt64
+	)
+	err := d.db.QueryRowContext(ctx, `SELECT `+
+		d.selectColumns("Information", "uuid", "schemaVersionMajor", "schemaVersionMinor", "schemaVersionPatch")+
+		` FROM Information ORDER BY id LIMIT 1`).Scan(&uuid, &major, &minor, &patch)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("database information: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Information{
+		UUID:               uuid.String,
+		SchemaVersionMajor: int(major.Int64),
+		SchemaVersionMinor: int(minor.Int64),
+		SchemaVersionPatch: int(patch.Int64),
+	}, nil
+}
+
+// parseTime converts a time column to a time. Engine DJ stores times as Unix
+// timestamps, older rows sometimes as SQLite date strings. Zero and NULL
+// give the zero time.
+func parseTime(v interface{}) time.Time {
+	switch v := v.(type) {
+	case int64:
+		if v > 0 {
+			return time.Unix(v, 0)
+		}
+	case float64:
+		if v > 0 {
+			return time.Unix(int64(v), 0)
+		}
+	case []byte:
+		return parseTime(string(v))
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return parseTime(n)
+		}
+		for _, layout := range []string{"2006-01-02 15:04:05", time.RFC3339} {
+			if t, err := time.ParseInLocation(layout, v, time.UTC); err == nil {
+				return t
+			}
+		}
+	case time.Time:
+		return v
+	}
+	return time.Time{}
+}
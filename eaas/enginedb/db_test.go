@@ -0,0 +1,296 @@
+package enginedb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/perfdata"
+	"github.com/stretchr/testify/require"
+)
+
+const testDatabaseUUID = "4d5e6f70-1234-4abc-9def-0123456789ab"
+
+// testSchema is the part of the Engine Library Database2 schema the package
+// reads.
+const testSchema = `
+CREATE TABLE Information (id INTEGER PRIMARY KEY AUTOINCREMENT, uuid TEXT, schemaVersionMajor INTEGER, schemaVersionMinor INTEGER, schemaVersionPatch INTEGER);
+CREATE TABLE AlbumArt (id INTEGER PRIMARY KEY AUTOINCREMENT, hash TEXT, albumArt BLOB);
+CREATE TABLE Track (id INTEGER PRIMARY KEY AUTOINCREMENT, playOrder INTEGER, length INTEGER, bpm INTEGER, year INTEGER, path TEXT, filename TEXT, bitrate INTEGER, bpmAnalyzed REAL, albumArtId INTEGER, fileBytes INTEGER, title TEXT, artist TEXT, album TEXT, genre TEXT, comment TEXT, label TEXT, composer TEXT, remixer TEXT, key INTEGER, rating INTEGER, albumArt TEXT, timeLastPlayed DATETIME, isPlayed BOOLEAN, fileType TEXT, isAnalyzed BOOLEAN, dateCreated DATETIME, dateAdded DATETIME, isAvailable BOOLEAN, originDatabaseUuid TEXT, originTrackId INTEGER, trackData BLOB, overviewWaveFormData BLOB, beatData BLOB, quickCues BLOB, loops BLOB, activeOnLoadLoops INTEGER);
+CREATE TABLE Playlist (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT, parentListId INTEGER, isPersisted BOOLEAN, nextListId INTEGER, lastEditTime DATETIME, isExplicitlyExported BOOLEAN);
+CREATE TABLE PlaylistEntity (id INTEGER PRIMARY KEY AUTOINCREMENT, listId INTEGER, trackId INTEGER, databaseUuid TEXT, nextEntityId INTEGER, membershipReference INTEGER);
+CREATE TABLE Smartlist (listUuid TEXT NOT NULL PRIMARY KEY, title TEXT, parentPlaylistPath TEXT, nextPlaylistPath TEXT, nextListUuid TEXT, rules TEXT, lastEditTime DATETIME);
+CREATE TABLE Historylist (id INTEGER PRIMARY KEY AUTOINCREMENT, sessionId INTEGER, title TEXT, startTime INTEGER, timezone TEXT, originDriveName TEXT, originDatabaseUuid TEXT, originListId INTEGER, isDeleted BOOLEAN, editTime DATETIME);
+CREATE TABLE HistorylistEntity (id INTEGER PRIMARY KEY AUTOINCREMENT, listId INTEGER, trackId INTEGER, startTime INTEGER);
+`
+
+func quickCuesBlob(cues []*QuickCue, mainCue *MainCue) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, int64(len(cues)))
+	for _, cue := range cues {
+		if cue == nil {
+			cue = &QuickCue{Position: unsetPosition}
+		}
+		buf.WriteByte(byte(len(cue.Name)))
+		buf.WriteString(cue.Name)
+		_ = binary.Write(buf, binary.BigEndian, cue.Position)
+		buf.Write([]byte{cue.Color.A, cue.Color.R, cue.Color.G, cue.Color.B})
+	}
+	_ = binary.Write(buf, binary.BigEndian, mainCue.Position)
+	if mainCue.SetManually {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	_ = binary.Write(buf, binary.BigEndian, mainCue.InitialPosition)
+	return perfdata.Compress(buf.Bytes())
+}
+
+func loopsBlob(loops []*Loop) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, int64(len(loops)))
+	for _, loop := range loops {
+		set := byte(1)
+		if loop == nil {
+			loop = &Loop{In: unsetPosition, Out: unsetPosition}
+			set = 0
+		}
+		buf.WriteByte(byte(len(loop.Name)))
+		buf.WriteString(loop.Name)
+		_ = binary.Write(buf, binary.LittleEndian, loop.In)
+		_ = binary.Write(buf, binary.LittleEndian, loop.Out)
+		buf.Write([]byte{set, set, loop.Color.A, loop.Color.R, loop.Color.G, loop.Color.B})
+	}
+	return perfdata.Compress(buf.Bytes())
+}
+
+func trackDataBlob(sampleRate float64, samples int64, key int32) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, sampleRate)
+	_ = binary.Write(buf, binary.BigEndian, samples)
+	_ = binary.Write(buf, binary.BigEndian, 0.5)
+	_ = binary.Write(buf, binary.BigEndian, key)
+	return perfdata.Compress(buf.Bytes())
+}
+
+// createTestLibrary writes an Engine Library folder with m.db and hm.db and
+// returns its path. Tracks live in a Music folder next to it.
+func createTestLibrary(t *testing.T) string {
+	root := t.TempDir()
+	dir := filepath.Join(root, "Engine Library")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Database2"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "Music"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "Music", "whiplash.mp3"), []byte("ID3 whiplash"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "Music", "secret.mp3"), []byte("ID3 secret"), 0o644))
+
+	exec := func(db *sql.DB, query string, args ...interface{}) {
+		t.Helper()
+		_, err := db.Exec(query, args...)
+		require.NoError(t, err)
+	}
+
+	m, err := sql.Open("sqlite", filepath.Join(dir, "Database2", "m.db"))
+	require.NoError(t, err)
+	defer m.Close()
+	exec(m, testSchema)
+	exec(m, `INSERT INTO Information (uuid, schemaVersionMajor, schemaVersionMinor, schemaVersionPatch) VALUES (?, 2, 18, 0)`, testDatabaseUUID)
+	exec(m, `INSERT INTO AlbumArt (id, albumArt) VALUES (1, ?)`, []byte("PNG"))
+	exec(m, `INSERT INTO Track (id, length, bpm, year, path, filename, bpmAnalyzed, albumArtId, fileBytes, title, artist, genre, key, rating, dateAdded, isAvailable,
+		trackData, overviewWaveFormData, beatData, quickCues, loops, activeOnLoadLoops)
+		VALUES (1, 215, 140, 2020, '../Music/whiplash.mp3', 'whiplash.mp3', 140.02, 1, 12, 'Whiplash', 'Icedream', 'Trance', 1, 80, 1700000000, 1,
+		?, ?, ?, ?, ?, 1)`,
+		trackDataBlob(44100, 9481500, 1),
+		perfdata.Compress([]byte("waveform")),
+		perfdata.Compress([]byte("beats")),
+		quickCuesBlob([]*QuickCue{
+			nil,
+			{Name: "Drop", Position: 88200, Color: color.RGBA{R: 255, A: 255}},
+		}, &MainCue{Position: 1000, InitialPosition: 500, SetManually: true}),
+		loopsBlob([]*Loop{
+			{Name: "Intro", In: 0, Out: 44100, Color: color.RGBA{G: 255, A: 255}},
+			{Name: "Outro", In: 88200, Out: 132300, Color: color.RGBA{B: 255, A: 255}},
+		}))
+	exec(m, `INSERT INTO Track (id, bpm, path, title, artist, key, dateAdded) VALUES (2, 124, '../Music/other.mp3', 'Other Song', 'Someone', NULL, '2023-11-14 22:13:20')`)
+	exec(m, `INSERT INTO Track (id, title, artist) VALUES (3, 'Streamed', 'Somebody')`)
+
+	// Folder contains B and A, in that order, and Techno is top level after
+	// Folder. IDs are deliberately out of display order.
+	exec(m, `INSERT INTO Playlist (id, title, parentListId, isPersisted, nextListId) VALUES
+		(1, 'Techno', 0, 1, 0),
+		(2, 'A', 4, 1, 0),
+		(3, 'B', 4, 1, 2),
+		(4, 'Folder', 0, 1, 1),
+		(5, 'Transient', 0, 0, 0)`)
+	exec(m, `INSERT INTO PlaylistEntity (id, listId, trackId, databaseUuid, nextEntityId) VALUES
+		(1, 1, 1, ?, 0),
+		(2, 1, 2, ?, 1),
+		(3, 2, 1, ?, 0)`, testDatabaseUUID, testDatabaseUUID, testDatabaseUUID)
+	exec(m, `INSERT INTO Smartlist (listUuid, title, parentPlaylistPath, nextListUuid, rules) VALUES
+		('b', 'Fast', '', '', '{"match":"all"}'),
+		('a', 'Recent', '', 'b', '{"match":"any"}')`)
+
+	hm, err := sql.Open("sqlite", filepath.Join(dir, "Database2", "hm.db"))
+	require.NoError(t, err)
+	defer hm.Close()
+	exec(hm, testSchema)
+	exec(hm, `INSERT INTO Information (uuid, schemaVersionMajor, schemaVersionMinor, schemaVersionPatch) VALUES ('history', 2, 18, 0)`)
+	exec(hm, `INSERT INTO Track (id, path, title, artist, originDatabaseUuid, originTrackId) VALUES
+		(10, '../Music/whiplash.mp3', 'Whiplash (copy)', 'Icedream', ?, 1),
+		(11, '../Music/gone.mp3', 'Gone', 'Nobody', 'elsewhere', 7)`, testDatabaseUUID)
+	exec(hm, `INSERT INTO Historylist (id, title, startTime, timezone, isDeleted) VALUES
+		(1, 'Friday', 1700000000, 'Europe/Berlin', 0),
+		(2, 'Deleted', 1700100000, '', 1)`)
+	exec(hm, `INSERT INTO HistorylistEntity (id, listId, trackId, startTime) VALUES
+		(2, 1, 11, 1700000300),
+		(1, 1, 10, 1700000060)`)
+	return dir
+}
+
+func Test_DB(t *testing.T) {
+	dir := createTestLibrary(t)
+	ctx := context.Background()
+
+	db, err := Open(filepath.Join(dir, "Database2", "m.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	info, err := db.Information(ctx)
+	require.NoError(t, err)
+	require.Equal(t, testDatabaseUUID, info.UUID)
+	require.Equal(t, "2.18.0", info.SchemaVersion())
+
+	tracks, err := db.Tracks(ctx)
+	require.NoError(t, err)
+	require.Len(t, tracks, 3)
+	track := tracks[0]
+	require.Equal(t, "Whiplash", track.Title)
+	require.Equal(t, "../Music/whiplash.mp3", track.Path)
+	require.Equal(t, 140.02, track.BPM)
+	require.Equal(t, "Am", track.KeyName())
+	require.Equal(t, 215*time.Second, track.Length)
+	require.Equal(t, int64(1700000000), track.DateAdded.Unix())
+	require.True(t, track.IsAvailable)
+	require.Equal(t, 124.0, tracks[1].BPM)
+	require.Equal(t, -1, tracks[1].Key)
+	require.Equal(t, int64(1700000000), tracks[1].DateAdded.Unix())
+
+	metadata := track.Metadata()
+	require.Equal(t, "1", metadata.GetId())
+	require.Equal(t, "Am", metadata.GetKey())
+	require.Equal(t, uint32(215), metadata.GetLengthSeconds())
+	require.Nil(t, tracks[1].Metadata().Key)
+
+	_, err = db.Track(ctx, 42)
+	require.ErrorIs(t, err, ErrNotFound)
+	art, err := db.AlbumArt(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("PNG"), art)
+
+	data, err := db.PerformanceData(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 44100.0, data.SampleRate)
+	require.Equal(t, int64(9481500), data.SampleCount)
+	require.Equal(t, 1, data.Key)
+	require.Equal(t, perfdata.Compress([]byte("waveform")), data.OverviewWaveform)
+	require.Equal(t, perfdata.Compress([]byte("beats")), data.BeatGrid)
+	require.Equal(t, &MainCue{Position: 1000, InitialPosition: 500, SetManually: true}, data.MainCue)
+	require.Len(t, data.QuickCues, 2)
+	require.Nil(t, data.QuickCues[0])
+	require.Equal(t, &QuickCue{Name: "Drop", Position: 88200, Color: color.RGBA{R: 255, A: 255}}, data.QuickCues[1])
+	require.Len(t, data.Loops, 2)
+	require.Equal(t, "Outro", data.Loops[1].Name)
+	require.Equal(t, 132300.0, data.Loops[1].Out)
+
+	m := data.TrackPerformanceData()
+	require.Equal(t, "1", m.GetId())
+	require.Equal(t, 140.02, m.GetBpm())
+	require.Equal(t, 1000.0, m.GetMainCue().GetPosition())
+	require.Len(t, m.GetQuickCues(), 1)
+	require.Equal(t, uint32(1), m.GetQuickCues()[0].GetKey())
+	require.Equal(t, uint32(255), m.GetQuickCues()[0].GetValue().GetColor().GetR())
+	require.Len(t, m.GetLoops(), 2)
+	require.False(t, m.GetLoops()[0].GetValue().GetActiveOnLoad())
+	require.True(t, m.GetLoops()[1].GetValue().GetActiveOnLoad())
+
+	// tracks without analysis data have none
+	data, err = db.PerformanceData(ctx, 2)
+	require.NoError(t, err)
+	require.Nil(t, data.MainCue)
+	require.Empty(t, data.QuickCues)
+	require.Equal(t, -1.0, data.TrackPerformanceData().GetMainCue().GetPosition())
+
+	playlists, err := db.Playlists(ctx)
+	require.NoError(t, err)
+	require.Len(t, playlists, 2)
+	require.Equal(t, "Folder", playlists[0].Title)
+	require.Equal(t, "Techno", playlists[1].Title)
+	require.Len(t, playlists[0].Children, 2)
+	require.Equal(t, "B", playlists[0].Children[0].Title)
+	require.Equal(t, "A", playlists[0].Children[1].Title)
+	require.Equal(t, []int64{1}, playlists[0].Children[1].TrackIDs)
+	require.Equal(t, []int64{2, 1}, playlists[1].TrackIDs)
+
+	smartLists, err := db.SmartLists(ctx)
+	require.NoError(t, err)
+	require.Len(t, smartLists, 2)
+	require.Equal(t, "Recent", smartLists[0].Title)
+	require.Equal(t, `{"match":"all"}`, smartLists[1].Rules)
+
+	// m.db has no history
+	sessions, err := db.History(ctx)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+
+	hm, err := Open(filepath.Join(dir, "Database2", "hm.db"))
+	require.NoError(t, err)
+	defer hm.Close()
+	sessions, err = hm.History(ctx)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, "Friday", sessions[0].Title)
+	require.Equal(t, "Europe/Berlin", sessions[0].Timezone)
+	require.Len(t, sessions[0].Entries, 2)
+	require.Equal(t, int64(10), sessions[0].Entries[0].TrackID)
+	require.Equal(t, int64(11), sessions[0].Entries[1].TrackID)
+
+	// databases are opened read-only
+	_, err = db.db.Exec(`DELETE FROM Track`)
+	require.Error(t, err)
+
+	// the data version changes with writes by others
+	version, err := db.DataVersion(ctx)
+	require.NoError(t, err)
+	unchanged, err := db.DataVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, version, unchanged)
+	writer, err := sql.Open("sqlite", filepath.Join(dir, "Database2", "m.db"))
+	require.NoError(t, err)
+	_, err = writer.Exec(`UPDATE Track SET title = 'Whiplash (Extended Mix)' WHERE id = 1`)
+	writer.Close()
+	require.NoError(t, err)
+	changed, err := db.DataVersion(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, version, changed)
+
+	_, err = Open(filepath.Join(dir, "missing.db"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_linkedOrder(t *testing.T) {
+	type item struct{ id, next int }
+	id := func(i item) int { return i.id }
+	next := func(i item) int { return i.next }
+
+	ordered := linkedOrder([]item{{1, 0}, {2, 3}, {3, 1}}, id, next)
+	require.Equal(t, []item{{2, 3}, {3, 1}, {1, 0}}, ordered)
+
+	// a cycle and a dangling link still return every item once
+	ordered = linkedOrder([]item{{1, 2}, {2, 1}, {3, 9}}, id, next)
+	require.Equal(t, []item{{3, 9}, {1, 2}, {2, 1}}, ordered)
+}
@@ -0,0 +1,20 @@
+/*
+This package reads Engine Library databases as written by Engine DJ 2 and
+later ("Database2"), and serves them as an EAAS library.
+
+An Engine Library is a folder called "Engine Library" holding a "Database2"
+folder with the SQLite databases: m.db contains the tracks, their analysis
+data and the playlists, hm.db contains the play history. The databases are
+only ever opened for reading, so they can be served while Engine DJ uses
+them.
+
+	db, err := enginedb.Open("/media/usb/Engine Library/Database2/m.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	tracks, err := db.Tracks(ctx)
+
+Use NewProvider to serve a whole Engine Library with an eaas/server.Server.
+*/
+package enginedb
@@ -0,0 +1,105 @@
+package enginedb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// HistorySession is a row of the Historylist table of hm.db along with its
+// entries. Engine DJ records one per session on a player.
+type HistorySession struct {
+	ID        int64
+	Title     string
+	StartTime time.Time
+
+	// Timezone is the name of the time zone the session was played in.
+	Timezone string
+
+	// OriginDatabaseUUID names the database the played tracks were loaded
+	// from.
+	OriginDatabaseUUID string
+
+	// Entries are the tracks played in the session, in the order they were
+	// played.
+	Entries []*HistoryEntry
+}
+
+// HistoryEntry is a row of the HistorylistEntity table. TrackID refers to
+// the Track table of the same database.
+type HistoryEntry struct {
+	ID        int64
+	TrackID   int64
+	StartTime time.Time
+}
+
+// History returns the history sessions of the database that were not
+// deleted, oldest first. Only hm.db has them, other databases have none.
+func (d *DB) History(ctx context.Context) ([]*HistorySession, error) {
+	if !d.hasTable("Historylist") {
+		return nil, nil
+	}
+	entries, err := d.historyEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, `SELECT `+
+		d.selectColumns("Historylist", "id", "title", "startTime", "timezone", "originDatabaseUuid", "isDeleted")+
+		` FROM Historylist ORDER BY startTime, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*HistorySession
+	for rows.Next() {
+		var (
+			session                             HistorySession
+			title, timezone, originDatabaseUUID sql.NullString
+			startTime                           interface{}
+			isDeleted                           sql.NullBool
+		)
+		if err := rows.Scan(&session.ID, &title, &startTime, &timezone, &originDatabaseUUID, &isDeleted); err != nil {
+			return nil, err
+		}
+		if isDeleted.Bool {
+			continue
+		}
+		session.Title = title.String
+		session.StartTime = parseTime(startTime)
+		session.Timezone = timezone.String
+		session.OriginDatabaseUUID = originDatabaseUUID.String
+		session.Entries = entries[session.ID]
+		sessions = append(sessions, &session)
+	}
+	return sessions, rows.Err()
+}
+
+// historyEntries returns the entries of all history sessions by session ID.
+func (d *DB) historyEntries(ctx context.Context) (map[int64][]*HistoryEntry, error) {
+	entries := map[int64][]*HistoryEntry{}
+	if !d.hasTable("HistorylistEntity") {
+		return entries, nil
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT `+
+		d.selectColumns("HistorylistEntity", "id", "listId", "trackId", "startTime")+
+		` FROM HistorylistEntity ORDER BY startTime, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			entry     HistoryEntry
+			listID    int64
+			startTime interface{}
+		)
+		if err := rows.Scan(&entry.ID, &listID, &entry.TrackID, &startTime); err != nil {
+			return nil, err
+		}
+		entry.StartTime = parseTime(startTime)
+		entries[listID] = append(entries[listID], &entry)
+	}
+	return entries, rows.Err()
+}
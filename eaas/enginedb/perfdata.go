@@ -0,0 +1,315 @@
+package enginedb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/icedream/go-stagelinq/eaas/perfdata"
+	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
+	"google.golang.org/protobuf/proto"
+)
+
+// unsetPosition marks cue and loop positions that are not set.
+const unsetPosition float64 = -1
+
+// MainCue is the position a track is cued to when loaded, in samples.
+type MainCue struct {
+	// Position is the main cue as adjusted by the DJ.
+	Position float64
+
+	// InitialPosition is the main cue as found by the analysis.
+	InitialPosition float64
+
+	SetManually bool
+}
+
+// QuickCue is a hot cue, with its position in samples.
+type QuickCue struct {
+	Name     string
+	Position float64
+	Color    color.RGBA
+}
+
+// Loop is a saved loop, with its positions in samples.
+type Loop struct {
+	Name  string
+	In    float64
+	Out   float64
+	Color color.RGBA
+}
+
+// PerformanceData is the analysis data of a track.
+type PerformanceData struct {
+	TrackID int64
+
+	BPM             float64
+	SampleRate      float64
+	SampleCount     int64
+	AverageLoudness float64
+
+	// Key is the analyzed key index, see KeyName, or -1 if unknown.
+	Key int
+
+	// BeatGrid and OverviewWaveform are the blobs as stored by Engine DJ,
+	// still compressed, which is also how devices expect them in
+	// TrackPerformanceData. Decode them with the eaas/perfdata package.
+	BeatGrid         []byte
+	OverviewWaveform []byte
+
+	// MainCue is nil if the track has none.
+	MainCue *MainCue
+
+	// QuickCues and Loops are indexed by pad. Pads without a cue or loop
+	// are nil.
+	QuickCues []*QuickCue
+	Loops     []*Loop
+
+	// ActiveOnLoadLoop is the pad of the loop activated when the track is
+	// loaded, or -1 for none.
+	ActiveOnLoadLoop int
+}
+
+func colorToProto(c color.RGBA) *enginelibrary.Color {
+	return &enginelibrary.Color{
+		R: proto.Uint32(uint32(c.R)),
+		G: proto.Uint32(uint32(c.G)),
+		B: proto.Uint32(uint32(c.B)),
+		A: proto.Uint32(uint32(c.A)),
+	}
+}
+
+// TrackPerformanceData returns the analysis data as sent to devices.
+func (d *PerformanceData) TrackPerformanceData() *enginelibrary.TrackPerformanceData {
+	m := &enginelibrary.TrackPerformanceData{
+		Id:               proto.String(strconv.FormatInt(d.TrackID, 10)),
+		BeatGrid:         d.BeatGrid,
+		OverviewWaveform: d.OverviewWaveform,
+		MainCue: &enginelibrary.MainCue{
+			Position:        proto.Float64(unsetPosition),
+			InitialPosition: proto.Float64(unsetPosition),
+		},
+	}
+	if d.BPM > 0 {
+		m.Bpm = proto.Float64(d.BPM)
+	}
+	if d.MainCue != nil {
+		m.MainCue = &enginelibrary.MainCue{
+			Position:        proto.Float64(d.MainCue.Position),
+			InitialPosition: proto.Float64(d.MainCue.InitialPosition),
+			IsSetManually:   proto.Bool(d.MainCue.SetManually),
+		}
+	}
+	for pad, cue := range d.QuickCues {
+		if cue == nil {
+			continue
+		}
+		m.QuickCues = append(m.QuickCues, &enginelibrary.TrackPerformanceData_QuickCuesEntry{
+			Key: proto.Uint32(uint32(pad)),
+			Value: &enginelibrary.QuickCue{
+				Name:     optionalString(cue.Name),
+				Position: proto.Float64(cue.Position),
+				Color:    colorToProto(cue.Color),
+			},
+		})
+	}
+	for pad, loop := range d.Loops {
+		if loop == nil {
+			continue
+		}
+		m.Loops = append(m.Loops, &enginelibrary.TrackPerformanceData_LoopsEntry{
+			Key: proto.Uint32(uint32(pad)),
+			Value: &enginelibrary.Loop{
+				Name:         optionalString(loop.Name),
+				LoopIn:       loop.In,
+				LoopOut:      loop.Out,
+				Color:        colorToProto(loop.Color),
+				ActiveOnLoad: proto.Bool(pad == d.ActiveOnLoadLoop),
+			},
+		})
+	}
+	return m
+}
+
+// PerformanceData returns the analysis data of a track.
+func (d *DB) PerformanceData(ctx context.Context, trackID int64) (*PerformanceData, error) {
+	var (
+		trackData, overviewWaveform, beatData, quickCues, loops []byte
+		bpm                                                     sql.NullFloat64
+		activeOnLoadLoop                                        sql.NullInt64
+	)
+	err := d.db.QueryRowContext(ctx, `SELECT `+
+		d.selectColumns("Track", "trackData", "overviewWaveFormData", "beatData", "quickCues", "loops", "bpmAnalyzed", "activeOnLoadLoops")+
+		` FROM Track WHERE id = ?`, trackID).
+		Scan(&trackData, &overviewWaveform, &beatData, &quickCues, &loops, &bpm, &activeOnLoadLoop)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("track %d: %w", trackID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := &PerformanceData{
+		TrackID:          trackID,
+		BPM:              bpm.Float64,
+		Key:              -1,
+		ActiveOnLoadLoop: -1,
+	}
+	if activeOnLoadLoop.Valid {
+		data.ActiveOnLoadLoop = int(activeOnLoadLoop.Int64)
+	}
+	if trackData, err = perfdata.Uncompress(trackData); err != nil {
+		return nil, fmt.Errorf("track %d: track data: %w", trackID, err)
+	}
+	if err = data.decodeTrackData(trackData); err != nil {
+		return nil, fmt.Errorf("track %d: track data: %w", trackID, err)
+	}
+	// passed on to devices as stored
+	data.OverviewWaveform = overviewWaveform
+	data.BeatGrid = beatData
+	if quickCues, err = perfdata.Uncompress(quickCues); err != nil {
+		return nil, fmt.Errorf("track %d: quick cues: %w", trackID, err)
+	}
+	if err = data.decodeQuickCues(quickCues); err != nil {
+		return nil, fmt.Errorf("track %d: quick cues: %w", trackID, err)
+	}
+	if loops, err = perfdata.Uncompress(loops); err != nil {
+		return nil, fmt.Errorf("track %d: loops: %w", trackID, err)
+	}
+	if err = data.decodeLoops(loops); err != nil {
+		return nil, fmt.Errorf("track %d: loops: %w", trackID, err)
+	}
+	return data, nil
+}
+
+// blobReader reads the fields of a blob, remembering the first error.
+type blobReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *blobReader) read(order binary.ByteOrder, v interface{}) {
+	if r.err == nil {
+		r.err = binary.Read(r.r, order, v)
+	}
+}
+
+func (r *blobReader) float64(order binary.ByteOrder) (v float64) {
+	r.read(order, &v)
+	return
+}
+
+func (r *blobReader) int64(order binary.ByteOrder) (v int64) {
+	r.read(order, &v)
+	return
+}
+
+func (r *blobReader) uint8() (v uint8) {
+	r.read(binary.BigEndian, &v)
+	return
+}
+
+func (r *blobReader) label() string {
+	n := r.uint8()
+	if r.err != nil || n == 0 {
+		return ""
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		r.err = err
+	}
+	return string(b)
+}
+
+// argb reads a color stored as alpha, red, green and blue bytes.
+func (r *blobReader) argb() color.RGBA {
+	a, red, g, b := r.uint8(), r.uint8(), r.uint8(), r.uint8()
+	return color.RGBA{R: red, G: g, B: b, A: a}
+}
+
+// count reads the number of entries of a list, refusing counts that can't
+// possibly fit the rest of the blob.
+func (r *blobReader) count(order binary.ByteOrder, minEntrySize int) int {
+	n := r.int64(order)
+	if r.err == nil && (n < 0 || n > int64(r.r.Len()/minEntrySize)) {
+		r.err = fmt.Errorf("invalid entry count %d", n)
+	}
+	return int(n)
+}
+
+// decodeTrackData decodes the big endian track data blob: sample rate,
+// sample count, average loudness and key.
+func (d *PerformanceData) decodeTrackData(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	r := &blobReader{r: bytes.NewReader(b)}
+	d.SampleRate = r.float64(binary.BigEndian)
+	d.SampleCount = r.int64(binary.BigEndian)
+	d.AverageLoudness = r.float64(binary.BigEndian)
+	var key int32
+	r.read(binary.BigEndian, &key)
+	if r.err == nil {
+		d.Key = int(key)
+	}
+	return r.err
+}
+
+// decodeQuickCues decodes the big endian quick cues blob: the hot cues
+// followed by the adjusted main cue, whether it was adjusted and the main
+// cue found by the analysis.
+func (d *PerformanceData) decodeQuickCues(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	r := &blobReader{r: bytes.NewReader(b)}
+	n := r.count(binary.BigEndian, 13)
+	for i := 0; i < n && r.err == nil; i++ {
+		cue := &QuickCue{Name: r.label()}
+		cue.Position = r.float64(binary.BigEndian)
+		cue.Color = r.argb()
+		if cue.Position == unsetPosition {
+			cue = nil
+		}
+		d.QuickCues = append(d.QuickCues, cue)
+	}
+	mainCue := &MainCue{}
+	mainCue.Position = r.float64(binary.BigEndian)
+	mainCue.SetManually = r.uint8() != 0
+	mainCue.InitialPosition = r.float64(binary.BigEndian)
+	if r.err != nil {
+		return r.err
+	}
+	if mainCue.Position != unsetPosition || mainCue.InitialPosition != unsetPosition {
+		d.MainCue = mainCue
+	}
+	return nil
+}
+
+// decodeLoops decodes the little endian loops blob.
+func (d *PerformanceData) decodeLoops(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	r := &blobReader{r: bytes.NewReader(b)}
+	n := r.count(binary.LittleEndian, 23)
+	for i := 0; i < n && r.err == nil; i++ {
+		loop := &Loop{Name: r.label()}
+		loop.In = r.float64(binary.LittleEndian)
+		loop.Out = r.float64(binary.LittleEndian)
+		inSet, outSet := r.uint8() != 0, r.uint8() != 0
+		loop.Color = r.argb()
+		if !inSet || !outSet || math.IsNaN(loop.In) || math.IsNaN(loop.Out) {
+			loop = nil
+		}
+		d.Loops = append(d.Loops, loop)
+	}
+	return r.err
+}
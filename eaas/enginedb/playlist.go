@@ -0,0 +1,244 @@
+package enginedb
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// Playlist is a row of the Playlist table along with its entries.
+type Playlist struct {
+	ID    int64
+	Title string
+
+	// ParentID is the ID of the playlist this one is nested in, 0 for top
+	// level playlists.
+	ParentID int64
+
+	LastEditTime time.Time
+
+	// Children are the playlists nested in this one, in order.
+	Children []*Playlist
+
+	// TrackIDs are the IDs of the tracks in the playlist, in order.
+	TrackIDs []int64
+}
+
+// SmartList is a row of the Smartlist table. Smart lists are not evaluated,
+// their rules are returned as stored.
+type SmartList struct {
+	UUID  string
+	Title string
+
+	// ParentPath names the playlist the smart list is nested in by the
+	// titles of it and its parents, each followed by a semicolon. It is
+	// empty for top level smart lists.
+	ParentPath string
+
+	// Rules are the JSON encoded rules selecting the tracks.
+	Rules string
+
+	LastEditTime time.Time
+}
+
+// linkedOrder orders items linked to each other by the ID of the next item.
+// The item nothing links to comes first. Items outside of the chain, for
+// example due to broken links, are appended in their original order.
+func linkedOrder[T any, K comparable](items []T, id, next func(T) K) []T {
+	byID := make(map[K]T, len(items))
+	linked := make(map[K]bool, len(items))
+	for _, item := range items {
+		byID[id(item)] = item
+	}
+	for _, item := range items {
+		if _, ok := byID[next(item)]; ok {
+			linked[next(item)] = true
+		}
+	}
+
+	ordered := make([]T, 0, len(items))
+	seen := make(map[K]bool, len(items))
+	for _, item := range items {
+		if linked[id(item)] {
+			continue
+		}
+		for !seen[id(item)] {
+			seen[id(item)] = true
+			ordered = append(ordered, item)
+			nextItem, ok := byID[next(item)]
+			if !ok {
+				break
+			}
+			item = nextItem
+		}
+	}
+	for _, item := range items {
+		if !seen[id(item)] {
+			seen[id(item)] = true
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered
+}
+
+type playlistRow struct {
+	*Playlist
+	nextID int64
+}
+
+type entityRow struct {
+	id, listID, trackID, nextID int64
+}
+
+// Playlists returns the top level playlists of the database with the ones
+// nested in them, in the order shown in Engine DJ.
+func (d *DB) Playlists(ctx context.Context) ([]*Playlist, error) {
+	entities, err := d.playlistEntities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, `SELECT `+
+		d.selectColumns("Playlist", "id", "title", "parentListId", "nextListId", "isPersisted", "lastEditTime")+
+		` FROM Playlist ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byParent := map[int64][]playlistRow{}
+	for rows.Next() {
+		var (
+			row              = playlistRow{Playlist: &Playlist{}}
+			title            sql.NullString
+			parentID, nextID sql.NullInt64
+			isPersisted      sql.NullBool
+			lastEditTime     interface{}
+		)
+		if err := rows.Scan(&row.ID, &title, &parentID, &nextID, &isPersisted, &lastEditTime); err != nil {
+			return nil, err
+		}
+		if isPersisted.Valid && !isPersisted.Bool {
+			continue
+		}
+		row.Title = title.String
+		row.ParentID = parentID.Int64
+		row.nextID = nextID.Int64
+		row.LastEditTime = parseTime(lastEditTime)
+		for _, entity := range entities[row.ID] {
+			row.TrackIDs = append(row.TrackIDs, entity.trackID)
+		}
+		byParent[row.ParentID] = append(byParent[row.ParentID], row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ordered := map[int64][]*Playlist{}
+	for parentID, siblings := range byParent {
+		siblings = linkedOrder(siblings,
+			func(row playlistRow) int64 { return row.ID },
+			func(row playlistRow) int64 { return row.nextID })
+		for _, row := range siblings {
+			ordered[parentID] = append(ordered[parentID], row.Playlist)
+		}
+	}
+	for _, playlists := range ordered {
+		for _, playlist := range playlists {
+			playlist.Children = ordered[playlist.ID]
+		}
+	}
+	return ordered[0], nil
+}
+
+// playlistEntities returns the entries of all playlists by playlist ID, in
+// playlist order.
+func (d *DB) playlistEntities(ctx context.Context) (map[int64][]entityRow, error) {
+	entities := map[int64][]entityRow{}
+	if !d.hasTable("PlaylistEntity") {
+		return entities, nil
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT `+
+		d.selectColumns("PlaylistEntity", "id", "listId", "trackId", "nextEntityId")+
+		` FROM PlaylistEntity ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			entity entityRow
+			nextID sql.NullInt64
+		)
+		if err := rows.Scan(&entity.id, &entity.listID, &entity.trackID, &nextID); err != nil {
+			return nil, err
+		}
+		entity.nextID = nextID.Int64
+		entities[entity.listID] = append(entities[entity.listID], entity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for listID, list := range entities {
+		entities[listID] = linkedOrder(list,
+			func(entity entityRow) int64 { return entity.id },
+			func(entity entityRow) int64 { return entity.nextID })
+	}
+	return entities, nil
+}
+
+// SmartLists returns the smart lists of the database, ordered by parent path
+// and then in the order shown in Engine DJ.
+func (d *DB) SmartLists(ctx context.Context) ([]*SmartList, error) {
+	if !d.hasTable("Smartlist") {
+		return nil, nil
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT `+
+		d.selectColumns("Smartlist", "listUuid", "title", "parentPlaylistPath", "nextListUuid", "rules", "lastEditTime")+
+		` FROM Smartlist ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type smartListRow struct {
+		*SmartList
+		nextUUID string
+	}
+	byParent := map[string][]smartListRow{}
+	for rows.Next() {
+		var (
+			row                                = smartListRow{SmartList: &SmartList{}}
+			title, parentPath, nextUUID, rules sql.NullString
+			lastEditTime                       interface{}
+		)
+		if err := rows.Scan(&row.UUID, &title, &parentPath, &nextUUID, &rules, &lastEditTime); err != nil {
+			return nil, err
+		}
+		row.Title = title.String
+		row.ParentPath = parentPath.String
+		row.nextUUID = nextUUID.String
+		row.Rules = rules.String
+		row.LastEditTime = parseTime(lastEditTime)
+		byParent[row.ParentPath] = append(byParent[row.ParentPath], row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	parents := make([]string, 0, len(byParent))
+	for parent := range byParent {
+		parents = append(parents, parent)
+	}
+	sort.Strings(parents)
+	var lists []*SmartList
+	for _, parent := range parents {
+		for _, row := range linkedOrder(byParent[parent],
+			func(row smartListRow) string { return row.UUID },
+			func(row smartListRow) string { return row.nextUUID }) {
+			lists = append(lists, row.SmartList)
+		}
+	}
+	return lists, nil
+}
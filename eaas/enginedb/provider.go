@@ -0,0 +1,446 @@
+package enginedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+const defaultTitle = "Engine Library"
+
+// ProviderConfiguration contains configurable values for a Provider.
+type ProviderConfiguration struct {
+	// Title is the name of the library shown on devices. Defaults to
+	// "Engine Library".
+	Title string
+}
+
+var _ server.LibraryProvider = &Provider{}
+var _ server.HistoryProvider = &Provider{}
+var _ server.VersionProvider = &Provider{}
+
+// Provider serves an Engine Library as a single library.
+//
+// Track and playlist IDs are the ones of the database and the library ID is
+// the UUID of m.db, so devices see the same IDs as when Engine DJ serves the
+// library. Smart lists are not served.
+type Provider struct {
+	dir     string
+	db      *DB
+	history *DB
+	library *server.Library
+
+	artLock sync.Mutex
+	art     map[int64][]byte
+}
+
+// findDatabase returns the path of m.db for a path naming an Engine Library
+// folder, its Database2 folder or m.db itself.
+func findDatabase(name string) (string, error) {
+	for _, candidate := range []string{
+		filepath.Join(name, "Database2", "m.db"),
+		filepath.Join(name, "m.db"),
+		name,
+	} {
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate, nil
+		}
+	}
+	return "", &os.PathError{Op: "open", Path: name, Err: errors.New("no Engine Library database found")}
+}
+
+// NewProvider opens the Engine Library at the given path for serving. The
+// path may name the Engine Library folder, its Database2 folder or m.db. The
+// play history is served if hm.db is next to m.db.
+func NewProvider(name string, config *ProviderConfiguration) (p *Provider, err error) {
+	if config == nil {
+		config = new(ProviderConfiguration)
+	}
+	title := config.Title
+	if title == "" {
+		title = defaultTitle
+	}
+
+	if name, err = filepath.Abs(name); err != nil {
+		return
+	}
+	dbName, err := findDatabase(name)
+	if err != nil {
+		return
+	}
+	db, err := Open(dbName)
+	if err != nil {
+		return
+	}
+	info, err := db.Information(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", dbName, err)
+	}
+
+	p = &Provider{
+		// track paths are relative to the Engine Library folder
+		dir: filepath.Dir(filepath.Dir(dbName)),
+		db:  db,
+		library: &server.Library{
+			ID:    info.UUID,
+			Title: title,
+		},
+		art: map[int64][]byte{},
+	}
+	historyName := filepath.Join(filepath.Dir(dbName), "hm.db")
+	if _, err := os.Stat(historyName); err == nil {
+		if p.history, err = Open(historyName); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return
+}
+
+// Close closes the databases.
+func (p *Provider) Close() error {
+	err := p.db.Close()
+	if p.history != nil {
+		if historyErr := p.history.Close(); err == nil {
+			err = historyErr
+		}
+	}
+	return err
+}
+
+// DB returns the database the provider serves, m.db.
+func (p *Provider) DB() *DB {
+	return p.db
+}
+
+// url returns the path of the audio file of a track.
+func (p *Provider) url(track *Track) string {
+	name := filepath.FromSlash(track.Path)
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(p.dir, name)
+	}
+	return filepath.ToSlash(filepath.Clean(name))
+}
+
+// albumArt returns the album art of a track, reading it only once.
+func (p *Provider) albumArt(ctx context.Context, id int64) []byte {
+	if id == 0 {
+		return nil
+	}
+	p.artLock.Lock()
+	defer p.artLock.Unlock()
+	if art, ok := p.art[id]; ok {
+		return art
+	}
+	art, err := p.db.AlbumArt(ctx, id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		// try again next time
+		return nil
+	}
+	p.art[id] = art
+	return art
+}
+
+func (p *Provider) toTrack(ctx context.Context, track *Track) *server.Track {
+	return &server.Track{
+		ID:             strconv.FormatInt(track.ID, 10),
+		Title:          track.Title,
+		Artist:         track.Artist,
+		Album:          track.Album,
+		Genre:          track.Genre,
+		Comment:        track.Comment,
+		Label:          track.Label,
+		Composer:       track.Composer,
+		Remixer:        track.Remixer,
+		Key:            track.KeyName(),
+		BPM:            track.BPM,
+		Rating:         track.Rating,
+		Year:           track.Year,
+		Length:         track.Length,
+		DateAdded:      track.DateAdded,
+		URL:            p.url(track),
+		FileSize:       track.FileSize,
+		PreviewArtwork: p.albumArt(ctx, track.AlbumArtID),
+	}
+}
+
+// toServerError passes on ErrNotFound as server.ErrNotFound.
+func toServerError(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: %w", server.ErrNotFound, err)
+	}
+	return err
+}
+
+func toPlaylists(playlists []*Playlist) []*server.Playlist {
+	result := make([]*server.Playlist, 0, len(playlists))
+	for _, playlist := range playlists {
+		result = append(result, &server.Playlist{
+			ID:         strconv.FormatInt(playlist.ID, 10),
+			Title:      playlist.Title,
+			TrackCount: len(playlist.TrackIDs),
+			Children:   toPlaylists(playlist.Children),
+		})
+	}
+	return result
+}
+
+func findPlaylist(playlists []*Playlist, id int64) *Playlist {
+	for _, playlist := range playlists {
+		if playlist.ID == id {
+			return playlist
+		}
+		if found := findPlaylist(playlist.Children, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Libraries implements server.LibraryProvider.
+func (p *Provider) Libraries(ctx context.Context) ([]*server.Library, error) {
+	return []*server.Library{p.library}, nil
+}
+
+// Playlists implements server.LibraryProvider.
+func (p *Provider) Playlists(ctx context.Context, libraryID string) ([]*server.Playlist, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	playlists, err := p.db.Playlists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toPlaylists(playlists), nil
+}
+
+// TracksVersion implements server.VersionProvider with the data version of
+// m.db.
+func (p *Provider) TracksVersion(ctx context.Context, libraryID string) (uint64, error) {
+	if libraryID != p.library.ID {
+		return 0, server.ErrNotFound
+	}
+	return p.db.DataVersion(ctx)
+}
+
+// Tracks implements server.LibraryProvider. Tracks without a file, like
+// streaming tracks, are left out.
+func (p *Provider) Tracks(ctx context.Context, libraryID, playlistID string) ([]*server.Track, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	tracks, err := p.db.Tracks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if playlistID == "" {
+		result := make([]*server.Track, 0, len(tracks))
+		for _, track := range tracks {
+			if track.Path != "" {
+				result = append(result, p.toTrack(ctx, track))
+			}
+		}
+		return result, nil
+	}
+
+	id, err := strconv.ParseInt(playlistID, 10, 64)
+	if err != nil {
+		return nil, server.ErrNotFound
+	}
+	playlists, err := p.db.Playlists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	playlist := findPlaylist(playlists, id)
+	if playlist == nil {
+		return nil, server.ErrNotFound
+	}
+	byID := make(map[int64]*Track, len(tracks))
+	for _, track := range tracks {
+		byID[track.ID] = track
+	}
+	result := make([]*server.Track, 0, len(playlist.TrackIDs))
+	for _, trackID := range playlist.TrackIDs {
+		if track, ok := byID[trackID]; ok && track.Path != "" {
+			result = append(result, p.toTrack(ctx, track))
+		}
+	}
+	return result, nil
+}
+
+// Track implements server.LibraryProvider.
+func (p *Provider) Track(ctx context.Context, libraryID, trackID string) (*server.Track, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	id, err := strconv.ParseInt(trackID, 10, 64)
+	if err != nil {
+		return nil, server.ErrNotFound
+	}
+	track, err := p.db.Track(ctx, id)
+	if err != nil {
+		return nil, toServerError(err)
+	}
+	return p.toTrack(ctx, track), nil
+}
+
+// PerformanceData implements server.LibraryProvider.
+func (p *Provider) PerformanceData(ctx context.Context, libraryID, trackID string) (*server.PerformanceData, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	id, err := strconv.ParseInt(trackID, 10, 64)
+	if err != nil {
+		return nil, server.ErrNotFound
+	}
+	data, err := p.db.PerformanceData(ctx, id)
+	if err != nil {
+		return nil, toServerError(err)
+	}
+
+	result := &server.PerformanceData{
+		BPM:              data.BPM,
+		BeatGrid:         data.BeatGrid,
+		OverviewWaveform: data.OverviewWaveform,
+		QuickCues:        map[int]*server.QuickCue{},
+		Loops:            map[int]*server.Loop{},
+	}
+	if data.MainCue != nil {
+		result.MainCue = &server.MainCue{
+			Position:        data.MainCue.Position,
+			InitialPosition: data.MainCue.InitialPosition,
+			SetManually:     data.MainCue.SetManually,
+		}
+	}
+	for pad, cue := range data.QuickCues {
+		if cue != nil {
+			result.QuickCues[pad] = &server.QuickCue{
+				Name:     cue.Name,
+				Position: cue.Position,
+				Color:    cue.Color,
+			}
+		}
+	}
+	for pad, loop := range data.Loops {
+		if loop != nil {
+			result.Loops[pad] = &server.Loop{
+				Name:         loop.Name,
+				In:           loop.In,
+				Out:          loop.Out,
+				Color:        loop.Color,
+				ActiveOnLoad: pad == data.ActiveOnLoadLoop,
+			}
+		}
+	}
+	return result, nil
+}
+
+// OpenBlob implements server.LibraryProvider. Only the audio files of tracks
+// in the database can be opened.
+func (p *Provider) OpenBlob(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	name := filepath.Clean(filepath.FromSlash(url))
+	path := filepath.ToSlash(name)
+	if rel, err := filepath.Rel(p.dir, name); err == nil {
+		path = filepath.ToSlash(rel)
+	}
+	track, err := p.db.TrackByPath(ctx, path)
+	if err != nil {
+		return nil, toServerError(err)
+	}
+	if p.url(track) != filepath.ToSlash(name) {
+		return nil, server.ErrNotFound
+	}
+	return os.Open(name)
+}
+
+// HistorySessions implements server.HistoryProvider.
+func (p *Provider) HistorySessions(ctx context.Context, libraryID string) ([]*server.HistorySession, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	if p.history == nil {
+		return nil, nil
+	}
+	sessions, err := p.history.History(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*server.HistorySession, 0, len(sessions))
+	for _, session := range sessions {
+		s := &server.HistorySession{
+			ID:         strconv.FormatInt(session.ID, 10),
+			Title:      session.Title,
+			StartTime:  session.StartTime,
+			Timezone:   session.Timezone,
+			TrackCount: len(session.Entries),
+		}
+		if n := len(session.Entries); n > 0 && !session.StartTime.IsZero() {
+			// up to the start of the last track, which is as good as it gets
+			s.Duration = max(session.Entries[n-1].StartTime.Sub(session.StartTime), 0)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// HistoryPlayedTracks implements server.HistoryProvider. Played tracks that
+// came from m.db are reported with their ID in m.db, so devices can load
+// them.
+func (p *Provider) HistoryPlayedTracks(ctx context.Context, libraryID, sessionID string) ([]*server.PlayedTrack, error) {
+	if libraryID != p.library.ID || p.history == nil {
+		return nil, server.ErrNotFound
+	}
+	id, err := strconv.ParseInt(sessionID, 10, 64)
+	if err != nil {
+		return nil, server.ErrNotFound
+	}
+	sessions, err := p.history.History(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		if session.ID != id {
+			continue
+		}
+		result := make([]*server.PlayedTrack, 0, len(session.Entries))
+		for _, entry := range session.Entries {
+			track, err := p.playedTrack(ctx, entry.TrackID)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, &server.PlayedTrack{
+				ID:        strconv.FormatInt(entry.ID, 10),
+				Track:     track,
+				StartTime: entry.StartTime,
+			})
+		}
+		return result, nil
+	}
+	return nil, server.ErrNotFound
+}
+
+// playedTrack returns the track of a history entry, from m.db if that is
+// where it came from.
+func (p *Provider) playedTrack(ctx context.Context, historyTrackID int64) (*server.Track, error) {
+	track, err := p.history.Track(ctx, historyTrackID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if track.OriginDatabaseUUID == p.library.ID && track.OriginTrackID > 0 {
+		if original, err := p.db.Track(ctx, track.OriginTrackID); err == nil {
+			track = original
+		}
+	}
+	return p.toTrack(ctx, track), nil
+}
@@ -0,0 +1,98 @@
+package enginedb
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/icedream/go-stagelinq/eaas/server"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Provider(t *testing.T) {
+	dir := createTestLibrary(t)
+	ctx := context.Background()
+
+	p, err := NewProvider(dir, nil)
+	require.NoError(t, err)
+	defer p.Close()
+
+	libraries, err := p.Libraries(ctx)
+	require.NoError(t, err)
+	require.Len(t, libraries, 1)
+	require.Equal(t, testDatabaseUUID, libraries[0].ID)
+	require.Equal(t, "Engine Library", libraries[0].Title)
+
+	_, err = p.Playlists(ctx, "other")
+	require.ErrorIs(t, err, server.ErrNotFound)
+	playlists, err := p.Playlists(ctx, testDatabaseUUID)
+	require.NoError(t, err)
+	require.Len(t, playlists, 2)
+	require.Equal(t, "Techno", playlists[1].Title)
+	require.Equal(t, 2, playlists[1].TrackCount)
+	require.Equal(t, "B", playlists[0].Children[0].Title)
+
+	// streaming tracks are left out
+	tracks, err := p.Tracks(ctx, testDatabaseUUID, "")
+	require.NoError(t, err)
+	require.Len(t, tracks, 2)
+	whiplash := tracks[0]
+	require.Equal(t, "1", whiplash.ID)
+	require.Equal(t, "Am", whiplash.Key)
+	require.Equal(t, []byte("PNG"), whiplash.PreviewArtwork)
+	musicDir := filepath.ToSlash(filepath.Join(filepath.Dir(dir), "Music"))
+	require.Equal(t, musicDir+"/whiplash.mp3", whiplash.URL)
+
+	tracks, err = p.Tracks(ctx, testDatabaseUUID, playlists[1].ID)
+	require.NoError(t, err)
+	require.Len(t, tracks, 2)
+	require.Equal(t, "Other Song", tracks[0].Title)
+	require.Equal(t, "Whiplash", tracks[1].Title)
+	_, err = p.Tracks(ctx, testDatabaseUUID, "99")
+	require.ErrorIs(t, err, server.ErrNotFound)
+
+	_, err = p.TracksVersion(ctx, testDatabaseUUID)
+	require.NoError(t, err)
+	_, err = p.TracksVersion(ctx, "other")
+	require.ErrorIs(t, err, server.ErrNotFound)
+
+	_, err = p.Track(ctx, testDatabaseUUID, "42")
+	require.ErrorIs(t, err, server.ErrNotFound)
+
+	data, err := p.PerformanceData(ctx, testDatabaseUUID, "1")
+	require.NoError(t, err)
+	require.Equal(t, 140.02, data.BPM)
+	require.Equal(t, "Drop", data.QuickCues[1].Name)
+	require.NotContains(t, data.QuickCues, 0)
+	require.True(t, data.Loops[1].ActiveOnLoad)
+	require.Equal(t, 1000.0, data.MainCue.Position)
+
+	blob, err := p.OpenBlob(ctx, whiplash.URL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(blob)
+	blob.Close()
+	require.NoError(t, err)
+	require.Equal(t, "ID3 whiplash", string(b))
+
+	// files next to tracks are not served unless they are tracks themselves
+	_, err = p.OpenBlob(ctx, musicDir+"/secret.mp3")
+	require.ErrorIs(t, err, server.ErrNotFound)
+
+	sessions, err := p.HistorySessions(ctx, testDatabaseUUID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, "Friday", sessions[0].Title)
+	require.Equal(t, 2, sessions[0].TrackCount)
+	require.Equal(t, 300.0, sessions[0].Duration.Seconds())
+
+	played, err := p.HistoryPlayedTracks(ctx, testDatabaseUUID, sessions[0].ID)
+	require.NoError(t, err)
+	require.Len(t, played, 2)
+	// tracks from m.db are reported as they are in m.db
+	require.Equal(t, "1", played[0].Track.ID)
+	require.Equal(t, "Whiplash", played[0].Track.Title)
+	require.Equal(t, "Gone", played[1].Track.Title)
+	_, err = p.HistoryPlayedTracks(ctx, testDatabaseUUID, "2")
+	require.ErrorIs(t, err, server.ErrNotFound)
+}
@@ -0,0 +1,248 @@
+package enginedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/musickey"
+	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// KeyName returns the name of a key as stored in the key column of the Track
+// table, or an empty string if the key is unknown. See musickey.FromEngineIndex
+// for the key itself.
+func KeyName(key int) string {
+	k, ok := musickey.FromEngineIndex(key)
+	if !ok {
+		return ""
+	}
+	return k.String()
+}
+
+// Track is a row of the Track table.
+type Track struct {
+	ID int64
+
+	// Path is the path of the audio file relative to the Engine Library
+	// folder, with forward slashes. It is empty for streaming tracks.
+	Path     string
+	Filename string
+	FileType string
+	FileSize int64
+
+	Title    string
+	Artist   string
+	Album    string
+	Genre    string
+	Comment  string
+	Label    string
+	Composer string
+	Remixer  string
+	Year     int
+
+	// Key is the key index, see KeyName, or -1 if unknown.
+	Key int
+
+	// BPM is the analyzed BPM, or the tagged one if the track was not
+	// analyzed.
+	BPM float64
+
+	// Rating goes from 0 to 100 in steps of 20 per star.
+	Rating int
+
+	Length  time.Duration
+	Bitrate int
+
+	// AlbumArtID references the AlbumArt table, 0 if the track has no art.
+	AlbumArtID int64
+
+	DateAdded      time.Time
+	DateCreated    time.Time
+	TimeLastPlayed time.Time
+
+	IsPlayed    bool
+	IsAnalyzed  bool
+	IsAvailable bool
+
+	// OriginDatabaseUUID and OriginTrackID tell where a track was copied
+	// from, for example in hm.db, where they point to the track in m.db.
+	OriginDatabaseUUID string
+	OriginTrackID      int64
+}
+
+// KeyName returns the name of the key of the track.
+func (t *Track) KeyName() string {
+	return KeyName(t.Key)
+}
+
+// Metadata returns the track as sent to devices.
+func (t *Track) Metadata() *enginelibrary.TrackMetadata {
+	m := &enginelibrary.TrackMetadata{
+		Id:       proto.String(strconv.FormatInt(t.ID, 10)),
+		Title:    optionalString(t.Title),
+		Artist:   optionalString(t.Artist),
+		Album:    optionalString(t.Album),
+		Genre:    optionalString(t.Genre),
+		Comment:  optionalString(t.Comment),
+		Label:    optionalString(t.Label),
+		Composer: optionalString(t.Composer),
+		Remixer:  optionalString(t.Remixer),
+		Key:      optionalString(t.KeyName()),
+	}
+	if t.BPM > 0 {
+		m.Bpm = proto.Float64(t.BPM)
+	}
+	if t.Rating > 0 {
+		m.Rating = proto.Uint32(uint32(t.Rating))
+	}
+	if t.Year > 0 {
+		m.Year = proto.Uint32(uint32(t.Year))
+	}
+	if t.Length > 0 {
+		m.LengthSeconds = proto.Uint32(uint32(t.Length.Seconds()))
+	}
+	if !t.DateAdded.IsZero() {
+		m.DateAdded = timestamppb.New(t.DateAdded)
+	}
+	return m
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return proto.String(s)
+}
+
+// trackColumns are the columns of the Track table read into a Track, in the
+// order scanTrack expects them.
+var trackColumns = []string{
+	"id", "path", "filename", "fileType", "fileBytes",
+	"title", "artist", "album", "genre", "comment", "label", "composer", "remixer", "year",
+	"key", "bpm", "bpmAnalyzed", "rating", "length", "bitrate", "albumArtId",
+	"dateAdded", "dateCreated", "timeLastPlayed",
+	"isPlayed", "isAnalyzed", "isAvailable",
+	"originDatabaseUuid", "originTrackId",
+}
+
+func (d *DB) trackQuery() string {
+	return `SELECT ` + d.selectColumns("Track", trackColumns...) + ` FROM Track`
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTrack(row scanner) (*Track, error) {
+	var (
+		t                                            Track
+		path, filename, fileType                     sql.NullString
+		title, artist, album, genre, comment         sql.NullString
+		label, composer, remixer, originDatabaseUUID sql.NullString
+		fileBytes, year, key, rating, albumArtID     sql.NullInt64
+		originTrackID                                sql.NullInt64
+		bpm, bpmAnalyzed, length, bitrate            sql.NullFloat64
+		dateAdded, dateCreated, timeLastPlayed       interface{}
+		isPlayed, isAnalyzed, isAvailable            sql.NullBool
+	)
+	err := row.Scan(&t.ID, &path, &filename, &fileType, &fileBytes,
+		&title, &artist, &album, &genre, &comment, &label, &composer, &remixer, &year,
+		&key, &bpm, &bpmAnalyzed, &rating, &length, &bitrate, &albumArtID,
+		&dateAdded, &dateCreated, &timeLastPlayed,
+		&isPlayed, &isAnalyzed, &isAvailable,
+		&originDatabaseUUID, &originTrackID)
+	if err != nil {
+		return nil, err
+	}
+	t.Path = path.String
+	t.Filename = filename.String
+	t.FileType = fileType.String
+	t.FileSize = fileBytes.Int64
+	t.Title = title.String
+	t.Artist = artist.String
+	t.Album = album.String
+	t.Genre = genre.String
+	t.Comment = comment.String
+	t.Label = label.String
+	t.Composer = composer.String
+	t.Remixer = remixer.String
+	t.Year = int(year.Int64)
+	t.Key = -1
+	if key.Valid {
+		t.Key = int(key.Int64)
+	}
+	t.BPM = bpmAnalyzed.Float64
+	if t.BPM <= 0 {
+		t.BPM = bpm.Float64
+	}
+	t.Rating = int(rating.Int64)
+	t.Length = time.Duration(length.Float64 * float64(time.Second))
+	t.Bitrate = int(bitrate.Float64)
+	t.AlbumArtID = albumArtID.Int64
+	t.DateAdded = parseTime(dateAdded)
+	t.DateCreated = parseTime(dateCreated)
+	t.TimeLastPlayed = parseTime(timeLastPlayed)
+	t.IsPlayed = isPlayed.Bool
+	t.IsAnalyzed = isAnalyzed.Bool
+	// older schemas lack the column, their tracks are all available
+	t.IsAvailable = isAvailable.Bool || !isAvailable.Valid
+	t.OriginDatabaseUUID = originDatabaseUUID.String
+	t.OriginTrackID = originTrackID.Int64
+	return &t, nil
+}
+
+// Tracks returns all tracks of the database ordered by ID.
+func (d *DB) Tracks(ctx context.Context) (tracks []*Track, err error) {
+	rows, err := d.db.QueryContext(ctx, d.trackQuery()+` ORDER BY id`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		track, err := scanTrack(rows)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	err = rows.Err()
+	return
+}
+
+// Track returns a single track.
+func (d *DB) Track(ctx context.Context, id int64) (*Track, error) {
+	track, err := scanTrack(d.db.QueryRowContext(ctx, d.trackQuery()+` WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("track %d: %w", id, ErrNotFound)
+	}
+	return track, err
+}
+
+// AlbumArt returns the encoded image with the given ID from the AlbumArt
+// table.
+func (d *DB) AlbumArt(ctx context.Context, id int64) (art []byte, err error) {
+	if !d.hasTable("AlbumArt") {
+		return nil, fmt.Errorf("album art %d: %w", id, ErrNotFound)
+	}
+	err = d.db.QueryRowContext(ctx, `SELECT albumArt FROM AlbumArt WHERE id = ?`, id).Scan(&art)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && len(art) == 0) {
+		return nil, fmt.Errorf("album art %d: %w", id, ErrNotFound)
+	}
+	return
+}
+
+// TrackByPath returns the track with the given path relative to the Engine
+// Library folder.
+func (d *DB) TrackByPath(ctx context.Context, path string) (*Track, error) {
+	track, err := scanTrack(d.db.QueryRowContext(ctx, d.trackQuery()+` WHERE path = ? ORDER BY id LIMIT 1`, path))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("track %q: %w", path, ErrNotFound)
+	}
+	return track, err
+}
@@ -28,7 +28,7 @@ func (m *eaasDiscoveryRequestMessage) CheckMatch(r *bufio.Reader) (ok bool, err
 
 func (m *eaasDiscoveryRequestMessage) ReadMessageFrom(r io.Reader) (err error) {
 	readMagic := make([]byte, 6)
-	if _, err = r.Read(readMagic); err != nil {
+	if err = messages.ReadFull(r, readMagic); err != nil {
 		return err
 	} else if !bytes.Equal(readMagic, append(eaasDiscoveryMagic, 1, 0)) {
 		err = ErrInvalidMessageReceived
@@ -55,7 +55,7 @@ func (m *eaasDiscoveryResponseMessage) CheckMatch(r *bufio.Reader) (ok bool, err
 
 func (m *eaasDiscoveryResponseMessage) ReadMessageFrom(r io.Reader) (err error) {
 	readMagic := make([]byte, 6)
-	if _, err = r.Read(readMagic); err != nil {
+	if err = messages.ReadFull(r, readMagic); err != nil {
 		return err
 	} else if !bytes.Equal(readMagic, append(eaasDiscoveryMagic, 1, 1)) {
 		err = ErrInvalidMessageReceived
@@ -74,7 +74,7 @@ func (m *eaasDiscoveryResponseMessage) ReadMessageFrom(r io.Reader) (err error)
 		return fmt.Errorf("failed to read software version string: %w", err)
 	}
 	// TODO - there is an extra 0x01 here which idk what to do with
-	if _, err = r.Read([]byte{0x01}); err != nil {
+	if err = messages.ReadFull(r, make([]byte, 1)); err != nil {
 		return err
 	}
 	// TODO - is this really a string?
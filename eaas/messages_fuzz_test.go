@@ -0,0 +1,52 @@
+package eaas
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/icedream/go-stagelinq/internal/messages"
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzMessage feeds arbitrary bytes to the message type created by create and
+// checks that parsing never panics and that any successfully parsed message
+// survives a decode→encode→decode round trip unchanged.
+func fuzzMessage(f *testing.F, create func() messages.Message) {
+	messageType := reflect.TypeOf(create())
+	for _, test := range testMessages {
+		if reflect.TypeOf(test.Message) == messageType {
+			f.Add(test.Bytes)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		// must not panic regardless of outcome
+		_, _ = create().CheckMatch(bufio.NewReader(bytes.NewReader(b)))
+
+		m := create()
+		if err := m.ReadMessageFrom(messages.NewFrameReader(b, messages.DefaultLimits)); err != nil {
+			return
+		}
+
+		encoded := new(bytes.Buffer)
+		require.NoError(t, m.WriteMessageTo(encoded))
+
+		m2 := create()
+		require.NoError(t, m2.ReadMessageFrom(bytes.NewReader(encoded.Bytes())))
+		require.Equal(t, m, m2)
+
+		reencoded := new(bytes.Buffer)
+		require.NoError(t, m2.WriteMessageTo(reencoded))
+		require.Equal(t, encoded.Bytes(), reencoded.Bytes())
+	})
+}
+
+func FuzzEAASDiscoveryRequestMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(eaasDiscoveryRequestMessage) })
+}
+
+func FuzzEAASDiscoveryResponseMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(eaasDiscoveryResponseMessage) })
+}
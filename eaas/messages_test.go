@@ -94,3 +94,15 @@ func Test_Messages_CheckMatch(t *testing.T) {
 		})
 	}
 }
+
+func Test_Messages_ReadTruncated(t *testing.T) {
+	for _, test := range testMessages {
+		def := test
+		t.Run(test.Name, func(t *testing.T) {
+			b := def.Bytes[:len(def.Bytes)-1]
+			m := def.CreateMessage()
+			err := m.ReadMessageFrom(messages.NewFrameReader(b, messages.DefaultLimits))
+			require.ErrorIs(t, err, ErrTruncatedFrame)
+		})
+	}
+}
@@ -0,0 +1,13 @@
+/*
+This package parses the musical keys of tracks as written by DJ software and
+tags, and tells which keys mix harmonically.
+
+Keys are accepted as note names ("Am", "F#", "Bb minor"), Camelot codes
+("8A") and Open Key codes ("1m"), so tracks tagged by different programs can
+be compared.
+
+	a, _ := musickey.Parse("Am")
+	b, _ := musickey.Parse("9A")
+	a.Compatible(b) // true, Em is a fifth above Am
+*/
+package musickey
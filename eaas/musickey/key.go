@@ -0,0 +1,168 @@
+package musickey
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownKey is returned by Parse for keys it can't make sense of.
+var ErrUnknownKey = errors.New("unknown key")
+
+// names lists the key names Engine DJ shows, in Camelot order starting at
+// 8B, major before minor. This is also the order of the key indices Engine DJ
+// stores in its databases and publishes via StagelinQ.
+var names = []string{
+	"C", "Am", "G", "Em", "D", "Bm", "A", "F#m",
+	"E", "C#m", "B", "G#m", "F#", "D#m", "Db", "Bbm",
+	"Ab", "Fm", "Eb", "Cm", "Bb", "Gm", "F", "Dm",
+}
+
+// notes maps note letters to pitch classes.
+var notes = map[byte]int{
+	'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11,
+}
+
+// Key is a musical key.
+type Key struct {
+	// Tonic is the pitch class of the root note, 0 for C up to 11 for B.
+	Tonic int
+
+	Minor bool
+}
+
+// Parse parses a key given as note name, Camelot code or Open Key code.
+// Letter case and spaces are ignored, except for the "m" of minor keys.
+func Parse(s string) (k Key, err error) {
+	s = strings.Join(strings.Fields(s), "")
+	if s == "" {
+		return k, ErrUnknownKey
+	}
+
+	// Camelot and Open Key codes are a number from 1 to 12 and a letter
+	if s[0] >= '0' && s[0] <= '9' {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n < 1 || n > 12 {
+			return k, ErrUnknownKey
+		}
+		switch strings.ToUpper(s[len(s)-1:]) {
+		case "A":
+			return fromCamelot(n, true), nil
+		case "B":
+			return fromCamelot(n, false), nil
+		case "M":
+			return fromCamelot((n+6)%12+1, true), nil
+		case "D":
+			return fromCamelot((n+6)%12+1, false), nil
+		}
+		return k, ErrUnknownKey
+	}
+
+	tonic, ok := notes[strings.ToUpper(s[:1])[0]]
+	if !ok {
+		return k, ErrUnknownKey
+	}
+	rest := s[1:]
+	switch {
+	case strings.HasPrefix(rest, "#"):
+		tonic, rest = tonic+1, rest[1:]
+	case strings.HasPrefix(rest, "♯"):
+		tonic, rest = tonic+1, rest[len("♯"):]
+	case strings.HasPrefix(rest, "b"):
+		tonic, rest = tonic+11, rest[1:]
+	case strings.HasPrefix(rest, "♭"):
+		tonic, rest = tonic+11, rest[len("♭"):]
+	}
+	k.Tonic = tonic % 12
+	switch strings.ToLower(rest) {
+	case "", "maj", "major", "dur":
+	case "min", "minor", "moll":
+		k.Minor = true
+	default:
+		// "m" is minor, "M" is major
+		switch rest {
+		case "m":
+			k.Minor = true
+		case "M":
+		default:
+			return Key{}, ErrUnknownKey
+		}
+	}
+	return k, nil
+}
+
+// FromEngineIndex returns the key of a key index as stored in the key column
+// of Engine library databases and published in the KeyIndex state value. ok
+// is false for indices outside 0 to 23, which Engine DJ uses for unknown
+// keys.
+func FromEngineIndex(index int) (k Key, ok bool) {
+	if index < 0 || index >= len(names) {
+		return
+	}
+	return fromCamelot((index/2+7)%12+1, index%2 == 1), true
+}
+
+func fromCamelot(n int, minor bool) Key {
+	// each step on the wheel is a fifth, which is 7 semitones up
+	offset := 8
+	if minor {
+		offset = 5
+	}
+	return Key{Tonic: ((n-offset)*7%12 + 12) % 12, Minor: minor}
+}
+
+// Camelot returns the number of the key on the Camelot wheel, 1 to 12.
+func (k Key) Camelot() int {
+	offset := 8
+	if k.Minor {
+		offset = 5
+	}
+	if n := (k.Tonic*7 + offset) % 12; n > 0 {
+		return n
+	}
+	return 12
+}
+
+// CamelotCode returns the key as Camelot code, for example "8A" for A minor.
+func (k Key) CamelotCode() string {
+	if k.Minor {
+		return strconv.Itoa(k.Camelot()) + "A"
+	}
+	return strconv.Itoa(k.Camelot()) + "B"
+}
+
+// OpenKey returns the key as Open Key code, for example "1m" for A minor.
+func (k Key) OpenKey() string {
+	n := (k.Camelot()+4)%12 + 1
+	if k.Minor {
+		return strconv.Itoa(n) + "m"
+	}
+	return strconv.Itoa(n) + "d"
+}
+
+// EngineIndex returns the index of the key as used by Engine DJ, see
+// FromEngineIndex.
+func (k Key) EngineIndex() int {
+	i := (k.Camelot() + 4) % 12 * 2
+	if k.Minor {
+		i++
+	}
+	return i
+}
+
+// String returns the key name as shown by Engine DJ, for example "Am".
+func (k Key) String() string {
+	return names[k.EngineIndex()]
+}
+
+// Compatible reports whether two keys mix harmonically, which is the case
+// for the same key, keys a fifth apart and relative major and minor keys.
+// These are the neighbours of a key on the Camelot wheel.
+func (k Key) Compatible(other Key) bool {
+	a, b := k.Camelot(), other.Camelot()
+	if k.Minor != other.Minor {
+		return a == b
+	}
+	d := (a - b + 12) % 12
+	return d == 0 || d == 1 || d == 11
+}
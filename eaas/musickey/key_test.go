@@ -0,0 +1,93 @@
+package musickey
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Parse(t *testing.T) {
+	for s, expected := range map[string]Key{
+		"Am":       {Tonic: 9, Minor: true},
+		"A min":    {Tonic: 9, Minor: true},
+		"a minor":  {Tonic: 9, Minor: true},
+		"8A":       {Tonic: 9, Minor: true},
+		"1m":       {Tonic: 9, Minor: true},
+		"C":        {Tonic: 0},
+		"CM":       {Tonic: 0},
+		"C major":  {Tonic: 0},
+		"8b":       {Tonic: 0},
+		"1d":       {Tonic: 0},
+		"F#m":      {Tonic: 6, Minor: true},
+		"Gbm":      {Tonic: 6, Minor: true},
+		"G♭m":      {Tonic: 6, Minor: true},
+		"11A":      {Tonic: 6, Minor: true},
+		"Db":       {Tonic: 1},
+		"C#":       {Tonic: 1},
+		"3B":       {Tonic: 1},
+		"Cb":       {Tonic: 11},
+		"12B":      {Tonic: 4},
+		"6m":       {Tonic: 8, Minor: true},
+		" Bb min ": {Tonic: 10, Minor: true},
+	} {
+		k, err := Parse(s)
+		require.NoError(t, err, s)
+		require.Equal(t, expected, k, s)
+	}
+
+	for _, s := range []string{"", "H", "13A", "0B", "8C", "Am7", "A#x"} {
+		_, err := Parse(s)
+		require.ErrorIs(t, err, ErrUnknownKey, s)
+	}
+}
+
+func Test_Key_Names(t *testing.T) {
+	// every key survives a round trip through all notations
+	for i, name := range names {
+		k, err := Parse(name)
+		require.NoError(t, err)
+		require.Equal(t, name, k.String())
+		require.Equal(t, (i/2+7)%12+1, k.Camelot(), name)
+		for _, code := range []string{k.CamelotCode(), k.OpenKey()} {
+			parsed, err := Parse(code)
+			require.NoError(t, err)
+			require.Equal(t, k, parsed, code)
+		}
+	}
+	k, _ := Parse("Am")
+	require.Equal(t, "8A", k.CamelotCode())
+	require.Equal(t, "1m", k.OpenKey())
+}
+
+func Test_Key_EngineIndex(t *testing.T) {
+	for i := range 24 {
+		k, ok := FromEngineIndex(i)
+		require.True(t, ok, i)
+		require.Equal(t, i, k.EngineIndex())
+		require.Equal(t, names[i], k.String())
+	}
+	k, ok := FromEngineIndex(1)
+	require.True(t, ok)
+	require.Equal(t, Key{Tonic: 9, Minor: true}, k)
+	for _, i := range []int{-1, 24} {
+		_, ok := FromEngineIndex(i)
+		require.False(t, ok, i)
+	}
+}
+
+func Test_Key_Compatible(t *testing.T) {
+	am, _ := Parse("Am")
+	for _, s := range []string{"Am", "Em", "Dm", "C"} {
+		k, _ := Parse(s)
+		require.True(t, am.Compatible(k), s)
+		require.True(t, k.Compatible(am), s)
+	}
+	for _, s := range []string{"Bm", "G", "F", "A", "Cm"} {
+		k, _ := Parse(s)
+		require.False(t, am.Compatible(k), s)
+	}
+	// the wheel wraps around
+	first, _ := Parse("1A")
+	last, _ := Parse("12A")
+	require.True(t, first.Compatible(last))
+}
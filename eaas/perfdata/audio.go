@@ -0,0 +1,244 @@
+package perfdata
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/mewkiz/flac"
+)
+
+// ErrUnsupportedFormat is returned by OpenAudio for audio formats there is no
+// decoder for, like AAC.
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// Audio is a decoded audio stream, mixed down to mono.
+type Audio interface {
+	// SampleRate returns the number of samples per second.
+	SampleRate() float64
+
+	// ReadSamples reads the next samples into buf, scaled to the range -1 to
+	// 1. It returns io.EOF at the end of the stream.
+	ReadSamples(buf []float64) (n int, err error)
+}
+
+// OpenAudio returns a decoder for the audio file read by r. The format is
+// told by the extension of the file name: WAV, FLAC and MP3 files can be
+// decoded.
+func OpenAudio(r io.ReadSeeker, name string) (Audio, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".wav", ".wave":
+		return newWAVAudio(r)
+	case ".flac":
+		return newFLACAudio(r)
+	case ".mp3":
+		return newMP3Audio(r)
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrUnsupportedFormat)
+}
+
+// wavAudio decodes PCM and IEEE float WAV files.
+type wavAudio struct {
+	r          io.Reader
+	sampleRate float64
+	channels   int
+	bits       int
+	float      bool
+	frame      []byte
+}
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xfffe
+)
+
+func newWAVAudio(r io.Reader) (*wavAudio, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a WAV file: %w", ErrUnsupportedFormat)
+	}
+
+	a := &wavAudio{}
+	format := 0
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, fmt.Errorf("WAV file without data: %w", err)
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			if size < 16 || size > 1024 {
+				return nil, errors.New("invalid WAV format chunk")
+			}
+			fmtChunk := make([]byte, size)
+			if _, err := io.ReadFull(r, fmtChunk); err != nil {
+				return nil, err
+			}
+			format = int(binary.LittleEndian.Uint16(fmtChunk))
+			a.channels = int(binary.LittleEndian.Uint16(fmtChunk[2:]))
+			a.sampleRate = float64(binary.LittleEndian.Uint32(fmtChunk[4:]))
+			a.bits = int(binary.LittleEndian.Uint16(fmtChunk[14:]))
+			if format == wavFormatExtensible && size >= 26 {
+				format = int(binary.LittleEndian.Uint16(fmtChunk[24:]))
+			}
+		case "data":
+			if format == 0 {
+				return nil, errors.New("WAV data before format")
+			}
+			a.float = format == wavFormatFloat
+			if (format != wavFormatPCM && format != wavFormatFloat) ||
+				a.channels == 0 || a.sampleRate == 0 ||
+				a.bits%8 != 0 || a.bits == 0 || a.bits > 32 || (a.float && a.bits != 32) {
+				return nil, fmt.Errorf("WAV format %d with %d bits: %w", format, a.bits, ErrUnsupportedFormat)
+			}
+			a.r = io.LimitReader(r, size)
+			a.frame = make([]byte, a.channels*a.bits/8)
+			return a, nil
+		default:
+			// chunks are padded to an even size
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (a *wavAudio) SampleRate() float64 {
+	return a.sampleRate
+}
+
+// sample converts a little endian sample to the range -1 to 1.
+func (a *wavAudio) sample(b []byte) float64 {
+	switch {
+	case a.float:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case a.bits == 8:
+		// 8-bit samples are unsigned
+		return (float64(b[0]) - 128) / 128
+	}
+	var v int32
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | int32(b[i])
+	}
+	// sign extend
+	shift := 32 - a.bits
+	v = v << shift >> shift
+	return float64(v) / float64(int64(1)<<(a.bits-1))
+}
+
+func (a *wavAudio) ReadSamples(buf []float64) (n int, err error) {
+	size := a.bits / 8
+	for n < len(buf) {
+		if _, err = io.ReadFull(a.r, a.frame); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = io.EOF
+			}
+			return
+		}
+		var sum float64
+		for c := 0; c < a.channels; c++ {
+			sum += a.sample(a.frame[c*size : (c+1)*size])
+		}
+		buf[n] = sum / float64(a.channels)
+		n++
+	}
+	return
+}
+
+// flacAudio decodes FLAC files frame by frame.
+type flacAudio struct {
+	stream  *flac.Stream
+	scale   float64
+	pending []float64
+}
+
+func newFLACAudio(r io.Reader) (*flacAudio, error) {
+	stream, err := flac.New(r)
+	if err != nil {
+		return nil, err
+	}
+	return &flacAudio{
+		stream: stream,
+		scale:  float64(int64(1) << (stream.Info.BitsPerSample - 1)),
+	}, nil
+}
+
+func (a *flacAudio) SampleRate() float64 {
+	return float64(a.stream.Info.SampleRate)
+}
+
+func (a *flacAudio) ReadSamples(buf []float64) (n int, err error) {
+	for n < len(buf) {
+		if len(a.pending) == 0 {
+			frame, err := a.stream.ParseNext()
+			if err != nil {
+				return n, err
+			}
+			channels := len(frame.Subframes)
+			if channels == 0 {
+				continue
+			}
+			blockSize := len(frame.Subframes[0].Samples)
+			a.pending = make([]float64, blockSize)
+			for _, subframe := range frame.Subframes {
+				for i, v := range subframe.Samples[:blockSize] {
+					a.pending[i] += float64(v) / a.scale / float64(channels)
+				}
+			}
+		}
+		copied := copy(buf[n:], a.pending)
+		a.pending = a.pending[copied:]
+		n += copied
+	}
+	return
+}
+
+// mp3Audio decodes MP3 files, which the decoder turns into 16-bit stereo.
+type mp3Audio struct {
+	decoder *mp3.Decoder
+	raw     []byte
+}
+
+func newMP3Audio(r io.Reader) (*mp3Audio, error) {
+	decoder, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	return &mp3Audio{decoder: decoder}, nil
+}
+
+func (a *mp3Audio) SampleRate() float64 {
+	return float64(a.decoder.SampleRate())
+}
+
+func (a *mp3Audio) ReadSamples(buf []float64) (n int, err error) {
+	const frameSize = 4
+	if cap(a.raw) < len(buf)*frameSize {
+		a.raw = make([]byte, len(buf)*frameSize)
+	}
+	raw := a.raw[:len(buf)*frameSize]
+	read, err := io.ReadFull(a.decoder, raw)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	for i := 0; i+frameSize <= read; i += frameSize {
+		left := int16(binary.LittleEndian.Uint16(raw[i:]))
+		right := int16(binary.LittleEndian.Uint16(raw[i+2:]))
+		buf[n] = (float64(left) + float64(right)) / 2 / 32768
+		n++
+	}
+	if err == nil && n == 0 {
+		err = io.EOF
+	}
+	return
+}
@@ -0,0 +1,184 @@
+package perfdata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// beatMarkerSize is the encoded size of a BeatMarker.
+const beatMarkerSize = 24
+
+// ErrInvalidBeatGrid is returned for beat grids that can't be encoded or
+// built.
+var ErrInvalidBeatGrid = errors.New("invalid beat grid")
+
+// BeatMarker anchors a beat of a beat grid to a position in the track. Beats
+// between two markers are spread evenly.
+type BeatMarker struct {
+	// SampleOffset is the position of the beat in samples. It may be
+	// negative or past the end of the track.
+	SampleOffset float64
+
+	// BeatNumber counts beats from the first downbeat, which is beat 0.
+	BeatNumber int64
+
+	// BeatsUntilNext is the number of beats until the next marker, 0 for the
+	// last marker.
+	BeatsUntilNext int32
+
+	// Unknown is kept as is so grids survive a round trip unchanged.
+	Unknown int32
+}
+
+// BeatGrid is the beat grid of a track.
+type BeatGrid struct {
+	SampleRate  float64
+	SampleCount float64
+
+	// IsSet is set once the track was analyzed.
+	IsSet bool
+
+	// DefaultMarkers is the grid as found by the analysis.
+	DefaultMarkers []BeatMarker
+
+	// Markers is the grid as adjusted by the DJ, the one players use.
+	Markers []BeatMarker
+}
+
+// DecodeBeatGrid decodes a compressed or uncompressed beat grid blob.
+//
+// The uncompressed blob holds the sample rate and sample count as big endian
+// doubles and a flag byte, followed by the default and the adjusted grid.
+// Each grid is a big endian marker count followed by the markers, which are
+// little endian.
+func DecodeBeatGrid(b []byte) (*BeatGrid, error) {
+	b, err := uncompressed(b)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(b)
+	g := new(BeatGrid)
+	var isSet uint8
+	for _, v := range []interface{}{&g.SampleRate, &g.SampleCount, &isSet} {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return nil, fmt.Errorf("beat grid header: %w", err)
+		}
+	}
+	g.IsSet = isSet != 0
+	if g.DefaultMarkers, err = readBeatMarkers(r); err != nil {
+		return nil, fmt.Errorf("default beat grid: %w", err)
+	}
+	if g.Markers, err = readBeatMarkers(r); err != nil {
+		return nil, fmt.Errorf("adjusted beat grid: %w", err)
+	}
+	return g, nil
+}
+
+func readBeatMarkers(r *bytes.Reader) ([]BeatMarker, error) {
+	var n int64
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	if n < 0 || n > int64(r.Len()/beatMarkerSize) {
+		return nil, fmt.Errorf("invalid marker count %d", n)
+	}
+	markers := make([]BeatMarker, n)
+	if err := binary.Read(r, binary.LittleEndian, markers); err != nil {
+		return nil, err
+	}
+	return markers, nil
+}
+
+// Encode returns the compressed beat grid blob.
+func (g *BeatGrid) Encode() []byte {
+	buf := new(bytes.Buffer)
+	isSet := uint8(0)
+	if g.IsSet {
+		isSet = 1
+	}
+	_ = binary.Write(buf, binary.BigEndian, g.SampleRate)
+	_ = binary.Write(buf, binary.BigEndian, g.SampleCount)
+	buf.WriteByte(isSet)
+	for _, markers := range [][]BeatMarker{g.DefaultMarkers, g.Markers} {
+		_ = binary.Write(buf, binary.BigEndian, int64(len(markers)))
+		_ = binary.Write(buf, binary.LittleEndian, markers)
+	}
+	return Compress(buf.Bytes())
+}
+
+// ConstantBeatGrid builds the grid of a track with a constant tempo. The
+// first downbeat is given in samples. Like the grids of Engine DJ, it has a
+// marker at or before the start of the track and one at or past its end.
+func ConstantBeatGrid(bpm, firstDownbeat, sampleRate, sampleCount float64) (*BeatGrid, error) {
+	if bpm <= 0 || sampleRate <= 0 || sampleCount <= 0 || math.IsNaN(firstDownbeat) || math.IsInf(firstDownbeat, 0) {
+		return nil, ErrInvalidBeatGrid
+	}
+	samplesPerBeat := sampleRate * 60 / bpm
+
+	// the number of whole beats before the first downbeat and between it and
+	// the end of the track
+	before := int64(math.Ceil(firstDownbeat / samplesPerBeat))
+	after := int64(math.Ceil((sampleCount - firstDownbeat) / samplesPerBeat))
+	if after <= -before {
+		// the track has to span at least one beat
+		after = -before + 1
+	}
+	markers := []BeatMarker{
+		{
+			SampleOffset:   firstDownbeat - float64(before)*samplesPerBeat,
+			BeatNumber:     -before,
+			BeatsUntilNext: int32(before + after),
+		},
+		{
+			SampleOffset: firstDownbeat + float64(after)*samplesPerBeat,
+			BeatNumber:   after,
+		},
+	}
+	return &BeatGrid{
+		SampleRate:     sampleRate,
+		SampleCount:    sampleCount,
+		IsSet:          true,
+		DefaultMarkers: markers,
+		Markers:        append([]BeatMarker(nil), markers...),
+	}, nil
+}
+
+// span returns the markers around a sample offset, extrapolating the first
+// or last section of the grid for offsets outside of it.
+func (g *BeatGrid) span(sampleOffset float64) (from, to BeatMarker, ok bool) {
+	if len(g.Markers) < 2 {
+		return
+	}
+	i := 1
+	for i < len(g.Markers)-1 && g.Markers[i].SampleOffset <= sampleOffset {
+		i++
+	}
+	from, to = g.Markers[i-1], g.Markers[i]
+	ok = to.SampleOffset > from.SampleOffset && to.BeatNumber > from.BeatNumber
+	return
+}
+
+// BPM returns the tempo at a sample offset, or 0 if the grid has less than
+// two markers.
+func (g *BeatGrid) BPM(sampleOffset float64) float64 {
+	from, to, ok := g.span(sampleOffset)
+	if !ok {
+		return 0
+	}
+	beats := float64(to.BeatNumber - from.BeatNumber)
+	return beats * 60 * g.SampleRate / (to.SampleOffset - from.SampleOffset)
+}
+
+// Beat returns the beat at a sample offset, counted from the first downbeat.
+// It returns NaN if the grid has less than two markers.
+func (g *BeatGrid) Beat(sampleOffset float64) float64 {
+	from, to, ok := g.span(sampleOffset)
+	if !ok {
+		return math.NaN()
+	}
+	samplesPerBeat := (to.SampleOffset - from.SampleOffset) / float64(to.BeatNumber-from.BeatNumber)
+	return float64(from.BeatNumber) + (sampleOffset-from.SampleOffset)/samplesPerBeat
+}
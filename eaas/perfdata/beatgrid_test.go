@@ -0,0 +1,75 @@
+package perfdata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ConstantBeatGrid(t *testing.T) {
+	// 120 BPM at 44.1 kHz are 22050 samples per beat
+	grid, err := ConstantBeatGrid(120, 30000, 44100, 44100*60)
+	require.NoError(t, err)
+	require.Equal(t, grid.Markers, grid.DefaultMarkers)
+	require.Equal(t, []BeatMarker{
+		{SampleOffset: 30000 - 2*22050, BeatNumber: -2, BeatsUntilNext: 2 + 119},
+		{SampleOffset: 30000 + 119*22050, BeatNumber: 119},
+	}, grid.Markers)
+	require.InDelta(t, 120, grid.BPM(0), 1e-9)
+	require.InDelta(t, 0, grid.Beat(30000), 1e-9)
+	require.InDelta(t, 4.5, grid.Beat(30000+4.5*22050), 1e-9)
+	// positions outside the markers are extrapolated
+	require.InDelta(t, -3, grid.Beat(30000-3*22050), 1e-9)
+
+	_, err = ConstantBeatGrid(0, 0, 44100, 44100)
+	require.ErrorIs(t, err, ErrInvalidBeatGrid)
+	require.True(t, math.IsNaN((&BeatGrid{}).Beat(0)))
+	require.Zero(t, (&BeatGrid{}).BPM(0))
+}
+
+func Test_BeatGrid_Encode(t *testing.T) {
+	grid, err := ConstantBeatGrid(140, 0, 48000, 48000*200)
+	require.NoError(t, err)
+	grid.Markers[0].Unknown = 7
+
+	b := grid.Encode()
+	decoded, err := DecodeBeatGrid(b)
+	require.NoError(t, err)
+	require.Equal(t, grid, decoded)
+
+	// two markers per grid plus the header
+	raw, err := Uncompress(b)
+	require.NoError(t, err)
+	require.Len(t, raw, 8+8+1+2*(8+2*beatMarkerSize))
+	require.Equal(t, 48000.0, math.Float64frombits(binary.BigEndian.Uint64(raw)))
+
+	// uncompressed blobs decode too
+	decoded, err = DecodeBeatGrid(raw)
+	require.NoError(t, err)
+	require.Equal(t, grid, decoded)
+
+	_, err = DecodeBeatGrid(raw[:len(raw)-1])
+	require.Error(t, err)
+	broken := bytes.Clone(raw)
+	binary.BigEndian.PutUint64(broken[17:], math.MaxInt64)
+	_, err = DecodeBeatGrid(broken)
+	require.Error(t, err)
+}
+
+func Test_Uncompress(t *testing.T) {
+	b, err := Uncompress(Compress([]byte("beats")))
+	require.NoError(t, err)
+	require.Equal(t, []byte("beats"), b)
+
+	b, err = Uncompress(nil)
+	require.NoError(t, err)
+	require.Nil(t, b)
+
+	_, err = Uncompress([]byte{0, 0, 1})
+	require.Error(t, err)
+	_, err = Uncompress([]byte{0, 0, 0, 5, 'n', 'o', 'p', 'e'})
+	require.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package perfdata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+)
+
+// maxPrealloc caps how much memory the size stored in a compressed blob may
+// allocate up front.
+const maxPrealloc = 1 << 20
+
+// Compress packs a blob like Qt's qCompress: the uncompressed size as 32-bit
+// big endian integer followed by a zlib stream.
+func Compress(b []byte) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(b)))
+	w := zlib.NewWriter(buf)
+	_, _ = w.Write(b)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+// Uncompress unpacks a blob packed by Compress. Empty blobs stay empty.
+func Uncompress(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	if len(b) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	size := binary.BigEndian.Uint32(b)
+	if size == 0 {
+		return nil, nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(b[4:]))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	// the size is only a hint, don't trust it with allocations
+	out := bytes.NewBuffer(make([]byte, 0, min(int(size), maxPrealloc)))
+	if _, err = io.Copy(out, r); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// isCompressed tells whether a blob looks like it was packed by Compress,
+// that is whether a zlib header follows the size.
+func isCompressed(b []byte) bool {
+	return len(b) >= 6 && b[4]&0x0f == 8 && (uint16(b[4])<<8|uint16(b[5]))%31 == 0
+}
+
+// uncompressed returns the uncompressed content of a blob that may or may
+// not be compressed.
+func uncompressed(b []byte) ([]byte, error) {
+	if isCompressed(b) {
+		return Uncompress(b)
+	}
+	return b, nil
+}
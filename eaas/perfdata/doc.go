@@ -0,0 +1,28 @@
+/*
+This package encodes and decodes the analysis blobs of TrackPerformanceData,
+such as the beat grid, in the format Engine DJ stores them in its database and
+sends them to devices.
+
+The blobs are compressed the way Qt's qCompress does it. Decoders accept both
+compressed and uncompressed blobs, encoders always compress.
+
+	grid, err := perfdata.ConstantBeatGrid(128, firstDownbeat, 44100, sampleCount)
+	if err != nil {
+		return err
+	}
+	data.BeatGrid = grid.Encode()
+
+Overview waveforms can be generated from WAV, FLAC and MP3 files. There is no
+pure Go decoder for AAC, so OpenAudio returns ErrUnsupportedFormat for it.
+
+	audio, err := perfdata.OpenAudio(f, name)
+	if err != nil {
+		return err
+	}
+	waveform, err := perfdata.GenerateOverviewWaveform(audio)
+	if err != nil {
+		return err
+	}
+	data.OverviewWaveform = waveform.Encode()
+*/
+package perfdata
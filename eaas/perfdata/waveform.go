@@ -0,0 +1,201 @@
+package perfdata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// OverviewWaveformEntries is the number of entries of the overview
+	// waveforms Engine DJ generates, no matter the length of the track.
+	OverviewWaveformEntries = 1024
+
+	// waveformBlockSize is the number of samples the generator keeps a peak
+	// for before spreading them over the entries.
+	waveformBlockSize = 256
+
+	// The crossover frequencies between the low, mid and high bands.
+	lowCrossover  = 250
+	highCrossover = 2500
+)
+
+// WaveformPoint holds the level of the low, mid and high frequencies at a
+// point of a track, from 0 to 255.
+type WaveformPoint struct {
+	Low  uint8
+	Mid  uint8
+	High uint8
+}
+
+// OverviewWaveform is the waveform of a whole track shown on players.
+type OverviewWaveform struct {
+	// SamplesPerEntry is the number of samples each point covers.
+	SamplesPerEntry float64
+
+	Points []WaveformPoint
+
+	// Max holds the highest level of each band.
+	Max WaveformPoint
+}
+
+// SampleCount returns the number of samples the waveform covers.
+func (w *OverviewWaveform) SampleCount() float64 {
+	return w.SamplesPerEntry * float64(len(w.Points))
+}
+
+// DecodeOverviewWaveform decodes a compressed or uncompressed overview
+// waveform blob.
+//
+// The uncompressed blob holds the number of entries twice as big endian
+// 64-bit integers and the samples per entry as big endian double, followed
+// by the low, mid and high byte of each entry and finally of the maximum.
+func DecodeOverviewWaveform(b []byte) (*OverviewWaveform, error) {
+	b, err := uncompressed(b)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(b)
+	var n, n2 int64
+	w := new(OverviewWaveform)
+	for _, v := range []interface{}{&n, &n2, &w.SamplesPerEntry} {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return nil, fmt.Errorf("overview waveform header: %w", err)
+		}
+	}
+	if n != n2 || n < 0 || n > int64(r.Len()/3) {
+		return nil, fmt.Errorf("invalid overview waveform entry count %d", n)
+	}
+	w.Points = make([]WaveformPoint, n)
+	if err := binary.Read(r, binary.BigEndian, w.Points); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &w.Max); err != nil {
+		return nil, fmt.Errorf("overview waveform maximum: %w", err)
+	}
+	return w, nil
+}
+
+// Encode returns the compressed overview waveform blob.
+func (w *OverviewWaveform) Encode() []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, int64(len(w.Points)))
+	_ = binary.Write(buf, binary.BigEndian, int64(len(w.Points)))
+	_ = binary.Write(buf, binary.BigEndian, w.SamplesPerEntry)
+	_ = binary.Write(buf, binary.BigEndian, w.Points)
+	_ = binary.Write(buf, binary.BigEndian, w.Max)
+	return Compress(buf.Bytes())
+}
+
+// biquad is a second order IIR filter.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// newBiquad returns a Butterworth low pass or high pass filter.
+func newBiquad(highPass bool, cutoff, sampleRate float64) *biquad {
+	w := 2 * math.Pi * cutoff / sampleRate
+	alpha := math.Sin(w) / math.Sqrt2
+	cos := math.Cos(w)
+	a0 := 1 + alpha
+	f := &biquad{a1: -2 * cos / a0, a2: (1 - alpha) / a0}
+	if highPass {
+		f.b0 = (1 + cos) / 2 / a0
+		f.b1 = -(1 + cos) / a0
+	} else {
+		f.b0 = (1 - cos) / 2 / a0
+		f.b1 = (1 - cos) / a0
+	}
+	f.b2 = f.b0
+	return f
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// level converts a peak from the range 0 to 1 to a waveform level.
+func level(peak float64) uint8 {
+	return uint8(math.Round(math.Min(peak, 1) * 255))
+}
+
+// GenerateOverviewWaveform decodes audio until its end and returns its
+// overview waveform with OverviewWaveformEntries entries. The audio is split
+// into low, mid and high frequencies, and the level of each band is the peak
+// within each entry.
+func GenerateOverviewWaveform(audio Audio) (*OverviewWaveform, error) {
+	sampleRate := audio.SampleRate()
+	if sampleRate <= 0 {
+		return nil, errors.New("invalid sample rate")
+	}
+	low := newBiquad(false, lowCrossover, sampleRate)
+	high := newBiquad(true, highCrossover, sampleRate)
+
+	// peaks of each band per block, as the length of the audio is not always
+	// known up front
+	var (
+		blocks   [][3]float64
+		block    [3]float64
+		inBlock  int
+		total    int64
+		samples  = make([]float64, 4096)
+		finished bool
+	)
+	for !finished {
+		n, err := audio.ReadSamples(samples)
+		if errors.Is(err, io.EOF) {
+			finished = true
+		} else if err != nil {
+			return nil, err
+		}
+		for _, x := range samples[:n] {
+			l := low.process(x)
+			h := high.process(x)
+			m := x - l - h
+			block[0] = math.Max(block[0], math.Abs(l))
+			block[1] = math.Max(block[1], math.Abs(m))
+			block[2] = math.Max(block[2], math.Abs(h))
+			inBlock++
+			if inBlock == waveformBlockSize {
+				blocks = append(blocks, block)
+				block = [3]float64{}
+				inBlock = 0
+			}
+		}
+		total += int64(n)
+	}
+	if inBlock > 0 {
+		blocks = append(blocks, block)
+	}
+	if total == 0 {
+		return nil, errors.New("no audio")
+	}
+
+	w := &OverviewWaveform{
+		SamplesPerEntry: float64(total) / OverviewWaveformEntries,
+		Points:          make([]WaveformPoint, OverviewWaveformEntries),
+	}
+	for i := range w.Points {
+		from := i * len(blocks) / OverviewWaveformEntries
+		to := max((i+1)*len(blocks)/OverviewWaveformEntries, from+1)
+		var peak [3]float64
+		for _, block := range blocks[from:min(to, len(blocks))] {
+			for band := range peak {
+				peak[band] = math.Max(peak[band], block[band])
+			}
+		}
+		p := WaveformPoint{Low: level(peak[0]), Mid: level(peak[1]), High: level(peak[2])}
+		w.Points[i] = p
+		w.Max.Low = max(w.Max.Low, p.Low)
+		w.Max.Mid = max(w.Max.Mid, p.Mid)
+		w.Max.High = max(w.Max.High, p.High)
+	}
+	return w, nil
+}
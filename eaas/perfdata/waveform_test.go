@@ -0,0 +1,104 @@
+package perfdata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sineWAV returns a 16-bit stereo WAV file with a sine wave of the given
+// frequency and amplitude.
+func sineWAV(frequency, amplitude float64, sampleRate, samples int) []byte {
+	data := new(bytes.Buffer)
+	for i := 0; i < samples; i++ {
+		v := int16(amplitude * 32767 * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate)))
+		_ = binary.Write(data, binary.LittleEndian, [2]int16{v, v})
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(4+8+16+8+8+2+8+data.Len()))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	_ = binary.Write(buf, binary.LittleEndian, []uint32{16})
+	_ = binary.Write(buf, binary.LittleEndian, []uint16{wavFormatPCM, 2})
+	_ = binary.Write(buf, binary.LittleEndian, []uint32{uint32(sampleRate), uint32(sampleRate * 4)})
+	_ = binary.Write(buf, binary.LittleEndian, []uint16{4, 16})
+	// chunks the decoder has to skip, with padding
+	buf.WriteString("LIST")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(1))
+	buf.Write([]byte{0, 0})
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(data.Len()))
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+func openWAV(t *testing.T, b []byte) Audio {
+	audio, err := OpenAudio(bytes.NewReader(b), "test.WAV")
+	require.NoError(t, err)
+	require.Equal(t, 44100.0, audio.SampleRate())
+	return audio
+}
+
+func generate(t *testing.T, b []byte) *OverviewWaveform {
+	w, err := GenerateOverviewWaveform(openWAV(t, b))
+	require.NoError(t, err)
+	require.Len(t, w.Points, OverviewWaveformEntries)
+	return w
+}
+
+func Test_GenerateOverviewWaveform(t *testing.T) {
+	low := generate(t, sineWAV(60, 0.8, 44100, 44100*2))
+	require.InDelta(t, 44100*2, low.SampleCount(), 1e-6)
+	require.Greater(t, low.Max.Low, uint8(150))
+	require.Less(t, low.Max.High, uint8(20))
+	require.Greater(t, low.Max.Low, low.Max.Mid)
+	middle := low.Points[OverviewWaveformEntries/2]
+	require.Greater(t, middle.Low, middle.High)
+
+	high := generate(t, sineWAV(8000, 0.8, 44100, 44100*2))
+	require.Greater(t, high.Max.High, uint8(150))
+	require.Less(t, high.Max.Low, uint8(20))
+	require.Greater(t, high.Max.High, high.Max.Mid)
+
+	// audio shorter than the number of entries still fills every entry
+	short := generate(t, sineWAV(60, 0.8, 44100, 100))
+	require.InDelta(t, 100, short.SampleCount(), 1e-6)
+
+	_, err := GenerateOverviewWaveform(openWAV(t, sineWAV(60, 1, 44100, 0)))
+	require.Error(t, err)
+
+	_, err = OpenAudio(bytes.NewReader(nil), "track.m4a")
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+	_, err = OpenAudio(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00AVI ")), "track.wav")
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func Test_OverviewWaveform_Encode(t *testing.T) {
+	w := &OverviewWaveform{
+		SamplesPerEntry: 1234.5,
+		Points:          []WaveformPoint{{1, 2, 3}, {40, 50, 60}, {7, 8, 9}},
+		Max:             WaveformPoint{40, 50, 60},
+	}
+	b := w.Encode()
+	decoded, err := DecodeOverviewWaveform(b)
+	require.NoError(t, err)
+	require.Equal(t, w, decoded)
+
+	raw, err := Uncompress(b)
+	require.NoError(t, err)
+	require.Len(t, raw, 8+8+8+3*3+3)
+	decoded, err = DecodeOverviewWaveform(raw)
+	require.NoError(t, err)
+	require.Equal(t, w, decoded)
+
+	_, err = DecodeOverviewWaveform(raw[:len(raw)-1])
+	require.Error(t, err)
+	broken := bytes.Clone(raw)
+	binary.BigEndian.PutUint64(broken[8:], 2)
+	_, err = DecodeOverviewWaveform(broken)
+	require.Error(t, err)
+}
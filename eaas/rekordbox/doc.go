@@ -0,0 +1,17 @@
+/*
+This package reads the XML collections Rekordbox exports ("File > Export
+Collection in xml format") and serves them as an EAAS library.
+
+	doc, err := rekordbox.Open("rekordbox.xml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, track := range doc.Tracks {
+		fmt.Println(track.Artist, "-", track.Name, track.Path())
+	}
+
+Use NewProvider to serve the collection with an eaas/server.Server. Devices
+download the audio files from the locations in the export, so the files need
+to be where Rekordbox saw them.
+*/
+package rekordbox
@@ -0,0 +1,269 @@
+package rekordbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/icedream/go-stagelinq/eaas/cues"
+	"github.com/icedream/go-stagelinq/eaas/perfdata"
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+const (
+	defaultTitle = "rekordbox"
+
+	// defaultSampleRate is assumed for tracks the export has no sample rate
+	// for.
+	defaultSampleRate = 44100
+
+	// starRating is the Rekordbox rating of a star, 20 in Engine DJ.
+	starRating = 51
+)
+
+// ProviderConfiguration contains configurable values for a Provider.
+type ProviderConfiguration struct {
+	// Title is the name of the library shown on devices. Defaults to
+	// "rekordbox".
+	Title string
+}
+
+var _ server.LibraryProvider = &Provider{}
+var _ server.VersionProvider = &Provider{}
+
+// Provider serves a Rekordbox XML export as a single library.
+//
+// Track IDs are the TrackIDs of the export. Playlist IDs and the library ID
+// are derived from the playlist names and the path of the export, so they
+// stay the same when the collection is exported again.
+type Provider struct {
+	library *server.Library
+
+	tracks []*server.Track
+	byID   map[string]*server.Track
+	byURL  map[string]*server.Track
+	// sources holds the tracks as exported by TrackID
+	sources map[string]*Track
+
+	playlists []*server.Playlist
+	// playlistTracks holds the tracks of each playlist by playlist ID
+	playlistTracks map[string][]*server.Track
+}
+
+// NewProvider reads the Rekordbox XML export at the given path for serving.
+// The export is read only once, create a new Provider to pick up changes.
+func NewProvider(name string, config *ProviderConfiguration) (p *Provider, err error) {
+	if config == nil {
+		config = new(ProviderConfiguration)
+	}
+	title := config.Title
+	if title == "" {
+		title = defaultTitle
+	}
+
+	if name, err = filepath.Abs(name); err != nil {
+		return
+	}
+	doc, err := Open(name)
+	if err != nil {
+		return
+	}
+	return newProvider(doc, uuid.NewSHA1(uuid.NameSpaceURL, []byte("file://"+filepath.ToSlash(name))), title), nil
+}
+
+func newProvider(doc *Document, namespace uuid.UUID, title string) *Provider {
+	p := &Provider{
+		library: &server.Library{
+			ID:    namespace.String(),
+			Title: title,
+		},
+		byID:           map[string]*server.Track{},
+		byURL:          map[string]*server.Track{},
+		sources:        map[string]*Track{},
+		playlistTracks: map[string][]*server.Track{},
+	}
+	byLocation := map[string]*server.Track{}
+	for _, track := range doc.Tracks {
+		if track.Path() == "" {
+			// streaming tracks and the like have no file to download
+			continue
+		}
+		converted := toTrack(track)
+		p.tracks = append(p.tracks, converted)
+		p.byID[track.TrackID] = converted
+		p.byURL[converted.URL] = converted
+		p.sources[track.TrackID] = track
+		byLocation[track.Location] = converted
+	}
+
+	var walk func(node *Node, key string) *server.Playlist
+	walk = func(node *Node, key string) *server.Playlist {
+		playlist := &server.Playlist{
+			ID:    uuid.NewSHA1(namespace, []byte("playlist:"+key)).String(),
+			Title: node.Name,
+		}
+		if node.Type == NodeFolder {
+			playlist.Children = make([]*server.Playlist, 0, len(node.Nodes))
+			seen := map[string]int{}
+			for _, child := range node.Nodes {
+				childKey := key + "/" + child.Name
+				// playlists of the same name are told apart by their order
+				if seen[child.Name]++; seen[child.Name] > 1 {
+					childKey += fmt.Sprintf("#%d", seen[child.Name])
+				}
+				playlist.Children = append(playlist.Children, walk(child, childKey))
+			}
+			return playlist
+		}
+		lookup := p.byID
+		if node.KeyType == KeyLocation {
+			lookup = byLocation
+		}
+		tracks := make([]*server.Track, 0, len(node.Tracks))
+		for _, entry := range node.Tracks {
+			if track, ok := lookup[entry.Key]; ok {
+				tracks = append(tracks, track)
+			}
+		}
+		playlist.TrackCount = len(tracks)
+		p.playlistTracks[playlist.ID] = tracks
+		return playlist
+	}
+	if doc.Root != nil {
+		// the root folder itself is not shown
+		p.playlists = walk(doc.Root, "").Children
+	}
+	return p
+}
+
+func toTrack(track *Track) *server.Track {
+	return &server.Track{
+		ID:        track.TrackID,
+		Title:     track.Name,
+		Artist:    track.Artist,
+		Album:     track.Album,
+		Genre:     track.Genre,
+		Comment:   track.Comments,
+		Label:     track.Label,
+		Composer:  track.Composer,
+		Remixer:   track.Remixer,
+		Key:       track.Tonality,
+		BPM:       track.AverageBpm,
+		Rating:    track.Rating / starRating * 20,
+		Year:      track.Year,
+		Length:    time.Duration(track.TotalTime * float64(time.Second)),
+		DateAdded: track.Added(),
+		URL:       track.Path(),
+		FileSize:  track.Size,
+	}
+}
+
+// Libraries implements server.LibraryProvider.
+func (p *Provider) Libraries(ctx context.Context) ([]*server.Library, error) {
+	return []*server.Library{p.library}, nil
+}
+
+// Playlists implements server.LibraryProvider.
+func (p *Provider) Playlists(ctx context.Context, libraryID string) ([]*server.Playlist, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	return p.playlists, nil
+}
+
+// TracksVersion implements server.VersionProvider. The library is read once,
+// so the version never changes.
+func (p *Provider) TracksVersion(ctx context.Context, libraryID string) (uint64, error) {
+	if libraryID != p.library.ID {
+		return 0, server.ErrNotFound
+	}
+	return 0, nil
+}
+
+// Tracks implements server.LibraryProvider. Folders have no tracks of their
+// own.
+func (p *Provider) Tracks(ctx context.Context, libraryID, playlistID string) ([]*server.Track, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	if playlistID == "" {
+		return p.tracks, nil
+	}
+	if tracks, ok := p.playlistTracks[playlistID]; ok {
+		return tracks, nil
+	}
+	if findPlaylist(p.playlists, playlistID) != nil {
+		return nil, nil
+	}
+	return nil, server.ErrNotFound
+}
+
+func findPlaylist(playlists []*server.Playlist, id string) *server.Playlist {
+	for _, playlist := range playlists {
+		if playlist.ID == id {
+			return playlist
+		}
+		if found := findPlaylist(playlist.Children, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Track implements server.LibraryProvider.
+func (p *Provider) Track(ctx context.Context, libraryID, trackID string) (*server.Track, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	track, ok := p.byID[trackID]
+	if !ok {
+		return nil, server.ErrNotFound
+	}
+	return track, nil
+}
+
+// PerformanceData implements server.LibraryProvider. The beat grid follows
+// the first tempo marker of the track, and hot cues, memory cues and loops
+// are converted from the position marks.
+func (p *Provider) PerformanceData(ctx context.Context, libraryID, trackID string) (*server.PerformanceData, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	track, ok := p.sources[trackID]
+	if !ok {
+		return nil, server.ErrNotFound
+	}
+	sampleRate := track.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = defaultSampleRate
+	}
+
+	data := &server.PerformanceData{BPM: track.AverageBpm}
+	if len(track.Tempos) > 0 && track.TotalTime > 0 {
+		tempo := track.Tempos[0]
+		// the first marker is not necessarily on a downbeat
+		firstDownbeat := tempo.Inizio
+		if tempo.Bpm > 0 && tempo.Battito > 1 {
+			firstDownbeat -= float64(tempo.Battito-1) * 60 / tempo.Bpm
+		}
+		grid, err := perfdata.ConstantBeatGrid(tempo.Bpm, firstDownbeat*sampleRate, sampleRate, track.TotalTime*sampleRate)
+		if err == nil {
+			data.BeatGrid = grid.Encode()
+		}
+	}
+	cues.FromRekordbox(track.PositionMarks, sampleRate).Apply(data)
+	return data, nil
+}
+
+// OpenBlob implements server.LibraryProvider. Only the audio files of tracks
+// in the export can be opened.
+func (p *Provider) OpenBlob(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	if _, ok := p.byURL[url]; !ok {
+		return nil, server.ErrNotFound
+	}
+	return os.Open(filepath.FromSlash(url))
+}
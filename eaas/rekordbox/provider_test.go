@@ -0,0 +1,166 @@
+package rekordbox
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/perfdata"
+	"github.com/icedream/go-stagelinq/eaas/server"
+	"github.com/stretchr/testify/require"
+)
+
+const testExport = `<?xml version="1.0" encoding="UTF-8"?>
+<DJ_PLAYLISTS Version="1.0.0">
+  <PRODUCT Name="rekordbox" Version="6.8.5" Company="AlphaTheta"/>
+  <COLLECTION Entries="3">
+    <TRACK TrackID="101" Name="Whiplash" Artist="Icedream" Composer="" Album="Singles"
+      Grouping="" Genre="Techno" Kind="MP3 File" Size="9437184" TotalTime="300" DiscNumber="0"
+      TrackNumber="1" Year="2021" AverageBpm="140.00" DateAdded="2021-03-01" BitRate="320"
+      SampleRate="48000" Comments="Peak time" PlayCount="3" Rating="204"
+      Location="{{LOCATION}}" Remixer="" Tonality="Am" Label="Self" Mix="Original">
+      <TEMPO Inizio="0.250" Bpm="140.00" Metro="4/4" Battito="2"/>
+      <TEMPO Inizio="100.250" Bpm="140.00" Metro="4/4" Battito="1"/>
+      <POSITION_MARK Name="" Type="0" Start="0.250" Num="-1"/>
+      <POSITION_MARK Name="Drop" Type="0" Start="60.000" Num="0" Red="230" Green="40" Blue="40"/>
+      <POSITION_MARK Name="" Type="4" Start="30.000" End="31.714" Num="-1"/>
+    </TRACK>
+    <TRACK TrackID="102" Name="Second" Artist="Someone" Size="1" TotalTime="200"
+      AverageBpm="128.00" Location="file://localhost/C:/Music/Second%20Track.mp3"/>
+    <TRACK TrackID="103" Name="Streamed" Artist="Someone" Location="https://example.com/track"/>
+  </COLLECTION>
+  <PLAYLISTS>
+    <NODE Type="0" Name="ROOT" Count="3">
+      <NODE Type="0" Name="Gigs" Count="2">
+        <NODE Name="Friday" Type="1" KeyType="0" Entries="3">
+          <TRACK Key="102"/>
+          <TRACK Key="101"/>
+          <TRACK Key="103"/>
+        </NODE>
+        <NODE Name="Friday" Type="1" KeyType="1" Entries="1">
+          <TRACK Key="{{LOCATION}}"/>
+        </NODE>
+      </NODE>
+      <NODE Name="Empty" Type="1" KeyType="0" Entries="0"/>
+    </NODE>
+  </PLAYLISTS>
+</DJ_PLAYLISTS>
+`
+
+func Test_Track_Path(t *testing.T) {
+	for location, path := range map[string]string{
+		"file://localhost/C:/Music/Second%20Track.mp3": "C:/Music/Second Track.mp3",
+		"file://localhost/Users/dj/M%C3%BCsik/a.mp3":   "/Users/dj/Müsik/a.mp3",
+		"file:///home/dj/a.flac":                       "/home/dj/a.flac",
+		"https://example.com/track":                    "",
+	} {
+		require.Equal(t, path, (&Track{Location: location}).Path(), location)
+	}
+}
+
+func Test_Provider(t *testing.T) {
+	dir := t.TempDir()
+	audio := filepath.Join(dir, "Music", "Whip lash.mp3")
+	require.NoError(t, os.MkdirAll(filepath.Dir(audio), 0o755))
+	require.NoError(t, os.WriteFile(audio, []byte("ID3"), 0o644))
+	audioURL := filepath.ToSlash(audio)
+	location := (&url.URL{Scheme: "file", Host: "localhost", Path: audioURL}).String()
+	if !strings.HasPrefix(audioURL, "/") {
+		location = (&url.URL{Scheme: "file", Host: "localhost", Path: "/" + audioURL}).String()
+	}
+	export := filepath.Join(dir, "rekordbox.xml")
+	require.NoError(t, os.WriteFile(export, []byte(strings.ReplaceAll(testExport, "{{LOCATION}}", location)), 0o644))
+
+	p, err := NewProvider(export, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	libraries, err := p.Libraries(ctx)
+	require.NoError(t, err)
+	require.Len(t, libraries, 1)
+	require.Equal(t, "rekordbox", libraries[0].Title)
+	libraryID := libraries[0].ID
+
+	tracks, err := p.Tracks(ctx, libraryID, "")
+	require.NoError(t, err)
+	require.Len(t, tracks, 2)
+	track := tracks[0]
+	require.Equal(t, "101", track.ID)
+	require.Equal(t, "Whiplash", track.Title)
+	require.Equal(t, "Icedream", track.Artist)
+	require.Equal(t, "Am", track.Key)
+	require.Equal(t, 140.0, track.BPM)
+	require.Equal(t, 80, track.Rating)
+	require.Equal(t, 5*time.Minute, track.Length)
+	require.Equal(t, 2021, track.Year)
+	require.Equal(t, time.Date(2021, 3, 1, 0, 0, 0, 0, time.Local), track.DateAdded)
+	require.Equal(t, audioURL, track.URL)
+	require.Equal(t, "C:/Music/Second Track.mp3", tracks[1].URL)
+
+	playlists, err := p.Playlists(ctx, libraryID)
+	require.NoError(t, err)
+	require.Len(t, playlists, 2)
+	require.Equal(t, "Gigs", playlists[0].Title)
+	require.Len(t, playlists[0].Children, 2)
+	first, second := playlists[0].Children[0], playlists[0].Children[1]
+	require.Equal(t, "Friday", first.Title)
+	require.Equal(t, "Friday", second.Title)
+	require.NotEqual(t, first.ID, second.ID)
+	require.Equal(t, 2, first.TrackCount)
+	require.Equal(t, "Empty", playlists[1].Title)
+	require.Zero(t, playlists[1].TrackCount)
+
+	friday, err := p.Tracks(ctx, libraryID, first.ID)
+	require.NoError(t, err)
+	require.Equal(t, []*server.Track{tracks[1], tracks[0]}, friday)
+	byLocation, err := p.Tracks(ctx, libraryID, second.ID)
+	require.NoError(t, err)
+	require.Equal(t, []*server.Track{tracks[0]}, byLocation)
+	folder, err := p.Tracks(ctx, libraryID, playlists[0].ID)
+	require.NoError(t, err)
+	require.Empty(t, folder)
+	_, err = p.Tracks(ctx, libraryID, "missing")
+	require.ErrorIs(t, err, server.ErrNotFound)
+
+	// IDs are stable across restarts
+	again, err := NewProvider(export, &ProviderConfiguration{Title: "Export"})
+	require.NoError(t, err)
+	againPlaylists, err := again.Playlists(ctx, libraryID)
+	require.NoError(t, err)
+	require.Equal(t, playlists, againPlaylists)
+
+	found, err := p.Track(ctx, libraryID, "101")
+	require.NoError(t, err)
+	require.Same(t, track, found)
+	_, err = p.Track(ctx, libraryID, "103")
+	require.ErrorIs(t, err, server.ErrNotFound)
+
+	data, err := p.PerformanceData(ctx, libraryID, "101")
+	require.NoError(t, err)
+	require.Equal(t, 140.0, data.BPM)
+	grid, err := perfdata.DecodeBeatGrid(data.BeatGrid)
+	require.NoError(t, err)
+	require.Equal(t, 48000.0, grid.SampleRate)
+	require.InDelta(t, 140, grid.BPM(0), 1e-9)
+	// the first marker is the second beat of a bar
+	require.InDelta(t, 1, grid.Beat(0.25*48000), 1e-9)
+	require.NotNil(t, data.MainCue)
+	require.InDelta(t, 0.25*48000, data.MainCue.Position, 1e-6)
+	require.Equal(t, "Drop", data.QuickCues[0].Name)
+	require.InDelta(t, 60*48000, data.QuickCues[0].Position, 1e-6)
+	require.InDelta(t, 30*48000, data.Loops[0].In, 1e-6)
+
+	blob, err := p.OpenBlob(ctx, audioURL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(blob)
+	blob.Close()
+	require.NoError(t, err)
+	require.Equal(t, []byte("ID3"), b)
+	_, err = p.OpenBlob(ctx, filepath.ToSlash(export))
+	require.ErrorIs(t, err, server.ErrNotFound)
+}
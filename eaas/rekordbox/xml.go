@@ -0,0 +1,163 @@
+package rekordbox
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/cues"
+)
+
+// Types of playlist nodes.
+const (
+	NodeFolder   = 0
+	NodePlaylist = 1
+)
+
+// Types of keys of the tracks of a playlist.
+const (
+	KeyTrackID  = 0
+	KeyLocation = 1
+)
+
+// Document is a Rekordbox XML export.
+type Document struct {
+	XMLName xml.Name `xml:"DJ_PLAYLISTS"`
+	Version string   `xml:"Version,attr"`
+	Product Product  `xml:"PRODUCT"`
+	Tracks  []*Track `xml:"COLLECTION>TRACK"`
+
+	// Root is the root folder of the playlists, called "ROOT".
+	Root *Node `xml:"PLAYLISTS>NODE"`
+}
+
+// Product names the software that wrote the export.
+type Product struct {
+	Name    string `xml:"Name,attr"`
+	Version string `xml:"Version,attr"`
+	Company string `xml:"Company,attr"`
+}
+
+// Track is a track of the collection.
+type Track struct {
+	TrackID  string `xml:"TrackID,attr"`
+	Name     string `xml:"Name,attr"`
+	Artist   string `xml:"Artist,attr"`
+	Composer string `xml:"Composer,attr"`
+	Album    string `xml:"Album,attr"`
+	Grouping string `xml:"Grouping,attr"`
+	Genre    string `xml:"Genre,attr"`
+	Kind     string `xml:"Kind,attr"`
+
+	// Size is the size of the audio file in bytes.
+	Size int64 `xml:"Size,attr"`
+
+	// TotalTime is the length in seconds.
+	TotalTime float64 `xml:"TotalTime,attr"`
+
+	DiscNumber  int     `xml:"DiscNumber,attr"`
+	TrackNumber int     `xml:"TrackNumber,attr"`
+	Year        int     `xml:"Year,attr"`
+	AverageBpm  float64 `xml:"AverageBpm,attr"`
+
+	// DateAdded is formatted like "2006-01-02".
+	DateAdded string `xml:"DateAdded,attr"`
+
+	BitRate    int     `xml:"BitRate,attr"`
+	SampleRate float64 `xml:"SampleRate,attr"`
+	Comments   string  `xml:"Comments,attr"`
+	PlayCount  int     `xml:"PlayCount,attr"`
+
+	// Rating goes from 0 to 255 in steps of 51 per star.
+	Rating int `xml:"Rating,attr"`
+
+	// Location is the file URL of the audio file.
+	Location string `xml:"Location,attr"`
+
+	Remixer  string `xml:"Remixer,attr"`
+	Tonality string `xml:"Tonality,attr"`
+	Label    string `xml:"Label,attr"`
+	Mix      string `xml:"Mix,attr"`
+
+	Tempos        []Tempo                      `xml:"TEMPO"`
+	PositionMarks []cues.RekordboxPositionMark `xml:"POSITION_MARK"`
+}
+
+// Tempo is a marker of the beat grid of a track.
+type Tempo struct {
+	// Inizio is the position of the marker in seconds.
+	Inizio float64 `xml:"Inizio,attr"`
+	Bpm    float64 `xml:"Bpm,attr"`
+
+	// Metro is the time signature, like "4/4".
+	Metro string `xml:"Metro,attr"`
+
+	// Battito is the beat within the bar at the marker, starting at 1.
+	Battito int `xml:"Battito,attr"`
+}
+
+// Node is a folder or playlist.
+type Node struct {
+	Type int    `xml:"Type,attr"`
+	Name string `xml:"Name,attr"`
+
+	// KeyType tells whether the tracks of a playlist refer to the TrackID or
+	// the Location of tracks.
+	KeyType int `xml:"KeyType,attr"`
+
+	// Nodes are the children of a folder.
+	Nodes []*Node `xml:"NODE"`
+
+	// Tracks are the tracks of a playlist.
+	Tracks []NodeTrack `xml:"TRACK"`
+}
+
+// NodeTrack refers to a track of the collection from a playlist.
+type NodeTrack struct {
+	Key string `xml:"Key,attr"`
+}
+
+// Decode reads a Rekordbox XML export.
+func Decode(r io.Reader) (*Document, error) {
+	doc := new(Document)
+	if err := xml.NewDecoder(r).Decode(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Open reads a Rekordbox XML export from a file.
+func Open(name string) (*Document, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// Path returns the path of the audio file with forward slashes, like
+// "C:/Music/track.mp3" or "/Users/dj/Music/track.mp3", or an empty string if
+// the location is no file URL.
+func (t *Track) Path() string {
+	u, err := url.Parse(t.Location)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return ""
+	}
+	path := u.Path
+	// Windows paths come as "/C:/Music/track.mp3"
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return path
+}
+
+// Added returns the date the track was added to the collection, or the zero
+// time if unknown.
+func (t *Track) Added() time.Time {
+	added, _ := time.ParseInLocation(time.DateOnly, strings.TrimSpace(t.DateAdded), time.Local)
+	return added
+}
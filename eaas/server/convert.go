@@ -0,0 +1,167 @@
+package server
+
+import (
+	"image/color"
+	"sort"
+
+	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// unsetPosition marks cue positions that are not set.
+const unsetPosition float64 = -1
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return proto.String(s)
+}
+
+func libraryToProto(library *Library) *enginelibrary.Library {
+	return &enginelibrary.Library{
+		Id:    proto.String(library.ID),
+		Title: proto.String(library.Title),
+	}
+}
+
+func playlistToProto(playlist *Playlist) *enginelibrary.PlaylistMetadata {
+	m := &enginelibrary.PlaylistMetadata{
+		Id:         proto.String(playlist.ID),
+		Title:      proto.String(playlist.Title),
+		TrackCount: proto.Uint32(uint32(playlist.TrackCount)),
+		Playlists:  make([]*enginelibrary.PlaylistMetadata, 0, len(playlist.Children)),
+		ListType:   enginelibrary.ListType_LIST_TYPE_PLAY.Enum(),
+	}
+	if playlist.Smart {
+		m.ListType = enginelibrary.ListType_LIST_TYPE_SMART.Enum()
+	}
+	for _, child := range playlist.Children {
+		m.Playlists = append(m.Playlists, playlistToProto(child))
+	}
+	return m
+}
+
+func trackMetadataToProto(track *Track) *enginelibrary.TrackMetadata {
+	m := &enginelibrary.TrackMetadata{
+		Id:       proto.String(track.ID),
+		Title:    optionalString(track.Title),
+		Artist:   optionalString(track.Artist),
+		Album:    optionalString(track.Album),
+		Genre:    optionalString(track.Genre),
+		Comment:  optionalString(track.Comment),
+		Label:    optionalString(track.Label),
+		Composer: optionalString(track.Composer),
+		Remixer:  optionalString(track.Remixer),
+		Key:      optionalString(track.Key),
+	}
+	if track.BPM > 0 {
+		m.Bpm = proto.Float64(track.BPM)
+	}
+	if track.Rating > 0 {
+		m.Rating = proto.Uint32(uint32(track.Rating))
+	}
+	if track.Year > 0 {
+		m.Year = proto.Uint32(uint32(track.Year))
+	}
+	if track.Length > 0 {
+		m.LengthSeconds = proto.Uint32(uint32(track.Length.Seconds()))
+	}
+	if !track.DateAdded.IsZero() {
+		m.DateAdded = timestamppb.New(track.DateAdded)
+	}
+	return m
+}
+
+func listTrackToProto(track *Track) *enginelibrary.ListTrack {
+	return &enginelibrary.ListTrack{
+		Metadata:       trackMetadataToProto(track),
+		PreviewArtwork: track.PreviewArtwork,
+	}
+}
+
+// blobURL returns the URL of a track as sent to devices. Engine DJ puts it in
+// angle brackets, and devices send it back like that when downloading.
+func blobURL(url string) string {
+	return "<" + url + ">"
+}
+
+func trackBlobToProto(track *Track) *enginelibrary.TrackBlob {
+	return &enginelibrary.TrackBlob{
+		Type: &enginelibrary.TrackBlob_Url{
+			Url: &enginelibrary.TrackBlobUrl{
+				Url:      proto.String(blobURL(track.URL)),
+				FileSize: proto.Uint32(uint32(track.FileSize)),
+			},
+		},
+	}
+}
+
+func colorToProto(c color.RGBA) *enginelibrary.Color {
+	return &enginelibrary.Color{
+		R: proto.Uint32(uint32(c.R)),
+		G: proto.Uint32(uint32(c.G)),
+		B: proto.Uint32(uint32(c.B)),
+		A: proto.Uint32(uint32(c.A)),
+	}
+}
+
+func performanceDataToProto(trackID string, data *PerformanceData) *enginelibrary.TrackPerformanceData {
+	m := &enginelibrary.TrackPerformanceData{
+		Id: proto.String(trackID),
+		MainCue: &enginelibrary.MainCue{
+			Position:        proto.Float64(unsetPosition),
+			InitialPosition: proto.Float64(unsetPosition),
+		},
+	}
+	if data == nil {
+		return m
+	}
+	if data.BPM > 0 {
+		m.Bpm = proto.Float64(data.BPM)
+	}
+	m.BeatGrid = data.BeatGrid
+	m.OverviewWaveform = data.OverviewWaveform
+	if data.MainCue != nil {
+		m.MainCue = &enginelibrary.MainCue{
+			Position:        proto.Float64(data.MainCue.Position),
+			InitialPosition: proto.Float64(data.MainCue.InitialPosition),
+			IsSetManually:   proto.Bool(data.MainCue.SetManually),
+		}
+	}
+	for _, pad := range sortedKeys(data.QuickCues) {
+		cue := data.QuickCues[pad]
+		m.QuickCues = append(m.QuickCues, &enginelibrary.TrackPerformanceData_QuickCuesEntry{
+			Key: proto.Uint32(uint32(pad)),
+			Value: &enginelibrary.QuickCue{
+				Name:     optionalString(cue.Name),
+				Position: proto.Float64(cue.Position),
+				Color:    colorToProto(cue.Color),
+			},
+		})
+	}
+	for _, pad := range sortedKeys(data.Loops) {
+		loop := data.Loops[pad]
+		m.Loops = append(m.Loops, &enginelibrary.TrackPerformanceData_LoopsEntry{
+			Key: proto.Uint32(uint32(pad)),
+			Value: &enginelibrary.Loop{
+				Name:         optionalString(loop.Name),
+				LoopIn:       loop.In,
+				LoopOut:      loop.Out,
+				Color:        colorToProto(loop.Color),
+				ActiveOnLoad: proto.Bool(loop.ActiveOnLoad),
+			},
+		})
+	}
+	return m
+}
+
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,11 @@
+/*
+This package serves remote Engine DJ libraries to devices on the network.
+
+Implement LibraryProvider to expose your tracks and playlists, then hand it to
+NewServer, which sets up the EAAS gRPC services, the HTTP endpoints devices
+download tracks from and the beacon announcing the library to the network.
+
+Track searches, filters and sorting are answered from a TrackIndex of the
+library, built when the provider returns other tracks than before.
+*/
+package server
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// eventStreamWait is how long an EventStream request waits for events
+	// before returning empty-handed.
+	eventStreamWait = 20 * time.Second
+
+	// maxQueuedEvents is how many events are kept per device until it asks
+	// for them. Older events are dropped.
+	maxQueuedEvents = 256
+
+	// eventQueueExpiry is how long a device may stop asking for events
+	// before its queue is removed.
+	eventQueueExpiry = 5 * time.Minute
+)
+
+// Event reports a change of the content of a library to devices.
+type Event struct {
+	// LibraryID is the library that changed.
+	LibraryID string
+
+	// PlaylistHierarchyChanged is set if playlists were added, removed or
+	// moved.
+	PlaylistHierarchyChanged bool
+
+	// PlaylistIDs lists the playlists whose tracks changed.
+	PlaylistIDs []string
+
+	// Tracks lists the tracks whose metadata changed.
+	Tracks []*Track
+}
+
+// EventProvider can be implemented by a LibraryProvider whose content changes
+// while it is served. The server passes the events on to devices via
+// EventStream.
+type EventProvider interface {
+	// Events returns the channel via which changes are reported. It is read
+	// from until it is closed.
+	Events() <-chan *Event
+}
+
+func eventToProto(event *Event) (events []*enginelibrary.Event) {
+	libraryID := proto.String(event.LibraryID)
+	if event.PlaylistHierarchyChanged {
+		events = append(events, &enginelibrary.Event{
+			LibraryId: libraryID,
+			Data: &enginelibrary.Event_PlaylistHierarchyChanged{
+				PlaylistHierarchyChanged: &enginelibrary.EventPlaylistHierarchyChanged{},
+			},
+		})
+	}
+	if len(event.PlaylistIDs) > 0 {
+		events = append(events, &enginelibrary.Event{
+			LibraryId: libraryID,
+			Data: &enginelibrary.Event_PlaylistsContentChanged{
+				PlaylistsContentChanged: &enginelibrary.EventPlaylistsContentChanged{
+					PlaylistId: event.PlaylistIDs,
+				},
+			},
+		})
+	}
+	if len(event.Tracks) > 0 {
+		metadata := make([]*enginelibrary.TrackMetadata, 0, len(event.Tracks))
+		for _, track := range event.Tracks {
+			metadata = append(metadata, trackMetadataToProto(track))
+		}
+		events = append(events, &enginelibrary.Event{
+			LibraryId: libraryID,
+			Data: &enginelibrary.Event_TrackMetadataChanged{
+				TrackMetadataChanged: &enginelibrary.EventTrackMetadataChanged{
+					TrackMetadata: metadata,
+				},
+			},
+		})
+	}
+	return
+}
+
+type eventQueueKey struct {
+	libraryID string
+	deviceID  string
+}
+
+// eventQueue holds the events not yet picked up by a device.
+type eventQueue struct {
+	events []*enginelibrary.Event
+	// ready is closed and replaced whenever events are queued
+	ready chan struct{}
+	// waiting counts the requests waiting for events of this queue
+	waiting  int
+	lastPoll time.Time
+}
+
+// eventHub distributes the events of a provider to a queue per device, so
+// every device that polls EventStream sees every event.
+type eventHub struct {
+	lock   sync.Mutex
+	queues map[eventQueueKey]*eventQueue
+	expiry time.Duration
+	// versions counts the events of each library
+	versions map[string]uint64
+	// closed is set once the provider stopped reporting changes
+	closed bool
+}
+
+func newEventHub(events <-chan *Event) *eventHub {
+	h := &eventHub{
+		queues:   map[eventQueueKey]*eventQueue{},
+		expiry:   eventQueueExpiry,
+		versions: map[string]uint64{},
+	}
+	go func() {
+		for event := range events {
+			h.publish(event)
+		}
+		h.lock.Lock()
+		h.closed = true
+		h.lock.Unlock()
+	}()
+	return h
+}
+
+func (h *eventHub) publish(event *Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.versions[event.LibraryID]++
+	h.expire(time.Now())
+	for key, queue := range h.queues {
+		if key.libraryID != event.LibraryID {
+			continue
+		}
+		for _, e := range eventToProto(event) {
+			e.DeviceId = proto.String(key.deviceID)
+			queue.events = append(queue.events, e)
+		}
+		if len(queue.events) > maxQueuedEvents {
+			queue.events = queue.events[len(queue.events)-maxQueuedEvents:]
+		}
+		close(queue.ready)
+		queue.ready = make(chan struct{})
+	}
+}
+
+// version returns the number of events published for a library so far. ok
+// is false once the provider stopped reporting changes, as they can't be
+// told anymore.
+func (h *eventHub) version(libraryID string) (version uint64, ok bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.versions[libraryID], !h.closed
+}
+
+// expire removes the queues of devices that stopped asking for events. Must
+// be called with h.lock held.
+func (h *eventHub) expire(now time.Time) {
+	for key, queue := range h.queues {
+		if queue.waiting == 0 && now.Sub(queue.lastPoll) > h.expiry {
+			delete(h.queues, key)
+		}
+	}
+}
+
+// next returns the events queued for a device, waiting for some up to the
+// given time. The first call for a device starts queueing events for it.
+// Queues of devices that don't call next for a while are removed.
+func (h *eventHub) next(ctx context.Context, key eventQueueKey, wait time.Duration) []*enginelibrary.Event {
+	h.lock.Lock()
+	now := time.Now()
+	h.expire(now)
+	queue, ok := h.queues[key]
+	if !ok {
+		queue = &eventQueue{ready: make(chan struct{})}
+		h.queues[key] = queue
+	}
+	queue.waiting++
+	defer func() {
+		h.lock.Lock()
+		queue.waiting--
+		queue.lastPoll = time.Now()
+		h.lock.Unlock()
+	}()
+	if len(queue.events) == 0 {
+		ready := queue.ready
+		h.lock.Unlock()
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		case <-ready:
+		}
+		h.lock.Lock()
+	}
+	events := queue.events
+	queue.events = nil
+	h.lock.Unlock()
+
+	if events == nil {
+		events = []*enginelibrary.Event{}
+	}
+	return events
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EventHub(t *testing.T) {
+	events := make(chan *Event)
+	hub := newEventHub(events)
+	defer close(events)
+	ctx := context.Background()
+	prime4 := eventQueueKey{libraryID: "lib", deviceID: "prime4"}
+	sc6000 := eventQueueKey{libraryID: "lib", deviceID: "sc6000"}
+
+	// devices get queues on their first request
+	require.Empty(t, hub.next(ctx, prime4, time.Millisecond))
+	require.Empty(t, hub.next(ctx, sc6000, time.Millisecond))
+
+	events <- &Event{LibraryID: "other", PlaylistHierarchyChanged: true}
+	events <- &Event{
+		LibraryID:   "lib",
+		PlaylistIDs: []string{"pl"},
+		Tracks:      []*Track{{ID: "1", Title: "Whiplash"}},
+	}
+
+	received := hub.next(ctx, prime4, 5*time.Second)
+	require.Len(t, received, 2)
+	require.Equal(t, "prime4", received[0].GetDeviceId())
+	require.Equal(t, []string{"pl"}, received[0].GetPlaylistsContentChanged().GetPlaylistId())
+	require.Equal(t, "Whiplash", received[1].GetTrackMetadataChanged().GetTrackMetadata()[0].GetTitle())
+	version, ok := hub.version("lib")
+	require.True(t, ok)
+	require.Equal(t, uint64(1), version)
+
+	// every device sees every event
+	require.Len(t, hub.next(ctx, sc6000, time.Millisecond), 2)
+	require.Empty(t, hub.next(ctx, prime4, time.Millisecond))
+
+	// waiting requests return as soon as events come in
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		events <- &Event{LibraryID: "lib", PlaylistHierarchyChanged: true}
+	}()
+	received = hub.next(ctx, prime4, 5*time.Second)
+	require.Len(t, received, 1)
+	require.NotNil(t, received[0].GetPlaylistHierarchyChanged())
+	require.Len(t, hub.next(ctx, sc6000, time.Millisecond), 1)
+
+	// queues of devices that stop asking are removed
+	hub.lock.Lock()
+	hub.expiry = 10 * time.Millisecond
+	hub.lock.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	require.Empty(t, hub.next(ctx, prime4, time.Millisecond))
+	hub.lock.Lock()
+	require.Len(t, hub.queues, 1)
+	require.Contains(t, hub.queues, prime4)
+	hub.lock.Unlock()
+}
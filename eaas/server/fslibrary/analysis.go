@@ -0,0 +1,111 @@
+package fslibrary
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dhowden/tag"
+	"github.com/icedream/go-stagelinq/eaas/cues"
+	"github.com/icedream/go-stagelinq/eaas/perfdata"
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+// analysis holds the performance data generated for a file. It is carried
+// over to the next scan as long as the file does not change.
+type analysis struct {
+	once sync.Once
+	// data is nil if the file could not be analyzed
+	data *server.PerformanceData
+}
+
+// PerformanceData implements server.LibraryProvider. The overview waveform is
+// generated from the audio the first time a track is asked for, along with
+// the hot cues and loops Serato DJ stored in the tags. There is no beat grid
+// as tags tell the BPM but not where the first downbeat is, so devices place
+// the grid themselves. Tracks that can't be decoded have no performance data,
+// so devices analyze them themselves.
+func (p *Provider) PerformanceData(ctx context.Context, libraryID, trackID string) (*server.PerformanceData, error) {
+	if libraryID != p.library.ID || p.config.DisableAnalysis {
+		return nil, server.ErrNotFound
+	}
+	var f *file
+	for _, candidate := range p.current().files {
+		if candidate.track.ID == trackID {
+			f = candidate
+			break
+		}
+	}
+	if f == nil {
+		return nil, server.ErrNotFound
+	}
+	f.analysis.once.Do(func() {
+		// decoding is heavy, so analyze one file at a time
+		p.analysisLock.Lock()
+		defer p.analysisLock.Unlock()
+		f.analysis.data = p.analyze(f)
+	})
+	if f.analysis.data == nil {
+		return nil, server.ErrNotFound
+	}
+	return f.analysis.data, nil
+}
+
+// analyze decodes a file and returns its performance data, or nil if that
+// fails.
+func (p *Provider) analyze(f *file) *server.PerformanceData {
+	name := filepath.Join(p.root, filepath.FromSlash(f.rel))
+	r, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	audio, err := perfdata.OpenAudio(r, name)
+	if err != nil {
+		return nil
+	}
+	waveform, err := perfdata.GenerateOverviewWaveform(audio)
+	if err != nil {
+		return nil
+	}
+
+	data := &server.PerformanceData{
+		BPM:              f.track.BPM,
+		OverviewWaveform: waveform.Encode(),
+	}
+	if c := readSeratoCues(r, audio.SampleRate()); c != nil {
+		c.Apply(data)
+	}
+	return data
+}
+
+// readSeratoCues returns the cues Serato DJ stored in the ID3 tag of a file,
+// or nil if there are none.
+func readSeratoCues(r io.ReadSeeker, sampleRate float64) *cues.Cues {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil
+	}
+	metadata, err := tag.ReadFrom(r)
+	if err != nil {
+		return nil
+	}
+	for name, value := range metadata.Raw() {
+		frame, ok := value.([]byte)
+		if !ok || !strings.HasPrefix(name, "GEOB") {
+			continue
+		}
+		description, object, err := cues.DecodeGEOB(frame)
+		if err != nil || description != cues.SeratoMarkers2 {
+			continue
+		}
+		c, err := cues.DecodeSeratoMarkers2(object, sampleRate)
+		if err != nil || c.IsEmpty() {
+			return nil
+		}
+		return c
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+/*
+This package serves a folder of audio files as an EAAS library.
+
+Folders become playlists, nested like on disk, and tags are read from the
+files. M3U and PLS playlist files show up next to the folders they are in and
+Serato DJ crates kept in _Serato_/Subcrates below the folder at the top
+level, both keeping the order of their entries. Changes to the folder are picked up while serving and reported to
+devices. Overview waveforms are generated from the audio the first time a
+device asks for the performance data of a track, and hot cues and loops set
+in Serato DJ are read from the tags.
+*/
+package fslibrary
@@ -0,0 +1,278 @@
+package fslibrary
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+// seratoCrates is where Serato DJ keeps its crates, relative to the root.
+const seratoCrates = "_Serato_/Subcrates"
+
+// seratoCrateSeparator separates the names of nested crates in the file
+// name of a crate.
+const seratoCrateSeparator = "%%"
+
+var errInvalidCrate = errors.New("invalid Serato crate")
+
+// list is a playlist read from a playlist file or Serato crate.
+type list struct {
+	rel      string
+	playlist *server.Playlist
+	tracks   []*server.Track
+}
+
+// isPlaylistFile reports whether a file is a playlist file or Serato crate.
+func isPlaylistFile(rel string) bool {
+	switch strings.ToLower(path.Ext(rel)) {
+	case ".m3u", ".m3u8", ".pls":
+		return true
+	case ".crate":
+		return path.Dir(rel) == seratoCrates
+	}
+	return false
+}
+
+// buildLists reads the playlist files and crates found by the scan. Playlist
+// files show up next to the folders they are in, crates at the top level,
+// nested like in Serato DJ. Entries that are not tracks of the snapshot are
+// left out.
+func (s *snapshot) buildLists(p *Provider, rels []string) (files, crates []*list) {
+	sort.Strings(rels)
+	byName := map[string]*list{}
+	for _, rel := range rels {
+		name := filepath.Join(p.root, filepath.FromSlash(rel))
+		b, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+
+		if path.Dir(rel) == seratoCrates {
+			entries, err := readCrate(b)
+			if err != nil {
+				continue
+			}
+			l := s.crate(p, byName, strings.TrimSuffix(path.Base(rel), path.Ext(rel)))
+			for _, entry := range entries {
+				if f := s.resolveCrateEntry(p, entry); f != nil {
+					l.tracks = append(l.tracks, f.track)
+				}
+			}
+			continue
+		}
+
+		var entries []string
+		if strings.EqualFold(path.Ext(rel), ".pls") {
+			entries = readPLS(b)
+		} else {
+			entries = readM3U(b, strings.EqualFold(path.Ext(rel), ".m3u"))
+		}
+		l := &list{
+			rel: rel,
+			playlist: &server.Playlist{
+				ID:    p.id("list", rel),
+				Title: strings.TrimSuffix(path.Base(rel), path.Ext(rel)),
+			},
+		}
+		for _, entry := range entries {
+			if f := s.resolveEntry(p, path.Dir(rel), entry); f != nil {
+				l.tracks = append(l.tracks, f.track)
+			}
+		}
+		s.lists[rel] = l
+		files = append(files, l)
+	}
+
+	for _, l := range s.lists {
+		l.playlist.TrackCount = len(l.tracks)
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l := byName[name]
+		if i := strings.LastIndex(name, seratoCrateSeparator); i >= 0 {
+			parent := byName[name[:i]]
+			parent.playlist.Children = append(parent.playlist.Children, l.playlist)
+		} else {
+			crates = append(crates, l)
+		}
+	}
+	return
+}
+
+// crate returns the crate of the given name, adding it and the crates it is
+// nested in if needed.
+func (s *snapshot) crate(p *Provider, byName map[string]*list, name string) *list {
+	if l, ok := byName[name]; ok {
+		return l
+	}
+	title := name
+	if i := strings.LastIndex(name, seratoCrateSeparator); i >= 0 {
+		s.crate(p, byName, name[:i])
+		title = name[i+len(seratoCrateSeparator):]
+	}
+	rel := seratoCrates + "/" + name + ".crate"
+	l := &list{
+		rel: rel,
+		playlist: &server.Playlist{
+			ID:    p.id("list", rel),
+			Title: title,
+		},
+	}
+	byName[name] = l
+	s.lists[rel] = l
+	return l
+}
+
+// resolveEntry returns the file a playlist entry points at, or nil if it is
+// not part of the snapshot. Entries are file paths, relative to the folder of
+// the playlist file or absolute, or file URLs.
+func (s *snapshot) resolveEntry(p *Provider, dir, entry string) *file {
+	if strings.HasPrefix(strings.ToLower(entry), "file:") {
+		u, err := url.Parse(entry)
+		if err != nil {
+			return nil
+		}
+		entry = u.Path
+		if len(entry) > 2 && entry[0] == '/' && entry[2] == ':' {
+			// file:///C:/Music/a.mp3
+			entry = entry[1:]
+		}
+	}
+	// playlists written on Windows separate folders by backslashes
+	entry = strings.ReplaceAll(entry, "\\", "/")
+
+	var rel string
+	if isAbs(entry) {
+		rel = p.rel(entry)
+	} else {
+		rel = path.Join(dir, entry)
+	}
+	return s.files[path.Clean(rel)]
+}
+
+// resolveCrateEntry returns the file a crate entry points at, or nil if it is
+// not part of the snapshot. Crates store paths relative to the drive they are
+// on, which is taken to be the root first and the root of the file system
+// then.
+func (s *snapshot) resolveCrateEntry(p *Provider, entry string) *file {
+	entry = strings.TrimPrefix(strings.ReplaceAll(entry, "\\", "/"), "/")
+	if f, ok := s.files[path.Clean(entry)]; ok {
+		return f
+	}
+	return s.files[p.rel("/"+entry)]
+}
+
+func isAbs(name string) bool {
+	return strings.HasPrefix(name, "/") || len(name) > 2 && name[1] == ':' && name[2] == '/'
+}
+
+// readM3U returns the entries of an M3U playlist. Plain .m3u files that are
+// not valid UTF-8 are read as Latin-1.
+func readM3U(b []byte, latin1 bool) (entries []string) {
+	b = bytes.TrimPrefix(b, []byte("\ufeff"))
+	if latin1 && !utf8.Valid(b) {
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		b = []byte(string(runes))
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return
+}
+
+// readPLS returns the entries of a PLS playlist, ordered by their number.
+func readPLS(b []byte) []string {
+	b = bytes.TrimPrefix(b, []byte("\ufeff"))
+	type entry struct {
+		n    int
+		name string
+	}
+	var entries []entry
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || len(key) <= 4 || !strings.EqualFold(key[:4], "file") {
+			continue
+		}
+		n, err := strconv.Atoi(key[4:])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{n, strings.TrimSpace(value)})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].n < entries[j].n
+	})
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.name
+	}
+	return names
+}
+
+// readCrate returns the track paths of a Serato crate. Crates are a sequence
+// of fields, each a four letter tag, a big endian length and the value. Each
+// track is an "otrk" field holding a "ptrk" field with its UTF-16 path.
+func readCrate(b []byte) (entries []string, err error) {
+	err = crateFields(b, func(tag string, value []byte) error {
+		if tag != "otrk" {
+			return nil
+		}
+		return crateFields(value, func(tag string, value []byte) error {
+			if tag == "ptrk" {
+				entries = append(entries, decodeUTF16(value))
+			}
+			return nil
+		})
+	})
+	return
+}
+
+func crateFields(b []byte, f func(tag string, value []byte) error) error {
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return errInvalidCrate
+		}
+		n := binary.BigEndian.Uint32(b[4:8])
+		if uint64(n) > uint64(len(b)-8) {
+			return errInvalidCrate
+		}
+		if err := f(string(b[:4]), b[8:8+n]); err != nil {
+			return err
+		}
+		b = b[8+n:]
+	}
+	return nil
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
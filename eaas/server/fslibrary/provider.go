@@ -0,0 +1,401 @@
+package fslibrary
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/google/uuid"
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+// DefaultExtensions are the file name extensions of the audio files served
+// unless configured otherwise.
+var DefaultExtensions = []string{
+	".mp3", ".m4a", ".mp4", ".aac", ".flac", ".ogg", ".wav", ".aif", ".aiff", ".alac",
+}
+
+const defaultDebounce = time.Second
+
+// ProviderConfiguration contains configurable values for a Provider.
+type ProviderConfiguration struct {
+	// Title is the name of the library shown on devices. Defaults to the name
+	// of the root folder.
+	Title string
+
+	// Extensions are the file name extensions of the audio files to serve.
+	// Defaults to DefaultExtensions.
+	Extensions []string
+
+	// DisableWatch turns off watching the folder for changes. Call Rescan to
+	// pick up changes instead.
+	DisableWatch bool
+
+	// Debounce is how long to wait for the folder to settle after a change
+	// before scanning it again. Defaults to 1 second.
+	Debounce time.Duration
+
+	// DisableAnalysis turns off generating overview waveforms and beat grids
+	// from the audio files.
+	DisableAnalysis bool
+}
+
+var _ server.LibraryProvider = &Provider{}
+var _ server.EventProvider = &Provider{}
+
+// Provider serves a folder of audio files as a single library.
+//
+// Track and playlist IDs are derived from paths relative to the folder, so
+// they stay the same across restarts as long as files are not moved.
+type Provider struct {
+	config    ProviderConfiguration
+	root      string
+	namespace uuid.UUID
+	library   *server.Library
+
+	lock     sync.RWMutex
+	snapshot *snapshot
+
+	// scanLock serializes scans
+	scanLock sync.Mutex
+
+	// analysisLock serializes analyses
+	analysisLock sync.Mutex
+
+	watcher *fsnotify.Watcher
+	events  chan *server.Event
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewProvider scans the given folder and, unless disabled, starts watching it
+// for changes.
+func NewProvider(root string, config *ProviderConfiguration) (p *Provider, err error) {
+	if config == nil {
+		config = new(ProviderConfiguration)
+	}
+	c := *config
+	if len(c.Extensions) == 0 {
+		c.Extensions = DefaultExtensions
+	}
+	if c.Debounce <= 0 {
+		c.Debounce = defaultDebounce
+	}
+
+	if root, err = filepath.Abs(root); err != nil {
+		return
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return
+	}
+	if !info.IsDir() {
+		err = &os.PathError{Op: "open", Path: root, Err: errors.New("not a directory")}
+		return
+	}
+	if c.Title == "" {
+		c.Title = filepath.Base(root)
+	}
+
+	namespace := uuid.NewSHA1(uuid.NameSpaceURL, []byte("file://"+filepath.ToSlash(root)))
+	p = &Provider{
+		config:    c,
+		root:      root,
+		namespace: namespace,
+		library: &server.Library{
+			ID:    namespace.String(),
+			Title: c.Title,
+		},
+		events:  make(chan *server.Event, 16),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if p.snapshot, err = p.scan(nil); err != nil {
+		return nil, err
+	}
+
+	if c.DisableWatch {
+		close(p.stopped)
+		return
+	}
+	if p.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return nil, err
+	}
+	p.watchDirs(p.snapshot.dirs)
+	go p.watch()
+	return
+}
+
+// Close stops watching the folder and closes the event channel.
+func (p *Provider) Close() (err error) {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+	close(p.done)
+	if p.watcher != nil {
+		err = p.watcher.Close()
+	}
+	<-p.stopped
+	p.scanLock.Lock()
+	close(p.events)
+	p.scanLock.Unlock()
+	return
+}
+
+// Events implements server.EventProvider.
+func (p *Provider) Events() <-chan *server.Event {
+	return p.events
+}
+
+// Rescan scans the folder again right away and reports what changed.
+func (p *Provider) Rescan() (err error) {
+	p.scanLock.Lock()
+	defer p.scanLock.Unlock()
+	select {
+	case <-p.done:
+		return errors.New("provider closed")
+	default:
+	}
+
+	p.lock.RLock()
+	previous := p.snapshot
+	p.lock.RUnlock()
+
+	current, err := p.scan(previous)
+	if err != nil {
+		return
+	}
+	p.lock.Lock()
+	p.snapshot = current
+	p.lock.Unlock()
+
+	if p.watcher != nil {
+		p.watchDirs(current.dirs)
+	}
+	if event := p.diff(previous, current); event != nil {
+		select {
+		case p.events <- event:
+		case <-p.done:
+		}
+	}
+	return
+}
+
+func (p *Provider) watchDirs(dirs []string) {
+	watched := map[string]bool{}
+	for _, dir := range p.watcher.WatchList() {
+		watched[dir] = true
+	}
+	for _, dir := range dirs {
+		if !watched[dir] {
+			// directories that vanished in the meantime are picked up by the
+			// next scan
+			_ = p.watcher.Add(dir)
+		}
+	}
+}
+
+// watch rescans the folder once changes to it have settled.
+func (p *Provider) watch() {
+	defer close(p.stopped)
+
+	timer := time.NewTimer(p.config.Debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-p.done:
+			timer.Stop()
+			return
+		case _, ok := <-p.watcher.Events:
+			if !ok {
+				return
+			}
+			timer.Reset(p.config.Debounce)
+		case _, ok := <-p.watcher.Errors:
+			if !ok {
+				return
+			}
+			// events may have been lost, so look at everything again
+			timer.Reset(p.config.Debounce)
+		case <-timer.C:
+			_ = p.Rescan()
+		}
+	}
+}
+
+// diff works out the event reporting the changes between two scans, or nil
+// if nothing changed.
+func (p *Provider) diff(previous, current *snapshot) *server.Event {
+	event := &server.Event{LibraryID: p.library.ID}
+
+	for dir := range current.folders {
+		if _, ok := previous.folders[dir]; !ok {
+			event.PlaylistHierarchyChanged = true
+		}
+	}
+	for dir := range previous.folders {
+		if _, ok := current.folders[dir]; !ok {
+			event.PlaylistHierarchyChanged = true
+		}
+	}
+	for rel := range current.lists {
+		if _, ok := previous.lists[rel]; !ok {
+			event.PlaylistHierarchyChanged = true
+		}
+	}
+	for rel := range previous.lists {
+		if _, ok := current.lists[rel]; !ok {
+			event.PlaylistHierarchyChanged = true
+		}
+	}
+
+	for _, dir := range sortedKeys(current.folders) {
+		f := current.folders[dir]
+		old, ok := previous.folders[dir]
+		if !ok || !sameTracks(old.tracks, f.tracks) {
+			event.PlaylistIDs = append(event.PlaylistIDs, f.playlist.ID)
+		}
+	}
+	for _, rel := range sortedKeys(current.lists) {
+		l := current.lists[rel]
+		old, ok := previous.lists[rel]
+		if !ok || !sameTracks(old.tracks, l.tracks) {
+			event.PlaylistIDs = append(event.PlaylistIDs, l.playlist.ID)
+		}
+	}
+
+	for _, track := range current.tracks {
+		rel := p.rel(track.URL)
+		// new tracks are reported too, devices don't learn about tracks
+		// outside of playlists otherwise
+		if old, ok := previous.files[rel]; !ok || old.track != current.files[rel].track {
+			event.Tracks = append(event.Tracks, track)
+		}
+	}
+
+	if !event.PlaylistHierarchyChanged && len(event.PlaylistIDs) == 0 && len(event.Tracks) == 0 {
+		return nil
+	}
+	return event
+}
+
+func sameTracks(a, b []*server.Track) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// rel returns the slash-separated path of a track URL relative to the root.
+func (p *Provider) rel(url string) string {
+	rel, err := filepath.Rel(p.root, filepath.FromSlash(url))
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (p *Provider) current() *snapshot {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.snapshot
+}
+
+// Libraries implements server.LibraryProvider.
+func (p *Provider) Libraries(ctx context.Context) ([]*server.Library, error) {
+	return []*server.Library{p.library}, nil
+}
+
+// Playlists implements server.LibraryProvider.
+func (p *Provider) Playlists(ctx context.Context, libraryID string) ([]*server.Playlist, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	return p.current().top, nil
+}
+
+// Tracks implements server.LibraryProvider.
+func (p *Provider) Tracks(ctx context.Context, libraryID, playlistID string) ([]*server.Track, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	s := p.current()
+	if playlistID == "" {
+		return s.tracks, nil
+	}
+	for _, f := range s.folders {
+		if f.playlist.ID == playlistID {
+			return f.tracks, nil
+		}
+	}
+	// playlist files and crates keep the order of their entries
+	for _, l := range s.lists {
+		if l.playlist.ID == playlistID {
+			return l.tracks, nil
+		}
+	}
+	return nil, server.ErrNotFound
+}
+
+// Track implements server.LibraryProvider.
+func (p *Provider) Track(ctx context.Context, libraryID, trackID string) (*server.Track, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	for _, track := range p.current().tracks {
+		if track.ID == trackID {
+			return track, nil
+		}
+	}
+	return nil, server.ErrNotFound
+}
+
+// OpenBlob implements server.LibraryProvider. Only the audio files of tracks
+// found by the last scan can be opened, and only as long as they still are
+// regular files within the root folder.
+func (p *Provider) OpenBlob(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	rel := p.rel(url)
+	if _, ok := p.current().files[rel]; !ok {
+		return nil, server.ErrNotFound
+	}
+
+	// the file may have been replaced by a symlink since the scan
+	root, err := filepath.EvalSymlinks(p.root)
+	if err != nil {
+		return nil, server.ErrNotFound
+	}
+	name, err := filepath.EvalSymlinks(filepath.Join(p.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, server.ErrNotFound
+	}
+	if resolved, err := filepath.Rel(root, name); err != nil || resolved != filepath.FromSlash(rel) {
+		return nil, server.ErrNotFound
+	}
+	info, err := os.Stat(name)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, server.ErrNotFound
+	}
+	return os.Open(name)
+}
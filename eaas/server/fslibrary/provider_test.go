@@ -0,0 +1,403 @@
+package fslibrary
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image/color"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/icedream/go-stagelinq/eaas/cues"
+	"github.com/icedream/go-stagelinq/eaas/perfdata"
+	"github.com/icedream/go-stagelinq/eaas/server"
+	"github.com/stretchr/testify/require"
+)
+
+// id3v2Tag builds a minimal ID3v2.3 tag with text frames, which is enough
+// for tag.ReadFrom to read a file.
+func id3v2Tag(frames map[string]string) []byte {
+	body := new(bytes.Buffer)
+	for id, text := range frames {
+		body.WriteString(id)
+		_ = binary.Write(body, binary.BigEndian, uint32(len(text)+1))
+		body.Write([]byte{0, 0, 0}) // flags and ISO-8859-1 encoding
+		body.WriteString(text)
+	}
+	size := body.Len()
+	b := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(b, body.Bytes()...)
+}
+
+func writeFile(t *testing.T, name string, content []byte) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+	require.NoError(t, os.WriteFile(name, content, 0o644))
+}
+
+func Test_Provider(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "Techno", "Peak", "a.mp3"), id3v2Tag(map[string]string{
+		"TIT2": "Whiplash",
+		"TPE1": "Icedream",
+		"TBPM": "140",
+		"TKEY": "Am",
+	}))
+	writeFile(t, filepath.Join(root, "Techno", "Artist - Untagged.wav"), []byte("RIFF"))
+	writeFile(t, filepath.Join(root, "loose.flac"), []byte("fLaC"))
+	writeFile(t, filepath.Join(root, "Techno", "notes.txt"), []byte("not audio"))
+	writeFile(t, filepath.Join(root, ".hidden", "b.mp3"), []byte("ID3"))
+
+	p, err := NewProvider(root, &ProviderConfiguration{DisableWatch: true})
+	require.NoError(t, err)
+	defer p.Close()
+	ctx := context.Background()
+
+	libraries, err := p.Libraries(ctx)
+	require.NoError(t, err)
+	require.Len(t, libraries, 1)
+	require.Equal(t, filepath.Base(root), libraries[0].Title)
+	libraryID := libraries[0].ID
+
+	playlists, err := p.Playlists(ctx, libraryID)
+	require.NoError(t, err)
+	require.Len(t, playlists, 1)
+	require.Equal(t, "Techno", playlists[0].Title)
+	require.Equal(t, 1, playlists[0].TrackCount)
+	require.Len(t, playlists[0].Children, 1)
+	require.Equal(t, "Peak", playlists[0].Children[0].Title)
+
+	tracks, err := p.Tracks(ctx, libraryID, "")
+	require.NoError(t, err)
+	require.Len(t, tracks, 3)
+
+	peak, err := p.Tracks(ctx, libraryID, playlists[0].Children[0].ID)
+	require.NoError(t, err)
+	require.Len(t, peak, 1)
+	require.Equal(t, "Whiplash", peak[0].Title)
+	require.Equal(t, "Icedream", peak[0].Artist)
+	require.Equal(t, 140.0, peak[0].BPM)
+	require.Equal(t, "Am", peak[0].Key)
+
+	techno, err := p.Tracks(ctx, libraryID, playlists[0].ID)
+	require.NoError(t, err)
+	require.Len(t, techno, 1)
+	require.Equal(t, "Artist", techno[0].Artist)
+	require.Equal(t, "Untagged", techno[0].Title)
+
+	_, err = p.Tracks(ctx, libraryID, "missing")
+	require.ErrorIs(t, err, server.ErrNotFound)
+	_, err = p.Playlists(ctx, "missing")
+	require.ErrorIs(t, err, server.ErrNotFound)
+
+	// IDs are stable across restarts
+	again, err := NewProvider(root, &ProviderConfiguration{DisableWatch: true})
+	require.NoError(t, err)
+	defer again.Close()
+	track, err := again.Track(ctx, libraryID, peak[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, peak[0].URL, track.URL)
+
+	blob, err := p.OpenBlob(ctx, track.URL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(blob)
+	blob.Close()
+	require.NoError(t, err)
+	require.Equal(t, []byte("ID3"), b[:3])
+
+	// only the audio files of the library can be downloaded
+	for _, name := range []string{
+		filepath.Join(root, "Techno", "notes.txt"),
+		filepath.Join(root, ".hidden", "b.mp3"),
+		filepath.Join(root, "..", "escape.mp3"),
+		filepath.Join(root, "Techno"),
+	} {
+		_, err = p.OpenBlob(ctx, filepath.ToSlash(name))
+		require.ErrorIs(t, err, server.ErrNotFound, name)
+	}
+
+	// files replaced by symlinks since the scan are not followed
+	secret := filepath.Join(t.TempDir(), "secret.flac")
+	writeFile(t, secret, []byte("secret"))
+	loose := filepath.Join(root, "loose.flac")
+	require.NoError(t, os.Remove(loose))
+	require.NoError(t, os.Symlink(secret, loose))
+	_, err = p.OpenBlob(ctx, filepath.ToSlash(loose))
+	require.ErrorIs(t, err, server.ErrNotFound)
+}
+
+func nextEvent(t *testing.T, p *Provider) *server.Event {
+	select {
+	case event := <-p.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	return nil
+}
+
+func Test_Provider_Watch(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "House", "a.mp3"), id3v2Tag(map[string]string{"TIT2": "First"}))
+
+	p, err := NewProvider(root, &ProviderConfiguration{Debounce: 100 * time.Millisecond})
+	require.NoError(t, err)
+	defer p.Close()
+	ctx := context.Background()
+	libraryID := p.library.ID
+	playlists, err := p.Playlists(ctx, libraryID)
+	require.NoError(t, err)
+	house := playlists[0].ID
+
+	// adding a track changes the playlist content
+	writeFile(t, filepath.Join(root, "House", "b.mp3"), id3v2Tag(map[string]string{"TIT2": "Second"}))
+	event := nextEvent(t, p)
+	require.Equal(t, libraryID, event.LibraryID)
+	require.False(t, event.PlaylistHierarchyChanged)
+	require.Equal(t, []string{house}, event.PlaylistIDs)
+	tracks, err := p.Tracks(ctx, libraryID, house)
+	require.NoError(t, err)
+	require.Len(t, tracks, 2)
+
+	// retagging a track changes its metadata
+	name := filepath.Join(root, "House", "a.mp3")
+	writeFile(t, name, id3v2Tag(map[string]string{"TIT2": "First (Edit)"}))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(name, later, later))
+	event = nextEvent(t, p)
+	require.Empty(t, event.PlaylistIDs)
+	require.Len(t, event.Tracks, 1)
+	require.Equal(t, "First (Edit)", event.Tracks[0].Title)
+
+	// new folders are watched as well
+	writeFile(t, filepath.Join(root, "Techno", "c.mp3"), id3v2Tag(map[string]string{"TIT2": "Third"}))
+	event = nextEvent(t, p)
+	require.True(t, event.PlaylistHierarchyChanged)
+	writeFile(t, filepath.Join(root, "Techno", "d.mp3"), id3v2Tag(map[string]string{"TIT2": "Fourth"}))
+	event = nextEvent(t, p)
+	require.Len(t, event.PlaylistIDs, 1)
+
+	require.NoError(t, p.Close())
+	_, ok := <-p.Events()
+	require.False(t, ok)
+}
+
+// crateField encodes a field of a Serato crate.
+func crateField(tag string, value []byte) []byte {
+	b := []byte(tag)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(value)))
+	return append(b, value...)
+}
+
+// crate builds a Serato crate of the given track paths.
+func crate(paths ...string) []byte {
+	b := crateField("vrsn", utf16BE("1.0/Serato ScratchLive Crate"))
+	for _, name := range paths {
+		b = append(b, crateField("otrk", crateField("ptrk", utf16BE(name)))...)
+	}
+	return b
+}
+
+func utf16BE(s string) (b []byte) {
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = binary.BigEndian.AppendUint16(b, c)
+	}
+	return
+}
+
+func Test_Provider_PlaylistFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.mp3", "b.mp3", "c.flac"} {
+		writeFile(t, filepath.Join(root, "Music", name), []byte("ID3"))
+	}
+	abs := filepath.ToSlash(filepath.Join(root, "Music", "b.mp3"))
+	fileURL := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(root, "Music", "a.mp3"))}).String()
+	writeFile(t, filepath.Join(root, "Sets", "Friday.m3u8"), []byte(strings.Join([]string{
+		"#EXTM3U",
+		"#EXTINF:300,Artist - Title",
+		"..\\Music\\c.flac",
+		abs,
+		fileURL,
+		"missing.mp3",
+	}, "\r\n")))
+	writeFile(t, filepath.Join(root, "Warmup.pls"), []byte("[playlist]\nFile2=Music/a.mp3\nTitle2=A\nFile1=Music/c.flac\nNumberOfEntries=2\n"))
+	writeFile(t, filepath.Join(root, "_Serato_", "Subcrates", "Gigs%%Saturday.crate"), crate("Music/b.mp3", strings.TrimPrefix(abs, "/"), "Music/a.mp3"))
+	writeFile(t, filepath.Join(root, "_Serato_", "Subcrates", "Broken.crate"), []byte("otrk"))
+
+	p, err := NewProvider(root, &ProviderConfiguration{DisableWatch: true, DisableAnalysis: true})
+	require.NoError(t, err)
+	defer p.Close()
+	ctx := context.Background()
+	libraryID := p.library.ID
+
+	tracks, err := p.Tracks(ctx, libraryID, "")
+	require.NoError(t, err)
+	require.Len(t, tracks, 3)
+	a, b, c := tracks[0], tracks[1], tracks[2]
+
+	playlists, err := p.Playlists(ctx, libraryID)
+	require.NoError(t, err)
+	require.Len(t, playlists, 4)
+	require.Equal(t, "Music", playlists[0].Title)
+	sets := playlists[1]
+	require.Equal(t, "Sets", sets.Title)
+	require.Zero(t, sets.TrackCount)
+	require.Len(t, sets.Children, 1)
+	friday := sets.Children[0]
+	require.Equal(t, "Friday", friday.Title)
+	require.Equal(t, 3, friday.TrackCount)
+	warmup := playlists[2]
+	require.Equal(t, "Warmup", warmup.Title)
+	gigs := playlists[3]
+	require.Equal(t, "Gigs", gigs.Title)
+	require.Len(t, gigs.Children, 1)
+	saturday := gigs.Children[0]
+	require.Equal(t, "Saturday", saturday.Title)
+
+	// entries keep the order of the playlist
+	for id, expected := range map[string][]*server.Track{
+		friday.ID:   {c, b, a},
+		warmup.ID:   {c, a},
+		saturday.ID: {b, b, a},
+		gigs.ID:     nil,
+	} {
+		tracks, err := p.Tracks(ctx, libraryID, id)
+		require.NoError(t, err)
+		require.Equal(t, expected, tracks)
+	}
+
+	// editing a playlist file changes the playlist content
+	writeFile(t, filepath.Join(root, "Warmup.pls"), []byte("[playlist]\nFile1=Music/b.mp3\n"))
+	require.NoError(t, p.Rescan())
+	event := nextEvent(t, p)
+	require.False(t, event.PlaylistHierarchyChanged)
+	require.Equal(t, []string{warmup.ID}, event.PlaylistIDs)
+	tracks, err = p.Tracks(ctx, libraryID, warmup.ID)
+	require.NoError(t, err)
+	require.Equal(t, []*server.Track{b}, tracks)
+
+	require.NoError(t, os.Remove(filepath.Join(root, "Warmup.pls")))
+	require.NoError(t, p.Rescan())
+	event = nextEvent(t, p)
+	require.True(t, event.PlaylistHierarchyChanged)
+	_, err = p.Tracks(ctx, libraryID, warmup.ID)
+	require.ErrorIs(t, err, server.ErrNotFound)
+}
+
+// sineWAV returns a mono 16-bit WAV file with a 60 Hz sine wave.
+func sineWAV(seconds int) []byte {
+	const sampleRate = 44100
+	samples := seconds * sampleRate
+	buf := new(bytes.Buffer)
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(36+2*samples))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(buf, binary.LittleEndian, []uint32{16})
+	_ = binary.Write(buf, binary.LittleEndian, []uint16{1, 1})
+	_ = binary.Write(buf, binary.LittleEndian, []uint32{sampleRate, 2 * sampleRate})
+	_ = binary.Write(buf, binary.LittleEndian, []uint16{2, 16})
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(2*samples))
+	for i := 0; i < samples; i++ {
+		_ = binary.Write(buf, binary.LittleEndian, int16(16000*math.Sin(2*math.Pi*60*float64(i)/sampleRate)))
+	}
+	return buf.Bytes()
+}
+
+// silentMP3 returns an MP3 file with a second of silence: MPEG-1 layer III
+// frames at 128 kbit/s and 44.1 kHz without any audio data.
+func silentMP3(tag []byte) []byte {
+	b := bytes.Clone(tag)
+	for i := 0; i < 39; i++ {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+		b = append(b, frame...)
+	}
+	return b
+}
+
+func Test_Provider_PerformanceData(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "sine.wav"), sineWAV(1))
+	writeFile(t, filepath.Join(root, "broken.mp3"), id3v2Tag(map[string]string{"TBPM": "128"}))
+	markers, err := cues.EncodeSeratoMarkers2(&cues.Cues{
+		QuickCues: map[int]*server.QuickCue{2: {Name: "Drop", Position: 22050, Color: color.RGBA{R: 0xcc, A: 0xff}}},
+	}, 44100)
+	require.NoError(t, err)
+	writeFile(t, filepath.Join(root, "serato.mp3"), silentMP3(id3v2Tag(map[string]string{
+		"TBPM": "128",
+		// the tag helper adds the encoding byte
+		"GEOB": string(cues.EncodeGEOB(cues.SeratoMarkers2, markers)[1:]),
+	})))
+	writeFile(t, filepath.Join(root, "track.m4a"), []byte("not decodable"))
+
+	p, err := NewProvider(root, &ProviderConfiguration{DisableWatch: true})
+	require.NoError(t, err)
+	defer p.Close()
+	ctx := context.Background()
+	libraryID := p.library.ID
+
+	tracks, err := p.Tracks(ctx, libraryID, "")
+	require.NoError(t, err)
+	require.Len(t, tracks, 4)
+	ids := map[string]string{}
+	for _, track := range tracks {
+		ids[filepath.Base(track.URL)] = track.ID
+	}
+
+	data, err := p.PerformanceData(ctx, libraryID, ids["sine.wav"])
+	require.NoError(t, err)
+	require.Nil(t, data.BeatGrid)
+	waveform, err := perfdata.DecodeOverviewWaveform(data.OverviewWaveform)
+	require.NoError(t, err)
+	require.Len(t, waveform.Points, perfdata.OverviewWaveformEntries)
+	require.InDelta(t, 44100, waveform.SampleCount(), 1e-6)
+	require.Greater(t, waveform.Max.Low, waveform.Max.High)
+
+	// the analysis is kept
+	again, err := p.PerformanceData(ctx, libraryID, ids["sine.wav"])
+	require.NoError(t, err)
+	require.Same(t, data, again)
+
+	data, err = p.PerformanceData(ctx, libraryID, ids["serato.mp3"])
+	require.NoError(t, err)
+	require.Equal(t, 128.0, data.BPM)
+	require.Nil(t, data.BeatGrid)
+	require.Len(t, data.QuickCues, 1)
+	require.Equal(t, "Drop", data.QuickCues[2].Name)
+	require.InDelta(t, 22050, data.QuickCues[2].Position, 1)
+
+	for _, name := range []string{"broken.mp3", "track.m4a", "missing"} {
+		_, err = p.PerformanceData(ctx, libraryID, ids[name])
+		require.ErrorIs(t, err, server.ErrNotFound, name)
+	}
+
+	// files that change are analyzed again
+	name := filepath.Join(root, "sine.wav")
+	writeFile(t, name, sineWAV(2))
+	require.NoError(t, os.Chtimes(name, time.Now(), time.Now().Add(time.Minute)))
+	require.NoError(t, p.Rescan())
+	track, err := p.Track(ctx, libraryID, ids["sine.wav"])
+	require.NoError(t, err)
+	track.BPM = 120
+	data, err = p.PerformanceData(ctx, libraryID, ids["sine.wav"])
+	require.NoError(t, err)
+	require.Equal(t, 120.0, data.BPM)
+	waveform, err = perfdata.DecodeOverviewWaveform(data.OverviewWaveform)
+	require.NoError(t, err)
+	require.InDelta(t, 2*44100, waveform.SampleCount(), 1e-6)
+	require.Nil(t, data.BeatGrid)
+
+	disabled, err := NewProvider(root, &ProviderConfiguration{DisableWatch: true, DisableAnalysis: true})
+	require.NoError(t, err)
+	defer disabled.Close()
+	_, err = disabled.PerformanceData(ctx, libraryID, ids["sine.wav"])
+	require.ErrorIs(t, err, server.ErrNotFound)
+}
@@ -0,0 +1,270 @@
+package fslibrary
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+	"github.com/google/uuid"
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+// file is a scanned audio file.
+type file struct {
+	// rel is the slash-separated path relative to the root.
+	rel      string
+	size     int64
+	modTime  time.Time
+	track    *server.Track
+	analysis *analysis
+}
+
+// folder is a scanned folder containing audio files or playlist files
+// somewhere below it.
+type folder struct {
+	rel      string
+	playlist *server.Playlist
+	tracks   []*server.Track
+}
+
+// snapshot is the result of scanning the root folder.
+type snapshot struct {
+	files   map[string]*file
+	folders map[string]*folder
+	// lists holds the playlist files and crates by path
+	lists map[string]*list
+	// top lists the top level playlists
+	top []*server.Playlist
+	// tracks lists all tracks ordered by path
+	tracks []*server.Track
+	dirs   []string
+}
+
+// id derives a stable ID from a path relative to the root.
+func (p *Provider) id(kind, rel string) string {
+	return uuid.NewSHA1(p.namespace, []byte(kind+":"+rel)).String()
+}
+
+func (p *Provider) isAudioFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range p.config.Extensions {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// scan walks the root folder. Files that did not change since the previous
+// scan keep their track, everything else is read again.
+func (p *Provider) scan(previous *snapshot) (s *snapshot, err error) {
+	s = &snapshot{
+		files:   map[string]*file{},
+		folders: map[string]*folder{},
+		lists:   map[string]*list{},
+	}
+	var lists []string
+	err = filepath.WalkDir(p.root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// unreadable parts of the tree are skipped
+			if d != nil && d.IsDir() && name != p.root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(p.root, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			s.dirs = append(s.dirs, name)
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if isPlaylistFile(rel) {
+			lists = append(lists, rel)
+			return nil
+		}
+		if !p.isAudioFile(name) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		f := &file{rel: rel, size: info.Size(), modTime: info.ModTime()}
+		if previous != nil {
+			if old, ok := previous.files[rel]; ok && old.size == f.size && old.modTime.Equal(f.modTime) {
+				f.track = old.track
+				f.analysis = old.analysis
+			}
+		}
+		if f.track == nil {
+			f.track = p.readTrack(name, f)
+			f.analysis = new(analysis)
+		}
+		s.files[rel] = f
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	s.build(p, lists)
+	return
+}
+
+// build works out the playlists and track lists of a snapshot.
+func (s *snapshot) build(p *Provider, lists []string) {
+	rels := make([]string, 0, len(s.files))
+	for rel := range s.files {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	for _, rel := range rels {
+		track := s.files[rel].track
+		s.tracks = append(s.tracks, track)
+
+		dir := path.Dir(rel)
+		if dir == "." {
+			continue
+		}
+		s.folder(p, dir).tracks = append(s.folder(p, dir).tracks, track)
+		s.parents(p, dir)
+	}
+	files, crates := s.buildLists(p, lists)
+	for _, l := range files {
+		if dir := path.Dir(l.rel); dir != "." {
+			s.folder(p, dir)
+			s.parents(p, dir)
+		}
+	}
+
+	for _, f := range s.folders {
+		f.playlist.TrackCount = len(f.tracks)
+	}
+	dirs := make([]string, 0, len(s.folders))
+	for dir := range s.folders {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		playlist := s.folders[dir].playlist
+		if parent := path.Dir(dir); parent != "." {
+			s.folders[parent].playlist.Children = append(s.folders[parent].playlist.Children, playlist)
+		} else {
+			s.top = append(s.top, playlist)
+		}
+	}
+	// playlist files come after the folders next to them
+	for _, l := range files {
+		if dir := path.Dir(l.rel); dir != "." {
+			s.folders[dir].playlist.Children = append(s.folders[dir].playlist.Children, l.playlist)
+		} else {
+			s.top = append(s.top, l.playlist)
+		}
+	}
+	for _, l := range crates {
+		s.top = append(s.top, l.playlist)
+	}
+}
+
+// parents adds the folders a folder is in.
+func (s *snapshot) parents(p *Provider, dir string) {
+	for parent := path.Dir(dir); parent != "."; parent = path.Dir(parent) {
+		s.folder(p, parent)
+	}
+}
+
+func (s *snapshot) folder(p *Provider, dir string) *folder {
+	f, ok := s.folders[dir]
+	if !ok {
+		f = &folder{
+			rel: dir,
+			playlist: &server.Playlist{
+				ID:    p.id("folder", dir),
+				Title: path.Base(dir),
+			},
+		}
+		s.folders[dir] = f
+	}
+	return f
+}
+
+// readTrack reads the tags of an audio file. Files without readable tags are
+// named after the file, split into artist and title at " - ".
+func (p *Provider) readTrack(name string, f *file) *server.Track {
+	track := &server.Track{
+		ID:        p.id("track", f.rel),
+		URL:       filepath.ToSlash(name),
+		FileSize:  f.size,
+		DateAdded: f.modTime,
+	}
+
+	base := strings.TrimSuffix(path.Base(f.rel), path.Ext(f.rel))
+	if artist, title, ok := strings.Cut(base, " - "); ok {
+		track.Artist, track.Title = artist, title
+	} else {
+		track.Title = base
+	}
+
+	r, err := os.Open(name)
+	if err != nil {
+		return track
+	}
+	defer r.Close()
+	metadata, err := tag.ReadFrom(r)
+	if err != nil {
+		return track
+	}
+	if v := metadata.Title(); v != "" {
+		track.Title = v
+	}
+	if v := metadata.Artist(); v != "" {
+		track.Artist = v
+	}
+	track.Album = metadata.Album()
+	track.Genre = metadata.Genre()
+	track.Comment = metadata.Comment()
+	track.Composer = metadata.Composer()
+	track.Year = metadata.Year()
+	track.Key = rawTag(metadata, "KEY", "TKEY", "initialkey", "INITIALKEY")
+	track.Label = rawTag(metadata, "LABEL", "TPUB", "ORGANIZATION")
+	track.Remixer = rawTag(metadata, "REMIXER", "TPE4")
+	if bpm, err := strconv.ParseFloat(rawTag(metadata, "BPM", "TBPM", "tmpo"), 64); err == nil {
+		track.BPM = bpm
+	}
+	if picture := metadata.Picture(); picture != nil {
+		track.PreviewArtwork = picture.Data
+	}
+	return track
+}
+
+// rawTag returns the first of the given raw tags that is set.
+func rawTag(metadata tag.Metadata, names ...string) string {
+	raw := metadata.Raw()
+	for _, name := range names {
+		if v, ok := raw[name]; ok {
+			if s := strings.Trim(fmt.Sprint(v), "\x00 "); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
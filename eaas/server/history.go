@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// HistorySession is a recorded session of played tracks, typically one gig.
+type HistorySession struct {
+	ID        string
+	Title     string
+	StartTime time.Time
+
+	// Timezone is the name of the time zone the session was played in, if
+	// known.
+	Timezone string
+
+	// Duration is how long the session went on, if known.
+	Duration time.Duration
+
+	// TrackCount is the number of tracks played in the session.
+	TrackCount int
+}
+
+// PlayedTrack is a track played in a history session.
+type PlayedTrack struct {
+	// ID identifies the entry within its session.
+	ID string
+
+	Track     *Track
+	StartTime time.Time
+}
+
+// HistoryProvider can be implemented by a LibraryProvider that keeps a play
+// history. The server serves it to devices via GetHistorySessions and
+// GetHistoryPlayedTracks.
+type HistoryProvider interface {
+	// HistorySessions returns the history sessions of a library, oldest
+	// first.
+	HistorySessions(ctx context.Context, libraryID string) ([]*HistorySession, error)
+
+	// HistoryPlayedTracks returns the tracks played in a session in the order
+	// they were played.
+	HistoryPlayedTracks(ctx context.Context, libraryID, sessionID string) ([]*PlayedTrack, error)
+}
+
+func historySessionToProto(session *HistorySession) *enginelibrary.HistorySession {
+	m := &enginelibrary.HistorySession{
+		Id:               proto.String(session.ID),
+		Title:            optionalString(session.Title),
+		Timezone:         optionalString(session.Timezone),
+		PlayedTrackCount: proto.Uint32(uint32(session.TrackCount)),
+	}
+	if !session.StartTime.IsZero() {
+		m.StartTime = timestamppb.New(session.StartTime)
+	}
+	if session.Duration > 0 {
+		m.EstimatedDurationSeconds = proto.Uint32(uint32(session.Duration.Seconds()))
+	}
+	return m
+}
+
+func playedTrackToProto(track *PlayedTrack) *enginelibrary.HistoryPlayedTrack {
+	m := &enginelibrary.PlayedTrack{
+		PlayedTrackId: proto.String(track.ID),
+	}
+	if track.Track != nil {
+		m.Metadata = trackMetadataToProto(track.Track)
+	}
+	if !track.StartTime.IsZero() {
+		m.StartTime = timestamppb.New(track.StartTime)
+	}
+	return &enginelibrary.HistoryPlayedTrack{PlayedTrack: m}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type httpHandler struct {
+	provider LibraryProvider
+}
+
+// NewHTTPHandler returns the handler for the HTTP endpoints of EAAS, which
+// devices use to check whether the library is reachable (/ping) and to
+// download audio files (/download/{url}), for use with your own HTTP server.
+func NewHTTPHandler(provider LibraryProvider) http.Handler {
+	h := &httpHandler{provider: provider}
+	r := mux.NewRouter()
+	r.UseEncodedPath()
+	r.SkipClean(true)
+	r.HandleFunc("/download/{path}", h.download).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/ping", h.ping).Methods(http.MethodGet)
+	return r
+}
+
+func (h *httpHandler) ping(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *httpHandler) download(w http.ResponseWriter, r *http.Request) {
+	requestedURL, err := url.PathUnescape(mux.Vars(r)["path"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	requestedURL = strings.TrimSuffix(strings.TrimPrefix(requestedURL, "<"), ">")
+
+	blob, err := h.provider.OpenBlob(r.Context(), requestedURL)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, path.Base(requestedURL), time.Time{}, blob)
+}
@@ -0,0 +1,647 @@
+package server
+
+import (
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/icedream/go-stagelinq/eaas/musickey"
+	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// defaultQueryFields are searched when a query names no fields.
+var defaultQueryFields = []enginelibrary.SearchQueryField{
+	enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_TITLE,
+	enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_ARTIST,
+	enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_ALBUM,
+}
+
+// TrackQuery selects, orders and limits the tracks of a TrackIndex, following
+// the EAAS track search and track list requests.
+type TrackQuery struct {
+	// Text is searched for in Fields, which default to title, artist and
+	// album. Each word of the text has to start a word of any of the fields,
+	// ignoring case and accents. Searching the key field for a key finds
+	// tracks in keys compatible with it.
+	Text   string
+	Fields []enginelibrary.SearchQueryField
+
+	// Filters restrict the tracks to those matching all filters. A filter
+	// with several values matches if any of them does. Genres, artists and
+	// albums have to match in full, ignoring case, keys in any notation and
+	// BPMs rounded, either a single one like "128" or a range like
+	// "120-130".
+	Filters []*enginelibrary.SearchFilter
+
+	// Sort orders the tracks, later entries breaking ties of earlier ones.
+	// Tracks without a value for a field come last. Tracks keep the order of
+	// the index otherwise, which for playlists is the playlist order.
+	Sort []*enginelibrary.Sort
+
+	// Limit is the maximum number of tracks returned, all are if 0.
+	Limit int
+}
+
+// TrackFacets are the distinct values of a set of tracks offered as search
+// filters.
+type TrackFacets struct {
+	Genres  []string
+	Artists []string
+	Albums  []string
+
+	// BPMs are rounded and ordered by tempo.
+	BPMs []string
+
+	// Keys are named like Engine DJ does and ordered by the Camelot wheel,
+	// followed by those that could not be parsed.
+	Keys []string
+}
+
+// TrackIndex indexes tracks for searching, filtering and sorting them. It is
+// safe for concurrent use.
+type TrackIndex struct {
+	tracks  []*Track
+	entries []indexEntry
+
+	// values holds the tracks by folded genre, artist, album and unparsed
+	// key
+	values map[enginelibrary.SearchFilterField]map[string][]int32
+	// keys holds the tracks by parsed key
+	keys map[musickey.Key][]int32
+
+	// words is the inverted index of the query fields, built on the first
+	// search
+	wordsOnce sync.Once
+	words     map[enginelibrary.SearchQueryField]*wordIndex
+}
+
+// indexEntry holds what tracks are sorted by.
+type indexEntry struct {
+	title, artist, album, genre, comment, label string
+
+	key    musickey.Key
+	hasKey bool
+}
+
+// NewTrackIndex indexes the given tracks. The tracks must not be changed
+// while the index is in use.
+func NewTrackIndex(tracks []*Track) *TrackIndex {
+	x := &TrackIndex{
+		tracks:  tracks,
+		entries: make([]indexEntry, len(tracks)),
+		values: map[enginelibrary.SearchFilterField]map[string][]int32{
+			enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_GENRE:  {},
+			enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_ARTIST: {},
+			enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_ALBUM:  {},
+			enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_KEY:    {},
+		},
+		keys: map[musickey.Key][]int32{},
+	}
+	for i, track := range tracks {
+		e := &x.entries[i]
+		e.title = fold(track.Title)
+		e.artist = fold(track.Artist)
+		e.album = fold(track.Album)
+		e.genre = fold(track.Genre)
+		e.comment = fold(track.Comment)
+		e.label = fold(track.Label)
+		x.add(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_GENRE, e.genre, i)
+		x.add(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_ARTIST, e.artist, i)
+		x.add(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_ALBUM, e.album, i)
+		if k, err := musickey.Parse(track.Key); err == nil {
+			e.key, e.hasKey = k, true
+			x.keys[k] = append(x.keys[k], int32(i))
+		} else {
+			x.add(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_KEY, fold(track.Key), i)
+		}
+	}
+	return x
+}
+
+func (x *TrackIndex) add(field enginelibrary.SearchFilterField, value string, i int) {
+	if value != "" {
+		x.values[field][value] = append(x.values[field][value], int32(i))
+	}
+}
+
+// Search returns the tracks matching a query in the order it asks for.
+func (x *TrackIndex) Search(q *TrackQuery) []*Track {
+	set := x.match(q)
+	positions := make([]int, 0, len(x.tracks))
+	for i := range x.tracks {
+		if set == nil || set.has(i) {
+			positions = append(positions, i)
+		}
+	}
+	x.sort(positions, q.Sort)
+	if q.Limit > 0 && len(positions) > q.Limit {
+		positions = positions[:q.Limit]
+	}
+	tracks := make([]*Track, len(positions))
+	for i, pos := range positions {
+		tracks[i] = x.tracks[pos]
+	}
+	return tracks
+}
+
+// Facets returns the filter values of the tracks matching the text and
+// filters of a query.
+func (x *TrackIndex) Facets(q *TrackQuery) *TrackFacets {
+	set := x.match(q)
+	genres, artists, albums := map[string]string{}, map[string]string{}, map[string]string{}
+	bpms := map[int]bool{}
+	keys := map[musickey.Key]bool{}
+	unparsed := map[string]string{}
+	for i, track := range x.tracks {
+		if set != nil && !set.has(i) {
+			continue
+		}
+		e := &x.entries[i]
+		facet(genres, e.genre, track.Genre)
+		facet(artists, e.artist, track.Artist)
+		facet(albums, e.album, track.Album)
+		if track.BPM > 0 {
+			bpms[int(math.Round(track.BPM))] = true
+		}
+		if e.hasKey {
+			keys[e.key] = true
+		} else {
+			facet(unparsed, fold(track.Key), track.Key)
+		}
+	}
+
+	facets := &TrackFacets{
+		Genres:  facetValues(genres),
+		Artists: facetValues(artists),
+		Albums:  facetValues(albums),
+	}
+	tempos := make([]int, 0, len(bpms))
+	for bpm := range bpms {
+		tempos = append(tempos, bpm)
+	}
+	sort.Ints(tempos)
+	for _, bpm := range tempos {
+		facets.BPMs = append(facets.BPMs, strconv.Itoa(bpm))
+	}
+	parsed := make([]musickey.Key, 0, len(keys))
+	for k := range keys {
+		parsed = append(parsed, k)
+	}
+	sort.Slice(parsed, func(i, j int) bool {
+		return keyOrder(parsed[i]) < keyOrder(parsed[j])
+	})
+	for _, k := range parsed {
+		facets.Keys = append(facets.Keys, k.String())
+	}
+	facets.Keys = append(facets.Keys, facetValues(unparsed)...)
+	return facets
+}
+
+// facet adds a value by its folded form, keeping the first spelling seen.
+func facet(values map[string]string, folded, value string) {
+	if _, ok := values[folded]; !ok && folded != "" {
+		values[folded] = value
+	}
+}
+
+// facetValues returns the values ordered by their folded form.
+func facetValues(values map[string]string) []string {
+	folded := make([]string, 0, len(values))
+	for f := range values {
+		folded = append(folded, f)
+	}
+	sort.Strings(folded)
+	result := make([]string, len(folded))
+	for i, f := range folded {
+		result[i] = values[f]
+	}
+	return result
+}
+
+// match returns the tracks matching the text and filters of a query, or nil
+// if that's all of them.
+func (x *TrackIndex) match(q *TrackQuery) (set bitset) {
+	fields := q.Fields
+	if len(fields) == 0 {
+		fields = defaultQueryFields
+	}
+	for _, term := range strings.Fields(q.Text) {
+		// terms without words, like a lone dash, match anything
+		if len(words(term)) == 0 {
+			continue
+		}
+		set = intersect(set, x.matchTerm(term, fields))
+	}
+	for _, filter := range q.Filters {
+		if len(filter.GetValue()) == 0 {
+			continue
+		}
+		if matched := x.matchFilter(filter); matched != nil {
+			set = intersect(set, matched)
+		}
+	}
+	return
+}
+
+func intersect(set, other bitset) bitset {
+	if set == nil {
+		return other
+	}
+	set.and(other)
+	return set
+}
+
+// matchTerm returns the tracks with any of the fields matching a word of a
+// query.
+func (x *TrackIndex) matchTerm(term string, fields []enginelibrary.SearchQueryField) bitset {
+	set := newBitset(len(x.tracks))
+	parts := words(term)
+	for _, field := range fields {
+		if field == enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_KEY {
+			if k, err := musickey.Parse(term); err == nil {
+				for other, positions := range x.keys {
+					if k.Compatible(other) {
+						set.add(positions)
+					}
+				}
+				continue
+			}
+		}
+		x.matchWords(set, field, parts)
+	}
+	return set
+}
+
+// matchWords adds the tracks whose field contains the given words in a row,
+// the last one possibly cut short.
+func (x *TrackIndex) matchWords(set bitset, field enginelibrary.SearchQueryField, parts []string) {
+	w, ok := x.wordIndex()[field]
+	if !ok {
+		return
+	}
+	var candidates bitset
+	for i, part := range parts {
+		matched := newBitset(len(x.tracks))
+		w.match(matched, part, i == len(parts)-1)
+		candidates = intersect(candidates, matched)
+	}
+	for i := range x.tracks {
+		if !candidates.has(i) || set.has(i) {
+			continue
+		}
+		// words found apart from each other don't count
+		if len(parts) == 1 || containsRun(words(queryFieldValue(x.tracks[i], field)), parts) {
+			set.set(i)
+		}
+	}
+}
+
+// containsRun reports whether the words contain the parts in a row, the
+// last part being the start of a word.
+func containsRun(words, parts []string) bool {
+	for start := 0; start+len(parts) <= len(words); start++ {
+		matched := true
+		for i, part := range parts {
+			word := words[start+i]
+			if i == len(parts)-1 && !strings.HasPrefix(word, part) || i < len(parts)-1 && word != part {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchFilter returns the tracks matching any value of a filter, or nil for
+// filters that don't restrict anything.
+func (x *TrackIndex) matchFilter(filter *enginelibrary.SearchFilter) bitset {
+	set := newBitset(len(x.tracks))
+	switch field := filter.GetField(); field {
+	case enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_GENRE,
+		enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_ARTIST,
+		enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_ALBUM:
+		for _, value := range filter.GetValue() {
+			set.add(x.values[field][fold(value)])
+		}
+	case enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_KEY:
+		for _, value := range filter.GetValue() {
+			if k, err := musickey.Parse(value); err == nil {
+				set.add(x.keys[k])
+			} else {
+				set.add(x.values[field][fold(value)])
+			}
+		}
+	case enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_BPM:
+		for _, value := range filter.GetValue() {
+			min, max, ok := parseBPMRange(value)
+			if !ok {
+				continue
+			}
+			for i, track := range x.tracks {
+				if bpm := math.Round(track.BPM); track.BPM > 0 && bpm >= min && bpm <= max {
+					set.set(i)
+				}
+			}
+		}
+	default:
+		// unknown fields don't restrict anything
+		return nil
+	}
+	return set
+}
+
+// parseBPMRange parses a BPM filter value, a single BPM or a range like
+// "120-130", rounding the BPMs.
+func parseBPMRange(value string) (min, max float64, ok bool) {
+	from, to, isRange := strings.Cut(value, "-")
+	min, err := strconv.ParseFloat(strings.TrimSpace(from), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	max = min
+	if isRange {
+		if max, err = strconv.ParseFloat(strings.TrimSpace(to), 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	min, max = math.Round(min), math.Round(max)
+	if min > max {
+		min, max = max, min
+	}
+	return min, max, true
+}
+
+// sort orders positions of tracks as asked for, breaking ties by position.
+func (x *TrackIndex) sort(positions []int, sorts []*enginelibrary.Sort) {
+	sort.SliceStable(positions, func(i, j int) bool {
+		a, b := positions[i], positions[j]
+		for _, s := range sorts {
+			if c := x.compare(s.GetField(), a, b); c != 0 {
+				if s.GetDirection() == enginelibrary.SortDirection_SORT_DIRECTION_DESC {
+					// tracks without a value stay last
+					if x.missing(s.GetField(), a) == x.missing(s.GetField(), b) {
+						c = -c
+					}
+				}
+				return c < 0
+			}
+		}
+		return a < b
+	})
+}
+
+// missing reports whether a track has no value for a sort field.
+func (x *TrackIndex) missing(field enginelibrary.SortField, pos int) bool {
+	track, e := x.tracks[pos], &x.entries[pos]
+	switch field {
+	case enginelibrary.SortField_SORT_FIELD_TITLE:
+		return e.title == ""
+	case enginelibrary.SortField_SORT_FIELD_ARTIST:
+		return e.artist == ""
+	case enginelibrary.SortField_SORT_FIELD_ALBUM:
+		return e.album == ""
+	case enginelibrary.SortField_SORT_FIELD_GENRE:
+		return e.genre == ""
+	case enginelibrary.SortField_SORT_FIELD_COMMENT:
+		return e.comment == ""
+	case enginelibrary.SortField_SORT_FIELD_LABEL:
+		return e.label == ""
+	case enginelibrary.SortField_SORT_FIELD_BPM:
+		return track.BPM <= 0
+	case enginelibrary.SortField_SORT_FIELD_LENGTH:
+		return track.Length <= 0
+	case enginelibrary.SortField_SORT_FIELD_KEY:
+		return !e.hasKey
+	case enginelibrary.SortField_SORT_FIELD_RATING:
+		return track.Rating <= 0
+	case enginelibrary.SortField_SORT_FIELD_YEAR:
+		return track.Year <= 0
+	case enginelibrary.SortField_SORT_FIELD_DATE_ADDED:
+		return track.DateAdded.IsZero()
+	}
+	return false
+}
+
+// compare compares two tracks by a sort field, tracks without a value coming
+// last.
+func (x *TrackIndex) compare(field enginelibrary.SortField, a, b int) int {
+	if missingA, missingB := x.missing(field, a), x.missing(field, b); missingA || missingB {
+		switch {
+		case missingA && missingB:
+			return 0
+		case missingA:
+			return 1
+		}
+		return -1
+	}
+	ta, tb, ea, eb := x.tracks[a], x.tracks[b], &x.entries[a], &x.entries[b]
+	switch field {
+	case enginelibrary.SortField_SORT_FIELD_TITLE:
+		return strings.Compare(ea.title, eb.title)
+	case enginelibrary.SortField_SORT_FIELD_ARTIST:
+		return strings.Compare(ea.artist, eb.artist)
+	case enginelibrary.SortField_SORT_FIELD_ALBUM:
+		return strings.Compare(ea.album, eb.album)
+	case enginelibrary.SortField_SORT_FIELD_GENRE:
+		return strings.Compare(ea.genre, eb.genre)
+	case enginelibrary.SortField_SORT_FIELD_COMMENT:
+		return strings.Compare(ea.comment, eb.comment)
+	case enginelibrary.SortField_SORT_FIELD_LABEL:
+		return strings.Compare(ea.label, eb.label)
+	case enginelibrary.SortField_SORT_FIELD_BPM:
+		return compareNumbers(ta.BPM, tb.BPM)
+	case enginelibrary.SortField_SORT_FIELD_LENGTH:
+		return compareNumbers(ta.Length, tb.Length)
+	case enginelibrary.SortField_SORT_FIELD_KEY:
+		return compareNumbers(keyOrder(ea.key), keyOrder(eb.key))
+	case enginelibrary.SortField_SORT_FIELD_RATING:
+		return compareNumbers(ta.Rating, tb.Rating)
+	case enginelibrary.SortField_SORT_FIELD_YEAR:
+		return compareNumbers(ta.Year, tb.Year)
+	case enginelibrary.SortField_SORT_FIELD_DATE_ADDED:
+		return ta.DateAdded.Compare(tb.DateAdded)
+	case enginelibrary.SortField_SORT_FIELD_ORDER_PLAYLIST:
+		return compareNumbers(a, b)
+	}
+	// unknown fields don't order anything
+	return 0
+}
+
+func compareNumbers[T int | float64 | time.Duration](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// keyOrder orders keys along the Camelot wheel, minor before major.
+func keyOrder(k musickey.Key) int {
+	if k.Minor {
+		return k.Camelot() * 2
+	}
+	return k.Camelot()*2 + 1
+}
+
+// queryFieldValue returns the value of a track searched for a query field.
+func queryFieldValue(track *Track, field enginelibrary.SearchQueryField) string {
+	switch field {
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_TITLE:
+		return track.Title
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_ARTIST:
+		return track.Artist
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_ALBUM:
+		return track.Album
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_LENGTH:
+		if track.Length > 0 {
+			seconds := int(track.Length.Round(time.Second).Seconds())
+			return strconv.Itoa(seconds/60) + ":" + strconv.Itoa(seconds%60/10) + strconv.Itoa(seconds%10)
+		}
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_KEY:
+		if k, err := musickey.Parse(track.Key); err == nil {
+			// the key can be searched for in any notation
+			return strings.Join([]string{track.Key, k.String(), k.CamelotCode(), k.OpenKey()}, " ")
+		}
+		return track.Key
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_COMMENT:
+		return track.Comment
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_BPM:
+		if track.BPM > 0 {
+			return strconv.Itoa(int(math.Round(track.BPM)))
+		}
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_GENRE:
+		return track.Genre
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_LABEL:
+		return track.Label
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_YEAR:
+		if track.Year > 0 {
+			return strconv.Itoa(track.Year)
+		}
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_DATE_ADDED:
+		if !track.DateAdded.IsZero() {
+			return track.DateAdded.Format("2006-01-02")
+		}
+	case enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_FILENAME:
+		if track.URL != "" {
+			return path.Base(track.URL)
+		}
+	}
+	return ""
+}
+
+// wordIndex is the inverted index of a query field.
+type wordIndex struct {
+	// words are the distinct words found in the field, ordered
+	words []string
+	// positions holds the tracks each word is found in, ordered
+	positions [][]int32
+}
+
+func (x *TrackIndex) wordIndex() map[enginelibrary.SearchQueryField]*wordIndex {
+	x.wordsOnce.Do(func() {
+		x.words = map[enginelibrary.SearchQueryField]*wordIndex{}
+		for value := range enginelibrary.SearchQueryField_name {
+			field := enginelibrary.SearchQueryField(value)
+			if field == enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_UNSPECIFIED {
+				continue
+			}
+			byWord := map[string][]int32{}
+			for i, track := range x.tracks {
+				for _, word := range words(queryFieldValue(track, field)) {
+					// words repeated within a value are added once
+					if positions := byWord[word]; len(positions) == 0 || positions[len(positions)-1] != int32(i) {
+						byWord[word] = append(positions, int32(i))
+					}
+				}
+			}
+			w := &wordIndex{words: make([]string, 0, len(byWord))}
+			for word := range byWord {
+				w.words = append(w.words, word)
+			}
+			sort.Strings(w.words)
+			w.positions = make([][]int32, len(w.words))
+			for i, word := range w.words {
+				w.positions[i] = byWord[word]
+			}
+			x.words[field] = w
+		}
+	})
+	return x.words
+}
+
+// match adds the tracks containing a word, or a word starting with it.
+func (w *wordIndex) match(set bitset, word string, prefix bool) {
+	i := sort.SearchStrings(w.words, word)
+	if !prefix {
+		if i < len(w.words) && w.words[i] == word {
+			set.add(w.positions[i])
+		}
+		return
+	}
+	for ; i < len(w.words) && strings.HasPrefix(w.words[i], word); i++ {
+		set.add(w.positions[i])
+	}
+}
+
+// fold lower cases a string and strips accents from it.
+func fold(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+			if err == nil {
+				s = folded
+			}
+			break
+		}
+	}
+	return strings.ToLower(s)
+}
+
+// words splits a string into folded words at anything but letters and
+// digits.
+func words(s string) []string {
+	return strings.FieldsFunc(fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// bitset is a set of track positions.
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) set(i int) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+func (b bitset) has(i int) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+func (b bitset) add(positions []int32) {
+	for _, i := range positions {
+		b.set(int(i))
+	}
+}
+
+func (b bitset) and(other bitset) {
+	for i := range b {
+		b[i] &= other[i]
+	}
+}
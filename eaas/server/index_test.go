@@ -0,0 +1,219 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
+	"github.com/stretchr/testify/require"
+)
+
+func indexTestTracks() []*Track {
+	return []*Track{
+		{
+			ID: "1", Title: "Whiplash", Artist: "Icedream", Album: "Frozen", Genre: "Trance",
+			Comment: "Peak time", Label: "Cold Records", Key: "Am", BPM: 140, Rating: 80, Year: 2021,
+			Length: 6*time.Minute + 5*time.Second, DateAdded: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			URL: "file:///music/whiplash.m4a",
+		},
+		{
+			ID: "2", Title: "Café del Mar", Artist: "Energy 52", Genre: "trance",
+			Key: "9A", BPM: 133.6, Rating: 100, Year: 1993,
+			Length: 4 * time.Minute, DateAdded: time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			ID: "3", Title: "Deep Down Under", Artist: "Someone", Genre: "House",
+			Key: "C", BPM: 124,
+		},
+		{
+			ID: "4", Title: "Untagged", Key: "unknown",
+		},
+	}
+}
+
+func trackIDs(tracks []*Track) []string {
+	ids := []string{}
+	for _, track := range tracks {
+		ids = append(ids, track.ID)
+	}
+	return ids
+}
+
+func Test_TrackIndex_Search_Text(t *testing.T) {
+	x := NewTrackIndex(indexTestTracks())
+	for _, test := range []struct {
+		text     string
+		fields   []enginelibrary.SearchQueryField
+		expected []string
+	}{
+		{"", nil, []string{"1", "2", "3", "4"}},
+		{"whip", nil, []string{"1"}},
+		{"WHIPLASH", nil, []string{"1"}},
+		{"cafe", nil, []string{"2"}},
+		{"del mar", nil, []string{"2"}},
+		{"mar del", nil, []string{"2"}},
+		{"deep under", nil, []string{"3"}},
+		{"deep under", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_ARTIST}, []string{}},
+		{"del-ma", nil, []string{"2"}},
+		{"mar-del", nil, []string{}},
+		{"lash", nil, []string{}},
+		{"energy whip", nil, []string{}},
+		{"-", nil, []string{"1", "2", "3", "4"}},
+		{"frozen", nil, []string{"1"}},
+		{"6:05", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_LENGTH}, []string{"1"}},
+		{"peak", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_COMMENT}, []string{"1"}},
+		{"134", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_BPM}, []string{"2"}},
+		{"tran", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_GENRE}, []string{"1", "2"}},
+		{"cold", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_LABEL}, []string{"1"}},
+		{"199", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_YEAR}, []string{"2"}},
+		{"2024-03", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_DATE_ADDED}, []string{"1"}},
+		{"whiplash.m4a", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_FILENAME}, []string{"1"}},
+		{"m4a", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_FILENAME}, []string{"1"}},
+		// keys find compatible keys
+		{"8A", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_KEY}, []string{"1", "2", "3"}},
+		{"Em", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_KEY}, []string{"1", "2"}},
+		{"unk", []enginelibrary.SearchQueryField{enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_KEY}, []string{"4"}},
+		{"icedream trance", []enginelibrary.SearchQueryField{
+			enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_ARTIST,
+			enginelibrary.SearchQueryField_SEARCH_QUERY_FIELD_GENRE,
+		}, []string{"1"}},
+	} {
+		require.Equal(t, test.expected, trackIDs(x.Search(&TrackQuery{
+			Text:   test.text,
+			Fields: test.fields,
+		})), test.text)
+	}
+}
+
+func Test_TrackIndex_Search_Filters(t *testing.T) {
+	x := NewTrackIndex(indexTestTracks())
+	filter := func(field enginelibrary.SearchFilterField, values ...string) *enginelibrary.SearchFilter {
+		return &enginelibrary.SearchFilter{Field: field.Enum(), Value: values}
+	}
+	for name, test := range map[string]struct {
+		filters  []*enginelibrary.SearchFilter
+		expected []string
+	}{
+		"genre": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_GENRE, "TRANCE"),
+		}, []string{"1", "2"}},
+		"genre in full": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_GENRE, "Tran"),
+		}, []string{}},
+		"artists": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_ARTIST, "Someone", "icedream"),
+		}, []string{"1", "3"}},
+		"album": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_ALBUM, "Frozen"),
+		}, []string{"1"}},
+		"key in other notation": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_KEY, "8A", "Em"),
+		}, []string{"1", "2"}},
+		"unparsed key": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_KEY, "Unknown"),
+		}, []string{"4"}},
+		"bpm": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_BPM, "134"),
+		}, []string{"2"}},
+		"bpm range": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_BPM, "140 - 124"),
+		}, []string{"1", "2", "3"}},
+		"invalid bpm": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_BPM, "fast"),
+		}, []string{}},
+		"all filters": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_GENRE, "trance"),
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_BPM, "130-135"),
+		}, []string{"2"}},
+		"no values": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_GENRE),
+		}, []string{"1", "2", "3", "4"}},
+		"unknown field": {[]*enginelibrary.SearchFilter{
+			filter(enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_UNSPECIFIED, "x"),
+		}, []string{"1", "2", "3", "4"}},
+	} {
+		require.Equal(t, test.expected, trackIDs(x.Search(&TrackQuery{Filters: test.filters})), name)
+	}
+}
+
+func Test_TrackIndex_Search_Sort(t *testing.T) {
+	x := NewTrackIndex(indexTestTracks())
+	asc := enginelibrary.SortDirection_SORT_DIRECTION_ASC
+	desc := enginelibrary.SortDirection_SORT_DIRECTION_DESC
+	for _, test := range []struct {
+		field     enginelibrary.SortField
+		direction enginelibrary.SortDirection
+		expected  []string
+	}{
+		{enginelibrary.SortField_SORT_FIELD_TITLE, asc, []string{"2", "3", "4", "1"}},
+		{enginelibrary.SortField_SORT_FIELD_TITLE, desc, []string{"1", "4", "3", "2"}},
+		{enginelibrary.SortField_SORT_FIELD_ARTIST, asc, []string{"2", "1", "3", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_ARTIST, desc, []string{"3", "1", "2", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_ALBUM, desc, []string{"1", "2", "3", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_BPM, asc, []string{"3", "2", "1", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_BPM, desc, []string{"1", "2", "3", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_GENRE, asc, []string{"3", "1", "2", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_COMMENT, asc, []string{"1", "2", "3", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_LABEL, asc, []string{"1", "2", "3", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_LENGTH, asc, []string{"2", "1", "3", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_KEY, asc, []string{"1", "3", "2", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_KEY, desc, []string{"2", "3", "1", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_RATING, desc, []string{"2", "1", "3", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_YEAR, asc, []string{"2", "1", "3", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_DATE_ADDED, desc, []string{"1", "2", "3", "4"}},
+		{enginelibrary.SortField_SORT_FIELD_ORDER_PLAYLIST, desc, []string{"4", "3", "2", "1"}},
+		{enginelibrary.SortField_SORT_FIELD_UNSPECIFIED, asc, []string{"1", "2", "3", "4"}},
+	} {
+		require.Equal(t, test.expected, trackIDs(x.Search(&TrackQuery{
+			Sort: []*enginelibrary.Sort{{Field: test.field.Enum(), Direction: test.direction.Enum()}},
+		})), "%s %s", test.field, test.direction)
+	}
+
+	// later fields break ties
+	require.Equal(t, []string{"2", "1", "3", "4"}, trackIDs(x.Search(&TrackQuery{
+		Sort: []*enginelibrary.Sort{
+			{Field: enginelibrary.SortField_SORT_FIELD_GENRE.Enum(), Direction: desc.Enum()},
+			{Field: enginelibrary.SortField_SORT_FIELD_YEAR.Enum()},
+		},
+	})))
+}
+
+func Test_TrackIndex_Search_Limit(t *testing.T) {
+	tracks := make([]*Track, 200)
+	for i := range tracks {
+		tracks[i] = &Track{ID: fmt.Sprint(i), Title: "Loop", BPM: float64(120 + i%3)}
+	}
+	x := NewTrackIndex(tracks)
+	query := &TrackQuery{
+		Text: "loop",
+		Sort: []*enginelibrary.Sort{{Field: enginelibrary.SortField_SORT_FIELD_BPM.Enum()}},
+	}
+	all := x.Search(query)
+	require.Len(t, all, 200)
+	// ties are kept in library order, so pages are consistent
+	query.Limit = 50
+	require.Equal(t, all[:50], x.Search(query))
+	require.Equal(t, "0", all[0].ID)
+	require.Equal(t, "3", all[1].ID)
+}
+
+func Test_TrackIndex_Facets(t *testing.T) {
+	tracks := append(indexTestTracks(), &Track{ID: "5", Genre: "Trancé", Artist: "icedream", Key: "A minor", BPM: 139.6})
+	x := NewTrackIndex(tracks)
+	require.Equal(t, &TrackFacets{
+		Genres:  []string{"House", "Trance"},
+		Artists: []string{"Energy 52", "Icedream", "Someone"},
+		Albums:  []string{"Frozen"},
+		BPMs:    []string{"124", "134", "140"},
+		Keys:    []string{"Am", "C", "Em", "unknown"},
+	}, x.Facets(&TrackQuery{}))
+
+	require.Equal(t, &TrackFacets{
+		Genres:  []string{"Trance"},
+		Artists: []string{"Icedream"},
+		Albums:  []string{"Frozen"},
+		BPMs:    []string{"140"},
+		Keys:    []string{"Am"},
+	}, x.Facets(&TrackQuery{Text: "icedream"}))
+}
@@ -0,0 +1,357 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ enginelibrary.EngineLibraryServiceServer = &libraryService{}
+
+// libraryService implements the EAAS EngineLibraryService on top of a
+// LibraryProvider.
+type libraryService struct {
+	enginelibrary.UnimplementedEngineLibraryServiceServer
+
+	provider LibraryProvider
+	events   *eventHub
+
+	indexLock sync.Mutex
+	// indexes holds the indexes of each library, see index
+	indexes map[string]*libraryIndexes
+}
+
+// libraryIndexes are the indexes of the tracks of a library and its
+// playlists, valid as long as the tracks version is version.
+type libraryIndexes struct {
+	version uint64
+	// indexes holds the indexes by playlist ID, the empty ID being the
+	// whole library
+	indexes map[string]*TrackIndex
+}
+
+// NewEngineLibraryServiceServer returns an implementation of the EAAS
+// EngineLibraryService serving the libraries of the given provider, for use
+// with your own gRPC server.
+func NewEngineLibraryServiceServer(provider LibraryProvider) enginelibrary.EngineLibraryServiceServer {
+	s := &libraryService{
+		provider: provider,
+		indexes:  map[string]*libraryIndexes{},
+	}
+	if p, ok := provider.(EventProvider); ok {
+		s.events = newEventHub(p.Events())
+	}
+	return s
+}
+
+// toStatus turns provider errors into gRPC status errors.
+func toStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// libraryID resolves an empty library ID to the default library.
+func (s *libraryService) libraryID(ctx context.Context, id string) (string, error) {
+	if id != "" {
+		return id, nil
+	}
+	libraries, err := s.provider.Libraries(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(libraries) == 0 {
+		return "", ErrNotFound
+	}
+	return libraries[0].ID, nil
+}
+
+// tracksVersion returns the version of the tracks of a library, taken from
+// the provider if it implements VersionProvider or else counted from the
+// events of an EventProvider still reporting changes. ok is false if there is
+// no telling when the tracks change.
+func (s *libraryService) tracksVersion(ctx context.Context, libraryID string) (version uint64, ok bool, err error) {
+	if p, isVersionProvider := s.provider.(VersionProvider); isVersionProvider {
+		version, err = p.TracksVersion(ctx, libraryID)
+		if err == nil {
+			return version, true, nil
+		}
+		if !errors.Is(err, ErrVersionUnknown) {
+			return
+		}
+		err = nil
+	}
+	if s.events != nil {
+		version, ok = s.events.version(libraryID)
+	}
+	return
+}
+
+// index returns the index of the tracks of a playlist, or of all tracks of a
+// library if playlistID is empty. Indexes are kept until the tracks version
+// of the library changes.
+func (s *libraryService) index(ctx context.Context, libraryID, playlistID string) (*TrackIndex, error) {
+	version, versioned, err := s.tracksVersion(ctx, libraryID)
+	if err != nil {
+		return nil, err
+	}
+	if versioned {
+		s.indexLock.Lock()
+		cached, ok := s.indexes[libraryID]
+		var x *TrackIndex
+		if ok && cached.version == version {
+			x = cached.indexes[playlistID]
+		}
+		s.indexLock.Unlock()
+		if x != nil {
+			return x, nil
+		}
+	}
+
+	tracks, err := s.provider.Tracks(ctx, libraryID, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	x := NewTrackIndex(tracks)
+	if versioned {
+		s.indexLock.Lock()
+		cached, ok := s.indexes[libraryID]
+		if !ok || cached.version != version {
+			cached = &libraryIndexes{version: version, indexes: map[string]*TrackIndex{}}
+			s.indexes[libraryID] = cached
+		}
+		cached.indexes[playlistID] = x
+		s.indexLock.Unlock()
+	}
+	return x, nil
+}
+
+// EventStream implements enginelibrary.EngineLibraryServiceServer. Devices
+// poll it for changes, so it waits a while for events of providers
+// implementing EventProvider before returning.
+func (s *libraryService) EventStream(ctx context.Context, req *enginelibrary.EventStreamRequest) (*enginelibrary.EventStreamResponse, error) {
+	if s.events == nil {
+		return &enginelibrary.EventStreamResponse{
+			Event: []*enginelibrary.Event{},
+		}, nil
+	}
+	libraryID, err := s.libraryID(ctx, req.GetLibraryId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &enginelibrary.EventStreamResponse{
+		Event: s.events.next(ctx, eventQueueKey{
+			libraryID: libraryID,
+			deviceID:  req.GetDeviceId(),
+		}, eventStreamWait),
+	}, nil
+}
+
+// GetCredentials implements enginelibrary.EngineLibraryServiceServer.
+func (s *libraryService) GetCredentials(ctx context.Context, req *enginelibrary.GetCredentialsRequest) (*enginelibrary.GetCredentialsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "no credentials needed")
+}
+
+// GetHistoryPlayedTracks implements enginelibrary.EngineLibraryServiceServer.
+func (s *libraryService) GetHistoryPlayedTracks(ctx context.Context, req *enginelibrary.GetHistoryPlayedTracksRequest) (*enginelibrary.GetHistoryPlayedTracksResponse, error) {
+	resp := &enginelibrary.GetHistoryPlayedTracksResponse{
+		Tracks: []*enginelibrary.HistoryPlayedTrack{},
+	}
+	history, ok := s.provider.(HistoryProvider)
+	if !ok {
+		return resp, nil
+	}
+	libraryID, err := s.libraryID(ctx, req.GetLibraryId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	tracks, err := history.HistoryPlayedTracks(ctx, libraryID, req.GetSessionId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	for _, track := range tracks {
+		resp.Tracks = append(resp.Tracks, playedTrackToProto(track))
+	}
+	return resp, nil
+}
+
+// GetHistorySessions implements enginelibrary.EngineLibraryServiceServer.
+func (s *libraryService) GetHistorySessions(ctx context.Context, req *enginelibrary.GetHistorySessionsRequest) (*enginelibrary.GetHistorySessionsResponse, error) {
+	resp := &enginelibrary.GetHistorySessionsResponse{
+		Sessions: []*enginelibrary.HistorySession{},
+	}
+	history, ok := s.provider.(HistoryProvider)
+	if !ok {
+		return resp, nil
+	}
+	libraryID, err := s.libraryID(ctx, req.GetLibraryId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	sessions, err := history.HistorySessions(ctx, libraryID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, historySessionToProto(session))
+	}
+	return resp, nil
+}
+
+// GetLibraries implements enginelibrary.EngineLibraryServiceServer.
+func (s *libraryService) GetLibraries(ctx context.Context, req *enginelibrary.GetLibrariesRequest) (*enginelibrary.GetLibrariesResponse, error) {
+	libraries, err := s.provider.Libraries(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &enginelibrary.GetLibrariesResponse{
+		Libraries: make([]*enginelibrary.Library, 0, len(libraries)),
+	}
+	for _, library := range libraries {
+		resp.Libraries = append(resp.Libraries, libraryToProto(library))
+	}
+	return resp, nil
+}
+
+// GetLibrary implements enginelibrary.EngineLibraryServiceServer.
+func (s *libraryService) GetLibrary(ctx context.Context, req *enginelibrary.GetLibraryRequest) (*enginelibrary.GetLibraryResponse, error) {
+	libraryID, err := s.libraryID(ctx, req.GetLibraryId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	playlists, err := s.provider.Playlists(ctx, libraryID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &enginelibrary.GetLibraryResponse{
+		Playlists: make([]*enginelibrary.PlaylistMetadata, 0, len(playlists)),
+	}
+	for _, playlist := range playlists {
+		resp.Playlists = append(resp.Playlists, playlistToProto(playlist))
+	}
+	return resp, nil
+}
+
+// GetSearchFilters implements enginelibrary.EngineLibraryServiceServer.
+func (s *libraryService) GetSearchFilters(ctx context.Context, req *enginelibrary.GetSearchFiltersRequest) (*enginelibrary.GetSearchFiltersResponse, error) {
+	libraryID, err := s.libraryID(ctx, req.GetLibraryId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	x, err := s.index(ctx, libraryID, "")
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	facets := x.Facets(&TrackQuery{
+		Text:   req.GetQuery(),
+		Fields: req.GetQueryFields(),
+	})
+	return &enginelibrary.GetSearchFiltersResponse{
+		SearchFilters: &enginelibrary.SearchFilterOptions{
+			Genres:  filterValues(facets.Genres),
+			Artists: filterValues(facets.Artists),
+			Albums:  filterValues(facets.Albums),
+			Bpms:    filterValues(facets.BPMs),
+			Keys:    filterValues(facets.Keys),
+		},
+	}, nil
+}
+
+// filterValues converts search filter values.
+func filterValues(values []string) []*enginelibrary.SearchFilterValue {
+	result := make([]*enginelibrary.SearchFilterValue, 0, len(values))
+	for _, value := range values {
+		result = append(result, &enginelibrary.SearchFilterValue{Value: proto.String(value)})
+	}
+	return result
+}
+
+// listTracks converts tracks for track list responses.
+func listTracks(tracks []*Track) []*enginelibrary.ListTrack {
+	result := make([]*enginelibrary.ListTrack, 0, len(tracks))
+	for _, track := range tracks {
+		result = append(result, listTrackToProto(track))
+	}
+	return result
+}
+
+// GetTrack implements enginelibrary.EngineLibraryServiceServer.
+func (s *libraryService) GetTrack(ctx context.Context, req *enginelibrary.GetTrackRequest) (*enginelibrary.GetTrackResponse, error) {
+	libraryID, err := s.libraryID(ctx, req.GetLibraryId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	track, err := s.provider.Track(ctx, libraryID, req.GetTrackId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	data, err := s.provider.PerformanceData(ctx, libraryID, track.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, toStatus(err)
+	}
+	performanceData := performanceDataToProto(track.ID, data)
+	if performanceData.Bpm == nil && track.BPM > 0 {
+		performanceData.Bpm = proto.Float64(track.BPM)
+	}
+	return &enginelibrary.GetTrackResponse{
+		Blob:            trackBlobToProto(track),
+		Metadata:        trackMetadataToProto(track),
+		PerformanceData: performanceData,
+	}, nil
+}
+
+// GetTracks implements enginelibrary.EngineLibraryServiceServer.
+func (s *libraryService) GetTracks(ctx context.Context, req *enginelibrary.GetTracksRequest) (*enginelibrary.GetTracksResponse, error) {
+	libraryID, err := s.libraryID(ctx, req.GetLibraryId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	x, err := s.index(ctx, libraryID, req.GetPlaylistId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &enginelibrary.GetTracksResponse{
+		Tracks: listTracks(x.Search(&TrackQuery{
+			Filters: req.GetFilters(),
+			Sort:    req.GetSort(),
+			Limit:   int(req.GetPageSize()),
+		})),
+	}, nil
+}
+
+// PutEvents implements enginelibrary.EngineLibraryServiceServer.
+func (s *libraryService) PutEvents(ctx context.Context, req *enginelibrary.PutEventsRequest) (*enginelibrary.PutEventsResponse, error) {
+	return &enginelibrary.PutEventsResponse{}, nil
+}
+
+// SearchTracks implements enginelibrary.EngineLibraryServiceServer.
+func (s *libraryService) SearchTracks(ctx context.Context, req *enginelibrary.SearchTracksRequest) (*enginelibrary.SearchTracksResponse, error) {
+	libraryID, err := s.libraryID(ctx, req.GetLibraryId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	x, err := s.index(ctx, libraryID, "")
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &enginelibrary.SearchTracksResponse{
+		Tracks: listTracks(x.Search(&TrackQuery{
+			Text:    req.GetQuery(),
+			Fields:  req.GetQueryFields(),
+			Filters: req.GetFilters(),
+			Sort:    req.GetSort(),
+			Limit:   int(req.GetPageSize()),
+		})),
+	}, nil
+}
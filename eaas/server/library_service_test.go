@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// copyingProvider returns new slices on every call and counts them.
+type copyingProvider struct {
+	*testProvider
+	calls atomic.Int32
+}
+
+func (p *copyingProvider) Tracks(ctx context.Context, libraryID, playlistID string) ([]*Track, error) {
+	p.calls.Add(1)
+	tracks, err := p.testProvider.Tracks(ctx, libraryID, playlistID)
+	return append([]*Track(nil), tracks...), err
+}
+
+type versionedProvider struct {
+	copyingProvider
+	version uint64
+}
+
+func (p *versionedProvider) TracksVersion(ctx context.Context, libraryID string) (uint64, error) {
+	return p.version, nil
+}
+
+type unknownVersionProvider struct {
+	copyingProvider
+	events chan *Event
+}
+
+func (p *unknownVersionProvider) TracksVersion(ctx context.Context, libraryID string) (uint64, error) {
+	return 0, ErrVersionUnknown
+}
+
+func (p *unknownVersionProvider) Events() <-chan *Event { return p.events }
+
+func Test_libraryService_index_Version(t *testing.T) {
+	ctx := context.Background()
+	p := &versionedProvider{copyingProvider: copyingProvider{testProvider: newTestProvider()}}
+	s := NewEngineLibraryServiceServer(p).(*libraryService)
+
+	x, err := s.index(ctx, "lib", "")
+	require.NoError(t, err)
+	pl, err := s.index(ctx, "lib", "pl")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), p.calls.Load())
+
+	// indexes are kept while the version stays the same
+	y, err := s.index(ctx, "lib", "")
+	require.NoError(t, err)
+	require.Same(t, x, y)
+	y, err = s.index(ctx, "lib", "pl")
+	require.NoError(t, err)
+	require.Same(t, pl, y)
+	require.Equal(t, int32(2), p.calls.Load())
+
+	// changes in place show up once the version changes
+	p.tracks[0].Title = "Whiplash (Extended Mix)"
+	p.version++
+	y, err = s.index(ctx, "lib", "")
+	require.NoError(t, err)
+	require.NotSame(t, x, y)
+	require.Equal(t, int32(3), p.calls.Load())
+	require.Len(t, y.Search(&TrackQuery{Text: "extended"}), 1)
+}
+
+func Test_libraryService_index_Events(t *testing.T) {
+	ctx := context.Background()
+	p := &unknownVersionProvider{
+		copyingProvider: copyingProvider{testProvider: newTestProvider()},
+		events:          make(chan *Event),
+	}
+	defer close(p.events)
+	s := NewEngineLibraryServiceServer(p).(*libraryService)
+
+	x, err := s.index(ctx, "lib", "pl")
+	require.NoError(t, err)
+	y, err := s.index(ctx, "lib", "pl")
+	require.NoError(t, err)
+	require.Same(t, x, y)
+	require.Equal(t, int32(1), p.calls.Load())
+
+	// any change event of the library drops its indexes
+	p.events <- &Event{LibraryID: "other", PlaylistHierarchyChanged: true}
+	p.events <- &Event{LibraryID: "lib", PlaylistIDs: []string{"pl"}}
+	// the hub publishes the second event before taking a third
+	p.events <- &Event{LibraryID: "other", PlaylistHierarchyChanged: true}
+	y, err = s.index(ctx, "lib", "pl")
+	require.NoError(t, err)
+	require.NotSame(t, x, y)
+	require.Equal(t, int32(2), p.calls.Load())
+}
+
+func Test_libraryService_index_Unversioned(t *testing.T) {
+	ctx := context.Background()
+	p := &copyingProvider{testProvider: newTestProvider()}
+	s := NewEngineLibraryServiceServer(p).(*libraryService)
+
+	// without a way to tell changes every request sees the current tracks
+	_, err := s.index(ctx, "lib", "")
+	require.NoError(t, err)
+	p.tracks[0].Title = "Whiplash (Extended Mix)"
+	x, err := s.index(ctx, "lib", "")
+	require.NoError(t, err)
+	require.Len(t, x.Search(&TrackQuery{Text: "extended"}), 1)
+	require.Equal(t, int32(2), p.calls.Load())
+	require.Empty(t, s.indexes)
+
+	_, err = s.index(ctx, "other", "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// neither can providers that stopped reporting changes
+	closed := &unknownVersionProvider{
+		copyingProvider: copyingProvider{testProvider: newTestProvider()},
+		events:          make(chan *Event),
+	}
+	close(closed.events)
+	s = NewEngineLibraryServiceServer(closed).(*libraryService)
+	require.Eventually(t, func() bool {
+		_, ok := s.events.version("lib")
+		return !ok
+	}, 5*time.Second, time.Millisecond)
+	_, err = s.index(ctx, "lib", "")
+	require.NoError(t, err)
+	require.Empty(t, s.indexes)
+}
+
+func Benchmark_libraryService_index(b *testing.B) {
+	tracks := make([]*Track, 50000)
+	for i := range tracks {
+		tracks[i] = &Track{
+			ID:     fmt.Sprint(i),
+			Title:  fmt.Sprintf("Track %d", i),
+			Artist: fmt.Sprintf("Artist %d", i%500),
+			Genre:  fmt.Sprintf("Genre %d", i%20),
+			BPM:    float64(100 + i%60),
+		}
+	}
+	ctx := context.Background()
+	p := &versionedProvider{copyingProvider: copyingProvider{testProvider: &testProvider{tracks: tracks}}}
+	s := NewEngineLibraryServiceServer(p).(*libraryService)
+	b.ResetTimer()
+	for b.Loop() {
+		if _, err := s.index(ctx, "lib", ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
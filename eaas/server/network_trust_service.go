@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+
+	"github.com/icedream/go-stagelinq/eaas/proto/networktrust"
+)
+
+// TrustFunc decides whether a device asking to trust us is granted access.
+type TrustFunc func(ctx context.Context, req *networktrust.CreateTrustRequest) bool
+
+// TrustAll grants access to every device that asks.
+func TrustAll(ctx context.Context, req *networktrust.CreateTrustRequest) bool {
+	return true
+}
+
+var _ networktrust.NetworkTrustServiceServer = &networkTrustService{}
+
+type networkTrustService struct {
+	networktrust.UnimplementedNetworkTrustServiceServer
+
+	trust TrustFunc
+}
+
+// NewNetworkTrustServiceServer returns an implementation of the EAAS
+// NetworkTrustService that asks trust whether to grant access, for use with
+// your own gRPC server. A nil trust grants access to everyone.
+func NewNetworkTrustServiceServer(trust TrustFunc) networktrust.NetworkTrustServiceServer {
+	if trust == nil {
+		trust = TrustAll
+	}
+	return &networkTrustService{trust: trust}
+}
+
+// CreateTrust implements networktrust.NetworkTrustServiceServer.
+func (n *networkTrustService) CreateTrust(ctx context.Context, req *networktrust.CreateTrustRequest) (*networktrust.CreateTrustResponse, error) {
+	if !n.trust(ctx, req) {
+		return &networktrust.CreateTrustResponse{
+			Response: &networktrust.CreateTrustResponse_Denied{Denied: &networktrust.CreateTrustDenied{}},
+		}, nil
+	}
+	return &networktrust.CreateTrustResponse{
+		Response: &networktrust.CreateTrustResponse_Granted{Granted: &networktrust.CreateTrustGranted{}},
+	}, nil
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned by a LibraryProvider for libraries, playlists,
+// tracks and blobs it does not know. Errors wrapping it are reported to
+// devices as not found.
+var ErrNotFound = errors.New("not found")
+
+// Library describes a library offered to devices.
+type Library struct {
+	ID    string
+	Title string
+}
+
+// Playlist describes a playlist of a library. Playlists can be nested.
+type Playlist struct {
+	ID    string
+	Title string
+
+	// TrackCount is the number of tracks in the playlist.
+	TrackCount int
+
+	// Smart is set for playlists whose tracks are picked by rules rather
+	// than by hand.
+	Smart bool
+
+	// Children are the playlists nested in this one.
+	Children []*Playlist
+}
+
+// Track describes a track of a library.
+type Track struct {
+	// ID identifies the track within its library.
+	ID string
+
+	Title    string
+	Artist   string
+	Album    string
+	Genre    string
+	Comment  string
+	Label    string
+	Composer string
+	Remixer  string
+	Key      string
+	BPM      float64
+	Rating   int
+	Year     int
+	Length   time.Duration
+
+	DateAdded time.Time
+
+	// URL identifies the audio file of the track. Devices request it via
+	// HTTP and it is passed back to LibraryProvider.OpenBlob. Engine DJ uses
+	// file paths here, for example "C:/Music/track.mp3".
+	URL string
+
+	// FileSize is the size of the audio file in bytes.
+	FileSize int64
+
+	// PreviewArtwork is the encoded image shown in track lists, if any.
+	PreviewArtwork []byte
+}
+
+// MainCue is the position a track is cued to when loaded, in samples.
+type MainCue struct {
+	Position        float64
+	InitialPosition float64
+	SetManually     bool
+}
+
+// QuickCue is a hot cue of a track, with its position in samples.
+type QuickCue struct {
+	Name     string
+	Position float64
+	Color    color.RGBA
+}
+
+// Loop is a saved loop of a track, with its positions in samples.
+type Loop struct {
+	Name         string
+	In           float64
+	Out          float64
+	Color        color.RGBA
+	ActiveOnLoad bool
+}
+
+// PerformanceData is the analysis data of a track.
+type PerformanceData struct {
+	BPM float64
+
+	// BeatGrid and OverviewWaveform are passed to devices as is. The
+	// eaas/perfdata package encodes them.
+	BeatGrid         []byte
+	OverviewWaveform []byte
+
+	// MainCue is the position the track is cued to when loaded, or nil if
+	// none is set.
+	MainCue *MainCue
+
+	// QuickCues and Loops are keyed by pad, starting at 0.
+	QuickCues map[int]*QuickCue
+	Loops     map[int]*Loop
+}
+
+// LibraryProvider provides the content of the libraries served by a Server.
+//
+// Library IDs passed to it are never empty, requests for the default library
+// are passed the ID of the first library returned by Libraries.
+type LibraryProvider interface {
+	// Libraries returns the libraries offered to devices.
+	Libraries(ctx context.Context) ([]*Library, error)
+
+	// Playlists returns the top level playlists of a library.
+	Playlists(ctx context.Context, libraryID string) ([]*Playlist, error)
+
+	// Tracks returns the tracks of a playlist in playlist order, or of the
+	// whole library if playlistID is empty. The returned tracks must not be
+	// changed afterwards, the server indexes them.
+	Tracks(ctx context.Context, libraryID, playlistID string) ([]*Track, error)
+
+	// Track returns a single track of a library.
+	Track(ctx context.Context, libraryID, trackID string) (*Track, error)
+
+	// PerformanceData returns the analysis data of a track.
+	PerformanceData(ctx context.Context, libraryID, trackID string) (*PerformanceData, error)
+
+	// OpenBlob opens the audio file with the given Track.URL.
+	OpenBlob(ctx context.Context, url string) (io.ReadSeekCloser, error)
+}
+
+// ErrVersionUnknown is returned by a VersionProvider that can't tell when the
+// tracks of a library change, for example because it wraps a provider that
+// doesn't say.
+var ErrVersionUnknown = errors.New("version unknown")
+
+// VersionProvider can be implemented by a LibraryProvider to tell the server
+// when the tracks of a library change. The server keeps the search indexes of
+// the library and its playlists as long as the version stays the same.
+//
+// Without it the server drops the indexes of a library whenever the
+// provider reports a change via EventProvider, and providers implementing
+// neither have their tracks indexed again for every request.
+type VersionProvider interface {
+	// TracksVersion returns a value that changes whenever the tracks of the
+	// library or of any of its playlists change, or ErrVersionUnknown. A
+	// provider returning ErrVersionUnknown for a library has to keep doing
+	// so.
+	TracksVersion(ctx context.Context, libraryID string) (uint64, error)
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/icedream/go-stagelinq/eaas"
+	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
+	"github.com/icedream/go-stagelinq/eaas/proto/networktrust"
+	"github.com/icedream/go-stagelinq/internal/socket"
+	"google.golang.org/grpc"
+)
+
+// ErrServerStarted is returned by Server.Start if the server is already
+// running or has been shut down.
+var ErrServerStarted = errors.New("server already started")
+
+// ServerConfiguration contains configurable values for a Server.
+type ServerConfiguration struct {
+	// Name is the name under which the library is announced to the network.
+	// Defaults to the hostname.
+	Name string
+
+	// SoftwareVersion is your application's version, announced to the
+	// network.
+	SoftwareVersion string
+
+	// Token identifies the library on the network. Devices use it to tell a
+	// library that restarted from a new one, so keep it stable. A random
+	// token is used if left empty.
+	Token eaas.Token
+
+	// GRPCPort is the port the gRPC API listens on. Defaults to
+	// eaas.DefaultEAASGRPCPort.
+	GRPCPort uint16
+
+	// HTTPPort is the port the HTTP endpoints listen on. Devices expect it
+	// 10 above the gRPC port, which is the default.
+	HTTPPort uint16
+
+	// GRPCListener and HTTPListener can be set to serve on existing
+	// listeners instead of listening on GRPCPort and HTTPPort.
+	GRPCListener net.Listener
+	HTTPListener net.Listener
+
+	// GRPCServerOptions are passed to the gRPC server, for example to install
+	// interceptors for logging.
+	GRPCServerOptions []grpc.ServerOption
+
+	// Trust decides which devices are granted access. Defaults to TrustAll.
+	Trust TrustFunc
+
+	// DisableBeacon turns off announcing the library to the network, for
+	// example if clients are pointed at it directly.
+	DisableBeacon bool
+}
+
+// Server serves the libraries of a LibraryProvider to devices via the EAAS
+// gRPC API and HTTP endpoints, and announces them to the network.
+type Server struct {
+	config   ServerConfiguration
+	provider LibraryProvider
+
+	lock         sync.Mutex
+	started      bool
+	grpcServer   *grpc.Server
+	httpServer   *http.Server
+	grpcListener net.Listener
+	httpListener net.Listener
+	beacon       *eaas.Beacon
+	serveErrC    chan error
+}
+
+// NewServer creates a server for the libraries of the given provider. Call
+// Start to start serving.
+func NewServer(provider LibraryProvider, config *ServerConfiguration) *Server {
+	if config == nil {
+		config = new(ServerConfiguration)
+	}
+	c := *config
+	if c.Name == "" {
+		if hostname, err := os.Hostname(); err == nil {
+			c.Name = hostname
+		}
+	}
+	if c.GRPCPort == 0 {
+		c.GRPCPort = eaas.DefaultEAASGRPCPort
+	}
+	if c.HTTPPort == 0 {
+		c.HTTPPort = c.GRPCPort + (eaas.DefaultEAASHTTPPort - eaas.DefaultEAASGRPCPort)
+	}
+	return &Server{
+		config:    c,
+		provider:  provider,
+		serveErrC: make(chan error, 2),
+	}
+}
+
+// Start listens on the configured ports, starts serving in the background
+// and announces the library to the network.
+func (s *Server) Start() (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.started {
+		return ErrServerStarted
+	}
+
+	grpcListener := s.config.GRPCListener
+	if grpcListener == nil {
+		if grpcListener, err = net.Listen("tcp", fmt.Sprintf(":%d", s.config.GRPCPort)); err != nil {
+			return
+		}
+	}
+	httpListener := s.config.HTTPListener
+	if httpListener == nil {
+		if httpListener, err = net.Listen("tcp", fmt.Sprintf(":%d", s.config.HTTPPort)); err != nil {
+			grpcListener.Close()
+			return
+		}
+	}
+
+	var beacon *eaas.Beacon
+	if !s.config.DisableBeacon {
+		beacon, err = eaas.StartBeaconWithConfiguration(&eaas.BeaconConfiguration{
+			Name:            s.config.Name,
+			SoftwareVersion: s.config.SoftwareVersion,
+			Token:           s.config.Token,
+			GRPCPort:        uint16(socket.GetPort(grpcListener.Addr())),
+		})
+		if err != nil {
+			grpcListener.Close()
+			httpListener.Close()
+			return
+		}
+	}
+
+	s.grpcServer = grpc.NewServer(s.config.GRPCServerOptions...)
+	enginelibrary.RegisterEngineLibraryServiceServer(s.grpcServer, NewEngineLibraryServiceServer(s.provider))
+	networktrust.RegisterNetworkTrustServiceServer(s.grpcServer, NewNetworkTrustServiceServer(s.config.Trust))
+	s.httpServer = &http.Server{Handler: NewHTTPHandler(s.provider)}
+	s.grpcListener = grpcListener
+	s.httpListener = httpListener
+	s.beacon = beacon
+	s.started = true
+
+	grpcServer, httpServer := s.grpcServer, s.httpServer
+	go func() {
+		s.serveErrC <- grpcServer.Serve(grpcListener)
+	}()
+	go func() {
+		if err := httpServer.Serve(httpListener); !errors.Is(err, http.ErrServerClosed) {
+			s.serveErrC <- err
+			return
+		}
+		s.serveErrC <- nil
+	}()
+	return
+}
+
+// GRPCAddr returns the address the gRPC API listens on once started.
+func (s *Server) GRPCAddr() net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.grpcListener == nil {
+		return nil
+	}
+	return s.grpcListener.Addr()
+}
+
+// HTTPAddr returns the address the HTTP endpoints listen on once started.
+func (s *Server) HTTPAddr() net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.httpListener == nil {
+		return nil
+	}
+	return s.httpListener.Addr()
+}
+
+// Token returns the token the library is announced with, or the configured
+// token if the beacon is disabled.
+func (s *Server) Token() eaas.Token {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.beacon != nil {
+		return s.beacon.Token()
+	}
+	return s.config.Token
+}
+
+// Shutdown stops announcing the library, stops accepting new requests and
+// waits for running requests and downloads to finish. Once ctx is done, the
+// remaining connections are closed forcefully.
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	s.lock.Lock()
+	if !s.started || s.grpcServer == nil {
+		s.lock.Unlock()
+		return
+	}
+	grpcServer, httpServer, beacon := s.grpcServer, s.httpServer, s.beacon
+	s.grpcServer, s.httpServer, s.beacon = nil, nil, nil
+	s.lock.Unlock()
+
+	var errs []error
+	if beacon != nil {
+		errs = append(errs, beacon.Shutdown())
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		errs = append(errs, err, httpServer.Close())
+	}
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		grpcServer.Stop()
+		<-stopped
+	}
+
+	for i := 0; i < 2; i++ {
+		errs = append(errs, <-s.serveErrC)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/color"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas"
+	"github.com/icedream/go-stagelinq/eaas/proto/enginelibrary"
+	"github.com/icedream/go-stagelinq/eaas/proto/networktrust"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type testProvider struct {
+	tracks []*Track
+	blobs  map[string][]byte
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func newTestProvider() *testProvider {
+	return &testProvider{
+		tracks: []*Track{
+			{ID: "1", Title: "Whiplash", Artist: "Icedream", Genre: "Trance", BPM: 140, Key: "Am", URL: "C:/Music/whiplash.m4a", FileSize: 10},
+			{ID: "2", Title: "Other Song", Artist: "Someone", Genre: "House", BPM: 124, URL: "C:/Music/other.mp3", FileSize: 3},
+		},
+		blobs: map[string][]byte{
+			"C:/Music/whiplash.m4a": []byte("0123456789"),
+		},
+	}
+}
+
+func (p *testProvider) Libraries(ctx context.Context) ([]*Library, error) {
+	return []*Library{{ID: "lib", Title: "Test Library"}}, nil
+}
+
+func (p *testProvider) Playlists(ctx context.Context, libraryID string) ([]*Playlist, error) {
+	if libraryID != "lib" {
+		return nil, ErrNotFound
+	}
+	return []*Playlist{{
+		ID: "folder", Title: "Folder",
+		Children: []*Playlist{
+			{ID: "pl", Title: "Playlist", TrackCount: 1},
+			{ID: "smart", Title: "Smart", Smart: true},
+		},
+	}}, nil
+}
+
+func (p *testProvider) Tracks(ctx context.Context, libraryID, playlistID string) ([]*Track, error) {
+	switch playlistID {
+	case "":
+		return p.tracks, nil
+	case "pl":
+		return p.tracks[1:], nil
+	}
+	return nil, ErrNotFound
+}
+
+func (p *testProvider) Track(ctx context.Context, libraryID, trackID string) (*Track, error) {
+	for _, track := range p.tracks {
+		if track.ID == trackID {
+			return track, nil
+		}
+	}
+	return nil, fmt.Errorf("track %q: %w", trackID, ErrNotFound)
+}
+
+func (p *testProvider) PerformanceData(ctx context.Context, libraryID, trackID string) (*PerformanceData, error) {
+	if trackID != "1" {
+		return nil, ErrNotFound
+	}
+	return &PerformanceData{
+		BeatGrid: []byte{1, 2, 3},
+		QuickCues: map[int]*QuickCue{
+			2: {Name: "Drop", Position: 44100, Color: color.RGBA{R: 255, A: 255}},
+		},
+	}, nil
+}
+
+func (p *testProvider) OpenBlob(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	b, ok := p.blobs[url]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return nopSeekCloser{bytes.NewReader(b)}, nil
+}
+
+func (p *testProvider) HistorySessions(ctx context.Context, libraryID string) ([]*HistorySession, error) {
+	return []*HistorySession{{
+		ID: "gig", Title: "Friday", StartTime: time.Unix(1700000000, 0), TrackCount: 1,
+	}}, nil
+}
+
+func (p *testProvider) HistoryPlayedTracks(ctx context.Context, libraryID, sessionID string) ([]*PlayedTrack, error) {
+	if sessionID != "gig" {
+		return nil, ErrNotFound
+	}
+	return []*PlayedTrack{{ID: "1", Track: p.tracks[0], StartTime: time.Unix(1700000060, 0)}}, nil
+}
+
+func startTestServer(t *testing.T) (*Server, *eaas.EngineLibraryConnection) {
+	grpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	httpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := NewServer(newTestProvider(), &ServerConfiguration{
+		GRPCListener:  grpcListener,
+		HTTPListener:  httpListener,
+		DisableBeacon: true,
+		Trust: func(ctx context.Context, req *networktrust.CreateTrustRequest) bool {
+			return req.GetDeviceName() != "untrusted"
+		},
+	})
+	require.NoError(t, s.Start())
+	require.ErrorIs(t, s.Start(), ErrServerStarted)
+
+	conn, err := eaas.Dial(s.GRPCAddr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return s, conn
+}
+
+func Test_Server_Library(t *testing.T) {
+	s, conn := startTestServer(t)
+	defer s.Shutdown(context.Background())
+	ctx := context.Background()
+
+	libraries, err := conn.GetLibraries(ctx, &enginelibrary.GetLibrariesRequest{})
+	require.NoError(t, err)
+	require.Len(t, libraries.GetLibraries(), 1)
+	require.Equal(t, "Test Library", libraries.GetLibraries()[0].GetTitle())
+
+	// the default library is the first one
+	library, err := conn.GetLibrary(ctx, &enginelibrary.GetLibraryRequest{})
+	require.NoError(t, err)
+	require.Len(t, library.GetPlaylists(), 1)
+	require.Equal(t, "pl", library.GetPlaylists()[0].GetPlaylists()[0].GetId())
+	require.Equal(t, enginelibrary.ListType_LIST_TYPE_PLAY, library.GetPlaylists()[0].GetListType())
+	require.Equal(t, enginelibrary.ListType_LIST_TYPE_SMART, library.GetPlaylists()[0].GetPlaylists()[1].GetListType())
+
+	_, err = conn.GetLibrary(ctx, &enginelibrary.GetLibraryRequest{LibraryId: proto.String("missing")})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	tracks, err := conn.GetTracks(ctx, &enginelibrary.GetTracksRequest{PlaylistId: proto.String("pl")})
+	require.NoError(t, err)
+	require.Len(t, tracks.GetTracks(), 1)
+	require.Equal(t, "Other Song", tracks.GetTracks()[0].GetMetadata().GetTitle())
+
+	tracks, err = conn.GetTracks(ctx, &enginelibrary.GetTracksRequest{
+		PageSize: proto.Uint32(1),
+		Sort: []*enginelibrary.Sort{{
+			Field:     enginelibrary.SortField_SORT_FIELD_BPM.Enum(),
+			Direction: enginelibrary.SortDirection_SORT_DIRECTION_ASC.Enum(),
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, tracks.GetTracks(), 1)
+	require.Equal(t, "2", tracks.GetTracks()[0].GetMetadata().GetId())
+
+	found, err := conn.SearchTracks(ctx, &enginelibrary.SearchTracksRequest{Query: proto.String("whip")})
+	require.NoError(t, err)
+	require.Len(t, found.GetTracks(), 1)
+	require.Equal(t, "1", found.GetTracks()[0].GetMetadata().GetId())
+
+	found, err = conn.SearchTracks(ctx, &enginelibrary.SearchTracksRequest{
+		Filters: []*enginelibrary.SearchFilter{{
+			Field: enginelibrary.SearchFilterField_SEARCH_FILTER_FIELD_BPM.Enum(),
+			Value: []string{"120-130"},
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, found.GetTracks(), 1)
+	require.Equal(t, "2", found.GetTracks()[0].GetMetadata().GetId())
+
+	filters, err := conn.GetSearchFilters(ctx, &enginelibrary.GetSearchFiltersRequest{})
+	require.NoError(t, err)
+	require.Len(t, filters.GetSearchFilters().GetGenres(), 2)
+	require.Equal(t, "House", filters.GetSearchFilters().GetGenres()[0].GetValue())
+	require.Len(t, filters.GetSearchFilters().GetKeys(), 1)
+
+	track, err := conn.GetTrack(ctx, &enginelibrary.GetTrackRequest{TrackId: proto.String("1")})
+	require.NoError(t, err)
+	require.Equal(t, "<C:/Music/whiplash.m4a>", track.GetBlob().GetUrl().GetUrl())
+	require.Equal(t, uint32(10), track.GetBlob().GetUrl().GetFileSize())
+	require.Equal(t, []byte{1, 2, 3}, track.GetPerformanceData().GetBeatGrid())
+	require.Equal(t, 140.0, track.GetPerformanceData().GetBpm())
+	require.Len(t, track.GetPerformanceData().GetQuickCues(), 1)
+	require.Equal(t, uint32(2), track.GetPerformanceData().GetQuickCues()[0].GetKey())
+	require.Equal(t, "Drop", track.GetPerformanceData().GetQuickCues()[0].GetValue().GetName())
+
+	// tracks without performance data still get unset cue positions
+	track, err = conn.GetTrack(ctx, &enginelibrary.GetTrackRequest{TrackId: proto.String("2")})
+	require.NoError(t, err)
+	require.Equal(t, unsetPosition, track.GetPerformanceData().GetMainCue().GetPosition())
+
+	_, err = conn.GetTrack(ctx, &enginelibrary.GetTrackRequest{TrackId: proto.String("3")})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	sessions, err := conn.GetHistorySessions(ctx, &enginelibrary.GetHistorySessionsRequest{})
+	require.NoError(t, err)
+	require.Len(t, sessions.GetSessions(), 1)
+	require.Equal(t, "Friday", sessions.GetSessions()[0].GetTitle())
+	require.Equal(t, uint32(1), sessions.GetSessions()[0].GetPlayedTrackCount())
+
+	played, err := conn.GetHistoryPlayedTracks(ctx, &enginelibrary.GetHistoryPlayedTracksRequest{SessionId: proto.String("gig")})
+	require.NoError(t, err)
+	require.Len(t, played.GetTracks(), 1)
+	require.Equal(t, "Whiplash", played.GetTracks()[0].GetPlayedTrack().GetMetadata().GetTitle())
+	require.Equal(t, int64(1700000060), played.GetTracks()[0].GetPlayedTrack().GetStartTime().GetSeconds())
+
+	_, err = conn.GetHistoryPlayedTracks(ctx, &enginelibrary.GetHistoryPlayedTracksRequest{SessionId: proto.String("missing")})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	trust, err := conn.CreateTrust(ctx, &networktrust.CreateTrustRequest{DeviceName: proto.String("prime4")})
+	require.NoError(t, err)
+	require.NotNil(t, trust.GetGranted())
+	trust, err = conn.CreateTrust(ctx, &networktrust.CreateTrustRequest{DeviceName: proto.String("untrusted")})
+	require.NoError(t, err)
+	require.NotNil(t, trust.GetDenied())
+}
+
+func Test_Server_HTTP(t *testing.T) {
+	s, _ := startTestServer(t)
+	base := "http://" + s.HTTPAddr().String()
+
+	resp, err := http.Get(base + "/ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	downloadURL := base + "/download/" + url.PathEscape("<C:/Music/whiplash.m4a>")
+	resp, err = http.Get(downloadURL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "0123456789", string(b))
+
+	req, err := http.NewRequest(http.MethodGet, downloadURL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Range", "bytes=2-4")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	b, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "234", string(b))
+
+	resp, err = http.Get(base + "/download/" + url.PathEscape("<C:/Music/missing.mp3>"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, s.Shutdown(ctx))
+	_, err = http.Get(base + "/ping")
+	require.Error(t, err)
+}
@@ -0,0 +1,43 @@
+package smartlist
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Definition defines a smart playlist, or a folder of smart playlists if it
+// has no rule.
+type Definition struct {
+	Title string `json:"title"`
+
+	// Rule picks the tracks of the playlist, see Parse.
+	Rule string `json:"rule,omitempty"`
+
+	// Playlists are the playlists nested in this one.
+	Playlists []*Definition `json:"playlists,omitempty"`
+}
+
+// file is the layout of smart playlist files.
+type file struct {
+	Playlists []*Definition `json:"playlists"`
+}
+
+// Load reads smart playlist definitions from a JSON file like
+//
+//	{"playlists": [
+//		{"title": "Peak Time", "rule": "bpm between 128 and 135 and genre contains techno"},
+//		{"title": "By Key", "playlists": [
+//			{"title": "Around Am", "rule": "key compatible Am"}
+//		]}
+//	]}
+func Load(name string) ([]*Definition, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	return f.Playlists, nil
+}
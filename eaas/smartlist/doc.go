@@ -0,0 +1,43 @@
+/*
+This package adds smart playlists to the libraries of any
+server.LibraryProvider. Smart playlists pick their tracks by rules instead of
+by hand and are served to devices as such.
+
+Rules compare the fields of tracks and are combined with "and", "or", "not"
+and parentheses, "and" binding tighter than "or":
+
+	bpm between 124 and 130 and (genre = house or genre contains "tech house")
+	key compatible 8A and rating >= 4
+	added within 30d and not comment contains warmup
+	year < 2000 or album = "Greatest Hits"
+
+Text fields are title, artist, album, genre, comment, label, composer,
+remixer and text, which stands for any of these. They are compared with "=",
+"!=" and "contains", ignoring case. Values with spaces or operators in them
+are quoted.
+
+Number fields are bpm, rating in stars from 0 to 5 and year. They are
+compared with "=", "!=", "<", "<=", ">", ">=" and "between … and …", which
+includes both ends. Tracks with no BPM or year don't match any comparison of
+it.
+
+The key field is compared with "=" and "!=" in any notation musickey.Parse
+understands, so "Am", "8A" and "1m" are the same, and with "compatible",
+which matches the key, keys a fifth apart and the relative major or minor
+key.
+
+The added field is the day a track was added. It is compared to dates like
+2024-12-31 like number fields and with "within" to periods before now, given
+in hours (h), days (d), weeks (w), months (m) or years (y).
+
+Smart playlists are usually defined in a JSON file, see Load.
+
+	definitions, err := smartlist.Load("smartlists.json")
+	if err != nil {
+		return err
+	}
+	provider, err := smartlist.NewProvider(library, &smartlist.ProviderConfiguration{
+		Playlists: definitions,
+	})
+*/
+package smartlist
@@ -0,0 +1,233 @@
+package smartlist
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/musickey"
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+// starRating is the rating of a star, rules count stars.
+const starRating = 20
+
+var errInvalidPeriod = errors.New("invalid period")
+
+// textFields maps the names of text fields to the values they compare.
+var textFields = map[string][]func(*server.Track) string{
+	"title":    {trackTitle},
+	"artist":   {trackArtist},
+	"album":    {trackAlbum},
+	"genre":    {trackGenre},
+	"comment":  {trackComment},
+	"label":    {trackLabel},
+	"composer": {trackComposer},
+	"remixer":  {trackRemixer},
+	"text": {
+		trackTitle, trackArtist, trackAlbum, trackGenre,
+		trackComment, trackLabel, trackComposer, trackRemixer,
+	},
+}
+
+func trackTitle(track *server.Track) string    { return track.Title }
+func trackArtist(track *server.Track) string   { return track.Artist }
+func trackAlbum(track *server.Track) string    { return track.Album }
+func trackGenre(track *server.Track) string    { return track.Genre }
+func trackComment(track *server.Track) string  { return track.Comment }
+func trackLabel(track *server.Track) string    { return track.Label }
+func trackComposer(track *server.Track) string { return track.Composer }
+func trackRemixer(track *server.Track) string  { return track.Remixer }
+
+// numberFields maps the names of number fields to their values. Fields that
+// are not set don't match any comparison.
+var numberFields = map[string]func(*server.Track) (float64, bool){
+	"bpm": func(track *server.Track) (float64, bool) {
+		return track.BPM, track.BPM > 0
+	},
+	"rating": func(track *server.Track) (float64, bool) {
+		// unrated tracks have no stars
+		return float64(track.Rating) / starRating, true
+	},
+	"year": func(track *server.Track) (float64, bool) {
+		return float64(track.Year), track.Year > 0
+	},
+}
+
+// allRule matches tracks matching all of its rules.
+type allRule []Rule
+
+func (r allRule) Match(track *server.Track) bool {
+	for _, rule := range r {
+		if !rule.Match(track) {
+			return false
+		}
+	}
+	return true
+}
+
+// anyRule matches tracks matching any of its rules.
+type anyRule []Rule
+
+func (r anyRule) Match(track *server.Track) bool {
+	for _, rule := range r {
+		if rule.Match(track) {
+			return true
+		}
+	}
+	return false
+}
+
+type notRule struct {
+	Rule
+}
+
+func (r notRule) Match(track *server.Track) bool {
+	return !r.Rule.Match(track)
+}
+
+// textRule matches tracks with any of the values equal to or containing the
+// lower case value, ignoring case.
+type textRule struct {
+	values   []func(*server.Track) string
+	value    string
+	contains bool
+}
+
+func (r textRule) Match(track *server.Track) bool {
+	for _, value := range r.values {
+		v := strings.ToLower(value(track))
+		if r.contains && strings.Contains(v, r.value) || !r.contains && v == r.value {
+			return true
+		}
+	}
+	return false
+}
+
+// numberRule compares a number field to a, or checks whether it is between a
+// and b.
+type numberRule struct {
+	value func(*server.Track) (float64, bool)
+	op    string
+	a, b  float64
+}
+
+func (r numberRule) Match(track *server.Track) bool {
+	v, ok := r.value(track)
+	if !ok {
+		return false
+	}
+	switch r.op {
+	case "=":
+		return v == r.a
+	case "!=":
+		return v != r.a
+	case "<":
+		return v < r.a
+	case "<=":
+		return v <= r.a
+	case ">":
+		return v > r.a
+	case ">=":
+		return v >= r.a
+	case "between":
+		return v >= r.a && v <= r.b
+	}
+	return false
+}
+
+// keyRule matches tracks in the given key or a key compatible with it. Keys
+// are compared in any notation, keys that can't be parsed by name.
+type keyRule struct {
+	name       string
+	key        musickey.Key
+	known      bool
+	compatible bool
+}
+
+func (r keyRule) Match(track *server.Track) bool {
+	if track.Key == "" {
+		return false
+	}
+	k, err := musickey.Parse(track.Key)
+	if err != nil || !r.known {
+		return strings.EqualFold(track.Key, r.name)
+	}
+	if r.compatible {
+		return r.key.Compatible(k)
+	}
+	return r.key == k
+}
+
+// dateRule compares the day a track was added to a, checks whether it is
+// between a and b or whether it was added within a period before now.
+type dateRule struct {
+	op     string
+	a, b   time.Time
+	within period
+}
+
+func (r dateRule) Match(track *server.Track) bool {
+	if track.DateAdded.IsZero() {
+		return false
+	}
+	if r.op == "within" {
+		return !track.DateAdded.Before(r.within.before(now()))
+	}
+	y, m, d := track.DateAdded.In(time.Local).Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	switch r.op {
+	case "=":
+		return day.Equal(r.a)
+	case "!=":
+		return !day.Equal(r.a)
+	case "<":
+		return day.Before(r.a)
+	case "<=":
+		return !day.After(r.a)
+	case ">":
+		return day.After(r.a)
+	case ">=":
+		return !day.Before(r.a)
+	case "between":
+		return !day.Before(r.a) && !day.After(r.b)
+	}
+	return false
+}
+
+// period is a number of hours, days, weeks, months or years.
+type period struct {
+	n    int
+	unit byte
+}
+
+// parsePeriod parses periods like "12h", "30d", "2w", "6m" or "1y".
+func parsePeriod(s string) (p period, err error) {
+	if len(s) < 2 {
+		return p, errInvalidPeriod
+	}
+	if p.n, err = strconv.Atoi(s[:len(s)-1]); err != nil || p.n < 0 {
+		return p, errInvalidPeriod
+	}
+	p.unit = s[len(s)-1] | 0x20 // lower case
+	if !strings.ContainsRune("hdwmy", rune(p.unit)) {
+		return p, errInvalidPeriod
+	}
+	return p, nil
+}
+
+// before returns the time the period before t.
+func (p period) before(t time.Time) time.Time {
+	switch p.unit {
+	case 'h':
+		return t.Add(-time.Duration(p.n) * time.Hour)
+	case 'd':
+		return t.AddDate(0, 0, -p.n)
+	case 'w':
+		return t.AddDate(0, 0, -7*p.n)
+	case 'm':
+		return t.AddDate(0, -p.n, 0)
+	}
+	return t.AddDate(-p.n, 0, 0)
+}
@@ -0,0 +1,322 @@
+package smartlist
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+// ProviderConfiguration contains configurable values for a Provider.
+type ProviderConfiguration struct {
+	// Playlists are the smart playlists added to every library, after the
+	// playlists of the library itself.
+	Playlists []*Definition
+}
+
+var _ server.LibraryProvider = &Provider{}
+var _ server.EventProvider = &Provider{}
+var _ server.HistoryProvider = &Provider{}
+var _ server.VersionProvider = &Provider{}
+
+// Provider adds smart playlists to the libraries of another provider.
+//
+// The tracks of smart playlists are picked from all tracks of a library in
+// library order whenever they are asked for. If the other provider reports
+// changes, the smart playlists whose tracks changed are reported along with
+// them.
+//
+// Playlist IDs are derived from the library ID and the titles of the
+// playlist and the folders it is in, so they stay the same across restarts
+// as long as the playlists are not renamed.
+type Provider struct {
+	base      server.LibraryProvider
+	playlists []*playlist
+
+	lock sync.Mutex
+	// results holds the track IDs of each smart playlist by library and
+	// playlist ID, as last reported
+	results map[string]map[string][]string
+
+	events chan *server.Event
+}
+
+// playlist is a parsed Definition.
+type playlist struct {
+	// key identifies the playlist among the others
+	key      string
+	title    string
+	rule     Rule
+	children []*playlist
+}
+
+// NewProvider wraps the given provider, adding the configured smart
+// playlists to its libraries.
+func NewProvider(base server.LibraryProvider, config *ProviderConfiguration) (p *Provider, err error) {
+	if config == nil {
+		config = new(ProviderConfiguration)
+	}
+	p = &Provider{
+		base:    base,
+		results: map[string]map[string][]string{},
+		events:  make(chan *server.Event, 16),
+	}
+	if p.playlists, err = parseDefinitions(config.Playlists, ""); err != nil {
+		return nil, err
+	}
+
+	events, ok := base.(server.EventProvider)
+	if !ok {
+		close(p.events)
+		return
+	}
+	// remember the current tracks, so the first event can tell what changed
+	ctx := context.Background()
+	libraries, err := base.Libraries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, library := range libraries {
+		p.update(ctx, library.ID)
+	}
+	go p.relay(events.Events())
+	return
+}
+
+func parseDefinitions(definitions []*Definition, parent string) ([]*playlist, error) {
+	playlists := make([]*playlist, 0, len(definitions))
+	seen := map[string]int{}
+	for _, definition := range definitions {
+		key := parent + "/" + definition.Title
+		// playlists of the same name are told apart by their order
+		if seen[definition.Title]++; seen[definition.Title] > 1 {
+			key += fmt.Sprintf("#%d", seen[definition.Title])
+		}
+		pl := &playlist{key: key, title: definition.Title}
+		if definition.Rule != "" {
+			rule, err := Parse(definition.Rule)
+			if err != nil {
+				return nil, fmt.Errorf("smart playlist %q: %w", definition.Title, err)
+			}
+			pl.rule = rule
+		}
+		children, err := parseDefinitions(definition.Playlists, key)
+		if err != nil {
+			return nil, err
+		}
+		pl.children = children
+		playlists = append(playlists, pl)
+	}
+	return playlists, nil
+}
+
+// id returns the ID of a smart playlist in the given library.
+func (p *Provider) id(libraryID string, pl *playlist) string {
+	namespace, err := uuid.Parse(libraryID)
+	if err != nil {
+		namespace = uuid.NewSHA1(uuid.NameSpaceOID, []byte(libraryID))
+	}
+	return uuid.NewSHA1(namespace, []byte("smart:"+pl.key)).String()
+}
+
+// find returns the smart playlist with the given ID, or nil.
+func (p *Provider) find(libraryID, playlistID string, playlists []*playlist) *playlist {
+	for _, pl := range playlists {
+		if p.id(libraryID, pl) == playlistID {
+			return pl
+		}
+		if found := p.find(libraryID, playlistID, pl.children); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// each calls f for all smart playlists with a rule.
+func each(playlists []*playlist, f func(pl *playlist)) {
+	for _, pl := range playlists {
+		if pl.rule != nil {
+			f(pl)
+		}
+		each(pl.children, f)
+	}
+}
+
+func pick(rule Rule, tracks []*server.Track) []*server.Track {
+	picked := []*server.Track{}
+	for _, track := range tracks {
+		if rule.Match(track) {
+			picked = append(picked, track)
+		}
+	}
+	return picked
+}
+
+// update works out the tracks of the smart playlists of a library and
+// returns the IDs of those whose tracks changed since the last update.
+func (p *Provider) update(ctx context.Context, libraryID string) (changed []string) {
+	tracks, err := p.base.Tracks(ctx, libraryID, "")
+	if err != nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	results, ok := p.results[libraryID]
+	if !ok {
+		results = map[string][]string{}
+		p.results[libraryID] = results
+	}
+	each(p.playlists, func(pl *playlist) {
+		id := p.id(libraryID, pl)
+		picked := pick(pl.rule, tracks)
+		ids := make([]string, len(picked))
+		for i, track := range picked {
+			ids[i] = track.ID
+		}
+		previous, known := results[id]
+		if known && equal(previous, ids) {
+			return
+		}
+		results[id] = ids
+		// playlists seen for the first time are no change
+		if known {
+			changed = append(changed, id)
+		}
+	})
+	return
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// relay passes on the events of the wrapped provider, adding the smart
+// playlists whose tracks changed.
+func (p *Provider) relay(events <-chan *server.Event) {
+	defer close(p.events)
+	for event := range events {
+		changed := p.update(context.Background(), event.LibraryID)
+		if len(changed) > 0 {
+			e := *event
+			e.PlaylistIDs = append(append([]string{}, event.PlaylistIDs...), changed...)
+			event = &e
+		}
+		p.events <- event
+	}
+}
+
+// Events implements server.EventProvider. The channel is closed along with
+// the one of the wrapped provider, or right away if that does not report
+// changes.
+func (p *Provider) Events() <-chan *server.Event {
+	return p.events
+}
+
+// Libraries implements server.LibraryProvider.
+func (p *Provider) Libraries(ctx context.Context) ([]*server.Library, error) {
+	return p.base.Libraries(ctx)
+}
+
+// Playlists implements server.LibraryProvider.
+func (p *Provider) Playlists(ctx context.Context, libraryID string) ([]*server.Playlist, error) {
+	playlists, err := p.base.Playlists(ctx, libraryID)
+	if err != nil || len(p.playlists) == 0 {
+		return playlists, err
+	}
+	tracks, err := p.base.Tracks(ctx, libraryID, "")
+	if err != nil {
+		return nil, err
+	}
+	var convert func(playlists []*playlist) []*server.Playlist
+	convert = func(playlists []*playlist) []*server.Playlist {
+		converted := make([]*server.Playlist, 0, len(playlists))
+		for _, pl := range playlists {
+			c := &server.Playlist{
+				ID:       p.id(libraryID, pl),
+				Title:    pl.title,
+				Smart:    pl.rule != nil,
+				Children: convert(pl.children),
+			}
+			if pl.rule != nil {
+				c.TrackCount = len(pick(pl.rule, tracks))
+			}
+			converted = append(converted, c)
+		}
+		return converted
+	}
+	// the slice may be kept by the wrapped provider, so don't append to it
+	return append(playlists[:len(playlists):len(playlists)], convert(p.playlists)...), nil
+}
+
+// TracksVersion implements server.VersionProvider. Smart playlists change
+// only along with the tracks of the library, so this is the version of the
+// wrapped provider if it tells.
+func (p *Provider) TracksVersion(ctx context.Context, libraryID string) (uint64, error) {
+	if versions, ok := p.base.(server.VersionProvider); ok {
+		return versions.TracksVersion(ctx, libraryID)
+	}
+	return 0, server.ErrVersionUnknown
+}
+
+// Tracks implements server.LibraryProvider. Folders of smart playlists have
+// no tracks of their own.
+func (p *Provider) Tracks(ctx context.Context, libraryID, playlistID string) ([]*server.Track, error) {
+	if playlistID == "" {
+		return p.base.Tracks(ctx, libraryID, "")
+	}
+	pl := p.find(libraryID, playlistID, p.playlists)
+	if pl == nil {
+		return p.base.Tracks(ctx, libraryID, playlistID)
+	}
+	if pl.rule == nil {
+		return nil, nil
+	}
+	tracks, err := p.base.Tracks(ctx, libraryID, "")
+	if err != nil {
+		return nil, err
+	}
+	return pick(pl.rule, tracks), nil
+}
+
+// Track implements server.LibraryProvider.
+func (p *Provider) Track(ctx context.Context, libraryID, trackID string) (*server.Track, error) {
+	return p.base.Track(ctx, libraryID, trackID)
+}
+
+// PerformanceData implements server.LibraryProvider.
+func (p *Provider) PerformanceData(ctx context.Context, libraryID, trackID string) (*server.PerformanceData, error) {
+	return p.base.PerformanceData(ctx, libraryID, trackID)
+}
+
+// OpenBlob implements server.LibraryProvider.
+func (p *Provider) OpenBlob(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	return p.base.OpenBlob(ctx, url)
+}
+
+// HistorySessions implements server.HistoryProvider. There are none unless
+// the wrapped provider keeps a history.
+func (p *Provider) HistorySessions(ctx context.Context, libraryID string) ([]*server.HistorySession, error) {
+	if history, ok := p.base.(server.HistoryProvider); ok {
+		return history.HistorySessions(ctx, libraryID)
+	}
+	return nil, nil
+}
+
+// HistoryPlayedTracks implements server.HistoryProvider.
+func (p *Provider) HistoryPlayedTracks(ctx context.Context, libraryID, sessionID string) ([]*server.PlayedTrack, error) {
+	if history, ok := p.base.(server.HistoryProvider); ok {
+		return history.HistoryPlayedTracks(ctx, libraryID, sessionID)
+	}
+	return nil, server.ErrNotFound
+}
@@ -0,0 +1,203 @@
+package smartlist
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/server"
+	"github.com/stretchr/testify/require"
+)
+
+const testLibraryID = "3d9a1c52-7d4e-4d7b-9a51-8f0e6c2b1a44"
+
+// testProvider serves testTracks and a single playlist, reporting changes
+// via events.
+type testProvider struct {
+	lock   sync.Mutex
+	tracks []*server.Track
+	events chan *server.Event
+}
+
+func (p *testProvider) Libraries(ctx context.Context) ([]*server.Library, error) {
+	return []*server.Library{{ID: testLibraryID, Title: "Test"}}, nil
+}
+
+func (p *testProvider) Playlists(ctx context.Context, libraryID string) ([]*server.Playlist, error) {
+	if libraryID != testLibraryID {
+		return nil, server.ErrNotFound
+	}
+	return []*server.Playlist{{ID: "crate", Title: "Crate", TrackCount: 1}}, nil
+}
+
+func (p *testProvider) Tracks(ctx context.Context, libraryID, playlistID string) ([]*server.Track, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	switch {
+	case libraryID != testLibraryID:
+		return nil, server.ErrNotFound
+	case playlistID == "":
+		return p.tracks, nil
+	case playlistID == "crate":
+		return p.tracks[:1], nil
+	}
+	return nil, server.ErrNotFound
+}
+
+func (p *testProvider) Track(ctx context.Context, libraryID, trackID string) (*server.Track, error) {
+	return nil, server.ErrNotFound
+}
+
+func (p *testProvider) PerformanceData(ctx context.Context, libraryID, trackID string) (*server.PerformanceData, error) {
+	return nil, server.ErrNotFound
+}
+
+func (p *testProvider) OpenBlob(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	return nil, server.ErrNotFound
+}
+
+func (p *testProvider) Events() <-chan *server.Event {
+	return p.events
+}
+
+// retag changes the genre of a track and reports it.
+func (p *testProvider) retag(i int, genre string) {
+	p.lock.Lock()
+	track := *p.tracks[i]
+	track.Genre = genre
+	p.tracks = append([]*server.Track{}, p.tracks...)
+	p.tracks[i] = &track
+	p.lock.Unlock()
+	p.events <- &server.Event{LibraryID: testLibraryID, Tracks: []*server.Track{&track}}
+}
+
+// versionedProvider tells the version of the tracks of testProvider.
+type versionedProvider struct {
+	*testProvider
+	version uint64
+}
+
+func (p *versionedProvider) TracksVersion(ctx context.Context, libraryID string) (uint64, error) {
+	return p.version, nil
+}
+
+func Test_Load(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "smartlists.json")
+	require.NoError(t, os.WriteFile(name, []byte(`{"playlists": [
+		{"title": "Techno", "rule": "genre contains techno"},
+		{"title": "Moods", "playlists": [{"title": "Chill", "rule": "bpm < 110"}]}
+	]}`), 0o644))
+	definitions, err := Load(name)
+	require.NoError(t, err)
+	require.Equal(t, []*Definition{
+		{Title: "Techno", Rule: "genre contains techno"},
+		{Title: "Moods", Playlists: []*Definition{{Title: "Chill", Rule: "bpm < 110"}}},
+	}, definitions)
+}
+
+func Test_Provider(t *testing.T) {
+	base := &testProvider{tracks: testTracks(), events: make(chan *server.Event)}
+	p, err := NewProvider(base, &ProviderConfiguration{
+		Playlists: []*Definition{
+			{Title: "Techno", Rule: "genre = techno"},
+			{Title: "Keys", Playlists: []*Definition{
+				{Title: "Around Am", Rule: "key compatible Am"},
+				{Title: "Around Am", Rule: "key compatible Am and bpm > 130"},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	playlists, err := p.Playlists(ctx, testLibraryID)
+	require.NoError(t, err)
+	require.Len(t, playlists, 3)
+	require.Equal(t, "crate", playlists[0].ID)
+	techno := playlists[1]
+	require.Equal(t, "Techno", techno.Title)
+	require.True(t, techno.Smart)
+	require.Equal(t, 1, techno.TrackCount)
+	keys := playlists[2]
+	require.False(t, keys.Smart)
+	require.Len(t, keys.Children, 2)
+	require.Equal(t, 2, keys.Children[0].TrackCount)
+	require.Equal(t, 1, keys.Children[1].TrackCount)
+	require.NotEqual(t, keys.Children[0].ID, keys.Children[1].ID)
+
+	tracks, err := p.Tracks(ctx, testLibraryID, keys.Children[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, base.tracks[:2], tracks)
+	folder, err := p.Tracks(ctx, testLibraryID, keys.ID)
+	require.NoError(t, err)
+	require.Empty(t, folder)
+	crate, err := p.Tracks(ctx, testLibraryID, "crate")
+	require.NoError(t, err)
+	require.Equal(t, base.tracks[:1], crate)
+	_, err = p.Tracks(ctx, testLibraryID, "missing")
+	require.ErrorIs(t, err, server.ErrNotFound)
+	_, err = p.Playlists(ctx, "missing")
+	require.ErrorIs(t, err, server.ErrNotFound)
+
+	// IDs are stable across restarts, the wrapped provider reporting
+	// changes or not
+	again, err := NewProvider(struct{ server.LibraryProvider }{&testProvider{tracks: testTracks()}}, &ProviderConfiguration{
+		Playlists: []*Definition{{Title: "Techno", Rule: "genre = techno"}},
+	})
+	require.NoError(t, err)
+	againPlaylists, err := again.Playlists(ctx, testLibraryID)
+	require.NoError(t, err)
+	require.Equal(t, techno.ID, againPlaylists[1].ID)
+	// without events from the wrapped provider there are none to pass on
+	_, ok := <-again.Events()
+	require.False(t, ok)
+
+	// the tracks version is the one of the wrapped provider, if any
+	_, err = p.TracksVersion(ctx, testLibraryID)
+	require.ErrorIs(t, err, server.ErrVersionUnknown)
+	versioned, err := NewProvider(&versionedProvider{testProvider: &testProvider{tracks: testTracks()}, version: 7}, nil)
+	require.NoError(t, err)
+	version, err := versioned.TracksVersion(ctx, testLibraryID)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), version)
+
+	// smart playlists whose tracks change are reported along with the change
+	go base.retag(1, "Techno")
+	select {
+	case event := <-p.Events():
+		require.Equal(t, []string{techno.ID}, event.PlaylistIDs)
+		require.Len(t, event.Tracks, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	tracks, err = p.Tracks(ctx, testLibraryID, techno.ID)
+	require.NoError(t, err)
+	require.Len(t, tracks, 2)
+
+	// others are passed on as is
+	go base.retag(2, "Ambient")
+	select {
+	case event := <-p.Events():
+		require.Empty(t, event.PlaylistIDs)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+
+	close(base.events)
+	_, ok = <-p.Events()
+	require.False(t, ok)
+}
+
+func Test_NewProvider_InvalidRule(t *testing.T) {
+	_, err := NewProvider(&testProvider{}, &ProviderConfiguration{
+		Playlists: []*Definition{{Title: "Folder", Playlists: []*Definition{
+			{Title: "Broken", Rule: "bpm between"},
+		}}},
+	})
+	var syntaxError *SyntaxError
+	require.ErrorAs(t, err, &syntaxError)
+	require.Contains(t, err.Error(), `"Broken"`)
+}
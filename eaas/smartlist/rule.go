@@ -0,0 +1,356 @@
+package smartlist
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/musickey"
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+// dateLayout is how dates are written in rules.
+const dateLayout = "2006-01-02"
+
+// now is replaced by tests.
+var now = time.Now
+
+// Rule picks the tracks of a smart playlist.
+type Rule interface {
+	// Match reports whether a track belongs in the playlist.
+	Match(track *server.Track) bool
+}
+
+// SyntaxError is returned by Parse for rules it can't parse.
+type SyntaxError struct {
+	// Offset is the byte offset in the rule the error was found at.
+	Offset int
+
+	Message string
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("offset %d: %s", err.Offset, err.Message)
+}
+
+// Parse parses a rule. See the package documentation for the rule language.
+func Parse(rule string) (Rule, error) {
+	tokens, err := lex(rule)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	r, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return r, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of rule"
+	}
+	return strconv.Quote(t.text)
+}
+
+// is reports whether the token is the given keyword or operator.
+func (t token) is(text string) bool {
+	return (t.kind == tokenWord || t.kind == tokenOperator) && strings.EqualFold(t.text, text)
+}
+
+func lex(s string) (tokens []token, err error) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenOpen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenClose, ")", i})
+			i++
+		case c == '"':
+			quoted, err := strconv.QuotedPrefix(s[i:])
+			if err != nil {
+				return nil, &SyntaxError{Offset: i, Message: "unterminated string"}
+			}
+			text, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, token{tokenString, text, i})
+			i += len(quoted)
+		case strings.ContainsRune("=!<>", rune(c)):
+			op := s[i : i+1]
+			if i+1 < len(s) && s[i+1] == '=' {
+				op = s[i : i+2]
+			}
+			switch op {
+			case "!":
+				return nil, &SyntaxError{Offset: i, Message: `unexpected "!"`}
+			case "==":
+				tokens = append(tokens, token{tokenOperator, "=", i})
+			default:
+				tokens = append(tokens, token{tokenOperator, op, i})
+			}
+			i += len(op)
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\r\n()\"=!<>", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenWord, s[start:i], start})
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(s)}), nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &SyntaxError{Offset: t.offset, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) or() (Rule, error) {
+	r, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	rules := anyRule{r}
+	for p.peek().is("or") {
+		p.next()
+		if r, err = p.and(); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	if len(rules) == 1 {
+		return rules[0], nil
+	}
+	return rules, nil
+}
+
+func (p *parser) and() (Rule, error) {
+	r, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	rules := allRule{r}
+	for p.peek().is("and") {
+		p.next()
+		if r, err = p.not(); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	if len(rules) == 1 {
+		return rules[0], nil
+	}
+	return rules, nil
+}
+
+func (p *parser) not() (Rule, error) {
+	t := p.peek()
+	switch {
+	case t.is("not"):
+		p.next()
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return notRule{r}, nil
+	case t.kind == tokenOpen:
+		p.next()
+		r, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenClose {
+			return nil, p.errorf(t, `expected ")" instead of %s`, t)
+		}
+		return r, nil
+	}
+	return p.condition()
+}
+
+// value reads a word or string.
+func (p *parser) value() (token, error) {
+	t := p.next()
+	if t.kind != tokenWord && t.kind != tokenString {
+		return t, p.errorf(t, "expected a value instead of %s", t)
+	}
+	return t, nil
+}
+
+func (p *parser) condition() (Rule, error) {
+	field := p.next()
+	if field.kind != tokenWord {
+		return nil, p.errorf(field, "expected a field instead of %s", field)
+	}
+	name := strings.ToLower(field.text)
+	if values, ok := textFields[name]; ok {
+		return p.textCondition(values)
+	}
+	if value, ok := numberFields[name]; ok {
+		return p.numberCondition(value)
+	}
+	switch name {
+	case "key":
+		return p.keyCondition()
+	case "added":
+		return p.dateCondition()
+	}
+	return nil, p.errorf(field, "unknown field %s", field)
+}
+
+func (p *parser) textCondition(values []func(*server.Track) string) (Rule, error) {
+	op := p.next()
+	if !op.is("=") && !op.is("!=") && !op.is("contains") {
+		return nil, p.errorf(op, `expected "=", "!=" or "contains" instead of %s`, op)
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	r := textRule{values: values, value: strings.ToLower(value.text), contains: op.is("contains")}
+	if op.is("!=") {
+		return notRule{r}, nil
+	}
+	return r, nil
+}
+
+func (p *parser) numberCondition(value func(*server.Track) (float64, bool)) (Rule, error) {
+	op := p.next()
+	if !op.is("between") && op.kind != tokenOperator {
+		return nil, p.errorf(op, `expected a comparison or "between" instead of %s`, op)
+	}
+	number := func() (float64, error) {
+		t, err := p.value()
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return 0, p.errorf(t, "expected a number instead of %s", t)
+		}
+		return n, nil
+	}
+	r := numberRule{value: value, op: strings.ToLower(op.text)}
+	var err error
+	if r.a, err = number(); err != nil {
+		return nil, err
+	}
+	if r.op == "between" {
+		if t := p.next(); !t.is("and") {
+			return nil, p.errorf(t, `expected "and" instead of %s`, t)
+		}
+		if r.b, err = number(); err != nil {
+			return nil, err
+		}
+		if r.a > r.b {
+			r.a, r.b = r.b, r.a
+		}
+	}
+	return r, nil
+}
+
+func (p *parser) keyCondition() (Rule, error) {
+	op := p.next()
+	if !op.is("=") && !op.is("!=") && !op.is("compatible") {
+		return nil, p.errorf(op, `expected "=", "!=" or "compatible" instead of %s`, op)
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	r := keyRule{name: value.text, compatible: op.is("compatible")}
+	k, err := musickey.Parse(value.text)
+	if err != nil && r.compatible {
+		return nil, p.errorf(value, "unknown key %s", value)
+	}
+	r.key, r.known = k, err == nil
+	if op.is("!=") {
+		return notRule{r}, nil
+	}
+	return r, nil
+}
+
+func (p *parser) dateCondition() (Rule, error) {
+	op := p.next()
+	if !op.is("between") && !op.is("within") && op.kind != tokenOperator {
+		return nil, p.errorf(op, `expected a comparison, "between" or "within" instead of %s`, op)
+	}
+	date := func() (time.Time, error) {
+		t, err := p.value()
+		if err != nil {
+			return time.Time{}, err
+		}
+		d, err := time.ParseInLocation(dateLayout, t.text, time.Local)
+		if err != nil {
+			return time.Time{}, p.errorf(t, "expected a date like 2006-01-02 instead of %s", t)
+		}
+		return d, nil
+	}
+	r := dateRule{op: strings.ToLower(op.text)}
+	var err error
+	if r.op == "within" {
+		t, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if r.within, err = parsePeriod(t.text); err != nil {
+			return nil, p.errorf(t, "expected a period like 30d instead of %s", t)
+		}
+		return r, nil
+	}
+	if r.a, err = date(); err != nil {
+		return nil, err
+	}
+	if r.op == "between" {
+		if t := p.next(); !t.is("and") {
+			return nil, p.errorf(t, `expected "and" instead of %s`, t)
+		}
+		if r.b, err = date(); err != nil {
+			return nil, err
+		}
+		if r.a.After(r.b) {
+			r.a, r.b = r.b, r.a
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,95 @@
+package smartlist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/server"
+	"github.com/stretchr/testify/require"
+)
+
+func testTracks() []*server.Track {
+	return []*server.Track{
+		{
+			ID: "1", Title: "Whiplash", Artist: "Icedream", Genre: "Techno", Comment: "Peak time",
+			Key: "Am", BPM: 140, Rating: 80, Year: 2021,
+			DateAdded: time.Date(2024, 3, 1, 20, 0, 0, 0, time.Local),
+		},
+		{
+			ID: "2", Title: "Deep Down", Artist: "Someone", Genre: "Tech House",
+			Key: "9A", BPM: 124, Rating: 60, Year: 1998,
+			DateAdded: time.Date(2024, 3, 10, 8, 0, 0, 0, time.Local),
+		},
+		{
+			ID: "3", Title: "Untagged", Key: "unknown",
+		},
+	}
+}
+
+func Test_Parse(t *testing.T) {
+	defer func(previous func() time.Time) { now = previous }(now)
+	now = func() time.Time { return time.Date(2024, 3, 12, 12, 0, 0, 0, time.Local) }
+
+	for rule, expected := range map[string][]string{
+		`bpm between 120 and 130`:                 {"2"},
+		`bpm between 145 and 135`:                 {"1"},
+		`bpm >= 124 and bpm<140`:                  {"2"},
+		`bpm != 140`:                              {"2"},
+		`genre = techno`:                          {"1"},
+		`genre != techno`:                         {"2", "3"},
+		`genre contains TECH`:                     {"1", "2"},
+		`title = "deep down"`:                     {"2"},
+		`text contains "peak time"`:               {"1"},
+		`key = 8A`:                                {"1"},
+		`key = "A minor"`:                         {"1"},
+		`key compatible Am`:                       {"1", "2"},
+		`key = UNKNOWN`:                           {"3"},
+		`rating >= 4`:                             {"1"},
+		`rating = 0`:                              {"3"},
+		`year < 2000`:                             {"2"},
+		`added = 2024-03-01`:                      {"1"},
+		`added > 2024-03-01`:                      {"2"},
+		`added between 2024-03-10 and 2024-02-01`: {"1", "2"},
+		`added within 7d`:                         {"2"},
+		`added within 2w`:                         {"1", "2"},
+		`genre = techno or genre = "tech house"`:  {"1", "2"},
+		`genre contains tech and bpm > 130 or year = 1998`:   {"1", "2"},
+		`genre contains tech and (bpm > 130 or year = 2021)`: {"1"},
+		`not (genre = techno or genre contains house)`:       {"3"},
+		`NOT bpm > 130 AND genre contains tech`:              {"2"},
+	} {
+		r, err := Parse(rule)
+		require.NoError(t, err, rule)
+		matched := []string{}
+		for _, track := range testTracks() {
+			if r.Match(track) {
+				matched = append(matched, track.ID)
+			}
+		}
+		require.Equal(t, expected, matched, rule)
+	}
+}
+
+func Test_Parse_Errors(t *testing.T) {
+	for rule, offset := range map[string]int{
+		``:                          0,
+		`bpm`:                       3,
+		`tempo > 120`:               0,
+		`bpm > fast`:                6,
+		`bpm between 120 or 130`:    16,
+		`genre > techno`:            6,
+		`genre = "techno`:           8,
+		`key compatible H`:          15,
+		`added > yesterday`:         8,
+		`added within ages`:         13,
+		`(bpm > 120`:                10,
+		`bpm > 120 genre = techno`:  10,
+		`bpm ! 120`:                 4,
+		`genre = techno and or bpm`: 19,
+	} {
+		_, err := Parse(rule)
+		var syntaxError *SyntaxError
+		require.ErrorAs(t, err, &syntaxError, rule)
+		require.Equal(t, offset, syntaxError.Offset, rule)
+	}
+}
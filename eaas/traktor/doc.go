@@ -0,0 +1,17 @@
+/*
+This package reads Traktor collections (collection.nml) and serves them as an
+EAAS library.
+
+	nml, err := traktor.Open("collection.nml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, entry := range nml.Entries {
+		fmt.Println(entry.Artist, "-", entry.Title, entry.Location.Key())
+	}
+
+Use NewProvider to serve the collection with an eaas/server.Server. Traktor
+stores locations as a volume name and a path on it, see
+ProviderConfiguration.Volumes for how they are turned into file paths.
+*/
+package traktor
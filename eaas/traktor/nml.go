@@ -0,0 +1,203 @@
+package traktor
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/cues"
+)
+
+// Types of playlist nodes.
+const (
+	NodeFolder   = "FOLDER"
+	NodePlaylist = "PLAYLIST"
+)
+
+// keyNames maps MUSICAL_KEY values to key names, major keys first.
+var keyNames = []string{
+	"C", "Db", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B",
+	"Cm", "C#m", "Dm", "D#m", "Em", "Fm", "F#m", "Gm", "G#m", "Am", "Bbm", "Bm",
+}
+
+// NML is a Traktor collection.
+type NML struct {
+	XMLName xml.Name `xml:"NML"`
+	Version int      `xml:"VERSION,attr"`
+	Entries []*Entry `xml:"COLLECTION>ENTRY"`
+
+	// Root is the root folder of the playlists, called "$ROOT".
+	Root *Node `xml:"PLAYLISTS>NODE"`
+}
+
+// Entry is a track of the collection.
+type Entry struct {
+	Title    string              `xml:"TITLE,attr"`
+	Artist   string              `xml:"ARTIST,attr"`
+	AudioID  string              `xml:"AUDIO_ID,attr"`
+	Location Location            `xml:"LOCATION"`
+	Album    Album               `xml:"ALBUM"`
+	Info     Info                `xml:"INFO"`
+	Tempo    Tempo               `xml:"TEMPO"`
+	Key      *MusicalKey         `xml:"MUSICAL_KEY"`
+	Cues     []cues.TraktorCueV2 `xml:"CUE_V2"`
+}
+
+// Location is where the audio file of an entry is.
+type Location struct {
+	// Dir is the folder on the volume, with each name preceded by "/:", like
+	// "/:Users/:dj/:Music/:".
+	Dir  string `xml:"DIR,attr"`
+	File string `xml:"FILE,attr"`
+
+	// Volume is the name of the volume, like "Macintosh HD" or "C:".
+	Volume   string `xml:"VOLUME,attr"`
+	VolumeID string `xml:"VOLUMEID,attr"`
+}
+
+// Album is the album of an entry.
+type Album struct {
+	Title string `xml:"TITLE,attr"`
+	Track int    `xml:"TRACK,attr"`
+}
+
+// Info holds the details of an entry.
+type Info struct {
+	Bitrate int    `xml:"BITRATE,attr"`
+	Genre   string `xml:"GENRE,attr"`
+	Label   string `xml:"LABEL,attr"`
+	Comment string `xml:"COMMENT,attr"`
+
+	// Key is the key in the notation chosen in Traktor.
+	Key       string `xml:"KEY,attr"`
+	PlayCount int    `xml:"PLAYCOUNT,attr"`
+
+	// Playtime is the length in seconds.
+	Playtime      int     `xml:"PLAYTIME,attr"`
+	PlaytimeFloat float64 `xml:"PLAYTIME_FLOAT,attr"`
+
+	// Ranking goes from 0 to 255 in steps of 51 per star.
+	Ranking int `xml:"RANKING,attr"`
+
+	// ImportDate and ReleaseDate are formatted like "2006/1/2".
+	ImportDate  string `xml:"IMPORT_DATE,attr"`
+	ReleaseDate string `xml:"RELEASE_DATE,attr"`
+
+	// FileSize is the size of the audio file in kilobytes.
+	FileSize int64  `xml:"FILESIZE,attr"`
+	Remixer  string `xml:"REMIXER,attr"`
+	Producer string `xml:"PRODUCER,attr"`
+	Mix      string `xml:"MIX,attr"`
+}
+
+// Tempo is the tempo of an entry.
+type Tempo struct {
+	BPM float64 `xml:"BPM,attr"`
+}
+
+// MusicalKey is the key of an entry, 0 to 11 for C to B major and 12 to 23
+// for C to B minor.
+type MusicalKey struct {
+	Value int `xml:"VALUE,attr"`
+}
+
+// Node is a folder or playlist.
+type Node struct {
+	Type string `xml:"TYPE,attr"`
+	Name string `xml:"NAME,attr"`
+
+	// Nodes are the children of a folder.
+	Nodes []*Node `xml:"SUBNODES>NODE"`
+
+	Playlist *Playlist `xml:"PLAYLIST"`
+}
+
+// Playlist holds the tracks of a playlist node.
+type Playlist struct {
+	UUID    string          `xml:"UUID,attr"`
+	Entries []PlaylistEntry `xml:"ENTRY"`
+}
+
+// PlaylistEntry is a track of a playlist.
+type PlaylistEntry struct {
+	PrimaryKey PrimaryKey `xml:"PRIMARYKEY"`
+}
+
+// PrimaryKey refers to an entry of the collection by its Location.Key.
+type PrimaryKey struct {
+	Type string `xml:"TYPE,attr"`
+	Key  string `xml:"KEY,attr"`
+}
+
+// Decode reads a Traktor collection.
+func Decode(r io.Reader) (*NML, error) {
+	nml := new(NML)
+	if err := xml.NewDecoder(r).Decode(nml); err != nil {
+		return nil, err
+	}
+	return nml, nil
+}
+
+// Open reads a Traktor collection from a file.
+func Open(name string) (*NML, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// Key returns the key playlists refer to the location by, like
+// "Macintosh HD/:Users/:dj/:Music/:track.mp3".
+func (l *Location) Key() string {
+	return l.Volume + l.Dir + l.File
+}
+
+// Path returns the path of the file on its volume with forward slashes,
+// like "/Users/dj/Music/track.mp3".
+func (l *Location) Path() string {
+	return strings.ReplaceAll(l.Dir, "/:", "/") + l.File
+}
+
+// KeyName returns the name of the key of the entry, or an empty string if it
+// is unknown.
+func (e *Entry) KeyName() string {
+	if e.Key != nil && e.Key.Value >= 0 && e.Key.Value < len(keyNames) {
+		return keyNames[e.Key.Value]
+	}
+	return e.Info.Key
+}
+
+// Length returns the length of the entry.
+func (e *Entry) Length() time.Duration {
+	if e.Info.PlaytimeFloat > 0 {
+		return time.Duration(e.Info.PlaytimeFloat * float64(time.Second))
+	}
+	return time.Duration(e.Info.Playtime) * time.Second
+}
+
+func parseDate(date string) time.Time {
+	t, _ := time.ParseInLocation("2006/1/2", strings.TrimSpace(date), time.Local)
+	return t
+}
+
+// Imported returns the date the entry was added to the collection, or the
+// zero time if unknown.
+func (e *Entry) Imported() time.Time {
+	return parseDate(e.Info.ImportDate)
+}
+
+// Year returns the year of the release date, or 0 if unknown.
+func (e *Entry) Year() int {
+	if released := parseDate(e.Info.ReleaseDate); !released.IsZero() {
+		return released.Year()
+	}
+	// release dates may only have the year
+	if year, err := time.Parse("2006", strings.TrimSpace(e.Info.ReleaseDate)); err == nil {
+		return year.Year()
+	}
+	return 0
+}
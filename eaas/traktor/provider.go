@@ -0,0 +1,310 @@
+package traktor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/icedream/go-stagelinq/eaas/cues"
+	"github.com/icedream/go-stagelinq/eaas/perfdata"
+	"github.com/icedream/go-stagelinq/eaas/server"
+)
+
+const (
+	defaultTitle = "Traktor"
+
+	// defaultSampleRate is assumed for audio files the sample rate can't be
+	// read from.
+	defaultSampleRate = 44100
+
+	// starRanking is the Traktor ranking of a star, 20 in Engine DJ.
+	starRanking = 51
+)
+
+// ProviderConfiguration contains configurable values for a Provider.
+type ProviderConfiguration struct {
+	// Title is the name of the library shown on devices. Defaults to
+	// "Traktor".
+	Title string
+
+	// Volumes maps volume names to the folders they are found at, for
+	// example "Macintosh HD" to "/" or "USB" to "/media/usb". Volumes not
+	// listed are looked for as drive letters ("C:") on Windows and in
+	// /Volumes otherwise, which is where macOS mounts them.
+	Volumes map[string]string
+}
+
+var _ server.LibraryProvider = &Provider{}
+var _ server.VersionProvider = &Provider{}
+
+// Provider serves a Traktor collection as a single library.
+//
+// Track IDs are derived from the locations of the tracks, playlist IDs are
+// the UUIDs of the playlists and folder IDs as well as the library ID are
+// derived from folder names and the path of the collection. They stay the
+// same as long as files and folders are not moved or renamed.
+type Provider struct {
+	config  ProviderConfiguration
+	library *server.Library
+
+	tracks  []*server.Track
+	byID    map[string]*server.Track
+	byURL   map[string]*server.Track
+	sources map[string]*Entry
+
+	playlists      []*server.Playlist
+	playlistTracks map[string][]*server.Track
+
+	sampleRateLock sync.Mutex
+	sampleRates    map[string]float64
+}
+
+// NewProvider reads the Traktor collection at the given path for serving.
+// The collection is read only once, create a new Provider to pick up
+// changes.
+func NewProvider(name string, config *ProviderConfiguration) (p *Provider, err error) {
+	if config == nil {
+		config = new(ProviderConfiguration)
+	}
+	c := *config
+	if c.Title == "" {
+		c.Title = defaultTitle
+	}
+
+	if name, err = filepath.Abs(name); err != nil {
+		return
+	}
+	nml, err := Open(name)
+	if err != nil {
+		return
+	}
+	return newProvider(nml, uuid.NewSHA1(uuid.NameSpaceURL, []byte("file://"+filepath.ToSlash(name))), c), nil
+}
+
+func newProvider(nml *NML, namespace uuid.UUID, config ProviderConfiguration) *Provider {
+	p := &Provider{
+		config: config,
+		library: &server.Library{
+			ID:    namespace.String(),
+			Title: config.Title,
+		},
+		byID:           map[string]*server.Track{},
+		byURL:          map[string]*server.Track{},
+		sources:        map[string]*Entry{},
+		playlistTracks: map[string][]*server.Track{},
+		sampleRates:    map[string]float64{},
+	}
+	byKey := map[string]*server.Track{}
+	for _, entry := range nml.Entries {
+		key := entry.Location.Key()
+		if entry.Location.File == "" || byKey[key] != nil {
+			continue
+		}
+		track := p.toTrack(uuid.NewSHA1(namespace, []byte("track:"+key)).String(), entry)
+		p.tracks = append(p.tracks, track)
+		p.byID[track.ID] = track
+		p.byURL[track.URL] = track
+		p.sources[track.ID] = entry
+		byKey[key] = track
+	}
+
+	var walk func(node *Node, key string) *server.Playlist
+	walk = func(node *Node, key string) *server.Playlist {
+		playlist := &server.Playlist{
+			ID:    uuid.NewSHA1(namespace, []byte("playlist:"+key)).String(),
+			Title: node.Name,
+		}
+		if node.Type != NodePlaylist {
+			playlist.Children = make([]*server.Playlist, 0, len(node.Nodes))
+			seen := map[string]int{}
+			for _, child := range node.Nodes {
+				childKey := key + "/" + child.Name
+				// folders of the same name are told apart by their order
+				if seen[child.Name]++; seen[child.Name] > 1 {
+					childKey += fmt.Sprintf("#%d", seen[child.Name])
+				}
+				playlist.Children = append(playlist.Children, walk(child, childKey))
+			}
+			return playlist
+		}
+		var tracks []*server.Track
+		if node.Playlist != nil {
+			if node.Playlist.UUID != "" {
+				playlist.ID = node.Playlist.UUID
+			}
+			tracks = make([]*server.Track, 0, len(node.Playlist.Entries))
+			for _, entry := range node.Playlist.Entries {
+				if track, ok := byKey[entry.PrimaryKey.Key]; ok {
+					tracks = append(tracks, track)
+				}
+			}
+		}
+		playlist.TrackCount = len(tracks)
+		p.playlistTracks[playlist.ID] = tracks
+		return playlist
+	}
+	if nml.Root != nil {
+		// the root folder itself is not shown
+		p.playlists = walk(nml.Root, "").Children
+	}
+	return p
+}
+
+// path returns the file path of a location with forward slashes.
+func (p *Provider) path(l *Location) string {
+	if dir, ok := p.config.Volumes[l.Volume]; ok {
+		return strings.TrimSuffix(filepath.ToSlash(dir), "/") + l.Path()
+	}
+	if len(l.Volume) == 2 && l.Volume[1] == ':' {
+		return l.Volume + l.Path()
+	}
+	return "/Volumes/" + l.Volume + l.Path()
+}
+
+func (p *Provider) toTrack(id string, entry *Entry) *server.Track {
+	return &server.Track{
+		ID:        id,
+		Title:     entry.Title,
+		Artist:    entry.Artist,
+		Album:     entry.Album.Title,
+		Genre:     entry.Info.Genre,
+		Comment:   entry.Info.Comment,
+		Label:     entry.Info.Label,
+		Composer:  entry.Info.Producer,
+		Remixer:   entry.Info.Remixer,
+		Key:       entry.KeyName(),
+		BPM:       entry.Tempo.BPM,
+		Rating:    entry.Info.Ranking / starRanking * 20,
+		Year:      entry.Year(),
+		Length:    entry.Length(),
+		DateAdded: entry.Imported(),
+		URL:       p.path(&entry.Location),
+		FileSize:  entry.Info.FileSize * 1024,
+	}
+}
+
+// Libraries implements server.LibraryProvider.
+func (p *Provider) Libraries(ctx context.Context) ([]*server.Library, error) {
+	return []*server.Library{p.library}, nil
+}
+
+// Playlists implements server.LibraryProvider.
+func (p *Provider) Playlists(ctx context.Context, libraryID string) ([]*server.Playlist, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	return p.playlists, nil
+}
+
+// TracksVersion implements server.VersionProvider. The library is read once,
+// so the version never changes.
+func (p *Provider) TracksVersion(ctx context.Context, libraryID string) (uint64, error) {
+	if libraryID != p.library.ID {
+		return 0, server.ErrNotFound
+	}
+	return 0, nil
+}
+
+// Tracks implements server.LibraryProvider. Folders have no tracks of their
+// own.
+func (p *Provider) Tracks(ctx context.Context, libraryID, playlistID string) ([]*server.Track, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	if playlistID == "" {
+		return p.tracks, nil
+	}
+	if tracks, ok := p.playlistTracks[playlistID]; ok {
+		return tracks, nil
+	}
+	if findPlaylist(p.playlists, playlistID) != nil {
+		return nil, nil
+	}
+	return nil, server.ErrNotFound
+}
+
+func findPlaylist(playlists []*server.Playlist, id string) *server.Playlist {
+	for _, playlist := range playlists {
+		if playlist.ID == id {
+			return playlist
+		}
+		if found := findPlaylist(playlist.Children, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Track implements server.LibraryProvider.
+func (p *Provider) Track(ctx context.Context, libraryID, trackID string) (*server.Track, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	track, ok := p.byID[trackID]
+	if !ok {
+		return nil, server.ErrNotFound
+	}
+	return track, nil
+}
+
+// sampleRate returns the sample rate of an audio file, which Traktor does
+// not store, reading it only once.
+func (p *Provider) sampleRate(url string) float64 {
+	p.sampleRateLock.Lock()
+	defer p.sampleRateLock.Unlock()
+	if sampleRate, ok := p.sampleRates[url]; ok {
+		return sampleRate
+	}
+	sampleRate := float64(defaultSampleRate)
+	if f, err := os.Open(filepath.FromSlash(url)); err == nil {
+		if audio, err := perfdata.OpenAudio(f, url); err == nil && audio.SampleRate() > 0 {
+			sampleRate = audio.SampleRate()
+		}
+		f.Close()
+	}
+	p.sampleRates[url] = sampleRate
+	return sampleRate
+}
+
+// PerformanceData implements server.LibraryProvider. The beat grid starts at
+// the grid marker of the track, and hot cues, the load marker and loops are
+// converted from the cues.
+func (p *Provider) PerformanceData(ctx context.Context, libraryID, trackID string) (*server.PerformanceData, error) {
+	if libraryID != p.library.ID {
+		return nil, server.ErrNotFound
+	}
+	entry, ok := p.sources[trackID]
+	if !ok {
+		return nil, server.ErrNotFound
+	}
+	sampleRate := p.sampleRate(p.byID[trackID].URL)
+
+	data := &server.PerformanceData{BPM: entry.Tempo.BPM}
+	for _, cue := range entry.Cues {
+		if cue.Type != cues.TraktorGrid {
+			continue
+		}
+		length := entry.Length().Seconds()
+		grid, err := perfdata.ConstantBeatGrid(entry.Tempo.BPM, cue.Start/1000*sampleRate, sampleRate, length*sampleRate)
+		if err == nil {
+			data.BeatGrid = grid.Encode()
+		}
+		break
+	}
+	cues.FromTraktor(entry.Cues, sampleRate).Apply(data)
+	return data, nil
+}
+
+// OpenBlob implements server.LibraryProvider. Only the audio files of tracks
+// in the collection can be opened.
+func (p *Provider) OpenBlob(ctx context.Context, url string) (io.ReadSeekCloser, error) {
+	if _, ok := p.byURL[url]; !ok {
+		return nil, server.ErrNotFound
+	}
+	return os.Open(filepath.FromSlash(url))
+}
@@ -0,0 +1,168 @@
+package traktor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icedream/go-stagelinq/eaas/perfdata"
+	"github.com/icedream/go-stagelinq/eaas/server"
+	"github.com/stretchr/testify/require"
+)
+
+const testCollection = `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<NML VERSION="19"><HEAD COMPANY="www.native-instruments.com" PROGRAM="Traktor"></HEAD>
+<MUSICFOLDERS></MUSICFOLDERS>
+<COLLECTION ENTRIES="3">
+<ENTRY MODIFIED_DATE="2021/3/1" MODIFIED_TIME="3600" TITLE="Whiplash" ARTIST="Icedream">
+<LOCATION DIR="/:Music/:Techno/:" FILE="whiplash.wav" VOLUME="Data" VOLUMEID="abc"></LOCATION>
+<ALBUM TRACK="1" TITLE="Singles"></ALBUM>
+<INFO BITRATE="1536000" GENRE="Techno" LABEL="Self" COMMENT="Peak time" KEY="8A" PLAYCOUNT="3" PLAYTIME="300" PLAYTIME_FLOAT="300.000000" RANKING="153" IMPORT_DATE="2021/3/1" RELEASE_DATE="2021/1/1" FILESIZE="1024"></INFO>
+<TEMPO BPM="140.000000" BPM_QUALITY="100.000000"></TEMPO>
+<MUSICAL_KEY VALUE="21"></MUSICAL_KEY>
+<CUE_V2 NAME="AutoGrid" DISPL_ORDER="0" TYPE="4" START="250.000000" LEN="0.000000" REPEATS="-1" HOTCUE="0"></CUE_V2>
+<CUE_V2 NAME="Drop" DISPL_ORDER="0" TYPE="0" START="60000.000000" LEN="0.000000" REPEATS="-1" HOTCUE="1"></CUE_V2>
+<CUE_V2 NAME="n.n." DISPL_ORDER="0" TYPE="3" START="250.000000" LEN="0.000000" REPEATS="-1" HOTCUE="-1"></CUE_V2>
+</ENTRY>
+<ENTRY TITLE="Second" ARTIST="Someone">
+<LOCATION DIR="/:Music/:" FILE="second.mp3" VOLUME="C:" VOLUMEID="def"></LOCATION>
+<INFO KEY="Gm" RELEASE_DATE="1999"></INFO>
+<TEMPO BPM="128.000000"></TEMPO>
+</ENTRY>
+<ENTRY TITLE="Duplicate">
+<LOCATION DIR="/:Music/:" FILE="second.mp3" VOLUME="C:" VOLUMEID="def"></LOCATION>
+</ENTRY>
+</COLLECTION>
+<PLAYLISTS><NODE TYPE="FOLDER" NAME="$ROOT"><SUBNODES COUNT="2">
+<NODE TYPE="FOLDER" NAME="Gigs"><SUBNODES COUNT="1">
+<NODE TYPE="PLAYLIST" NAME="Friday"><PLAYLIST ENTRIES="3" TYPE="LIST" UUID="4f1c3a5e0b7d4d6c9e2f1a3b5c7d9e0f">
+<ENTRY><PRIMARYKEY TYPE="TRACK" KEY="C:/:Music/:second.mp3"></PRIMARYKEY></ENTRY>
+<ENTRY><PRIMARYKEY TYPE="TRACK" KEY="Data/:Music/:Techno/:whiplash.wav"></PRIMARYKEY></ENTRY>
+<ENTRY><PRIMARYKEY TYPE="TRACK" KEY="Data/:Music/:missing.mp3"></PRIMARYKEY></ENTRY>
+</PLAYLIST></NODE>
+</SUBNODES></NODE>
+<NODE TYPE="PLAYLIST" NAME="Preparation"><PLAYLIST ENTRIES="0" TYPE="LIST" UUID="0a1b2c3d4e5f60718293a4b5c6d7e8f9"></PLAYLIST></NODE>
+</SUBNODES></NODE></PLAYLISTS>
+</NML>
+`
+
+// silentWAV returns a mono 16-bit WAV file with a few samples of silence.
+func silentWAV(sampleRate uint32) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(36+8))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(buf, binary.LittleEndian, []uint32{16})
+	_ = binary.Write(buf, binary.LittleEndian, []uint16{1, 1})
+	_ = binary.Write(buf, binary.LittleEndian, []uint32{sampleRate, 2 * sampleRate})
+	_ = binary.Write(buf, binary.LittleEndian, []uint16{2, 16})
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(8))
+	buf.Write(make([]byte, 8))
+	return buf.Bytes()
+}
+
+func Test_Provider(t *testing.T) {
+	dir := t.TempDir()
+	audio := filepath.Join(dir, "Music", "Techno", "whiplash.wav")
+	require.NoError(t, os.MkdirAll(filepath.Dir(audio), 0o755))
+	require.NoError(t, os.WriteFile(audio, silentWAV(48000), 0o644))
+	collection := filepath.Join(dir, "collection.nml")
+	require.NoError(t, os.WriteFile(collection, []byte(testCollection), 0o644))
+
+	p, err := NewProvider(collection, &ProviderConfiguration{
+		Volumes: map[string]string{"Data": dir + string(filepath.Separator)},
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	libraries, err := p.Libraries(ctx)
+	require.NoError(t, err)
+	require.Len(t, libraries, 1)
+	require.Equal(t, "Traktor", libraries[0].Title)
+	libraryID := libraries[0].ID
+
+	tracks, err := p.Tracks(ctx, libraryID, "")
+	require.NoError(t, err)
+	require.Len(t, tracks, 2)
+	track := tracks[0]
+	require.Equal(t, "Whiplash", track.Title)
+	require.Equal(t, "Icedream", track.Artist)
+	require.Equal(t, "Singles", track.Album)
+	require.Equal(t, "Am", track.Key)
+	require.Equal(t, 140.0, track.BPM)
+	require.Equal(t, 60, track.Rating)
+	require.Equal(t, 2021, track.Year)
+	require.Equal(t, 5*time.Minute, track.Length)
+	require.Equal(t, int64(1024*1024), track.FileSize)
+	require.Equal(t, time.Date(2021, 3, 1, 0, 0, 0, 0, time.Local), track.DateAdded)
+	require.Equal(t, filepath.ToSlash(audio), track.URL)
+	require.Equal(t, "C:/Music/second.mp3", tracks[1].URL)
+	require.Equal(t, "Gm", tracks[1].Key)
+	require.Equal(t, 1999, tracks[1].Year)
+
+	playlists, err := p.Playlists(ctx, libraryID)
+	require.NoError(t, err)
+	require.Len(t, playlists, 2)
+	require.Equal(t, "Gigs", playlists[0].Title)
+	require.Len(t, playlists[0].Children, 1)
+	friday := playlists[0].Children[0]
+	require.Equal(t, "4f1c3a5e0b7d4d6c9e2f1a3b5c7d9e0f", friday.ID)
+	require.Equal(t, 2, friday.TrackCount)
+	require.Equal(t, "Preparation", playlists[1].Title)
+
+	fridayTracks, err := p.Tracks(ctx, libraryID, friday.ID)
+	require.NoError(t, err)
+	require.Equal(t, []*server.Track{tracks[1], tracks[0]}, fridayTracks)
+	folder, err := p.Tracks(ctx, libraryID, playlists[0].ID)
+	require.NoError(t, err)
+	require.Empty(t, folder)
+	_, err = p.Tracks(ctx, libraryID, "missing")
+	require.ErrorIs(t, err, server.ErrNotFound)
+
+	// IDs are stable across restarts
+	again, err := NewProvider(collection, nil)
+	require.NoError(t, err)
+	againTracks, err := again.Tracks(ctx, libraryID, "")
+	require.NoError(t, err)
+	require.Equal(t, track.ID, againTracks[0].ID)
+	// volumes not configured are expected where macOS mounts them
+	require.True(t, strings.HasPrefix(againTracks[0].URL, "/Volumes/Data/Music/"))
+
+	found, err := p.Track(ctx, libraryID, track.ID)
+	require.NoError(t, err)
+	require.Same(t, track, found)
+
+	// the sample rate is read from the file
+	data, err := p.PerformanceData(ctx, libraryID, track.ID)
+	require.NoError(t, err)
+	require.Equal(t, 140.0, data.BPM)
+	grid, err := perfdata.DecodeBeatGrid(data.BeatGrid)
+	require.NoError(t, err)
+	require.Equal(t, 48000.0, grid.SampleRate)
+	require.InDelta(t, 0, grid.Beat(0.25*48000), 1e-9)
+	require.InDelta(t, 0.25*48000, data.MainCue.Position, 1e-6)
+	require.Equal(t, "Drop", data.QuickCues[1].Name)
+	require.InDelta(t, 60*48000, data.QuickCues[1].Position, 1e-6)
+
+	// tracks without a grid marker have no beat grid
+	data, err = p.PerformanceData(ctx, libraryID, tracks[1].ID)
+	require.NoError(t, err)
+	require.Nil(t, data.BeatGrid)
+	require.Nil(t, data.MainCue)
+
+	blob, err := p.OpenBlob(ctx, track.URL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(blob)
+	blob.Close()
+	require.NoError(t, err)
+	require.Equal(t, []byte("RIFF"), b[:4])
+	_, err = p.OpenBlob(ctx, filepath.ToSlash(collection))
+	require.ErrorIs(t, err, server.ErrNotFound)
+}
@@ -0,0 +1,9 @@
+/*
+This package keeps a history of the tracks played on StagelinQ devices and
+turns it into setlists: plain text, CSV, JSON, cue sheets aligned to a
+recording and Mixcloud-style timestamps.
+
+A Tracker watches the logical decks of a stagelinq.Aggregator and reports a
+track once it has been audible for a while, which a Setlist collects.
+*/
+package history
@@ -0,0 +1,242 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnknownFormat is returned when an unsupported setlist format is
+// requested.
+var ErrUnknownFormat = errors.New("unknown setlist format")
+
+// Format is a setlist output format.
+type Format string
+
+const (
+	// FormatText lists the tracks with the time they started playing.
+	FormatText Format = "text"
+
+	// FormatCSV writes one row per track with all known details.
+	FormatCSV Format = "csv"
+
+	// FormatJSON writes the setlist as JSON, which ReadSetlist reads back.
+	FormatJSON Format = "json"
+
+	// FormatCue writes a cue sheet for a recording of the set.
+	FormatCue Format = "cue"
+
+	// FormatMixcloud lists the tracks with their offset into a recording of
+	// the set, as accepted by Mixcloud's tracklist editor.
+	FormatMixcloud Format = "mixcloud"
+)
+
+// Formats lists all supported formats.
+var Formats = []Format{FormatText, FormatCSV, FormatJSON, FormatCue, FormatMixcloud}
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if strings.EqualFold(name, string(format)) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+}
+
+// Extension returns the file name extension commonly used for the format.
+func (f Format) Extension() string {
+	switch f {
+	case FormatText:
+		return ".txt"
+	case FormatCSV:
+		return ".csv"
+	case FormatJSON:
+		return ".json"
+	case FormatCue:
+		return ".cue"
+	case FormatMixcloud:
+		return ".mixcloud.txt"
+	}
+	return ""
+}
+
+// WriteOptions contains options for writing setlists.
+type WriteOptions struct {
+	// RecordingStart is when the recording of the set started. Offsets in
+	// cue sheets and Mixcloud timestamps are relative to it. Defaults to the
+	// start of the setlist.
+	RecordingStart time.Time
+
+	// AudioFile is the recording referenced by cue sheets. Defaults to
+	// "recording.wav".
+	AudioFile string
+
+	// Performer and Title describe the whole set in cue sheets.
+	Performer string
+	Title     string
+}
+
+// Write writes the setlist in the given format.
+func (s *Setlist) Write(w io.Writer, format Format, opts *WriteOptions) error {
+	if opts == nil {
+		opts = new(WriteOptions)
+	}
+	switch format {
+	case FormatText:
+		return s.WriteText(w)
+	case FormatCSV:
+		return s.WriteCSV(w)
+	case FormatJSON:
+		return s.WriteJSON(w)
+	case FormatCue:
+		return s.WriteCue(w, opts)
+	case FormatMixcloud:
+		return s.WriteMixcloud(w, opts.RecordingStart)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// WriteText writes the setlist as numbered lines with the wall clock time
+// each track started.
+func (s *Setlist) WriteText(w io.Writer) (err error) {
+	for i, entry := range s.Entries {
+		if _, err = fmt.Fprintf(w, "%2d. %s  %s\n", i+1, entry.Time.Format("15:04:05"), entry); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// WriteCSV writes the setlist as CSV with a header row.
+func (s *Setlist) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"index", "time", "offset", "deck", "device", "artist", "title", "bpm", "path"})
+	for i, entry := range s.Entries {
+		bpm := ""
+		if entry.BPM > 0 {
+			bpm = strconv.FormatFloat(entry.BPM, 'f', 2, 64)
+		}
+		_ = cw.Write([]string{
+			strconv.Itoa(i + 1),
+			entry.Time.Format(time.RFC3339),
+			formatOffset(entry.Time.Sub(s.Start)),
+			strconv.Itoa(entry.Deck),
+			entry.Device,
+			entry.Artist,
+			entry.Title,
+			bpm,
+			entry.Path,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the setlist as indented JSON.
+func (s *Setlist) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// WriteCue writes a cue sheet for a recording of the set started at
+// opts.RecordingStart.
+func (s *Setlist) WriteCue(w io.Writer, opts *WriteOptions) (err error) {
+	audioFile := opts.AudioFile
+	if audioFile == "" {
+		audioFile = "recording.wav"
+	}
+	fileType := "WAVE"
+	switch strings.ToLower(filepath.Ext(audioFile)) {
+	case ".mp3":
+		fileType = "MP3"
+	case ".aif", ".aiff":
+		fileType = "AIFF"
+	}
+
+	b := new(strings.Builder)
+	if opts.Performer != "" {
+		fmt.Fprintf(b, "PERFORMER %s\n", cueString(opts.Performer))
+	}
+	if opts.Title != "" {
+		fmt.Fprintf(b, "TITLE %s\n", cueString(opts.Title))
+	}
+	fmt.Fprintf(b, "FILE %s %s\n", cueString(audioFile), fileType)
+	for i, track := range s.aligned(opts.RecordingStart) {
+		fmt.Fprintf(b, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(b, "    TITLE %s\n", cueString(track.entry.Title))
+		if track.entry.Artist != "" {
+			fmt.Fprintf(b, "    PERFORMER %s\n", cueString(track.entry.Artist))
+		}
+		fmt.Fprintf(b, "    INDEX 01 %s\n", formatCueTime(track.offset))
+	}
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+// WriteMixcloud writes one line per track with its offset into a recording
+// of the set started at recordingStart, which defaults to the start of the
+// setlist.
+func (s *Setlist) WriteMixcloud(w io.Writer, recordingStart time.Time) (err error) {
+	for _, track := range s.aligned(recordingStart) {
+		if _, err = fmt.Fprintf(w, "%s %s\n", formatOffset(track.offset), track.entry); err != nil {
+			return
+		}
+	}
+	return
+}
+
+type alignedEntry struct {
+	entry  *Entry
+	offset time.Duration
+}
+
+// aligned returns the entries audible in a recording started at the given
+// time. Of the tracks started before the recording only the last one is kept,
+// at the very start of the recording.
+func (s *Setlist) aligned(start time.Time) (tracks []alignedEntry) {
+	if start.IsZero() {
+		start = s.Start
+	}
+	for _, entry := range s.Entries {
+		offset := entry.Time.Sub(start)
+		if offset < 0 {
+			offset = 0
+			if len(tracks) > 0 && tracks[len(tracks)-1].offset == 0 {
+				tracks = tracks[:len(tracks)-1]
+			}
+		}
+		tracks = append(tracks, alignedEntry{entry: entry, offset: offset})
+	}
+	return
+}
+
+// formatOffset formats an offset as [h:]mm:ss.
+func formatOffset(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	seconds := int64(d / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+// formatCueTime formats an offset as mm:ss:ff with 75 frames per second as
+// used by cue sheets.
+func formatCueTime(d time.Duration) string {
+	frames := int64(d) * 75 / int64(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", frames/75/60, frames/75%60, frames%75)
+}
+
+func cueString(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
@@ -0,0 +1,135 @@
+package history
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testSetlist() *Setlist {
+	start := time.Date(2024, 5, 4, 22, 0, 0, 0, time.UTC)
+	s := NewSetlist(start)
+	s.Add(&Entry{Time: start.Add(-2 * time.Minute), Deck: 1, Artist: "Warmup", Title: "Track"})
+	s.Add(&Entry{Time: start.Add(-time.Minute), Deck: 2, Artist: "Icedream", Title: "Whiplash (Radio Edit)", BPM: 140})
+	s.Add(&Entry{Time: start.Add(3*time.Minute + 30*time.Second + 500*time.Millisecond), Deck: 1, Title: `The "Quoted" One`})
+	s.Add(&Entry{Time: start.Add(62 * time.Minute), Deck: 2, Artist: "Artist", Title: "Late"})
+	return s
+}
+
+func Test_Setlist_Add(t *testing.T) {
+	s := testSetlist()
+	require.False(t, s.Add(&Entry{Deck: 1, Artist: "Artist", Title: "Late"}))
+	require.True(t, s.Add(&Entry{Deck: 1, Artist: "Artist", Title: "Later"}))
+	require.Len(t, s.Entries, 5)
+}
+
+func Test_ParseFormat(t *testing.T) {
+	for _, format := range Formats {
+		parsed, err := ParseFormat(string(format))
+		require.NoError(t, err)
+		require.Equal(t, format, parsed)
+		require.NotEmpty(t, format.Extension())
+	}
+	_, err := ParseFormat("xml")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func Test_Setlist_Write(t *testing.T) {
+	s := testSetlist()
+	recordingStart := s.Start
+
+	tests := []struct {
+		format   Format
+		opts     *WriteOptions
+		expected string
+	}{
+		{
+			format: FormatText,
+			expected: " 1. 21:58:00  Warmup - Track\n" +
+				" 2. 21:59:00  Icedream - Whiplash (Radio Edit)\n" +
+				" 3. 22:03:30  The \"Quoted\" One\n" +
+				" 4. 23:02:00  Artist - Late\n",
+		},
+		{
+			format: FormatCSV,
+			expected: "index,time,offset,deck,device,artist,title,bpm,path\n" +
+				"1,2024-05-04T21:58:00Z,00:00,1,,Warmup,Track,,\n" +
+				"2,2024-05-04T21:59:00Z,00:00,2,,Icedream,Whiplash (Radio Edit),140.00,\n" +
+				"3,2024-05-04T22:03:30Z,03:30,1,,,\"The \"\"Quoted\"\" One\",,\n" +
+				"4,2024-05-04T23:02:00Z,1:02:00,2,,Artist,Late,,\n",
+		},
+		{
+			format: FormatMixcloud,
+			opts:   &WriteOptions{RecordingStart: recordingStart},
+			expected: "00:00 Icedream - Whiplash (Radio Edit)\n" +
+				"03:30 The \"Quoted\" One\n" +
+				"1:02:00 Artist - Late\n",
+		},
+		{
+			format: FormatCue,
+			opts: &WriteOptions{
+				RecordingStart: recordingStart,
+				AudioFile:      "set.mp3",
+				Performer:      "Icedream",
+				Title:          "Live",
+			},
+			expected: "PERFORMER \"Icedream\"\n" +
+				"TITLE \"Live\"\n" +
+				"FILE \"set.mp3\" MP3\n" +
+				"  TRACK 01 AUDIO\n" +
+				"    TITLE \"Whiplash (Radio Edit)\"\n" +
+				"    PERFORMER \"Icedream\"\n" +
+				"    INDEX 01 00:00:00\n" +
+				"  TRACK 02 AUDIO\n" +
+				"    TITLE \"The 'Quoted' One\"\n" +
+				"    INDEX 01 03:30:37\n" +
+				"  TRACK 03 AUDIO\n" +
+				"    TITLE \"Late\"\n" +
+				"    PERFORMER \"Artist\"\n" +
+				"    INDEX 01 62:00:00\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(string(test.format), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, s.Write(buf, test.format, test.opts))
+			require.Equal(t, test.expected, buf.String())
+		})
+	}
+
+	require.ErrorIs(t, s.Write(new(bytes.Buffer), "xml", nil), ErrUnknownFormat)
+}
+
+func Test_Setlist_Resume(t *testing.T) {
+	s := testSetlist()
+	name := filepath.Join(t.TempDir(), "setlist.json")
+	require.NoError(t, s.WriteFile(name, FormatJSON, nil))
+	fi, err := os.Stat(name)
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0o644), fi.Mode().Perm())
+
+	// replacing the file keeps its permissions
+	require.NoError(t, os.Chmod(name, 0o640))
+	require.NoError(t, s.WriteFile(name, FormatJSON, nil))
+	fi, err = os.Stat(name)
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0o640), fi.Mode().Perm())
+
+	loaded, err := LoadSetlist(name)
+	require.NoError(t, err)
+	require.True(t, s.Start.Equal(loaded.Start))
+	require.Len(t, loaded.Entries, len(s.Entries))
+	require.False(t, loaded.Add(&Entry{Deck: 2, Artist: "Artist", Title: "Late"}))
+
+	_, err = LoadSetlist(filepath.Join(t.TempDir(), "missing.json"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(name), ".*"))
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
@@ -0,0 +1,135 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry is a track that has been played.
+type Entry struct {
+	// Time is when the track became audible.
+	Time time.Time `json:"time"`
+
+	// Deck is the logical deck the track was played on.
+	Deck int `json:"deck"`
+
+	// Device is the name of the device the track was played on.
+	Device string `json:"device,omitempty"`
+
+	Artist string  `json:"artist"`
+	Title  string  `json:"title"`
+	BPM    float64 `json:"bpm,omitempty"`
+
+	// Path is the network path of the track as reported by the device.
+	Path string `json:"path,omitempty"`
+}
+
+// String formats the entry as "Artist - Title".
+func (e *Entry) String() string {
+	switch {
+	case e.Artist == "":
+		return e.Title
+	case e.Title == "":
+		return e.Artist
+	}
+	return e.Artist + " - " + e.Title
+}
+
+func (e *Entry) sameTrack(other *Entry) bool {
+	return e.Artist == other.Artist && e.Title == other.Title && e.Path == other.Path
+}
+
+// Setlist is the list of tracks played in a set, in the order they were
+// played.
+type Setlist struct {
+	// Start is when the set started.
+	Start time.Time `json:"start"`
+
+	Entries []*Entry `json:"entries"`
+}
+
+// NewSetlist creates an empty setlist starting at the given time.
+func NewSetlist(start time.Time) *Setlist {
+	return &Setlist{
+		Start:   start,
+		Entries: []*Entry{},
+	}
+}
+
+// Add appends an entry to the setlist. Entries repeating the track of the
+// last entry are ignored, which happens when a set is resumed while a track
+// plays, and false is returned for them.
+func (s *Setlist) Add(entry *Entry) bool {
+	if len(s.Entries) > 0 && s.Entries[len(s.Entries)-1].sameTrack(entry) {
+		return false
+	}
+	s.Entries = append(s.Entries, entry)
+	return true
+}
+
+// ReadSetlist reads a setlist as written in FormatJSON.
+func ReadSetlist(r io.Reader) (s *Setlist, err error) {
+	s = new(Setlist)
+	if err = json.NewDecoder(r).Decode(s); err != nil {
+		s = nil
+		return
+	}
+	if s.Entries == nil {
+		s.Entries = []*Entry{}
+	}
+	return
+}
+
+// LoadSetlist reads a setlist from a file written in FormatJSON, for example
+// to resume a set after a restart. It returns an error satisfying
+// errors.Is(err, fs.ErrNotExist) if there is no such file.
+func LoadSetlist(name string) (s *Setlist, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return ReadSetlist(f)
+}
+
+// WriteFile writes the setlist to the named file in the given format. The
+// file is replaced atomically so readers never see a partial setlist. It keeps
+// the permissions of the file it replaces, new files are readable by everyone.
+func (s *Setlist) WriteFile(name string, format Format, opts *WriteOptions) (err error) {
+	buf := new(bytes.Buffer)
+	if err = s.Write(buf, format, opts); err != nil {
+		return
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	// CreateTemp creates files only readable by us
+	mode := fs.FileMode(0o644)
+	if fi, statErr := os.Stat(name); statErr == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err = f.Chmod(mode); err != nil {
+		f.Close()
+		return
+	}
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	return os.Rename(f.Name(), name)
+}
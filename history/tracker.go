@@ -0,0 +1,159 @@
+package history
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/icedream/go-stagelinq"
+)
+
+const defaultMinPlayTime = 30 * time.Second
+
+// TrackerConfiguration contains configurable values for a Tracker.
+type TrackerConfiguration struct {
+	// MinPlayTime is how long a track has to be audible without interruption
+	// before it counts as played, which keeps tracks that are only previewed
+	// out of the history. Defaults to 30 seconds.
+	MinPlayTime time.Duration
+
+	// IgnoreFaders counts tracks as audible while their deck plays, no
+	// matter the mixer volume or fader position reported for the deck.
+	IgnoreFaders bool
+}
+
+type trackedDeck struct {
+	key      string
+	entry    Entry
+	since    time.Time
+	reported bool
+}
+
+// Tracker works out which tracks have been played from snapshots of logical
+// decks as reported by stagelinq.Aggregator.
+type Tracker struct {
+	config TrackerConfiguration
+
+	lock  sync.Mutex
+	decks map[int]*trackedDeck
+}
+
+// NewTracker creates a tracker that has not seen any decks yet.
+func NewTracker(config *TrackerConfiguration) *Tracker {
+	if config == nil {
+		config = new(TrackerConfiguration)
+	}
+	c := *config
+	if c.MinPlayTime <= 0 {
+		c.MinPlayTime = defaultMinPlayTime
+	}
+	return &Tracker{
+		config: c,
+		decks:  map[int]*trackedDeck{},
+	}
+}
+
+// Update feeds a deck snapshot taken at the given time into the tracker. It
+// returns the entry for the track on the deck once that track counts as
+// played, which happens only once per track load.
+func (t *Tracker) Update(deck *stagelinq.AggregatedDeck, now time.Time) (entry *Entry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	d, ok := t.decks[deck.Number]
+	if !ok {
+		d = new(trackedDeck)
+		t.decks[deck.Number] = d
+	}
+
+	artist := stringValue(deck, "Track/ArtistName")
+	title := stringValue(deck, "Track/SongName")
+	path := stringValue(deck, "Track/TrackNetworkPath")
+	key := ""
+	if loaded, ok := boolValue(deck, "Track/SongLoaded"); !ok || loaded {
+		key = path + "\x00" + artist + "\x00" + title
+		if path == "" && artist == "" && title == "" {
+			key = ""
+		}
+	}
+	if key != d.key {
+		*d = trackedDeck{key: key}
+	}
+	d.entry.Deck = deck.Number
+	d.entry.Device = deck.DeviceName
+	d.entry.Artist = artist
+	d.entry.Title = title
+	d.entry.Path = path
+	if bpm, ok := numberValue(deck, "CurrentBPM"); ok && bpm > 0 {
+		d.entry.BPM = bpm
+	} else if bpm, ok := numberValue(deck, "Track/CurrentBPM"); ok && bpm > 0 {
+		d.entry.BPM = bpm
+	}
+
+	switch {
+	case d.key == "" || !t.audible(deck):
+		d.since = time.Time{}
+	case d.since.IsZero():
+		d.since = now
+	}
+	return t.check(d, now)
+}
+
+// Tick reports the tracks that count as played by now without any deck
+// having changed. Call it regularly, for example every second.
+func (t *Tracker) Tick(now time.Time) (entries []*Entry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	numbers := make([]int, 0, len(t.decks))
+	for number := range t.decks {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	for _, number := range numbers {
+		if entry := t.check(t.decks[number], now); entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return
+}
+
+// check returns the entry of a deck if its track just started counting as
+// played. Must be called with t.lock held.
+func (t *Tracker) check(d *trackedDeck, now time.Time) *Entry {
+	if d.reported || d.since.IsZero() || now.Sub(d.since) < t.config.MinPlayTime {
+		return nil
+	}
+	d.reported = true
+	entry := d.entry
+	entry.Time = d.since
+	return &entry
+}
+
+func (t *Tracker) audible(deck *stagelinq.AggregatedDeck) bool {
+	if playing, _ := boolValue(deck, "Play"); !playing {
+		return false
+	}
+	if t.config.IgnoreFaders {
+		return true
+	}
+	if deck.FaderPosition != nil && *deck.FaderPosition <= 0 {
+		return false
+	}
+	volume, ok := numberValue(deck, "ExternalMixerVolume")
+	return !ok || volume > 0
+}
+
+func stringValue(deck *stagelinq.AggregatedDeck, name string) string {
+	s, _ := deck.Values[name]["string"].(string)
+	return s
+}
+
+func boolValue(deck *stagelinq.AggregatedDeck, name string) (b bool, ok bool) {
+	b, ok = deck.Values[name]["state"].(bool)
+	return
+}
+
+func numberValue(deck *stagelinq.AggregatedDeck, name string) (f float64, ok bool) {
+	f, ok = deck.Values[name]["value"].(float64)
+	return
+}
@@ -0,0 +1,86 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icedream/go-stagelinq"
+	"github.com/stretchr/testify/require"
+)
+
+func testDeck(number int, playing bool, volume float64, artist, title string) *stagelinq.AggregatedDeck {
+	return &stagelinq.AggregatedDeck{
+		Number:     number,
+		DeviceName: "prime4",
+		Values: map[string]map[string]interface{}{
+			"Play":                {"state": playing, "type": float64(1)},
+			"ExternalMixerVolume": {"value": volume, "type": float64(0)},
+			"CurrentBPM":          {"value": 128.0, "type": float64(0)},
+			"Track/SongLoaded":    {"state": true, "type": float64(1)},
+			"Track/ArtistName":    {"string": artist, "type": float64(8)},
+			"Track/SongName":      {"string": title, "type": float64(8)},
+		},
+	}
+}
+
+func Test_Tracker(t *testing.T) {
+	start := time.Date(2024, 5, 4, 22, 0, 0, 0, time.UTC)
+	tracker := NewTracker(&TrackerConfiguration{MinPlayTime: 30 * time.Second})
+
+	// previewing with the fader down does not count
+	require.Nil(t, tracker.Update(testDeck(1, true, 0, "Icedream", "Whiplash"), start))
+	require.Empty(t, tracker.Tick(start.Add(time.Minute)))
+
+	// fader up, but not long enough yet
+	require.Nil(t, tracker.Update(testDeck(1, true, 1, "Icedream", "Whiplash"), start.Add(time.Minute)))
+	require.Empty(t, tracker.Tick(start.Add(time.Minute+10*time.Second)))
+
+	entries := tracker.Tick(start.Add(time.Minute + 30*time.Second))
+	require.Len(t, entries, 1)
+	require.Equal(t, &Entry{
+		Time:   start.Add(time.Minute),
+		Deck:   1,
+		Device: "prime4",
+		Artist: "Icedream",
+		Title:  "Whiplash",
+		BPM:    128,
+	}, entries[0])
+
+	// reported only once per track load
+	require.Empty(t, tracker.Tick(start.Add(2*time.Minute)))
+	require.Nil(t, tracker.Update(testDeck(1, true, 1, "Icedream", "Whiplash"), start.Add(3*time.Minute)))
+
+	// pausing starts over
+	require.Nil(t, tracker.Update(testDeck(2, true, 1, "Artist", "Next"), start.Add(3*time.Minute)))
+	require.Nil(t, tracker.Update(testDeck(2, false, 1, "Artist", "Next"), start.Add(3*time.Minute+20*time.Second)))
+	require.Nil(t, tracker.Update(testDeck(2, true, 1, "Artist", "Next"), start.Add(4*time.Minute)))
+	entry := tracker.Update(testDeck(2, true, 1, "Artist", "Next"), start.Add(4*time.Minute+30*time.Second))
+	require.NotNil(t, entry)
+	require.Equal(t, start.Add(4*time.Minute), entry.Time)
+
+	// loading another track on a deck makes it count again
+	require.Nil(t, tracker.Update(testDeck(1, true, 1, "Icedream", "Other"), start.Add(5*time.Minute)))
+	require.Len(t, tracker.Tick(start.Add(6*time.Minute)), 1)
+}
+
+func Test_Tracker_IgnoreFaders(t *testing.T) {
+	start := time.Date(2024, 5, 4, 22, 0, 0, 0, time.UTC)
+	tracker := NewTracker(&TrackerConfiguration{IgnoreFaders: true})
+	require.Nil(t, tracker.Update(testDeck(1, true, 0, "Icedream", "Whiplash"), start))
+	require.Len(t, tracker.Tick(start.Add(defaultMinPlayTime)), 1)
+}
+
+func Test_Tracker_MixerFader(t *testing.T) {
+	start := time.Date(2024, 5, 4, 22, 0, 0, 0, time.UTC)
+	tracker := NewTracker(nil)
+	closed, open := 0.0, 1.0
+
+	deck := testDeck(1, true, 1, "Icedream", "Whiplash")
+	deck.FaderPosition = &closed
+	require.Nil(t, tracker.Update(deck, start))
+	require.Empty(t, tracker.Tick(start.Add(defaultMinPlayTime)))
+
+	deck.FaderPosition = &open
+	require.Nil(t, tracker.Update(deck, start.Add(defaultMinPlayTime)))
+	require.Len(t, tracker.Tick(start.Add(2*defaultMinPlayTime)), 1)
+}
@@ -0,0 +1,209 @@
+// Package catalog reads the machine-readable catalog of StateMap value paths
+// from which the path accessors of the stagelinq package are generated.
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Types lists the value types a catalog entry may have.
+var Types = []string{"number", "boolean", "enum", "string", "integer", "color"}
+
+// Param describes an additional parameter of a path, such as a pad number.
+type Param struct {
+	// Name is the name of the parameter and of its placeholder in the path.
+	Name string `json:"name"`
+
+	// Type is either "int" or "string".
+	Type string `json:"type"`
+
+	// Count is the number of values an int parameter takes, starting at 1.
+	Count int `json:"count,omitempty"`
+
+	// Values lists the values a string parameter takes.
+	Values []string `json:"values,omitempty"`
+}
+
+// Value describes a single StateMap value path.
+type Value struct {
+	// Name is the name of the generated Go accessor.
+	Name string `json:"name"`
+
+	// Path is the path of the value. Placeholders in braces are replaced by
+	// the group index and the parameter.
+	Path string `json:"path"`
+
+	Type        string `json:"type"`
+	Unit        string `json:"unit,omitempty"`
+	Description string `json:"description"`
+	Writable    bool   `json:"writable,omitempty"`
+
+	// Devices lists the devices the path has been observed on, if known.
+	Devices []string `json:"devices,omitempty"`
+
+	// Unverified marks paths that have been reported but not been observed
+	// on a device yet.
+	Unverified bool `json:"unverified,omitempty"`
+
+	Param *Param `json:"param,omitempty"`
+}
+
+// Group describes values repeated for each deck, mixer channel or similar.
+type Group struct {
+	// Type is the name of the generated Go type.
+	Type        string `json:"type"`
+	Description string `json:"description"`
+
+	// Index is the name of the index field of the generated Go type.
+	Index string `json:"index"`
+
+	// Placeholder is the name of the placeholder replaced by the index.
+	Placeholder string `json:"placeholder"`
+
+	// Variable is the prefix of the generated variables, one per index.
+	Variable string `json:"variable"`
+
+	// Count is the number of indexes, starting at 1.
+	Count int `json:"count"`
+
+	Values []*Value `json:"values"`
+}
+
+// Catalog is the list of all known StateMap value paths.
+type Catalog struct {
+	Values []*Value `json:"values"`
+	Groups []*Group `json:"groups"`
+}
+
+// Path is a single concrete path from the catalog.
+type Path struct {
+	Path  string
+	Value *Value
+	// Group is nil for values outside of a group
+	Group *Group
+}
+
+// Parse reads and validates a catalog.
+func Parse(r io.Reader) (c *Catalog, err error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	c = new(Catalog)
+	if err = decoder.Decode(c); err != nil {
+		return nil, err
+	}
+	if err = c.validate(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c *Catalog) validate() error {
+	names := map[string]bool{}
+	for _, v := range c.Values {
+		if names[v.Name] {
+			return fmt.Errorf("duplicate value %s", v.Name)
+		}
+		names[v.Name] = true
+		if err := v.validate(""); err != nil {
+			return err
+		}
+	}
+	for _, g := range c.Groups {
+		if g.Type == "" || g.Index == "" || g.Placeholder == "" || g.Variable == "" || g.Count < 1 {
+			return fmt.Errorf("group %s is incomplete", g.Type)
+		}
+		names := map[string]bool{}
+		for _, v := range g.Values {
+			if names[v.Name] {
+				return fmt.Errorf("duplicate value %s.%s", g.Type, v.Name)
+			}
+			names[v.Name] = true
+			if err := v.validate(g.Placeholder); err != nil {
+				return fmt.Errorf("%s: %w", g.Type, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (v *Value) validate(placeholder string) error {
+	if v.Name == "" || !strings.HasPrefix(v.Path, "/") {
+		return fmt.Errorf("value %q has no name or an invalid path", v.Name)
+	}
+	known := false
+	for _, t := range Types {
+		known = known || t == v.Type
+	}
+	if !known {
+		return fmt.Errorf("value %s has unknown type %q", v.Name, v.Type)
+	}
+	path := v.Path
+	if placeholder != "" {
+		if !strings.Contains(path, "{"+placeholder+"}") {
+			return fmt.Errorf("value %s lacks placeholder {%s}", v.Name, placeholder)
+		}
+		path = strings.ReplaceAll(path, "{"+placeholder+"}", "")
+	}
+	if v.Param != nil {
+		p := v.Param
+		switch {
+		case p.Type == "int" && p.Count > 0:
+		case p.Type == "string" && len(p.Values) > 0:
+		default:
+			return fmt.Errorf("value %s has an invalid parameter", v.Name)
+		}
+		if !strings.Contains(path, "{"+p.Name+"}") {
+			return fmt.Errorf("value %s lacks placeholder {%s}", v.Name, p.Name)
+		}
+		path = strings.ReplaceAll(path, "{"+p.Name+"}", "")
+	}
+	if strings.ContainsAny(path, "{}") {
+		return fmt.Errorf("value %s has unknown placeholders", v.Name)
+	}
+	return nil
+}
+
+// Args returns the values a parameter takes as strings.
+func (p *Param) Args() []string {
+	if p.Type == "string" {
+		return p.Values
+	}
+	args := make([]string, p.Count)
+	for i := range args {
+		args[i] = strconv.Itoa(i + 1)
+	}
+	return args
+}
+
+func (v *Value) expand(path string) (paths []string) {
+	if v.Param == nil {
+		return []string{path}
+	}
+	for _, arg := range v.Param.Args() {
+		paths = append(paths, strings.ReplaceAll(path, "{"+v.Param.Name+"}", arg))
+	}
+	return
+}
+
+// Paths returns all concrete paths of the catalog.
+func (c *Catalog) Paths() (paths []*Path) {
+	for _, v := range c.Values {
+		for _, path := range v.expand(v.Path) {
+			paths = append(paths, &Path{Path: path, Value: v})
+		}
+	}
+	for _, g := range c.Groups {
+		for i := 1; i <= g.Count; i++ {
+			for _, v := range g.Values {
+				for _, path := range v.expand(strings.ReplaceAll(v.Path, "{"+g.Placeholder+"}", strconv.Itoa(i))) {
+					paths = append(paths, &Path{Path: path, Value: v, Group: g})
+				}
+			}
+		}
+	}
+	return
+}
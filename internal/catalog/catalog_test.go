@@ -0,0 +1,47 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Parse(t *testing.T) {
+	c, err := Parse(strings.NewReader(`{
+		"values": [
+			{"name": "A", "path": "/A", "type": "boolean", "description": "a."},
+			{"name": "B", "path": "/B/{side}", "type": "string", "description": "b.",
+				"param": {"name": "side", "type": "string", "values": ["Left", "Right"]}}
+		],
+		"groups": [
+			{"type": "G", "description": "g.", "index": "I", "placeholder": "g", "variable": "V", "count": 2,
+				"values": [{"name": "C", "path": "/G{g}/C{slot}", "type": "number", "description": "c.",
+					"param": {"name": "slot", "type": "int", "count": 2}}]}
+		]
+	}`))
+	require.NoError(t, err)
+
+	paths := []string{}
+	for _, p := range c.Paths() {
+		paths = append(paths, p.Path)
+	}
+	require.Equal(t, []string{
+		"/A", "/B/Left", "/B/Right",
+		"/G1/C1", "/G1/C2", "/G2/C1", "/G2/C2",
+	}, paths)
+}
+
+func Test_Parse_Invalid(t *testing.T) {
+	for _, s := range []string{
+		`{"values": [{"name": "A", "path": "/A", "type": "blob", "description": ""}]}`,
+		`{"values": [{"name": "A", "path": "A", "type": "string", "description": ""}]}`,
+		`{"values": [{"name": "A", "path": "/A/{x}", "type": "string", "description": ""}]}`,
+		`{"values": [{"name": "A", "path": "/A", "type": "string", "description": ""}, {"name": "A", "path": "/B", "type": "string", "description": ""}]}`,
+		`{"groups": [{"type": "G", "index": "I", "placeholder": "g", "variable": "V", "count": 1, "values": [{"name": "A", "path": "/A", "type": "string", "description": ""}]}]}`,
+		`{"unknown": true}`,
+	} {
+		_, err := Parse(strings.NewReader(s))
+		require.Error(t, err, s)
+	}
+}
@@ -0,0 +1,151 @@
+// Command valuenames generates the StateMap value path accessors of the
+// stagelinq package from the path catalog.
+//
+// It is run via go generate from the root of the repository.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/icedream/go-stagelinq/internal/catalog"
+)
+
+var (
+	flagCatalog = flag.String("catalog", "state_values.json", "path catalog to read")
+	flagOutput  = flag.String("output", "value_names_gen.go", "Go file to write")
+)
+
+const commentWidth = 77
+
+func main() {
+	flag.Parse()
+
+	f, err := os.Open(*flagCatalog)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c, err := catalog.Parse(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("%s: %s", *flagCatalog, err)
+	}
+
+	src, err := format.Source(generate(c))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(*flagOutput, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func generate(c *catalog.Catalog) []byte {
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "// Code generated by go run ./internal/generate/valuenames; DO NOT EDIT.\n\n")
+	fmt.Fprintf(b, "package stagelinq\n\nimport \"fmt\"\n\n")
+
+	// plain paths become constants, parameterized ones functions
+	b.WriteString("const (\n")
+	for _, v := range c.Values {
+		if v.Param != nil {
+			continue
+		}
+		writeComment(b, "\t", v.Name+" is the StateMap path for "+describe(v))
+		fmt.Fprintf(b, "\t%s = %q\n", v.Name, v.Path)
+	}
+	b.WriteString(")\n")
+	for _, v := range c.Values {
+		if v.Param == nil {
+			continue
+		}
+		b.WriteString("\n")
+		writeComment(b, "", v.Name+" returns the StateMap path for "+describe(v))
+		format, args := sprintfArgs(v.Path, nil, v.Param)
+		fmt.Fprintf(b, "func %s(%s) string {\n\treturn fmt.Sprintf(%q, %s)\n}\n",
+			v.Name, paramDecl(v.Param), format, strings.Join(args, ", "))
+	}
+
+	for _, g := range c.Groups {
+		b.WriteString("\n")
+		writeComment(b, "", g.Type+" "+g.Description)
+		fmt.Fprintf(b, "type %s struct {\n\t%s int\n}\n\nvar (\n", g.Type, g.Index)
+		for i := 1; i <= g.Count; i++ {
+			fmt.Fprintf(b, "\t%s%d = %s{%s: %d}\n", g.Variable, i, g.Type, g.Index, i)
+		}
+		b.WriteString(")\n")
+		for _, v := range g.Values {
+			b.WriteString("\n")
+			writeComment(b, "", v.Name+" returns the StateMap path for "+describe(v))
+			format, args := sprintfArgs(v.Path, g, v.Param)
+			fmt.Fprintf(b, "func (n *%s) %s(%s) string {\n\treturn fmt.Sprintf(%q, %s)\n}\n",
+				g.Type, v.Name, paramDecl(v.Param), format, strings.Join(args, ", "))
+		}
+	}
+	return b.Bytes()
+}
+
+func describe(v *catalog.Value) string {
+	s := v.Description
+	if v.Unit != "" {
+		s += " The value is given in " + v.Unit + "."
+	}
+	if len(v.Devices) > 0 {
+		s += " Sent by " + strings.Join(v.Devices, ", ") + "."
+	}
+	if v.Writable {
+		s += " It can be written using StateMapConnection.Set."
+	}
+	if v.Unverified {
+		s += " This path has not been verified on a device yet."
+	}
+	return s
+}
+
+func paramDecl(p *catalog.Param) string {
+	if p == nil {
+		return ""
+	}
+	return p.Name + " " + p.Type
+}
+
+var placeholderRegexp = regexp.MustCompile(`\{(\w+)\}`)
+
+// sprintfArgs turns a path with placeholders into a format string and the
+// arguments for it.
+func sprintfArgs(path string, g *catalog.Group, p *catalog.Param) (format string, args []string) {
+	format = placeholderRegexp.ReplaceAllStringFunc(path, func(m string) string {
+		name := m[1 : len(m)-1]
+		switch {
+		case g != nil && name == g.Placeholder:
+			args = append(args, "n."+g.Index)
+			return "%d"
+		case p != nil && name == p.Name:
+			args = append(args, p.Name)
+			if p.Type == "string" {
+				return "%s"
+			}
+			return "%d"
+		}
+		return m
+	})
+	return
+}
+
+func writeComment(b *bytes.Buffer, indent, text string) {
+	line := indent + "//"
+	for _, word := range strings.Fields(text) {
+		if len(line)+1+len(word) > commentWidth && line != indent+"//" {
+			b.WriteString(line + "\n")
+			line = indent + "//"
+		}
+		line += " " + word
+	}
+	b.WriteString(line + "\n")
+}
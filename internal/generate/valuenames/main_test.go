@@ -0,0 +1,26 @@
+package main
+
+import (
+	"go/format"
+	"os"
+	"testing"
+
+	"github.com/icedream/go-stagelinq/internal/catalog"
+	"github.com/stretchr/testify/require"
+)
+
+// Test_Generated makes sure value_names_gen.go has been regenerated after the
+// catalog changed.
+func Test_Generated(t *testing.T) {
+	f, err := os.Open("../../../state_values.json")
+	require.NoError(t, err)
+	defer f.Close()
+	c, err := catalog.Parse(f)
+	require.NoError(t, err)
+
+	src, err := format.Source(generate(c))
+	require.NoError(t, err)
+	generated, err := os.ReadFile("../../../value_names_gen.go")
+	require.NoError(t, err)
+	require.Equal(t, string(src), string(generated), "value_names_gen.go is out of date, run go generate")
+}
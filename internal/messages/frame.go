@@ -0,0 +1,187 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrFrameTooLarge is returned when a peer announces a length-prefixed frame
+// or string that exceeds the configured maximum size.
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// ErrTruncatedFrame is returned when a message ends before all of the data it
+// announced could be read.
+var ErrTruncatedFrame = errors.New("truncated frame")
+
+const (
+	// DefaultMaxFrameSize is the maximum size of a length-prefixed frame that
+	// is accepted from the wire unless configured otherwise.
+	DefaultMaxFrameSize uint32 = 4 << 20
+
+	// DefaultMaxStringSize is the maximum encoded size of a network string
+	// that is accepted from the wire unless configured otherwise.
+	DefaultMaxStringSize uint32 = 1 << 20
+)
+
+// Limits bounds the sizes a peer may announce for data read from the wire.
+// Zero values are replaced with the respective defaults.
+type Limits struct {
+	MaxFrameSize  uint32
+	MaxStringSize uint32
+}
+
+// DefaultLimits contains the limits used when none have been configured.
+var DefaultLimits = Limits{
+	MaxFrameSize:  DefaultMaxFrameSize,
+	MaxStringSize: DefaultMaxStringSize,
+}
+
+func (l Limits) normalized() Limits {
+	if l.MaxFrameSize == 0 {
+		l.MaxFrameSize = DefaultMaxFrameSize
+	}
+	if l.MaxStringSize == 0 {
+		l.MaxStringSize = DefaultMaxStringSize
+	}
+	return l
+}
+
+type limitCarrier interface {
+	wireLimits() Limits
+}
+
+type limitedReader struct {
+	io.Reader
+	limits Limits
+}
+
+func (r *limitedReader) wireLimits() Limits {
+	return r.limits
+}
+
+// WithLimits wraps r so that message parsers reading from it enforce the given
+// limits.
+func WithLimits(r io.Reader, limits Limits) io.Reader {
+	return &limitedReader{
+		Reader: r,
+		limits: limits.normalized(),
+	}
+}
+
+// LimitsOf returns the limits attached to r, or DefaultLimits if there are
+// none.
+func LimitsOf(r io.Reader) Limits {
+	if lc, ok := r.(limitCarrier); ok {
+		return lc.wireLimits()
+	}
+	return DefaultLimits
+}
+
+// frameReader reads from a fully received frame. Reading past the end of the
+// frame yields ErrTruncatedFrame instead of io.EOF since parsers only do so if
+// the frame is shorter than its contents claim.
+type frameReader struct {
+	r      *bytes.Reader
+	limits Limits
+}
+
+func (r *frameReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	if err == io.EOF {
+		err = ErrTruncatedFrame
+	}
+	return
+}
+
+func (r *frameReader) wireLimits() Limits {
+	return r.limits
+}
+
+// NewFrameReader returns a reader for a fully received frame, such as a
+// datagram or the payload of a length-prefixed message.
+func NewFrameReader(b []byte, limits Limits) io.Reader {
+	return &frameReader{
+		r:      bytes.NewReader(b),
+		limits: limits.normalized(),
+	}
+}
+
+// remaining returns how many bytes are left to read from r, if known.
+func remaining(r io.Reader) (n int, ok bool) {
+	if fr, isFrame := r.(*frameReader); isFrame {
+		return fr.r.Len(), true
+	}
+	return
+}
+
+// checkLength validates a length announced by the peer against the given
+// limit and, if known, the remaining bytes of the surrounding frame.
+func checkLength(r io.Reader, length uint32, limit uint32, what string) error {
+	if length > limit {
+		return fmt.Errorf("%w: %s of %d bytes exceeds limit of %d bytes",
+			ErrFrameTooLarge, what, length, limit)
+	}
+	if n, ok := remaining(r); ok && uint64(length) > uint64(n) {
+		return fmt.Errorf("%w: %s of %d bytes but only %d bytes left",
+			ErrTruncatedFrame, what, length, n)
+	}
+	return nil
+}
+
+// CheckRemaining validates that r, if its size is known, still holds at least
+// n bytes. Use it before allocating space for a peer-announced number of
+// records.
+func CheckRemaining(r io.Reader, n uint64, what string) error {
+	if left, ok := remaining(r); ok && n > uint64(left) {
+		return fmt.Errorf("%w: %s need %d bytes but only %d bytes left",
+			ErrTruncatedFrame, what, n, left)
+	}
+	return nil
+}
+
+// ReadFull reads exactly len(buf) bytes from r. Running out of data after the
+// first byte has been read is reported as ErrTruncatedFrame.
+func ReadFull(r io.Reader, buf []byte) (err error) {
+	if _, err = io.ReadFull(r, buf); errors.Is(err, io.ErrUnexpectedEOF) {
+		err = fmt.Errorf("%w: %w", ErrTruncatedFrame, err)
+	}
+	return
+}
+
+// ReadValue reads a fixed-size big-endian value from r like binary.Read. Since
+// it is used for fields following the start of a message, running out of data
+// is reported as ErrTruncatedFrame.
+func ReadValue(r io.Reader, v any) (err error) {
+	if err = binary.Read(r, binary.BigEndian, v); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = fmt.Errorf("%w: %w", ErrTruncatedFrame, err)
+	}
+	return
+}
+
+// ReadFrame reads a frame prefixed with its 32-bit big-endian length from r and
+// returns a reader for its payload.
+func ReadFrame(r io.Reader) (frame io.Reader, err error) {
+	limits := LimitsOf(r)
+
+	var length uint32
+	if err = binary.Read(r, binary.BigEndian, &length); err != nil {
+		return
+	}
+	if err = checkLength(r, length, limits.MaxFrameSize, "frame"); err != nil {
+		return
+	}
+
+	buf := make([]byte, int(length))
+	if _, err = io.ReadFull(r, buf); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = fmt.Errorf("%w: %w", ErrTruncatedFrame, err)
+		}
+		return
+	}
+
+	frame = NewFrameReader(buf, limits)
+	return
+}
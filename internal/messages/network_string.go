@@ -27,20 +27,15 @@ func WriteNetworkStringWithEncoding(w io.Writer, v string, enc encoding.Encoding
 
 func ReadNetworkStringWithEncoding(r io.Reader, v *string, enc encoding.Encoding) (err error) {
 	var expectedLength uint32
-	if err = binary.Read(r, binary.BigEndian, &expectedLength); err != nil {
+	if err = ReadValue(r, &expectedLength); err != nil {
 		return
 	}
-	expectedLengthInt := int(expectedLength)
-	buf := make([]byte, expectedLengthInt)
-	offset := 0
-	// I don't know if this is necessary, but I'm doing it anyways because #DefensiveProgrammingIsGood
-	for offset < expectedLengthInt {
-		var n int
-		n, err = r.Read(buf[offset:])
-		if err != nil {
-			return
-		}
-		offset += n
+	if err = checkLength(r, expectedLength, LimitsOf(r).MaxStringSize, "string"); err != nil {
+		return
+	}
+	buf := make([]byte, int(expectedLength))
+	if err = ReadFull(r, buf); err != nil {
+		return
 	}
 	vBytes, err := enc.NewDecoder().Bytes(buf)
 	if err != nil {
@@ -10,7 +10,7 @@ type TokenPrefixedMessage struct {
 }
 
 func (m *TokenPrefixedMessage) ReadMessageFrom(r io.Reader) (err error) {
-	_, err = r.Read(m.Token[:])
+	err = ReadFull(r, m.Token[:])
 	return
 }
 
@@ -56,6 +56,8 @@ type Listener struct {
 	packetConn        net.PacketConn
 	token             Token
 	port              uint16
+	recording         *recordingStream
+	limits            messages.Limits
 	shutdownCond      *sync.Cond
 	shutdownWaitGroup sync.WaitGroup
 }
@@ -145,7 +147,20 @@ func (l *Listener) announce(action discovererMessageAction) (err error) {
 	if err != nil {
 		return
 	}
-	finalBytes := b.Bytes()
+	sentTo, err := broadcastDiscoveryMessage(b.Bytes())
+	if l.recording != nil {
+		for _, addr := range sentTo {
+			l.recording.datagram(DirectionSent, addr, b.Bytes())
+		}
+	}
+
+	return
+}
+
+// broadcastDiscoveryMessage sends an encoded discovery message to the broadcast
+// addresses of all network interfaces and returns the addresses the message
+// has been sent to.
+func broadcastDiscoveryMessage(b []byte) (sentTo []*net.UDPAddr, err error) {
 	ips, err := socket.GetAllBroadcastIPs()
 	if err != nil {
 		return
@@ -154,7 +169,9 @@ func (l *Listener) announce(action discovererMessageAction) (err error) {
 		addr := makeStagelinqDiscoveryBroadcastAddress(ip)
 		packetConn, err := net.DialUDP("udp", nil, addr)
 		if err == nil {
-			_, _ = packetConn.Write(finalBytes)
+			if _, err := packetConn.Write(b); err == nil {
+				sentTo = append(sentTo, addr)
+			}
 			packetConn.Close()
 		}
 	}
@@ -193,7 +210,7 @@ readLoop:
 		}
 
 		// decode message
-		r := bytes.NewReader(b)
+		r := messages.NewFrameReader(b[:n], l.limits)
 		m := new(discoveryMessage)
 		if err = m.ReadMessageFrom(r); err != nil {
 			return
@@ -224,6 +241,42 @@ readLoop:
 	}
 }
 
+// DeviceResolver finds a StagelinQ device on the network by its token.
+// It is used by Session to find a device again after the connection to it has
+// been lost, possibly under a different address.
+type DeviceResolver interface {
+	// ResolveDevice blocks until the device with the given token is present
+	// on the network or ctx is done.
+	ResolveDevice(ctx context.Context, token Token) (*Device, error)
+}
+
+// ResolveDevice listens for announcements until the device with the given
+// token announces itself as present or ctx is done.
+// Announcements of other devices received in the meantime are dropped, so
+// don't call Discover at the same time.
+func (l *Listener) ResolveDevice(ctx context.Context, token Token) (device *Device, err error) {
+	for {
+		if err = ctx.Err(); err != nil {
+			device = nil
+			return
+		}
+		var deviceState DeviceState
+		device, deviceState, err = l.Discover(time.Second)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				device = nil
+				return
+			}
+			// ignore garbage from other applications on the port
+			err = nil
+			continue
+		}
+		if device != nil && device.token == token && deviceState == DevicePresent {
+			return
+		}
+	}
+}
+
 // Listen sets up a StagelinQ listener.
 func Listen() (listener *Listener, err error) {
 	return ListenWithConfiguration(nil)
@@ -261,8 +314,17 @@ func ListenWithConfiguration(listenerConfig *ListenerConfiguration) (listener *L
 		return
 	}
 
+	// Record discovery traffic if requested
+	var recording *recordingStream
+	if listenerConfig.Recorder != nil {
+		recording = listenerConfig.Recorder.openStream("discovery", packetConn.LocalAddr(), nil)
+		packetConn = &recordingPacketConn{PacketConn: packetConn, stream: recording}
+	}
+
 	listener = &Listener{
 		name:            listenerConfig.Name,
+		recording:       recording,
+		limits:          listenerConfig.Limits,
 		packetConn:      packetConn,
 		softwareName:    listenerConfig.SoftwareName,
 		softwareVersion: listenerConfig.SoftwareVersion,
@@ -26,4 +26,11 @@ type ListenerConfiguration struct {
 
 	// Token is used as part of announcements and main data communication. It is currently recommended to leave this empty.
 	Token Token
+
+	// Limits bounds the sizes of strings accepted in discovery messages. Zero
+	// values use the defaults.
+	Limits Limits
+
+	// Recorder, if set, receives a copy of all discovery traffic sent and received by the listener.
+	Recorder *Recorder
 }
@@ -31,15 +31,15 @@ type MainConnection struct {
 	reference int64
 }
 
-var mainConnectionMessageSet = newDeviceConnMessageSet([]messages.Message{
+var mainConnectionMessageSet = newServiceMessageSet("main", []messages.Message{
 	&serviceAnnouncementMessage{},
 	&referenceMessage{},
 	&servicesRequestMessage{},
 })
 
 // newMainConnection wraps an existing network connection to communicate StagelinQ main connection messages with it.
-func newMainConnection(conn net.Conn, token Token, targetToken Token, offeredServices []*Service) (retval *MainConnection, err error) {
-	msgConn := newMessageConnection(conn, mainConnectionMessageSet)
+func newMainConnection(conn net.Conn, token Token, targetToken Token, offeredServices []*Service, opts ...ConnectionOption) (retval *MainConnection, err error) {
+	msgConn := newMessageConnection(conn, mainConnectionMessageSet, opts...)
 
 	mainConn := &MainConnection{
 		token:           token,
@@ -131,10 +131,8 @@ func (conn *MainConnection) Close() (err error) {
 
 // RequestServices asks the device to return other TCP ports it is listening on and which services it provides on them.
 func (conn *MainConnection) RequestServices() (retval []*Service, err error) {
-	if err = conn.requestServices(); err != nil {
-		return
-	}
-
+	// set up the channel before sending the request so that a fast reply
+	// can't arrive before we are ready for it
 	conn.lock.Lock()
 	serviceC := make(chan *Service)
 	conn.servicesC = serviceC
@@ -142,6 +140,15 @@ func (conn *MainConnection) RequestServices() (retval []*Service, err error) {
 	services := []*Service{}
 	conn.lock.Unlock()
 
+	if err = conn.requestServices(); err != nil {
+		conn.lock.Lock()
+		if conn.servicesC == serviceC {
+			conn.servicesC = nil
+		}
+		conn.lock.Unlock()
+		return
+	}
+
 	for service := range serviceC {
 		services = append(services, service)
 	}
@@ -0,0 +1,55 @@
+package stagelinq
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test_MainConnection_RequestServices_FastReply checks that services
+// announced while the request is still being sent are not mistaken for
+// unsolicited ones.
+func Test_MainConnection_RequestServices_FastReply(t *testing.T) {
+	reply := new(bytes.Buffer)
+	require.NoError(t, (&serviceAnnouncementMessage{Service: "StateMap", Port: 1234}).WriteMessageTo(reply))
+	require.NoError(t, (&referenceMessage{}).WriteMessageTo(reply))
+
+	for range 50 {
+		client, device := net.Pipe()
+		go func() {
+			defer device.Close()
+			// answer as soon as the request starts to arrive
+			if _, err := io.ReadFull(device, make([]byte, 4)); err != nil {
+				return
+			}
+			if _, err := device.Write(reply.Bytes()); err != nil {
+				return
+			}
+			_, _ = io.Copy(io.Discard, device)
+		}()
+
+		conn, err := newMainConnection(client, Token{}, Token{}, nil)
+		require.NoError(t, err)
+		type result struct {
+			services []*Service
+			err      error
+		}
+		done := make(chan result, 1)
+		go func() {
+			services, err := conn.RequestServices()
+			done <- result{services, err}
+		}()
+		select {
+		case r := <-done:
+			require.NoError(t, r.err)
+			require.Equal(t, []*Service{{Name: "StateMap", Port: 1234}}, r.services)
+		case <-time.After(5 * time.Second):
+			t.Fatal("services were never returned")
+		}
+		conn.Close()
+	}
+}
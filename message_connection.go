@@ -5,13 +5,16 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"net"
 	"reflect"
+	"strings"
 
 	"github.com/icedream/go-stagelinq/internal/messages"
 )
 
 type messageSet struct {
+	service  string
 	messages []reflect.Type
 }
 
@@ -21,20 +24,68 @@ func newDeviceConnMessageSet(messageObjects []messages.Message) *messageSet {
 		// .Elem() because type will be a pointer-to-type but we want to create instances of the type itself later
 		messages[i] = reflect.TypeOf(messageObject).Elem()
 	}
-	return &messageSet{messages}
+	return &messageSet{messages: messages}
+}
+
+// newServiceMessageSet works like newDeviceConnMessageSet but also remembers
+// the name of the service the messages belong to, which is used to label
+// recorded traffic.
+func newServiceMessageSet(service string, messageObjects []messages.Message) *messageSet {
+	ms := newDeviceConnMessageSet(messageObjects)
+	ms.service = service
+	return ms
 }
 
 func (ms *messageSet) Messages() []reflect.Type {
 	return ms.messages
 }
 
+// messageTypeName returns a short name for the type of the given message, for
+// example "stateEmit" for a stateEmitMessage.
+func messageTypeName(msg messages.Message) string {
+	return strings.TrimSuffix(reflect.TypeOf(msg).Elem().Name(), "Message")
+}
+
+// readMessage reads the next message matching any of the expected messages.
+// CheckMatch is run against bufferedReader while the message itself is read
+// from r, which must read from the same underlying buffer.
+func readMessage(bufferedReader *bufio.Reader, r io.Reader, expectedMessages *messageSet) (msg messages.Message, err error) {
+	var targetMsg messages.Message
+	var ok bool
+	for _, messageType := range expectedMessages.Messages() {
+		targetMsg = reflect.New(messageType).Interface().(messages.Message)
+		ok, err = targetMsg.CheckMatch(bufferedReader)
+		if err != nil {
+			return
+		}
+		if ok {
+			break
+		}
+	}
+
+	if !ok {
+		b, _ := bufferedReader.Peek(bufferedReader.Buffered())
+		err = fmt.Errorf("%w: buffered bytes:\n%s", ErrInvalidMessageReceived, hex.Dump(b))
+		return
+	}
+
+	err = targetMsg.ReadMessageFrom(r)
+	if err == nil {
+		msg = targetMsg
+	}
+
+	return
+}
+
 type messageConnection struct {
 	conn             net.Conn
 	bufferedReader   *bufio.Reader
+	reader           io.Reader
 	expectedMessages *messageSet
+	recording        *recordingStream
 }
 
-func newMessageConnection(conn net.Conn, expectedMessages *messageSet) *messageConnection {
+func newMessageConnection(conn net.Conn, expectedMessages *messageSet, opts ...ConnectionOption) *messageConnection {
 	if conn == nil {
 		panic("conn must not be nil")
 	}
@@ -44,10 +95,20 @@ func newMessageConnection(conn net.Conn, expectedMessages *messageSet) *messageC
 	if len(expectedMessages.Messages()) <= 0 {
 		panic("expectedMessages must not be empty")
 	}
+	config := newConnectionConfiguration(opts)
+	var recording *recordingStream
+	if config.recorder != nil {
+		recording = config.recorder.openStream(expectedMessages.service, conn.LocalAddr(), conn.RemoteAddr())
+		conn = &recordingConn{Conn: conn, stream: recording}
+	}
+	bufferedReader := bufio.NewReader(conn)
 	return &messageConnection{
-		conn:             conn,
-		bufferedReader:   bufio.NewReader(conn),
+		conn:           conn,
+		bufferedReader: bufferedReader,
+		// message parsers pick up the configured limits from this reader
+		reader:           messages.WithLimits(bufferedReader, config.limits),
 		expectedMessages: expectedMessages,
+		recording:        recording,
 	}
 }
 
@@ -62,38 +123,17 @@ func (s *messageConnection) WriteMessage(msg messages.Message) (err error) {
 	// write the whole thing out as one message to the device
 	_, err = s.conn.Write(buf.Bytes())
 
-	// if err == nil {
-	// 	log.Printf("SEND: %s", spew.Sdump(msg))
-	// }
+	if err == nil && s.recording != nil {
+		s.recording.message(DirectionSent, msg)
+	}
 
 	return
 }
 
 func (s *messageConnection) ReadMessage() (msg messages.Message, err error) {
-	var targetMsg messages.Message
-	var ok bool
-	for _, messageType := range s.expectedMessages.Messages() {
-		targetMsg = reflect.New(messageType).Interface().(messages.Message)
-		ok, err = targetMsg.CheckMatch(s.bufferedReader)
-		if err != nil {
-			return
-		}
-		if ok {
-			break
-		}
-	}
-
-	if !ok {
-		b, _ := s.bufferedReader.Peek(s.bufferedReader.Buffered())
-		err = fmt.Errorf("%w: buffered bytes:\n%s", ErrInvalidMessageReceived, hex.Dump(b))
-		return
-	}
-
-	err = targetMsg.ReadMessageFrom(s.bufferedReader)
-	if err == nil {
-		msg = targetMsg
-		// log.Printf("RECV: %s", spew.Sdump(msg))
+	msg, err = readMessage(s.bufferedReader, s.reader, s.expectedMessages)
+	if err == nil && s.recording != nil {
+		s.recording.message(DirectionReceived, msg)
 	}
-
 	return
 }
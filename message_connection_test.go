@@ -222,3 +222,21 @@ func Test_MessageConnection(t *testing.T) {
 		require.Equal(t, expectedMessage, message)
 	}
 }
+
+func Test_MessageConnection_MaxFrameSize(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		msgConn := newMessageConnection(client, stateMapConnectionMessageSet)
+		_ = msgConn.WriteMessage(&stateEmitMessage{
+			Name: ClientPreferencesPlayer,
+			JSON: `{"string":"1","type":4}`,
+		})
+	}()
+
+	msgConn := newMessageConnection(server, stateMapConnectionMessageSet, WithMaxFrameSize(16))
+	_, err := msgConn.ReadMessage()
+	require.ErrorIs(t, err, ErrFrameTooLarge)
+}
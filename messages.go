@@ -41,7 +41,7 @@ func (m *serviceAnnouncementMessage) ReadMessageFrom(r io.Reader) (err error) {
 	if err = messages.ReadUTF16NetworkString(r, &m.Service); err != nil {
 		return
 	}
-	if err = binary.Read(r, binary.BigEndian, &m.Port); err != nil {
+	if err = messages.ReadValue(r, &m.Port); err != nil {
 		return
 	}
 	return
@@ -87,10 +87,10 @@ func (m *referenceMessage) ReadMessageFrom(r io.Reader) (err error) {
 	if err = m.TokenPrefixedMessage.ReadMessageFrom(r); err != nil {
 		return
 	}
-	if _, err = r.Read(m.Token2[:]); err != nil {
+	if err = messages.ReadFull(r, m.Token2[:]); err != nil {
 		return
 	}
-	err = binary.Read(r, binary.BigEndian, &m.Reference)
+	err = messages.ReadValue(r, &m.Reference)
 	return
 }
 
@@ -196,14 +196,14 @@ func (m *stateSubscribeMessage) CheckMatch(r *bufio.Reader) (ok bool, err error)
 }
 
 func (m *stateSubscribeMessage) ReadMessageFrom(r io.Reader) (err error) {
-	var expectedLength uint32
-	if err = binary.Read(r, binary.BigEndian, &expectedLength); err != nil {
+	msgReader, err := messages.ReadFrame(r)
+	if err != nil {
 		return
 	}
 
 	// read smaa magic bytes
 	magicBytes := make([]byte, 4)
-	if _, err = r.Read(magicBytes); err != nil {
+	if err = messages.ReadFull(msgReader, magicBytes); err != nil {
 		return
 	}
 	if !bytes.Equal(magicBytes, smaaMagicBytes) {
@@ -212,7 +212,7 @@ func (m *stateSubscribeMessage) ReadMessageFrom(r io.Reader) (err error) {
 	}
 
 	// read and validate message type
-	if _, err = r.Read(magicBytes); err != nil {
+	if err = messages.ReadFull(msgReader, magicBytes); err != nil {
 		return
 	}
 	if binary.BigEndian.Uint32(magicBytes) != smaaMessageTypeSubscribe {
@@ -221,12 +221,12 @@ func (m *stateSubscribeMessage) ReadMessageFrom(r io.Reader) (err error) {
 	}
 
 	// read value name
-	if err = messages.ReadUTF16NetworkString(r, &m.Name); err != nil {
+	if err = messages.ReadUTF16NetworkString(msgReader, &m.Name); err != nil {
 		return
 	}
 
 	// read minimum update interval
-	err = binary.Read(r, binary.BigEndian, &m.Interval)
+	err = messages.ReadValue(msgReader, &m.Interval)
 	return
 }
 
@@ -277,14 +277,14 @@ func (m *stateEmitResponseMessage) CheckMatch(r *bufio.Reader) (ok bool, err err
 }
 
 func (m *stateEmitResponseMessage) ReadMessageFrom(r io.Reader) (err error) {
-	var expectedLength uint32
-	if err = binary.Read(r, binary.BigEndian, &expectedLength); err != nil {
+	msgReader, err := messages.ReadFrame(r)
+	if err != nil {
 		return
 	}
 
 	// read smaa magic bytes
 	magicBytes := make([]byte, 4)
-	if _, err = r.Read(magicBytes); err != nil {
+	if err = messages.ReadFull(msgReader, magicBytes); err != nil {
 		return
 	}
 	if !bytes.Equal(magicBytes, smaaMagicBytes) {
@@ -293,7 +293,7 @@ func (m *stateEmitResponseMessage) ReadMessageFrom(r io.Reader) (err error) {
 	}
 
 	// read and validate message type
-	if _, err = r.Read(magicBytes); err != nil {
+	if err = messages.ReadFull(msgReader, magicBytes); err != nil {
 		return
 	}
 	if binary.BigEndian.Uint32(magicBytes) != smaaMessageTypeSubscribeResponse {
@@ -302,12 +302,12 @@ func (m *stateEmitResponseMessage) ReadMessageFrom(r io.Reader) (err error) {
 	}
 
 	// read value name
-	if err = messages.ReadUTF16NetworkString(r, &m.Name); err != nil {
+	if err = messages.ReadUTF16NetworkString(msgReader, &m.Name); err != nil {
 		return
 	}
 
 	// read confirmed minimum update interval
-	err = binary.Read(r, binary.BigEndian, &m.Interval)
+	err = messages.ReadValue(msgReader, &m.Interval)
 	return
 }
 
@@ -355,27 +355,14 @@ func (m *stateEmitMessage) CheckMatch(r *bufio.Reader) (ok bool, err error) {
 }
 
 func (m *stateEmitMessage) ReadMessageFrom(r io.Reader) (err error) {
-	// read expected message length
-	var expectedLength uint32
-	if err = binary.Read(r, binary.BigEndian, &expectedLength); err != nil {
+	msgReader, err := messages.ReadFrame(r)
+	if err != nil {
 		return
 	}
 
-	// set up buffer to write message into
-	msgBytes := make([]byte, int(expectedLength))
-	msgBytesOffset := 0
-	for msgBytesOffset < int(expectedLength) {
-		var n int
-		if n, err = r.Read(msgBytes[msgBytesOffset:]); err != nil {
-			return
-		}
-		msgBytesOffset += n
-	}
-	msgReader := bytes.NewReader(msgBytes)
-
 	// read smaa magic bytes
 	magicBytes := make([]byte, 4)
-	if _, err = msgReader.Read(magicBytes); err != nil {
+	if err = messages.ReadFull(msgReader, magicBytes); err != nil {
 		return
 	}
 	if !bytes.Equal(magicBytes, smaaMagicBytes) {
@@ -384,7 +371,7 @@ func (m *stateEmitMessage) ReadMessageFrom(r io.Reader) (err error) {
 	}
 
 	// read and validate message type
-	if _, err = msgReader.Read(magicBytes); err != nil {
+	if err = messages.ReadFull(msgReader, magicBytes); err != nil {
 		return
 	}
 	if binary.BigEndian.Uint32(magicBytes) != smaaMessageTypeEmit {
@@ -458,27 +445,14 @@ func (m *beatInfoStartStreamMessage) CheckMatch(r *bufio.Reader) (ok bool, err e
 }
 
 func (m *beatInfoStartStreamMessage) ReadMessageFrom(r io.Reader) (err error) {
-	// read expected message length
-	var expectedLength uint32
-	if err = binary.Read(r, binary.BigEndian, &expectedLength); err != nil {
+	msgReader, err := messages.ReadFrame(r)
+	if err != nil {
 		return
 	}
 
-	// set up buffer to write message into
-	msgBytes := make([]byte, int(expectedLength))
-	msgBytesOffset := 0
-	for msgBytesOffset < int(expectedLength) {
-		var n int
-		if n, err = r.Read(msgBytes[msgBytesOffset:]); err != nil {
-			return
-		}
-		msgBytesOffset += n
-	}
-	msgReader := bytes.NewReader(msgBytes)
-
 	// read beatInfoStartStream magic bytes
 	magicBytes := make([]byte, 4)
-	if _, err = msgReader.Read(magicBytes); err != nil {
+	if err = messages.ReadFull(msgReader, magicBytes); err != nil {
 		return
 	}
 	if !bytes.Equal(magicBytes, beatInfoStartStreamMagicBytes) {
@@ -524,27 +498,14 @@ func (m *beatInfoStopStreamMessage) CheckMatch(r *bufio.Reader) (ok bool, err er
 }
 
 func (m *beatInfoStopStreamMessage) ReadMessageFrom(r io.Reader) (err error) {
-	// read expected message length
-	var expectedLength uint32
-	if err = binary.Read(r, binary.BigEndian, &expectedLength); err != nil {
+	msgReader, err := messages.ReadFrame(r)
+	if err != nil {
 		return
 	}
 
-	// set up buffer to write message into
-	msgBytes := make([]byte, int(expectedLength))
-	msgBytesOffset := 0
-	for msgBytesOffset < int(expectedLength) {
-		var n int
-		if n, err = r.Read(msgBytes[msgBytesOffset:]); err != nil {
-			return
-		}
-		msgBytesOffset += n
-	}
-	msgReader := bytes.NewReader(msgBytes)
-
 	// read beatInfoStopStream magic bytes
 	magicBytes := make([]byte, 4)
-	if _, err = msgReader.Read(magicBytes); err != nil {
+	if err = messages.ReadFull(msgReader, magicBytes); err != nil {
 		return
 	}
 	if !bytes.Equal(magicBytes, beatInfoStopStreamMagicBytes) {
@@ -602,27 +563,14 @@ func (m *beatEmitMessage) CheckMatch(r *bufio.Reader) (ok bool, err error) {
 }
 
 func (m *beatEmitMessage) ReadMessageFrom(r io.Reader) (err error) {
-	// read expected message length
-	var expectedLength uint32
-	if err = binary.Read(r, binary.BigEndian, &expectedLength); err != nil {
+	msgReader, err := messages.ReadFrame(r)
+	if err != nil {
 		return
 	}
 
-	// set up buffer to write message into
-	msgBytes := make([]byte, int(expectedLength))
-	msgBytesOffset := 0
-	for msgBytesOffset < int(expectedLength) {
-		var n int
-		if n, err = r.Read(msgBytes[msgBytesOffset:]); err != nil {
-			return
-		}
-		msgBytesOffset += n
-	}
-	msgReader := bytes.NewReader(msgBytes)
-
 	// read beatEmit magic bytes
 	magicBytes := make([]byte, 4)
-	if _, err = msgReader.Read(magicBytes); err != nil {
+	if err = messages.ReadFull(msgReader, magicBytes); err != nil {
 		return
 	}
 	if !bytes.Equal(magicBytes, beatEmitMagicBytes) {
@@ -631,50 +579,43 @@ func (m *beatEmitMessage) ReadMessageFrom(r io.Reader) (err error) {
 	}
 
 	// read clock value
-	if err = binary.Read(msgReader, binary.BigEndian, &m.Clock); err != nil {
+	if err = messages.ReadValue(msgReader, &m.Clock); err != nil {
 		return
 	}
 
 	// read expected player records
 	var expectedRecords uint32
-	if err = binary.Read(msgReader, binary.BigEndian, &expectedRecords); err != nil {
+	if err = messages.ReadValue(msgReader, &expectedRecords); err != nil {
 		return
 	}
 
-	// bounds check
-	// each playerInfo record is 24 bytes
-	if msgReader.Len() < int(expectedRecords)*24 {
-		err = errors.New("unknown packet format")
+	// bounds check before allocating anything
+	// each playerInfo record is 24 bytes followed by an 8 byte timeline record
+	if err = messages.CheckRemaining(msgReader, uint64(expectedRecords)*(24+8), "beat info records"); err != nil {
+		return
 	}
 
 	// loop through players records
-	for i := 0; i < int(expectedRecords); i++ {
-		var p PlayerInfo
-		if err = binary.Read(msgReader, binary.BigEndian, &p.Beat); err != nil {
+	m.Players = make([]PlayerInfo, int(expectedRecords))
+	for i := range m.Players {
+		p := &m.Players[i]
+		if err = messages.ReadValue(msgReader, &p.Beat); err != nil {
 			return
 		}
-		if err = binary.Read(msgReader, binary.BigEndian, &p.TotalBeats); err != nil {
+		if err = messages.ReadValue(msgReader, &p.TotalBeats); err != nil {
 			return
 		}
-		if err = binary.Read(msgReader, binary.BigEndian, &p.Bpm); err != nil {
+		if err = messages.ReadValue(msgReader, &p.Bpm); err != nil {
 			return
 		}
-		m.Players = append(m.Players, p)
-	}
-
-	// bounds check
-	// the rest of our payload should contain exactly enough bytes for the timeline records
-	if msgReader.Len() == int(expectedRecords)*8 {
-		err = errors.New("unknown packet format")
 	}
 
 	// loop through timelines
-	for i := 0; i < int(expectedRecords); i++ {
-		var t float64
-		if err = binary.Read(msgReader, binary.BigEndian, &t); err != nil {
+	m.Timelines = make([]float64, int(expectedRecords))
+	for i := range m.Timelines {
+		if err = messages.ReadValue(msgReader, &m.Timelines[i]); err != nil {
 			return
 		}
-		m.Timelines = append(m.Timelines, t)
 	}
 
 	return
@@ -770,7 +711,7 @@ func (m *discoveryMessage) CheckMatch(r *bufio.Reader) (ok bool, err error) {
 
 func (m *discoveryMessage) ReadMessageFrom(r io.Reader) (err error) {
 	readMagic := make([]byte, 4)
-	if _, err = r.Read(readMagic); err != nil {
+	if err = messages.ReadFull(r, readMagic); err != nil {
 		return
 	} else if !bytes.Equal(readMagic, discoveryMagic) {
 		err = ErrInvalidMessageReceived
@@ -793,7 +734,7 @@ func (m *discoveryMessage) ReadMessageFrom(r io.Reader) (err error) {
 	if err = messages.ReadUTF16NetworkString(r, &m.SoftwareVersion); err != nil {
 		return
 	}
-	err = binary.Read(r, binary.BigEndian, &m.Port)
+	err = messages.ReadValue(r, &m.Port)
 	return
 }
 
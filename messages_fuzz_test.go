@@ -0,0 +1,89 @@
+package stagelinq
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/icedream/go-stagelinq/internal/messages"
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzMessage feeds arbitrary bytes to the message type created by create and
+// checks that parsing never panics and that any successfully parsed message
+// survives a decode→encode→decode round trip unchanged.
+//
+// Encoded bytes are compared instead of message values so NaN floats in beat
+// info records do not cause false positives.
+func fuzzMessage(f *testing.F, create func() messages.Message) {
+	messageType := reflect.TypeOf(create())
+	for _, test := range testMessages {
+		if reflect.TypeOf(test.Message) == messageType {
+			f.Add(test.Bytes)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		// must not panic regardless of outcome
+		_, _ = create().CheckMatch(bufio.NewReader(bytes.NewReader(b)))
+
+		m := create()
+		if err := m.ReadMessageFrom(messages.NewFrameReader(b, messages.DefaultLimits)); err != nil {
+			return
+		}
+
+		encoded := new(bytes.Buffer)
+		require.NoError(t, m.WriteMessageTo(encoded))
+
+		m2 := create()
+		require.NoError(t, m2.ReadMessageFrom(bytes.NewReader(encoded.Bytes())))
+		ok, err := m2.CheckMatch(bufio.NewReader(bytes.NewReader(encoded.Bytes())))
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		reencoded := new(bytes.Buffer)
+		require.NoError(t, m2.WriteMessageTo(reencoded))
+		require.Equal(t, encoded.Bytes(), reencoded.Bytes())
+	})
+}
+
+func FuzzDiscoveryMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(discoveryMessage) })
+}
+
+func FuzzServiceAnnouncementMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(serviceAnnouncementMessage) })
+}
+
+func FuzzReferenceMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(referenceMessage) })
+}
+
+func FuzzServicesRequestMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(servicesRequestMessage) })
+}
+
+func FuzzStateSubscribeMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(stateSubscribeMessage) })
+}
+
+func FuzzStateEmitResponseMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(stateEmitResponseMessage) })
+}
+
+func FuzzStateEmitMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(stateEmitMessage) })
+}
+
+func FuzzBeatInfoStartStreamMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(beatInfoStartStreamMessage) })
+}
+
+func FuzzBeatInfoStopStreamMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(beatInfoStopStreamMessage) })
+}
+
+func FuzzBeatEmitMessage(f *testing.F) {
+	fuzzMessage(f, func() messages.Message { return new(beatEmitMessage) })
+}
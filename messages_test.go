@@ -273,3 +273,155 @@ func Test_Messages_CheckMatch(t *testing.T) {
 		})
 	}
 }
+
+var testMalformedMessages = []struct {
+	Name          string
+	CreateMessage func() messages.Message
+	Bytes         []byte
+	Error         error
+}{
+	{
+		Name:          "State emit with oversized frame",
+		CreateMessage: func() messages.Message { return new(stateEmitMessage) },
+		Bytes: []byte{
+			0xff, 0xff, 0xff, 0xff, 0x73, 0x6d, 0x61, 0x61,
+		},
+		Error: ErrFrameTooLarge,
+	},
+	{
+		Name:          "State emit with truncated frame",
+		CreateMessage: func() messages.Message { return new(stateEmitMessage) },
+		Bytes: []byte{
+			0x00, 0x00, 0x00, 0x72, 0x73, 0x6d, 0x61, 0x61,
+			0x00, 0x00, 0x00, 0x00,
+		},
+		Error: ErrTruncatedFrame,
+	},
+	{
+		Name:          "State emit with string exceeding frame",
+		CreateMessage: func() messages.Message { return new(stateEmitMessage) },
+		Bytes: []byte{
+			0x00, 0x00, 0x00, 0x0c, 0x73, 0x6d, 0x61, 0x61,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00,
+		},
+		Error: ErrTruncatedFrame,
+	},
+	{
+		Name:          "State subscribe with truncated interval",
+		CreateMessage: func() messages.Message { return new(stateSubscribeMessage) },
+		Bytes: []byte{
+			0x00, 0x00, 0x00, 0x0e, 0x73, 0x6d, 0x61, 0x61,
+			0x00, 0x00, 0x07, 0xd2, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00,
+		},
+		Error: ErrTruncatedFrame,
+	},
+	{
+		Name:          "Beat emit with too many records",
+		CreateMessage: func() messages.Message { return new(beatEmitMessage) },
+		Bytes: []byte{
+			0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x02,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0xff, 0xff, 0xff, 0xff,
+		},
+		Error: ErrTruncatedFrame,
+	},
+	{
+		Name:          "Beat emit missing timelines",
+		CreateMessage: func() messages.Message { return new(beatEmitMessage) },
+		Bytes: []byte{
+			0x00, 0x00, 0x00, 0x28, 0x00, 0x00, 0x00, 0x02,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+		},
+		Error: ErrTruncatedFrame,
+	},
+	{
+		Name:          "Service announcement with oversized service name",
+		CreateMessage: func() messages.Message { return new(serviceAnnouncementMessage) },
+		Bytes: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x52, 0x3e, 0x67, 0x9d,
+			0xa4, 0x18, 0x4d, 0x1e, 0x83, 0xd0, 0xc7, 0x52,
+			0xcf, 0xca, 0x8f, 0xf7, 0x7f, 0xff, 0xff, 0xff,
+		},
+		Error: ErrFrameTooLarge,
+	},
+	{
+		Name:          "Service announcement with truncated token",
+		CreateMessage: func() messages.Message { return new(serviceAnnouncementMessage) },
+		Bytes: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x52, 0x3e, 0x67, 0x9d,
+		},
+		Error: ErrTruncatedFrame,
+	},
+	{
+		Name:          "Service announcement with truncated port",
+		CreateMessage: func() messages.Message { return new(serviceAnnouncementMessage) },
+		Bytes: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x52, 0x3e, 0x67, 0x9d,
+			0xa4, 0x18, 0x4d, 0x1e, 0x83, 0xd0, 0xc7, 0x52,
+			0xcf, 0xca, 0x8f, 0xf7, 0x00, 0x00, 0x00, 0x00,
+			0xc0,
+		},
+		Error: ErrTruncatedFrame,
+	},
+	{
+		Name:          "Service announcement without port",
+		CreateMessage: func() messages.Message { return new(serviceAnnouncementMessage) },
+		Bytes: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x52, 0x3e, 0x67, 0x9d,
+			0xa4, 0x18, 0x4d, 0x1e, 0x83, 0xd0, 0xc7, 0x52,
+			0xcf, 0xca, 0x8f, 0xf7, 0x00, 0x00, 0x00, 0x00,
+		},
+		Error: ErrTruncatedFrame,
+	},
+	{
+		Name:          "Reference with truncated reference",
+		CreateMessage: func() messages.Message { return new(referenceMessage) },
+		Bytes: append(append([]byte{0x00, 0x00, 0x00, 0x01}, make([]byte, 32)...),
+			0x00, 0x00, 0x00),
+		Error: ErrTruncatedFrame,
+	},
+	{
+		Name:          "Discovery with truncated port",
+		CreateMessage: func() messages.Message { return new(discoveryMessage) },
+		Bytes: append(append([]byte("airD"), make([]byte, 16+4*4)...),
+			0x00),
+		Error: ErrTruncatedFrame,
+	},
+}
+
+func Test_Messages_ReadMalformed(t *testing.T) {
+	for _, test := range testMalformedMessages {
+		def := test
+		t.Run(test.Name, func(t *testing.T) {
+			r := bytes.NewReader(def.Bytes)
+			m := def.CreateMessage()
+			err := m.ReadMessageFrom(r)
+			require.ErrorIs(t, err, def.Error)
+		})
+	}
+}
+
+func Test_Messages_ReadWithLimits(t *testing.T) {
+	for _, test := range testMessages {
+		def := test
+		switch def.Name {
+		case "Discovery", "Service announcement", "State emit", "Beat emit":
+		default:
+			continue
+		}
+		t.Run(test.Name, func(t *testing.T) {
+			r := messages.WithLimits(bytes.NewReader(def.Bytes), messages.Limits{
+				MaxFrameSize:  8,
+				MaxStringSize: 8,
+			})
+			m := def.CreateMessage()
+			err := m.ReadMessageFrom(r)
+			require.ErrorIs(t, err, ErrFrameTooLarge)
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package pcap
+
+import (
+	"errors"
+	"io"
+	"net/netip"
+	"sort"
+	"time"
+
+	"github.com/icedream/go-stagelinq"
+	"github.com/icedream/go-stagelinq/eaas"
+)
+
+const (
+	stagelinqDiscoveryPort = 51337
+	eaasDiscoveryPort      = 11224
+
+	// ServiceDiscovery is the service name used for StagelinQ discovery
+	// messages.
+	ServiceDiscovery = "discovery"
+
+	// ServiceEAASDiscovery is the service name used for EAAS discovery
+	// messages.
+	ServiceEAASDiscovery = "EAAS discovery"
+
+	// ServiceMain is the service name used for messages on the main
+	// connection of a device.
+	ServiceMain = "main"
+)
+
+const (
+	// maxUnidentifiedStreamData is how much data is kept for TCP streams that
+	// can not be assigned to a service yet.
+	maxUnidentifiedStreamData = 1 << 20
+
+	// maxPendingSegments is how many out-of-order TCP segments are kept per
+	// stream before giving up on the missing data.
+	maxPendingSegments = 1024
+)
+
+// Event is a message decoded from a capture.
+type Event struct {
+	Time        time.Time
+	Source      netip.AddrPort
+	Destination netip.AddrPort
+
+	// Service is the StagelinQ service the message was sent on, "main" for
+	// main connections or one of ServiceDiscovery and ServiceEAASDiscovery.
+	Service string
+
+	// Type is a short name of the message type, for example "stateEmit".
+	Type string
+
+	// Message holds the decoded message fields. It is nil if Error is set.
+	Message interface{}
+
+	// Error describes why data on the stream could not be decoded.
+	Error string `json:",omitempty"`
+}
+
+type endpoint = netip.AddrPort
+
+type flow struct {
+	src, dst endpoint
+}
+
+type tcpStream struct {
+	flow
+
+	started bool
+	nextSeq uint32
+	pending map[uint32][]byte
+
+	service      string
+	decoder      *stagelinq.StreamDecoder
+	unidentified []byte
+}
+
+// Decoder decodes StagelinQ traffic from captured packets. TCP streams are
+// reassembled and assigned to services as the services are announced by the
+// devices in the capture, either through discovery or on their main
+// connection.
+type Decoder struct {
+	services     map[endpoint]string
+	servicePorts map[uint16]string
+	streams      map[flow]*tcpStream
+	fn           func(*Event) error
+}
+
+// NewDecoder returns a decoder that calls fn for every decoded message.
+// Decoding stops at the first error returned by fn.
+func NewDecoder(fn func(*Event) error) *Decoder {
+	return &Decoder{
+		services:     map[endpoint]string{},
+		servicePorts: map[uint16]string{},
+		streams:      map[flow]*tcpStream{},
+		fn:           fn,
+	}
+}
+
+// AddService makes the decoder treat TCP traffic on the given port as traffic
+// of the given service on any host. This is useful if the capture started
+// after the service has been announced.
+func (d *Decoder) AddService(port uint16, service string) {
+	d.servicePorts[port] = service
+}
+
+// Decode reads all packets from a pcap or pcapng file and decodes them.
+func (d *Decoder) Decode(r io.Reader) (err error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return
+	}
+	for {
+		var p *Packet
+		p, err = reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+		if err = d.DecodePacket(p); err != nil {
+			return
+		}
+	}
+}
+
+// DecodePacket decodes a single captured packet. Packets must be passed in the
+// order they were captured.
+func (d *Decoder) DecodePacket(p *Packet) error {
+	s, ok := decodePacket(p.LinkType, p.Data)
+	if !ok {
+		return nil
+	}
+	switch s.protocol {
+	case ipProtocolUDP:
+		return d.decodeDatagram(p.Time, s)
+	case ipProtocolTCP:
+		return d.decodeSegment(p.Time, s)
+	}
+	return nil
+}
+
+func (d *Decoder) decodeDatagram(t time.Time, s *segment) (err error) {
+	event := &Event{
+		Time:        t,
+		Source:      s.src,
+		Destination: s.dst,
+	}
+	switch {
+	case s.dst.Port() == stagelinqDiscoveryPort:
+		event.Service = ServiceDiscovery
+		msg, decodeErr := stagelinq.DecodeDatagram(s.payload)
+		if decodeErr != nil {
+			event.Error = decodeErr.Error()
+			break
+		}
+		event.Type = msg.Type
+		event.Message = msg.Message
+		if service := msg.AnnouncedService(); service != nil {
+			if err = d.announce(t, netip.AddrPortFrom(s.src.Addr(), service.Port), service.Name); err != nil {
+				return
+			}
+		}
+	case s.dst.Port() == eaasDiscoveryPort || s.src.Port() == eaasDiscoveryPort:
+		event.Service = ServiceEAASDiscovery
+		msg, decodeErr := eaas.DecodeDatagram(s.payload)
+		if decodeErr != nil {
+			event.Error = decodeErr.Error()
+			break
+		}
+		event.Type = msg.Type
+		event.Message = msg.Message
+	default:
+		return
+	}
+	return d.fn(event)
+}
+
+// announce remembers that a service is reachable at the given endpoint and
+// starts decoding streams to and from it.
+func (d *Decoder) announce(t time.Time, ep endpoint, service string) (err error) {
+	if d.services[ep] == service {
+		return
+	}
+	d.services[ep] = service
+
+	// sort for deterministic output
+	identified := []*tcpStream{}
+	for _, stream := range d.streams {
+		if stream.decoder == nil && (stream.src == ep || stream.dst == ep) {
+			identified = append(identified, stream)
+		}
+	}
+	sort.Slice(identified, func(i, j int) bool {
+		return identified[i].src.String()+identified[i].dst.String() < identified[j].src.String()+identified[j].dst.String()
+	})
+	for _, stream := range identified {
+		if err = d.identify(t, stream); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// serviceOf returns the service a TCP stream belongs to, if known.
+func (d *Decoder) serviceOf(f flow) (service string, ok bool) {
+	if service, ok = d.services[f.dst]; ok {
+		return
+	}
+	if service, ok = d.services[f.src]; ok {
+		return
+	}
+	if service, ok = d.servicePorts[f.dst.Port()]; ok {
+		return
+	}
+	service, ok = d.servicePorts[f.src.Port()]
+	return
+}
+
+// identify sets up decoding for a stream if its service is known. Data that
+// was captured before the service was known is decoded right away.
+func (d *Decoder) identify(t time.Time, stream *tcpStream) (err error) {
+	if stream.decoder != nil {
+		return
+	}
+	service, ok := d.serviceOf(stream.flow)
+	if !ok {
+		return
+	}
+	decoder := stagelinq.NewStreamDecoder(service)
+	if decoder == nil {
+		// not a service we know how to decode, drop data
+		stream.service = service
+		stream.unidentified = nil
+		return
+	}
+	stream.service = service
+	stream.decoder = decoder
+	data := stream.unidentified
+	stream.unidentified = nil
+	return d.deliver(t, stream, data)
+}
+
+func (d *Decoder) decodeSegment(t time.Time, s *segment) (err error) {
+	f := flow{src: s.src, dst: s.dst}
+	stream, ok := d.streams[f]
+	if !ok || s.flags&tcpFlagSYN != 0 {
+		stream = &tcpStream{
+			flow:    f,
+			pending: map[uint32][]byte{},
+		}
+		d.streams[f] = stream
+		if err = d.identify(t, stream); err != nil {
+			return
+		}
+	}
+
+	if s.flags&tcpFlagSYN != 0 {
+		stream.started = true
+		stream.nextSeq = s.seq + 1
+		return
+	}
+	if !stream.started {
+		// capture started in the middle of the connection
+		stream.started = true
+		stream.nextSeq = s.seq
+	}
+
+	if len(s.payload) > 0 {
+		if err = d.reassemble(t, stream, s.seq, s.payload); err != nil {
+			return
+		}
+	}
+
+	if s.flags&(tcpFlagFIN|tcpFlagRST) != 0 {
+		delete(d.streams, f)
+	}
+	return
+}
+
+func (d *Decoder) reassemble(t time.Time, stream *tcpStream, seq uint32, payload []byte) (err error) {
+	for int32(seq-stream.nextSeq) > 0 {
+		// data from the future, wait for the gap to be filled
+		if len(stream.pending) < maxPendingSegments {
+			if existing, ok := stream.pending[seq]; !ok || len(existing) < len(payload) {
+				stream.pending[seq] = payload
+			}
+			return
+		}
+		// give up on the missing data and continue with what we have, which
+		// delivers at least the lowest pending segment
+		stream.nextSeq = d.lowestPending(stream)
+		if err = d.deliverPending(t, stream); err != nil {
+			return
+		}
+	}
+
+	// trim data we have already seen
+	diff := int32(seq - stream.nextSeq)
+	if -int64(diff) >= int64(len(payload)) {
+		return
+	}
+	payload = payload[-diff:]
+	stream.nextSeq += uint32(len(payload))
+	if err = d.deliver(t, stream, payload); err != nil {
+		return
+	}
+	return d.deliverPending(t, stream)
+}
+
+// deliverPending delivers pending segments that follow without a gap.
+func (d *Decoder) deliverPending(t time.Time, stream *tcpStream) (err error) {
+	for len(stream.pending) > 0 {
+		found := false
+		for pendingSeq, pendingPayload := range stream.pending {
+			if int32(pendingSeq-stream.nextSeq) > 0 {
+				continue
+			}
+			delete(stream.pending, pendingSeq)
+			found = true
+			overlap := int64(stream.nextSeq - pendingSeq)
+			if overlap >= int64(len(pendingPayload)) {
+				break
+			}
+			pendingPayload = pendingPayload[overlap:]
+			stream.nextSeq += uint32(len(pendingPayload))
+			if err = d.deliver(t, stream, pendingPayload); err != nil {
+				return
+			}
+			break
+		}
+		if !found {
+			break
+		}
+	}
+	return
+}
+
+func (d *Decoder) lowestPending(stream *tcpStream) uint32 {
+	first := true
+	var lowest uint32
+	for seq := range stream.pending {
+		if first || int32(seq-lowest) < 0 {
+			lowest = seq
+			first = false
+		}
+	}
+	return lowest
+}
+
+func (d *Decoder) deliver(t time.Time, stream *tcpStream, data []byte) (err error) {
+	if stream.decoder == nil {
+		if stream.service == "" && len(stream.unidentified)+len(data) <= maxUnidentifiedStreamData {
+			stream.unidentified = append(stream.unidentified, data...)
+		}
+		return
+	}
+
+	stream.decoder.Write(data)
+	for {
+		msg, decodeErr := stream.decoder.Next()
+		if decodeErr == nil && msg == nil {
+			return
+		}
+		event := &Event{
+			Time:        t,
+			Source:      stream.src,
+			Destination: stream.dst,
+			Service:     stream.service,
+		}
+		if decodeErr != nil {
+			event.Error = decodeErr.Error()
+		} else {
+			event.Type = msg.Type
+			event.Message = msg.Message
+		}
+		if err = d.fn(event); err != nil {
+			return
+		}
+		if msg == nil {
+			continue
+		}
+		if service := msg.AnnouncedService(); service != nil && service.Name != ServiceMain {
+			if err = d.announce(t, netip.AddrPortFrom(stream.src.Addr(), service.Port), service.Name); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// captured by a Prime 4 announcing its main connection on port 0x8403
+var testDiscoveryBytes = []byte{
+	0x61, 0x69, 0x72, 0x44, 0xf4, 0x05, 0xdc, 0x14,
+	0x02, 0x23, 0x47, 0xf5, 0x8b, 0x79, 0x2c, 0x8c,
+	0x49, 0x33, 0x52, 0x76, 0x00, 0x00, 0x00, 0x0c,
+	0x00, 0x70, 0x00, 0x72, 0x00, 0x69, 0x00, 0x6d,
+	0x00, 0x65, 0x00, 0x34, 0x00, 0x00, 0x00, 0x22,
+	0x00, 0x44, 0x00, 0x49, 0x00, 0x53, 0x00, 0x43,
+	0x00, 0x4f, 0x00, 0x56, 0x00, 0x45, 0x00, 0x52,
+	0x00, 0x45, 0x00, 0x52, 0x00, 0x5f, 0x00, 0x48,
+	0x00, 0x4f, 0x00, 0x57, 0x00, 0x44, 0x00, 0x59,
+	0x00, 0x5f, 0x00, 0x00, 0x00, 0x08, 0x00, 0x4a,
+	0x00, 0x43, 0x00, 0x31, 0x00, 0x31, 0x00, 0x00,
+	0x00, 0x0a, 0x00, 0x31, 0x00, 0x2e, 0x00, 0x35,
+	0x00, 0x2e, 0x00, 0x32, 0x84, 0x03,
+}
+
+// announces StateMap on port 0xe196
+var testServiceAnnouncementBytes = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x52, 0x3e, 0x67, 0x9d,
+	0xa4, 0x18, 0x4d, 0x1e, 0x83, 0xd0, 0xc7, 0x52,
+	0xcf, 0xca, 0x8f, 0xf7, 0x00, 0x00, 0x00, 0x10,
+	0x00, 0x53, 0x00, 0x74, 0x00, 0x61, 0x00, 0x74,
+	0x00, 0x65, 0x00, 0x4d, 0x00, 0x61, 0x00, 0x70,
+	0xe1, 0x96,
+}
+
+var testStateEmitBytes = []byte{
+	0x00, 0x00, 0x00, 0x72, 0x73, 0x6d, 0x61, 0x61,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34,
+	0x00, 0x2f, 0x00, 0x43, 0x00, 0x6c, 0x00, 0x69,
+	0x00, 0x65, 0x00, 0x6e, 0x00, 0x74, 0x00, 0x2f,
+	0x00, 0x50, 0x00, 0x72, 0x00, 0x65, 0x00, 0x66,
+	0x00, 0x65, 0x00, 0x72, 0x00, 0x65, 0x00, 0x6e,
+	0x00, 0x63, 0x00, 0x65, 0x00, 0x73, 0x00, 0x2f,
+	0x00, 0x50, 0x00, 0x6c, 0x00, 0x61, 0x00, 0x79,
+	0x00, 0x65, 0x00, 0x72, 0x00, 0x00, 0x00, 0x2e,
+	0x00, 0x7b, 0x00, 0x22, 0x00, 0x73, 0x00, 0x74,
+	0x00, 0x72, 0x00, 0x69, 0x00, 0x6e, 0x00, 0x67,
+	0x00, 0x22, 0x00, 0x3a, 0x00, 0x22, 0x00, 0x31,
+	0x00, 0x22, 0x00, 0x2c, 0x00, 0x22, 0x00, 0x74,
+	0x00, 0x79, 0x00, 0x70, 0x00, 0x65, 0x00, 0x22,
+	0x00, 0x3a, 0x00, 0x34, 0x00, 0x7d,
+}
+
+var (
+	testDevice    = netip.MustParseAddr("10.0.0.2")
+	testClient    = netip.MustParseAddr("10.0.0.5")
+	testBroadcast = netip.MustParseAddr("10.0.0.255")
+)
+
+func buildFrame(protocol byte, src, dst netip.AddrPort, seq uint32, flags byte, payload []byte) []byte {
+	var transport []byte
+	switch protocol {
+	case ipProtocolTCP:
+		transport = make([]byte, 20)
+		binary.BigEndian.PutUint32(transport[4:], seq)
+		transport[12] = 5 << 4
+		transport[13] = flags
+	case ipProtocolUDP:
+		transport = make([]byte, 8)
+		binary.BigEndian.PutUint16(transport[4:], uint16(8+len(payload)))
+	}
+	binary.BigEndian.PutUint16(transport[0:], src.Port())
+	binary.BigEndian.PutUint16(transport[2:], dst.Port())
+	transport = append(transport, payload...)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(transport)))
+	ip[8] = 64
+	ip[9] = protocol
+	src4, dst4 := src.Addr().As4(), dst.Addr().As4()
+	copy(ip[12:], src4[:])
+	copy(ip[16:], dst4[:])
+
+	frame := make([]byte, 14)
+	binary.BigEndian.PutUint16(frame[12:], etherTypeIPv4)
+	frame = append(frame, ip...)
+	return append(frame, transport...)
+}
+
+func testFrames() [][]byte {
+	discoverySrc := netip.AddrPortFrom(testDevice, 51337)
+	discoveryDst := netip.AddrPortFrom(testBroadcast, 51337)
+	mainPort := netip.AddrPortFrom(testDevice, 0x8403)
+	mainClient := netip.AddrPortFrom(testClient, 40000)
+	stateMapPort := netip.AddrPortFrom(testDevice, 0xe196)
+	stateMapClient := netip.AddrPortFrom(testClient, 40001)
+
+	return [][]byte{
+		buildFrame(ipProtocolUDP, discoverySrc, discoveryDst, 0, 0, testDiscoveryBytes),
+		buildFrame(ipProtocolTCP, mainClient, mainPort, 99, tcpFlagSYN, nil),
+		buildFrame(ipProtocolTCP, mainPort, mainClient, 999, tcpFlagSYN, nil),
+		buildFrame(ipProtocolTCP, mainPort, mainClient, 1000, 0, testServiceAnnouncementBytes),
+		// StateMap data arriving out of order and with a retransmission
+		buildFrame(ipProtocolTCP, stateMapPort, stateMapClient, 5000, tcpFlagSYN, nil),
+		buildFrame(ipProtocolTCP, stateMapPort, stateMapClient, 5001+50, 0, testStateEmitBytes[50:]),
+		buildFrame(ipProtocolTCP, stateMapPort, stateMapClient, 5001, 0, testStateEmitBytes[:60]),
+		buildFrame(ipProtocolTCP, stateMapPort, stateMapClient, 5001, 0, testStateEmitBytes[:60]),
+		// followed by a second message in one go
+		buildFrame(ipProtocolTCP, stateMapPort, stateMapClient, 5001+uint32(len(testStateEmitBytes)), 0, testStateEmitBytes),
+	}
+}
+
+func writePcap(frames [][]byte) []byte {
+	buf := new(bytes.Buffer)
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], pcapMagicMicroseconds)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], 65535)
+	binary.LittleEndian.PutUint32(header[20:], uint32(LinkTypeEthernet))
+	buf.Write(header)
+	for i, frame := range frames {
+		record := make([]byte, 16)
+		binary.LittleEndian.PutUint32(record[0:], 1700000000)
+		binary.LittleEndian.PutUint32(record[4:], uint32(i))
+		binary.LittleEndian.PutUint32(record[8:], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(record[12:], uint32(len(frame)))
+		buf.Write(record)
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
+
+func writePcapngBlock(buf *bytes.Buffer, blockType uint32, body []byte) {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	length := uint32(12 + len(body))
+	binary.Write(buf, binary.BigEndian, blockType)
+	binary.Write(buf, binary.BigEndian, length)
+	buf.Write(body)
+	binary.Write(buf, binary.BigEndian, length)
+}
+
+func writePcapng(frames [][]byte) []byte {
+	buf := new(bytes.Buffer)
+
+	shb := make([]byte, 16)
+	binary.BigEndian.PutUint32(shb[0:], pcapngByteOrderMagic)
+	binary.BigEndian.PutUint16(shb[4:], 1)
+	binary.BigEndian.PutUint64(shb[8:], 0xffffffffffffffff)
+	writePcapngBlock(buf, pcapngSectionHeaderBlock, shb)
+
+	// nanosecond timestamps
+	idb := make([]byte, 8, 20)
+	binary.BigEndian.PutUint16(idb[0:], uint16(LinkTypeEthernet))
+	idb = append(idb, 0, pcapngOptionTsResol, 0, 1, 9, 0, 0, 0, 0, 0, 0, 0)
+	writePcapngBlock(buf, pcapngInterfaceDescriptionBlock, idb)
+
+	for i, frame := range frames {
+		ts := uint64(1700000000)*1e9 + uint64(i)*1000
+		epb := make([]byte, 20)
+		binary.BigEndian.PutUint32(epb[4:], uint32(ts>>32))
+		binary.BigEndian.PutUint32(epb[8:], uint32(ts))
+		binary.BigEndian.PutUint32(epb[12:], uint32(len(frame)))
+		binary.BigEndian.PutUint32(epb[16:], uint32(len(frame)))
+		writePcapngBlock(buf, pcapngEnhancedPacketBlock, append(epb, frame...))
+	}
+	return buf.Bytes()
+}
+
+func Test_Reader(t *testing.T) {
+	frames := testFrames()
+	for name, file := range map[string][]byte{
+		"pcap":   writePcap(frames),
+		"pcapng": writePcapng(frames),
+	} {
+		t.Run(name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(file))
+			require.NoError(t, err)
+			for i, frame := range frames {
+				p, err := r.Next()
+				require.NoError(t, err)
+				require.Equal(t, LinkTypeEthernet, p.LinkType)
+				require.Equal(t, frame, p.Data)
+				require.Equal(t, time.Unix(1700000000, int64(i)*1000), p.Time)
+			}
+			_, err = r.Next()
+			require.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func Test_Reader_Invalid(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("definitely not a capture file")))
+	require.ErrorIs(t, err, ErrInvalidCapture)
+
+	file := writePcap(testFrames())
+	r, err := NewReader(bytes.NewReader(file[:len(file)-1]))
+	require.NoError(t, err)
+	for err == nil {
+		_, err = r.Next()
+	}
+	require.ErrorIs(t, err, ErrInvalidCapture)
+}
+
+func Test_Decoder(t *testing.T) {
+	events := []*Event{}
+	d := NewDecoder(func(e *Event) error {
+		events = append(events, e)
+		return nil
+	})
+	require.NoError(t, d.Decode(bytes.NewReader(writePcapng(testFrames()))))
+
+	types := []string{}
+	for _, e := range events {
+		require.Empty(t, e.Error)
+		types = append(types, e.Service+"/"+e.Type)
+	}
+	require.Equal(t, []string{
+		"discovery/discovery",
+		"main/serviceAnnouncement",
+		"StateMap/stateEmit",
+		"StateMap/stateEmit",
+	}, types)
+	require.Equal(t, netip.AddrPortFrom(testDevice, 0xe196), events[2].Source)
+}
+
+func Test_Decoder_AddService(t *testing.T) {
+	// without discovery the main connection can't be identified on its own
+	frames := testFrames()[1:4]
+
+	count := 0
+	d := NewDecoder(func(e *Event) error {
+		count++
+		return nil
+	})
+	require.NoError(t, d.Decode(bytes.NewReader(writePcap(frames))))
+	require.Zero(t, count)
+
+	d = NewDecoder(func(e *Event) error {
+		count++
+		require.Equal(t, "serviceAnnouncement", e.Type)
+		return nil
+	})
+	d.AddService(0x8403, ServiceMain)
+	require.NoError(t, d.Decode(bytes.NewReader(writePcap(frames))))
+	require.Equal(t, 1, count)
+}
+
+func Test_Decoder_GiveUpOnGap(t *testing.T) {
+	stateMapPort := netip.AddrPortFrom(testDevice, 0xe196)
+	stateMapClient := netip.AddrPortFrom(testClient, 40001)
+
+	// the first segment after the handshake is lost, the following ones
+	// carry one message each
+	frames := [][]byte{
+		buildFrame(ipProtocolTCP, stateMapPort, stateMapClient, 100, tcpFlagSYN, nil),
+	}
+	seq := uint32(200)
+	for range maxPendingSegments + 1 {
+		frames = append(frames, buildFrame(ipProtocolTCP, stateMapPort, stateMapClient, seq, 0, testStateEmitBytes))
+		seq += uint32(len(testStateEmitBytes))
+	}
+
+	count := 0
+	d := NewDecoder(func(e *Event) error {
+		require.Empty(t, e.Error)
+		count++
+		return nil
+	})
+	d.AddService(0xe196, "StateMap")
+	require.NoError(t, d.Decode(bytes.NewReader(writePcap(frames))))
+	// messages are delivered in order once too many segments are pending
+	require.Equal(t, maxPendingSegments+1, count)
+}
@@ -0,0 +1,6 @@
+/*
+This package reads pcap and pcapng capture files, for example as saved by
+Wireshark or tcpdump, and decodes the StagelinQ and EAAS discovery traffic in
+them without depending on libpcap.
+*/
+package pcap
@@ -0,0 +1,178 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+
+	ipProtocolTCP = 6
+	ipProtocolUDP = 17
+
+	tcpFlagFIN = 0x01
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+)
+
+// segment is the transport layer part of a captured packet.
+type segment struct {
+	protocol byte
+	src, dst netip.AddrPort
+
+	// TCP only
+	seq   uint32
+	flags byte
+
+	payload []byte
+}
+
+// decodePacket extracts the TCP or UDP segment from a captured packet.
+// Packets of other protocols, fragments and malformed packets return false.
+func decodePacket(linkType LinkType, data []byte) (s *segment, ok bool) {
+	ip, ok := decodeLinkLayer(linkType, data)
+	if !ok {
+		return
+	}
+	return decodeIP(ip)
+}
+
+func decodeLinkLayer(linkType LinkType, data []byte) (ip []byte, ok bool) {
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return
+		}
+		etherType := binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(data) < 4 {
+				return
+			}
+			etherType = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+		if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+			return
+		}
+		return data, true
+	case LinkTypeNull, LinkTypeLoop:
+		// 4 bytes of address family in an unknown byte order
+		if len(data) < 4 {
+			return
+		}
+		return data[4:], true
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return
+		}
+		return data[16:], true
+	case LinkTypeLinuxSLL2:
+		if len(data) < 20 {
+			return
+		}
+		return data[20:], true
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+		return data, true
+	}
+	return
+}
+
+func decodeIP(data []byte) (s *segment, ok bool) {
+	if len(data) < 1 {
+		return
+	}
+	var protocol byte
+	var src, dst netip.Addr
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < 20 {
+			return
+		}
+		headerLength := int(data[0]&0x0f) * 4
+		totalLength := int(binary.BigEndian.Uint16(data[2:]))
+		if headerLength < 20 || totalLength < headerLength || len(data) < headerLength {
+			return
+		}
+		// only unfragmented packets are supported
+		if fragment := binary.BigEndian.Uint16(data[6:]); fragment&0x3fff != 0 {
+			return
+		}
+		protocol = data[9]
+		src = netip.AddrFrom4([4]byte(data[12:16]))
+		dst = netip.AddrFrom4([4]byte(data[16:20]))
+		if totalLength < len(data) {
+			// strip ethernet padding
+			data = data[:totalLength]
+		}
+		data = data[headerLength:]
+	case 6:
+		if len(data) < 40 {
+			return
+		}
+		payloadLength := int(binary.BigEndian.Uint16(data[4:]))
+		protocol = data[6]
+		src = netip.AddrFrom16([16]byte(data[8:24]))
+		dst = netip.AddrFrom16([16]byte(data[24:40]))
+		data = data[40:]
+		if payloadLength < len(data) {
+			data = data[:payloadLength]
+		}
+		// skip extension headers we know how to skip
+		for protocol == 0 || protocol == 43 || protocol == 60 {
+			if len(data) < 8 {
+				return
+			}
+			length := (int(data[1]) + 1) * 8
+			if len(data) < length {
+				return
+			}
+			protocol = data[0]
+			data = data[length:]
+		}
+	default:
+		return
+	}
+
+	switch protocol {
+	case ipProtocolTCP:
+		if len(data) < 20 {
+			return
+		}
+		headerLength := int(data[12]>>4) * 4
+		if headerLength < 20 || len(data) < headerLength {
+			return
+		}
+		s = &segment{
+			protocol: protocol,
+			src:      netip.AddrPortFrom(src, binary.BigEndian.Uint16(data[0:])),
+			dst:      netip.AddrPortFrom(dst, binary.BigEndian.Uint16(data[2:])),
+			seq:      binary.BigEndian.Uint32(data[4:]),
+			flags:    data[13],
+			payload:  data[headerLength:],
+		}
+	case ipProtocolUDP:
+		if len(data) < 8 {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(data[4:]))
+		payload := data[8:]
+		if length >= 8 && length-8 < len(payload) {
+			payload = payload[:length-8]
+		}
+		s = &segment{
+			protocol: protocol,
+			src:      netip.AddrPortFrom(src, binary.BigEndian.Uint16(data[0:])),
+			dst:      netip.AddrPortFrom(dst, binary.BigEndian.Uint16(data[2:])),
+			payload:  payload,
+		}
+	default:
+		return
+	}
+	ok = true
+	return
+}
@@ -0,0 +1,365 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ErrInvalidCapture is returned when reading data that is neither a pcap nor a
+// pcapng file or that is corrupt.
+var ErrInvalidCapture = errors.New("invalid capture file")
+
+// maxPacketSize bounds the size of single packets and pcapng blocks so that a
+// corrupt file can't make us allocate huge buffers.
+const maxPacketSize = 16 << 20
+
+// LinkType identifies the link layer header of captured packets.
+// See https://www.tcpdump.org/linktypes.html.
+type LinkType uint16
+
+const (
+	LinkTypeNull      LinkType = 0
+	LinkTypeEthernet  LinkType = 1
+	LinkTypeRaw       LinkType = 101
+	LinkTypeLoop      LinkType = 108
+	LinkTypeLinuxSLL  LinkType = 113
+	LinkTypeIPv4      LinkType = 228
+	LinkTypeIPv6      LinkType = 229
+	LinkTypeLinuxSLL2 LinkType = 276
+)
+
+// Packet is a single packet read from a capture file.
+type Packet struct {
+	Time     time.Time
+	LinkType LinkType
+	Data     []byte
+}
+
+const (
+	pcapMagicMicroseconds = 0xa1b2c3d4
+	pcapMagicNanoseconds  = 0xa1b23c4d
+
+	pcapngSectionHeaderBlock        = 0x0a0d0d0a
+	pcapngInterfaceDescriptionBlock = 0x00000001
+	pcapngPacketBlock               = 0x00000002
+	pcapngSimplePacketBlock         = 0x00000003
+	pcapngEnhancedPacketBlock       = 0x00000006
+	pcapngByteOrderMagic            = 0x1a2b3c4d
+
+	pcapngOptionEndOfOptions = 0
+	pcapngOptionTsResol      = 9
+)
+
+type pcapngInterface struct {
+	linkType LinkType
+	// units per second of timestamps
+	resolution uint64
+}
+
+// Reader reads packets from a pcap or pcapng file.
+type Reader struct {
+	r         *bufio.Reader
+	byteOrder binary.ByteOrder
+
+	// pcap
+	ng         bool
+	linkType   LinkType
+	nanosecond bool
+
+	// pcapng
+	interfaces []pcapngInterface
+}
+
+// NewReader detects the format of the capture file read from r and returns a
+// Reader for its packets. Both the classic pcap format and pcapng are
+// supported.
+func NewReader(r io.Reader) (reader *Reader, err error) {
+	reader = &Reader{
+		r: bufio.NewReader(r),
+	}
+	magic, err := reader.r.Peek(4)
+	if err != nil {
+		reader = nil
+		err = fmt.Errorf("%w: %w", ErrInvalidCapture, err)
+		return
+	}
+	if binary.BigEndian.Uint32(magic) == pcapngSectionHeaderBlock {
+		reader.ng = true
+		err = reader.readSectionHeader()
+	} else {
+		err = reader.readPcapHeader()
+	}
+	if err != nil {
+		reader = nil
+	}
+	return
+}
+
+func (r *Reader) readPcapHeader() (err error) {
+	header := make([]byte, 24)
+	if _, err = io.ReadFull(r.r, header); err != nil {
+		return r.truncated(err)
+	}
+	switch {
+	case binary.LittleEndian.Uint32(header) == pcapMagicMicroseconds:
+		r.byteOrder = binary.LittleEndian
+	case binary.BigEndian.Uint32(header) == pcapMagicMicroseconds:
+		r.byteOrder = binary.BigEndian
+	case binary.LittleEndian.Uint32(header) == pcapMagicNanoseconds:
+		r.byteOrder = binary.LittleEndian
+		r.nanosecond = true
+	case binary.BigEndian.Uint32(header) == pcapMagicNanoseconds:
+		r.byteOrder = binary.BigEndian
+		r.nanosecond = true
+	default:
+		return fmt.Errorf("%w: unknown magic bytes", ErrInvalidCapture)
+	}
+	// the upper bits of the link type field may contain FCS information
+	r.linkType = LinkType(r.byteOrder.Uint32(header[20:]) & 0xffff)
+	return
+}
+
+func (r *Reader) readSectionHeader() (err error) {
+	// block type, block length and byte order magic
+	header := make([]byte, 12)
+	if _, err = io.ReadFull(r.r, header); err != nil {
+		return r.truncated(err)
+	}
+	switch {
+	case binary.LittleEndian.Uint32(header[8:]) == pcapngByteOrderMagic:
+		r.byteOrder = binary.LittleEndian
+	case binary.BigEndian.Uint32(header[8:]) == pcapngByteOrderMagic:
+		r.byteOrder = binary.BigEndian
+	default:
+		return fmt.Errorf("%w: unknown byte order magic", ErrInvalidCapture)
+	}
+	length := r.byteOrder.Uint32(header[4:])
+	if length < 28 || length%4 != 0 || length > maxPacketSize {
+		return fmt.Errorf("%w: bad section header length %d", ErrInvalidCapture, length)
+	}
+	// skip version, section length, options and trailing block length
+	if _, err = r.r.Discard(int(length) - len(header)); err != nil {
+		return r.truncated(err)
+	}
+	// interface IDs are local to the section
+	r.interfaces = nil
+	return
+}
+
+func (r *Reader) truncated(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("%w: %w", ErrInvalidCapture, err)
+}
+
+// Next returns the next packet of the capture. At the end of the file, io.EOF
+// is returned.
+func (r *Reader) Next() (*Packet, error) {
+	if r.ng {
+		return r.nextBlock()
+	}
+	return r.nextRecord()
+}
+
+func (r *Reader) nextRecord() (p *Packet, err error) {
+	header := make([]byte, 16)
+	if _, err = io.ReadFull(r.r, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		return nil, r.truncated(err)
+	}
+	sec := r.byteOrder.Uint32(header[0:])
+	frac := r.byteOrder.Uint32(header[4:])
+	length := r.byteOrder.Uint32(header[8:])
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("%w: packet of %d bytes is too large", ErrInvalidCapture, length)
+	}
+	data := make([]byte, length)
+	if _, err = io.ReadFull(r.r, data); err != nil {
+		return nil, r.truncated(err)
+	}
+	nsec := int64(frac) * 1000
+	if r.nanosecond {
+		nsec = int64(frac)
+	}
+	p = &Packet{
+		Time:     time.Unix(int64(sec), nsec),
+		LinkType: r.linkType,
+		Data:     data,
+	}
+	return
+}
+
+func (r *Reader) nextBlock() (p *Packet, err error) {
+	for p == nil {
+		var header []byte
+		if header, err = r.r.Peek(8); err != nil {
+			if errors.Is(err, io.EOF) && len(header) == 0 {
+				return
+			}
+			return nil, r.truncated(err)
+		}
+		if binary.BigEndian.Uint32(header) == pcapngSectionHeaderBlock {
+			if err = r.readSectionHeader(); err != nil {
+				return
+			}
+			continue
+		}
+
+		blockType := r.byteOrder.Uint32(header)
+		length := r.byteOrder.Uint32(header[4:])
+		if length < 12 || length%4 != 0 || length > maxPacketSize {
+			return nil, fmt.Errorf("%w: bad block length %d", ErrInvalidCapture, length)
+		}
+		block := make([]byte, length)
+		if _, err = io.ReadFull(r.r, block); err != nil {
+			return nil, r.truncated(err)
+		}
+		body := block[8 : length-4]
+
+		switch blockType {
+		case pcapngInterfaceDescriptionBlock:
+			err = r.readInterfaceDescription(body)
+		case pcapngEnhancedPacketBlock:
+			p, err = r.readEnhancedPacket(body)
+		case pcapngPacketBlock:
+			p, err = r.readObsoletePacket(body)
+		case pcapngSimplePacketBlock:
+			p, err = r.readSimplePacket(body)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+func (r *Reader) readInterfaceDescription(body []byte) error {
+	if len(body) < 8 {
+		return fmt.Errorf("%w: interface description block too short", ErrInvalidCapture)
+	}
+	iface := pcapngInterface{
+		linkType:   LinkType(r.byteOrder.Uint16(body)),
+		resolution: 1e6,
+	}
+	options := body[8:]
+	for len(options) >= 4 {
+		code := r.byteOrder.Uint16(options)
+		length := int(r.byteOrder.Uint16(options[2:]))
+		options = options[4:]
+		if code == pcapngOptionEndOfOptions || length > len(options) {
+			break
+		}
+		if code == pcapngOptionTsResol && length >= 1 {
+			iface.resolution = tsResolution(options[0])
+		}
+		padded := (length + 3) &^ 3
+		if padded > len(options) {
+			break
+		}
+		options = options[padded:]
+	}
+	r.interfaces = append(r.interfaces, iface)
+	return nil
+}
+
+// tsResolution converts the value of the if_tsresol option into timestamp
+// units per second.
+func tsResolution(v byte) uint64 {
+	exp := uint64(v & 0x7f)
+	if v&0x80 != 0 {
+		if exp > 63 {
+			exp = 63
+		}
+		return 1 << exp
+	}
+	res := uint64(1)
+	for i := uint64(0); i < exp && res <= math.MaxUint64/10; i++ {
+		res *= 10
+	}
+	return res
+}
+
+func (r *Reader) iface(id uint32) (*pcapngInterface, error) {
+	if int(id) >= len(r.interfaces) {
+		return nil, fmt.Errorf("%w: packet refers to unknown interface %d", ErrInvalidCapture, id)
+	}
+	return &r.interfaces[id], nil
+}
+
+func (r *Reader) packet(iface *pcapngInterface, tsHigh, tsLow uint32, data []byte) *Packet {
+	ts := uint64(tsHigh)<<32 | uint64(tsLow)
+	sec := ts / iface.resolution
+	frac := ts % iface.resolution
+	var nsec uint64
+	if iface.resolution > 1e9 {
+		nsec = frac / (iface.resolution / 1e9)
+	} else {
+		nsec = frac * 1e9 / iface.resolution
+	}
+	return &Packet{
+		Time:     time.Unix(int64(sec), int64(nsec)),
+		LinkType: iface.linkType,
+		Data:     data,
+	}
+}
+
+func (r *Reader) readEnhancedPacket(body []byte) (p *Packet, err error) {
+	if len(body) < 20 {
+		return nil, fmt.Errorf("%w: enhanced packet block too short", ErrInvalidCapture)
+	}
+	iface, err := r.iface(r.byteOrder.Uint32(body))
+	if err != nil {
+		return
+	}
+	length := r.byteOrder.Uint32(body[12:])
+	if uint64(length) > uint64(len(body)-20) {
+		return nil, fmt.Errorf("%w: enhanced packet block too short", ErrInvalidCapture)
+	}
+	p = r.packet(iface, r.byteOrder.Uint32(body[4:]), r.byteOrder.Uint32(body[8:]), body[20:20+length])
+	return
+}
+
+func (r *Reader) readObsoletePacket(body []byte) (p *Packet, err error) {
+	if len(body) < 20 {
+		return nil, fmt.Errorf("%w: packet block too short", ErrInvalidCapture)
+	}
+	iface, err := r.iface(uint32(r.byteOrder.Uint16(body)))
+	if err != nil {
+		return
+	}
+	length := r.byteOrder.Uint32(body[12:])
+	if uint64(length) > uint64(len(body)-20) {
+		return nil, fmt.Errorf("%w: packet block too short", ErrInvalidCapture)
+	}
+	p = r.packet(iface, r.byteOrder.Uint32(body[4:]), r.byteOrder.Uint32(body[8:]), body[20:20+length])
+	return
+}
+
+func (r *Reader) readSimplePacket(body []byte) (p *Packet, err error) {
+	if len(body) < 4 {
+		return nil, fmt.Errorf("%w: simple packet block too short", ErrInvalidCapture)
+	}
+	iface, err := r.iface(0)
+	if err != nil {
+		return
+	}
+	length := r.byteOrder.Uint32(body)
+	if uint64(length) > uint64(len(body)-4) {
+		// the packet has been truncated to the snapshot length
+		length = uint32(len(body) - 4)
+	}
+	// simple packet blocks carry no timestamp
+	p = &Packet{
+		LinkType: iface.linkType,
+		Data:     body[4 : 4+length],
+	}
+	return
+}
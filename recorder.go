@@ -0,0 +1,218 @@
+package stagelinq
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/icedream/go-stagelinq/internal/messages"
+)
+
+// Recorder writes StagelinQ traffic to a recording that can later be decoded
+// with DecodeRecording or played back to applications using a Replayer.
+//
+// Pass it to connections using WithRecorder and to listeners using
+// ListenerConfiguration.Recorder. Raw bytes are recorded for every connection
+// alongside the messages decoded from them, so recordings stay useful even if
+// a device sends something the library does not understand yet.
+//
+// A Recorder is safe for concurrent use.
+type Recorder struct {
+	lock       sync.Mutex
+	w          io.Writer
+	start      time.Time
+	nextStream uint64
+	err        error
+}
+
+// NewRecorder creates a recorder writing to w and writes the recording header.
+func NewRecorder(w io.Writer) (rec *Recorder, err error) {
+	start := time.Now()
+	if err = writeRecordingHeader(w, start); err != nil {
+		return
+	}
+	rec = &Recorder{
+		w:     w,
+		start: start,
+	}
+	return
+}
+
+// Close stops recording and returns the first error that occurred while
+// writing the recording, if any. If the underlying writer implements
+// io.Closer, it is closed as well.
+func (rec *Recorder) Close() error {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	if closer, ok := rec.w.(io.Closer); ok {
+		if err := closer.Close(); err != nil && rec.err == nil {
+			rec.err = err
+		}
+	}
+	rec.w = nil
+	return rec.err
+}
+
+// WrapConn returns a connection that records all bytes read from and written
+// to conn. service names the StagelinQ service the connection talks to, for
+// example "StateMap", or "main" for the main connection of a device.
+func (rec *Recorder) WrapConn(conn net.Conn, service string) net.Conn {
+	return &recordingConn{
+		Conn:   conn,
+		stream: rec.openStream(service, conn.LocalAddr(), conn.RemoteAddr()),
+	}
+}
+
+// WrapPacketConn returns a packet connection that records all datagrams read
+// from and written to pc as discovery traffic.
+func (rec *Recorder) WrapPacketConn(pc net.PacketConn) net.PacketConn {
+	return &recordingPacketConn{
+		PacketConn: pc,
+		stream:     rec.openStream("discovery", pc.LocalAddr(), nil),
+	}
+}
+
+func (rec *Recorder) write(r *Record) {
+	r.Time = time.Now()
+
+	buf := new(bytes.Buffer)
+	r.writeTo(buf, rec.start)
+
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	if rec.w == nil || rec.err != nil {
+		return
+	}
+	_, rec.err = rec.w.Write(buf.Bytes())
+}
+
+func (rec *Recorder) openStream(service string, localAddr, remoteAddr net.Addr) *recordingStream {
+	rec.lock.Lock()
+	rec.nextStream++
+	id := rec.nextStream
+	rec.lock.Unlock()
+
+	rec.write(&Record{
+		Kind:       RecordStreamOpened,
+		Stream:     id,
+		Service:    service,
+		LocalAddr:  addrString(localAddr),
+		RemoteAddr: addrString(remoteAddr),
+	})
+	return &recordingStream{
+		recorder: rec,
+		id:       id,
+	}
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// recordingStream writes the records of a single connection or socket.
+type recordingStream struct {
+	recorder  *Recorder
+	id        uint64
+	closeOnce sync.Once
+}
+
+func (s *recordingStream) data(dir Direction, b []byte) {
+	s.recorder.write(&Record{
+		Kind:      RecordData,
+		Stream:    s.id,
+		Direction: dir,
+		Data:      b,
+	})
+}
+
+func (s *recordingStream) message(dir Direction, msg messages.Message) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		// message values are plain structs, this should not happen
+		return
+	}
+	s.recorder.write(&Record{
+		Kind:        RecordMessage,
+		Stream:      s.id,
+		Direction:   dir,
+		MessageType: messageTypeName(msg),
+		Data:        b,
+	})
+}
+
+func (s *recordingStream) datagram(dir Direction, addr net.Addr, b []byte) {
+	s.recorder.write(&Record{
+		Kind:       RecordDatagram,
+		Stream:     s.id,
+		Direction:  dir,
+		RemoteAddr: addrString(addr),
+		Data:       b,
+	})
+}
+
+func (s *recordingStream) close() {
+	s.closeOnce.Do(func() {
+		s.recorder.write(&Record{
+			Kind:   RecordStreamClosed,
+			Stream: s.id,
+		})
+	})
+}
+
+type recordingConn struct {
+	net.Conn
+	stream *recordingStream
+}
+
+func (c *recordingConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	if n > 0 {
+		c.stream.data(DirectionReceived, b[:n])
+	}
+	return
+}
+
+func (c *recordingConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	if n > 0 {
+		c.stream.data(DirectionSent, b[:n])
+	}
+	return
+}
+
+func (c *recordingConn) Close() error {
+	c.stream.close()
+	return c.Conn.Close()
+}
+
+type recordingPacketConn struct {
+	net.PacketConn
+	stream *recordingStream
+}
+
+func (c *recordingPacketConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = c.PacketConn.ReadFrom(b)
+	if n > 0 {
+		c.stream.datagram(DirectionReceived, addr, b[:n])
+	}
+	return
+}
+
+func (c *recordingPacketConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	n, err = c.PacketConn.WriteTo(b, addr)
+	if n > 0 {
+		c.stream.datagram(DirectionSent, addr, b[:n])
+	}
+	return
+}
+
+func (c *recordingPacketConn) Close() error {
+	c.stream.close()
+	return c.PacketConn.Close()
+}
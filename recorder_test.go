@@ -0,0 +1,137 @@
+package stagelinq
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testMessageBytes(t *testing.T, name string) []byte {
+	for _, test := range testMessages {
+		if test.Name == name {
+			return test.Bytes
+		}
+	}
+	t.Fatalf("no test message named %q", name)
+	return nil
+}
+
+func Test_Recorder_RoundTrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rec, err := NewRecorder(buf)
+	require.NoError(t, err)
+
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		_, _ = server.Write(testMessageBytes(t, "State emit"))
+		_, _ = server.Read(make([]byte, 1024))
+	}()
+
+	msgConn := newMessageConnection(client, stateMapConnectionMessageSet, WithRecorder(rec))
+	msg, err := msgConn.ReadMessage()
+	require.NoError(t, err)
+	require.IsType(t, &stateEmitMessage{}, msg)
+	require.NoError(t, msgConn.WriteMessage(&stateSubscribeMessage{Name: ClientPreferencesPlayer}))
+	require.NoError(t, msgConn.conn.Close())
+	require.NoError(t, rec.Close())
+
+	kinds := []RecordKind{}
+	decoded := map[Direction][]string{}
+	require.NoError(t, DecodeRecording(bytes.NewReader(buf.Bytes()), func(r *Record, m *DecodedMessage) error {
+		kinds = append(kinds, r.Kind)
+		if r.Kind == RecordStreamOpened {
+			require.Equal(t, "StateMap", r.Service)
+		}
+		if m != nil {
+			decoded[r.Direction] = append(decoded[r.Direction], m.Type)
+		}
+		return nil
+	}))
+
+	require.Equal(t, RecordStreamOpened, kinds[0])
+	require.Equal(t, RecordStreamClosed, kinds[len(kinds)-1])
+	// message records repeat the decoded data and are left out
+	require.NotContains(t, kinds, RecordMessage)
+	require.Equal(t, []string{"stateEmit"}, decoded[DirectionReceived])
+	require.Equal(t, []string{"stateSubscribe"}, decoded[DirectionSent])
+}
+
+func Test_RecordingReader_Invalid(t *testing.T) {
+	_, err := NewRecordingReader(bytes.NewReader([]byte("not a recording")))
+	require.ErrorIs(t, err, ErrInvalidRecording)
+
+	buf := new(bytes.Buffer)
+	rec, err := NewRecorder(buf)
+	require.NoError(t, err)
+	rec.openStream("main", nil, nil)
+	require.NoError(t, rec.Close())
+
+	rr, err := NewRecordingReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	require.NoError(t, err)
+	_, err = rr.Next()
+	require.ErrorIs(t, err, ErrInvalidRecording)
+}
+
+func Test_StreamDecoder_Partial(t *testing.T) {
+	d := NewStreamDecoder("StateMap")
+	require.NotNil(t, d)
+
+	b := append(testMessageBytes(t, "State emit"), testMessageBytes(t, "State subscribe")...)
+	types := []string{}
+	for i := range b {
+		d.Write(b[i : i+1])
+		for {
+			m, err := d.Next()
+			require.NoError(t, err)
+			if m == nil {
+				break
+			}
+			types = append(types, m.Type)
+		}
+	}
+	require.Equal(t, []string{"stateEmit", "stateSubscribe"}, types)
+	require.Zero(t, d.Buffered())
+}
+
+func Test_StreamDecoder_Invalid(t *testing.T) {
+	d := NewStreamDecoder("StateMap")
+	d.Write([]byte{0x00, 0x00, 0x00, 0x08, 'n', 'o', 'p', 'e', 0, 0, 0, 0})
+	_, err := d.Next()
+	require.Error(t, err)
+	require.Zero(t, d.Buffered())
+}
+
+func Test_DecodeDatagram(t *testing.T) {
+	m, err := DecodeDatagram(testMessageBytes(t, "Discovery"))
+	require.NoError(t, err)
+	require.Equal(t, "discovery", m.Type)
+
+	_, err = DecodeDatagram([]byte("EAAS"))
+	require.ErrorIs(t, err, ErrInvalidMessageReceived)
+}
+
+func Test_NewReplayer(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rec, err := NewRecorder(buf)
+	require.NoError(t, err)
+	rec.openStream("discovery", nil, nil).datagram(DirectionReceived, nil, testMessageBytes(t, "Discovery"))
+	rec.openStream("StateMap", nil, nil).data(DirectionReceived, testMessageBytes(t, "State emit"))
+	reconnected := rec.openStream("StateMap", nil, nil)
+	reconnected.data(DirectionReceived, testMessageBytes(t, "State emit"))
+	reconnected.data(DirectionSent, testMessageBytes(t, "State subscribe"))
+	reconnected.data(DirectionReceived, testMessageBytes(t, "State emit"))
+	require.NoError(t, rec.Close())
+
+	replayer, err := NewReplayer(bytes.NewReader(buf.Bytes()), nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"StateMap"}, replayer.serviceNames)
+	require.Len(t, replayer.services["StateMap"], 2)
+	require.Len(t, replayer.services["StateMap"][0], 1)
+	require.Len(t, replayer.services["StateMap"][1], 2)
+
+	_, err = NewReplayer(bytes.NewReader(buf.Bytes()[:len(recordingMagic)+9]), nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,330 @@
+package stagelinq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A recording starts with recordingMagic, followed by a version byte and the
+// recording start time as big-endian Unix nanoseconds. After that, records
+// follow back to back. Each record starts with its kind byte, the stream ID and
+// the time offset in nanoseconds since the start of the recording, the latter
+// two as unsigned varints. What follows depends on the kind of record:
+//
+//   - RecordStreamOpened: service name, local address, remote address
+//   - RecordData: direction byte, payload
+//   - RecordMessage: direction byte, message type, JSON-encoded message
+//   - RecordStreamClosed: nothing
+//   - RecordDatagram: direction byte, peer address, payload
+//
+// Strings and payloads are prefixed with their length as unsigned varint.
+var recordingMagic = []byte("SLQR")
+
+const recordingVersion byte = 1
+
+// maxRecordFieldSize bounds single strings and payloads when reading a
+// recording so that a corrupt file can't make us allocate huge buffers.
+const maxRecordFieldSize = 64 << 20
+
+// ErrInvalidRecording is returned when reading data that is not a recording
+// made by Recorder or that uses an unsupported version of the format.
+var ErrInvalidRecording = errors.New("invalid recording")
+
+// RecordKind identifies what a Record describes.
+type RecordKind byte
+
+const (
+	// RecordStreamOpened marks a new TCP connection or discovery socket being
+	// recorded.
+	RecordStreamOpened RecordKind = iota + 1
+
+	// RecordData contains raw bytes sent or received on a stream.
+	RecordData
+
+	// RecordMessage contains a message decoded from a stream, encoded as JSON.
+	RecordMessage
+
+	// RecordStreamClosed marks the end of a stream.
+	RecordStreamClosed
+
+	// RecordDatagram contains a raw datagram sent or received on a discovery
+	// socket.
+	RecordDatagram
+)
+
+func (k RecordKind) String() string {
+	switch k {
+	case RecordStreamOpened:
+		return "open"
+	case RecordData:
+		return "data"
+	case RecordMessage:
+		return "message"
+	case RecordStreamClosed:
+		return "close"
+	case RecordDatagram:
+		return "datagram"
+	default:
+		return fmt.Sprintf("RecordKind(%d)", byte(k))
+	}
+}
+
+// Direction tells whether recorded traffic was sent or received by us.
+type Direction byte
+
+const (
+	// DirectionReceived marks traffic received from a device.
+	DirectionReceived Direction = iota
+
+	// DirectionSent marks traffic sent to a device.
+	DirectionSent
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionReceived:
+		return "recv"
+	case DirectionSent:
+		return "send"
+	default:
+		return fmt.Sprintf("Direction(%d)", byte(d))
+	}
+}
+
+// Record is a single entry of a recording.
+type Record struct {
+	Kind RecordKind
+
+	// Stream identifies the connection or socket the record belongs to.
+	Stream uint64
+
+	// Time is when the record was captured.
+	Time time.Time
+
+	// Direction is set for data, message and datagram records.
+	Direction Direction
+
+	// Service is set for stream opened records. It is the StagelinQ service
+	// name, "main" for main connections or "discovery" for discovery sockets.
+	Service string
+
+	// LocalAddr is set for stream opened records.
+	LocalAddr string
+
+	// RemoteAddr is set for stream opened records and contains the peer
+	// address for datagram records.
+	RemoteAddr string
+
+	// MessageType is set for message records, for example "stateEmit".
+	MessageType string
+
+	// Data contains the raw bytes of data and datagram records and the JSON
+	// encoded message of message records.
+	Data []byte
+}
+
+func (rec *Record) writeTo(w *bytes.Buffer, start time.Time) {
+	w.WriteByte(byte(rec.Kind))
+	writeUvarint(w, rec.Stream)
+	offset := rec.Time.Sub(start)
+	if offset < 0 {
+		offset = 0
+	}
+	writeUvarint(w, uint64(offset))
+	switch rec.Kind {
+	case RecordStreamOpened:
+		writeRecordString(w, rec.Service)
+		writeRecordString(w, rec.LocalAddr)
+		writeRecordString(w, rec.RemoteAddr)
+	case RecordData:
+		w.WriteByte(byte(rec.Direction))
+		writeRecordBytes(w, rec.Data)
+	case RecordMessage:
+		w.WriteByte(byte(rec.Direction))
+		writeRecordString(w, rec.MessageType)
+		writeRecordBytes(w, rec.Data)
+	case RecordDatagram:
+		w.WriteByte(byte(rec.Direction))
+		writeRecordString(w, rec.RemoteAddr)
+		writeRecordBytes(w, rec.Data)
+	}
+}
+
+func writeUvarint(w *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func writeRecordBytes(w *bytes.Buffer, b []byte) {
+	writeUvarint(w, uint64(len(b)))
+	w.Write(b)
+}
+
+func writeRecordString(w *bytes.Buffer, s string) {
+	writeUvarint(w, uint64(len(s)))
+	w.WriteString(s)
+}
+
+func writeRecordingHeader(w io.Writer, start time.Time) (err error) {
+	buf := new(bytes.Buffer)
+	buf.Write(recordingMagic)
+	buf.WriteByte(recordingVersion)
+	if err = binary.Write(buf, binary.BigEndian, start.UnixNano()); err != nil {
+		return
+	}
+	_, err = w.Write(buf.Bytes())
+	return
+}
+
+// RecordingReader reads records from a recording made by Recorder.
+type RecordingReader struct {
+	r     *bufio.Reader
+	start time.Time
+}
+
+// NewRecordingReader reads the recording header from r and returns a reader
+// for the records that follow.
+func NewRecordingReader(r io.Reader) (rr *RecordingReader, err error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(recordingMagic)+1)
+	if _, err = io.ReadFull(br, header); err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidRecording, err)
+		return
+	}
+	if !bytes.Equal(header[:len(recordingMagic)], recordingMagic) {
+		err = fmt.Errorf("%w: bad magic bytes", ErrInvalidRecording)
+		return
+	}
+	if version := header[len(recordingMagic)]; version != recordingVersion {
+		err = fmt.Errorf("%w: unsupported version %d", ErrInvalidRecording, version)
+		return
+	}
+
+	var startNanos int64
+	if err = binary.Read(br, binary.BigEndian, &startNanos); err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidRecording, err)
+		return
+	}
+
+	rr = &RecordingReader{
+		r:     br,
+		start: time.Unix(0, startNanos),
+	}
+	return
+}
+
+// Start returns the time the recording was started.
+func (rr *RecordingReader) Start() time.Time {
+	return rr.start
+}
+
+// Next returns the next record. At the end of the recording, io.EOF is
+// returned.
+func (rr *RecordingReader) Next() (rec *Record, err error) {
+	kind, err := rr.r.ReadByte()
+	if err != nil {
+		return
+	}
+
+	r := &Record{
+		Kind: RecordKind(kind),
+	}
+	if r.Stream, err = binary.ReadUvarint(rr.r); err != nil {
+		return nil, rr.truncated(err)
+	}
+	offset, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return nil, rr.truncated(err)
+	}
+	r.Time = rr.start.Add(time.Duration(offset))
+
+	switch r.Kind {
+	case RecordStreamOpened:
+		if r.Service, err = rr.readString(); err != nil {
+			return
+		}
+		if r.LocalAddr, err = rr.readString(); err != nil {
+			return
+		}
+		if r.RemoteAddr, err = rr.readString(); err != nil {
+			return
+		}
+	case RecordData:
+		if err = rr.readDirection(r); err != nil {
+			return
+		}
+		if r.Data, err = rr.readBytes(); err != nil {
+			return
+		}
+	case RecordMessage:
+		if err = rr.readDirection(r); err != nil {
+			return
+		}
+		if r.MessageType, err = rr.readString(); err != nil {
+			return
+		}
+		if r.Data, err = rr.readBytes(); err != nil {
+			return
+		}
+	case RecordStreamClosed:
+	case RecordDatagram:
+		if err = rr.readDirection(r); err != nil {
+			return
+		}
+		if r.RemoteAddr, err = rr.readString(); err != nil {
+			return
+		}
+		if r.Data, err = rr.readBytes(); err != nil {
+			return
+		}
+	default:
+		err = fmt.Errorf("%w: unknown record kind %d", ErrInvalidRecording, kind)
+		return
+	}
+
+	rec = r
+	return
+}
+
+func (rr *RecordingReader) truncated(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("%w: %w", ErrInvalidRecording, err)
+}
+
+func (rr *RecordingReader) readDirection(rec *Record) error {
+	b, err := rr.r.ReadByte()
+	if err != nil {
+		return rr.truncated(err)
+	}
+	rec.Direction = Direction(b)
+	return nil
+}
+
+func (rr *RecordingReader) readBytes() (b []byte, err error) {
+	n, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return nil, rr.truncated(err)
+	}
+	if n > maxRecordFieldSize {
+		return nil, fmt.Errorf("%w: field of %d bytes is too large", ErrInvalidRecording, n)
+	}
+	b = make([]byte, int(n))
+	if _, err = io.ReadFull(rr.r, b); err != nil {
+		return nil, rr.truncated(err)
+	}
+	return
+}
+
+func (rr *RecordingReader) readString() (s string, err error) {
+	b, err := rr.readBytes()
+	s = string(b)
+	return
+}
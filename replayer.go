@@ -0,0 +1,384 @@
+package stagelinq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/icedream/go-stagelinq/internal/messages"
+	"github.com/icedream/go-stagelinq/internal/socket"
+)
+
+// DecodeRecording reads a recording made by Recorder and calls fn for every
+// record in it. For data and datagram records, the messages decoded from the
+// raw bytes are passed along, calling fn once per message. Records whose data
+// could not be decoded (yet) are passed with a nil message.
+//
+// Message records repeat what is decoded from the raw bytes of supported
+// services, so they are only passed on for streams that can't be decoded,
+// and every message is seen once.
+//
+// Decoding stops at the first error returned by fn.
+func DecodeRecording(r io.Reader, fn func(rec *Record, msg *DecodedMessage) error) (err error) {
+	rr, err := NewRecordingReader(r)
+	if err != nil {
+		return
+	}
+
+	type streamDecoders [2]*StreamDecoder
+	decoders := map[uint64]*streamDecoders{}
+
+	for {
+		var rec *Record
+		rec, err = rr.Next()
+		if errors.Is(err, io.EOF) {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		switch rec.Kind {
+		case RecordStreamOpened:
+			decoders[rec.Stream] = &streamDecoders{
+				NewStreamDecoder(rec.Service),
+				NewStreamDecoder(rec.Service),
+			}
+		case RecordStreamClosed:
+			delete(decoders, rec.Stream)
+		case RecordMessage:
+			if d, ok := decoders[rec.Stream]; ok && int(rec.Direction) < len(d) && d[rec.Direction] != nil {
+				continue
+			}
+		case RecordDatagram:
+			if msg, decodeErr := DecodeDatagram(rec.Data); decodeErr == nil {
+				if err = fn(rec, msg); err != nil {
+					return
+				}
+				continue
+			}
+		case RecordData:
+			d, ok := decoders[rec.Stream]
+			if !ok || int(rec.Direction) >= len(d) || d[rec.Direction] == nil {
+				break
+			}
+			decoder := d[rec.Direction]
+			decoder.Write(rec.Data)
+			found := false
+			for {
+				msg, decodeErr := decoder.Next()
+				if decodeErr != nil || msg == nil {
+					break
+				}
+				found = true
+				if err = fn(rec, msg); err != nil {
+					return
+				}
+			}
+			if found {
+				continue
+			}
+		}
+
+		if err = fn(rec, nil); err != nil {
+			return
+		}
+	}
+}
+
+// ReplayerConfiguration contains configurable values for a Replayer.
+type ReplayerConfiguration struct {
+	// Speed scales the timing of replayed traffic. 2 replays twice as fast as
+	// recorded, 0.5 at half speed. If this is not set, traffic is replayed at
+	// the recorded speed.
+	Speed float64
+
+	// Host is the address on which the replayer listens for connections.
+	// If this is not set, the replayer listens on all interfaces.
+	Host string
+}
+
+type replayedChunk struct {
+	offset time.Duration
+	data   []byte
+}
+
+// Replayer plays back a recording as if the recorded device was present on the
+// network. It announces the device, answers service requests on its main port
+// and sends the recorded traffic of each service to whoever connects to it, so
+// applications can be developed and tested without hardware. If a service was
+// connected to several times, connections get the recorded ones in turn.
+type Replayer struct {
+	speed float64
+	host  string
+
+	discovery *discoveryMessage
+	token     Token
+
+	// services maps recorded service names to the traffic the device sent
+	// on each recorded connection to that service, in the order the
+	// connections were made.
+	services     map[string][][]replayedChunk
+	serviceNames []string
+}
+
+// NewReplayer reads a recording made by Recorder for replay. The recording
+// needs to contain the discovery message of a device as well as at least one
+// of its service connections.
+func NewReplayer(r io.Reader, config *ReplayerConfiguration) (replayer *Replayer, err error) {
+	if config == nil {
+		config = new(ReplayerConfiguration)
+	}
+	speed := config.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	rr, err := NewRecordingReader(r)
+	if err != nil {
+		return
+	}
+
+	type stream struct {
+		service string
+		opened  time.Time
+		// index of the connection among those to the same service
+		index int
+	}
+	streams := map[uint64]*stream{}
+	services := map[string][][]replayedChunk{}
+	serviceNames := []string{}
+	var discovery *discoveryMessage
+
+	for {
+		var rec *Record
+		rec, err = rr.Next()
+		if errors.Is(err, io.EOF) {
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+
+		switch rec.Kind {
+		case RecordStreamOpened:
+			switch rec.Service {
+			case "main", "discovery":
+			default:
+				if _, ok := services[rec.Service]; !ok {
+					serviceNames = append(serviceNames, rec.Service)
+				}
+				streams[rec.Stream] = &stream{
+					service: rec.Service,
+					opened:  rec.Time,
+					index:   len(services[rec.Service]),
+				}
+				services[rec.Service] = append(services[rec.Service], []replayedChunk{})
+			}
+		case RecordDatagram:
+			if discovery != nil || rec.Direction != DirectionReceived {
+				break
+			}
+			m := new(discoveryMessage)
+			if m.ReadMessageFrom(messages.NewFrameReader(rec.Data, messages.DefaultLimits)) == nil &&
+				m.Action == discovererHowdy {
+				discovery = m
+			}
+		case RecordData:
+			s, ok := streams[rec.Stream]
+			if !ok || rec.Direction != DirectionReceived {
+				break
+			}
+			services[s.service][s.index] = append(services[s.service][s.index], replayedChunk{
+				offset: rec.Time.Sub(s.opened),
+				data:   rec.Data,
+			})
+		}
+	}
+
+	if discovery == nil {
+		err = errors.New("recording contains no device announcement")
+		return
+	}
+	if len(serviceNames) == 0 {
+		err = errors.New("recording contains no service connections")
+		return
+	}
+
+	replayer = &Replayer{
+		speed:        speed,
+		host:         config.Host,
+		discovery:    discovery,
+		token:        Token(discovery.Token),
+		services:     services,
+		serviceNames: serviceNames,
+	}
+	return
+}
+
+// Token returns the token of the replayed device.
+func (r *Replayer) Token() Token {
+	return r.token
+}
+
+// Serve replays the recording until ctx is cancelled.
+func (r *Replayer) Serve(ctx context.Context) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	listen := func() (l net.Listener, err error) {
+		l, err = net.Listen("tcp", net.JoinHostPort(r.host, "0"))
+		if err == nil {
+			listeners = append(listeners, l)
+		}
+		return
+	}
+
+	// open up a port for every recorded service
+	offeredServices := make([]*Service, len(r.serviceNames))
+	for i, name := range r.serviceNames {
+		var l net.Listener
+		if l, err = listen(); err != nil {
+			return
+		}
+		offeredServices[i] = &Service{
+			Name: name,
+			Port: socket.GetPort(l.Addr()),
+		}
+		// connections get the recorded connections in turn, starting over
+		// after the last one
+		recorded := r.services[name]
+		var next atomic.Uint64
+		r.accept(ctx, &wg, l, func(conn net.Conn) {
+			i := next.Add(1) - 1
+			r.replayService(ctx, conn, recorded[i%uint64(len(recorded))])
+		})
+	}
+
+	mainListener, err := listen()
+	if err != nil {
+		return
+	}
+	r.accept(ctx, &wg, mainListener, func(conn net.Conn) {
+		r.serveMain(ctx, conn, offeredServices)
+	})
+
+	// announce the device with our main port in place of the recorded one
+	announcement := *r.discovery
+	announcement.Port = socket.GetPort(mainListener.Addr())
+	announce := func(action discovererMessageAction) {
+		announcement.Action = action
+		b := new(bytes.Buffer)
+		if err := announcement.WriteMessageTo(b); err == nil {
+			_, _ = broadcastDiscoveryMessage(b.Bytes())
+		}
+	}
+	defer announce(discovererExit)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		announce(discovererHowdy)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Replayer) accept(ctx context.Context, wg *sync.WaitGroup, l net.Listener, handle func(conn net.Conn)) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+
+				// unblock reads and writes on shutdown
+				stop := context.AfterFunc(ctx, func() { conn.Close() })
+				defer stop()
+
+				handle(conn)
+			}()
+		}
+	}()
+}
+
+// serveMain answers service requests on the main port with the services
+// offered by the replayer.
+func (r *Replayer) serveMain(ctx context.Context, conn net.Conn, offeredServices []*Service) {
+	msgConn := newMessageConnection(conn, mainConnectionMessageSet)
+	token := messages.Token(r.token)
+	for {
+		msg, err := msgConn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if _, ok := msg.(*servicesRequestMessage); !ok {
+			continue
+		}
+		for _, service := range offeredServices {
+			if err = msgConn.WriteMessage(&serviceAnnouncementMessage{
+				TokenPrefixedMessage: messages.TokenPrefixedMessage{Token: token},
+				Service:              service.Name,
+				Port:                 service.Port,
+			}); err != nil {
+				return
+			}
+		}
+		if err = msgConn.WriteMessage(&referenceMessage{
+			TokenPrefixedMessage: messages.TokenPrefixedMessage{Token: token},
+			Token2:               msg.(*servicesRequestMessage).Token,
+		}); err != nil {
+			return
+		}
+	}
+}
+
+// replayService sends the recorded traffic of a service with its original
+// timing, scaled by the configured speed. Anything the client sends is
+// discarded.
+func (r *Replayer) replayService(ctx context.Context, conn net.Conn, chunks []replayedChunk) {
+	go io.Copy(io.Discard, conn)
+
+	start := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for _, chunk := range chunks {
+		timer.Reset(time.Until(start.Add(time.Duration(float64(chunk.offset) / r.speed))))
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if _, err := conn.Write(chunk.data); err != nil {
+			return
+		}
+	}
+
+	// keep the connection open like a device would
+	<-ctx.Done()
+}
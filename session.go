@@ -0,0 +1,740 @@
+package stagelinq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrSessionClosed is returned by Session methods after the session has been
+// closed.
+var ErrSessionClosed = errors.New("session closed")
+
+// ErrSessionNotConnected is returned by Session methods that need a
+// connection to the device while there is none.
+var ErrSessionNotConnected = errors.New("session not connected")
+
+// SessionState represents the state of the connection of a Session.
+type SessionState byte
+
+const (
+	// SessionConnecting indicates that the session is connecting to the device.
+	SessionConnecting SessionState = iota
+
+	// SessionConnected indicates that the session is connected to the device
+	// and subscriptions have been sent.
+	SessionConnected
+
+	// SessionDisconnected indicates that the connection to the device has been
+	// lost. The session waits for the device to reappear and reconnects.
+	SessionDisconnected
+
+	// SessionClosed indicates that the session has been closed and will not
+	// reconnect anymore.
+	SessionClosed
+)
+
+func (s SessionState) String() string {
+	switch s {
+	case SessionConnecting:
+		return "connecting"
+	case SessionConnected:
+		return "connected"
+	case SessionDisconnected:
+		return "disconnected"
+	case SessionClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("SessionState(%d)", byte(s))
+	}
+}
+
+// SessionStatus describes a state transition of a Session.
+type SessionStatus struct {
+	State SessionState
+
+	// Device is the device the session is connecting or connected to.
+	Device *Device
+
+	// Err is the error that caused the session to disconnect, if any.
+	Err error
+
+	// Attempt counts connection attempts since the last successful
+	// connection, starting at 1.
+	Attempt int
+}
+
+const (
+	defaultSessionMinBackoff     = 500 * time.Millisecond
+	defaultSessionMaxBackoff     = 30 * time.Second
+	defaultSessionConnectTimeout = 10 * time.Second
+)
+
+// SessionConfiguration contains configurable values for a Session.
+type SessionConfiguration struct {
+	// Token is the token we announce on the network, usually Listener.Token.
+	Token Token
+
+	// OfferedServices are announced to the device on the main connection.
+	OfferedServices []*Service
+
+	// Resolver is used to find the device again after the connection to it
+	// has been lost. If this is not set, the session opens its own Listener
+	// and waits for the device to announce itself again. Only if that fails
+	// does it reconnect to the address the device was last seen at.
+	Resolver DeviceResolver
+
+	// ConnectionOptions are applied to all connections made by the session.
+	ConnectionOptions []ConnectionOption
+
+	// MinBackoff is the time to wait before the first reconnection attempt.
+	// It doubles with every failed attempt up to MaxBackoff.
+	// Defaults to 500 milliseconds.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the maximum time to wait between reconnection attempts.
+	// Defaults to 30 seconds.
+	MaxBackoff time.Duration
+
+	// ConnectTimeout limits how long a single connection attempt may take.
+	// Defaults to 10 seconds.
+	ConnectTimeout time.Duration
+}
+
+type sessionSubscription struct {
+	name string
+	// pattern is set for SubscribeAll and SubscribeGlob subscriptions
+	pattern *statePattern
+	opts    []StateMapSubscriptionOption
+	// handle is set for subscriptions made via SubscribeContext
+	handle *StateSubscription
+}
+
+func (sub *sessionSubscription) send(conn *StateMapConnection) error {
+	if sub.pattern != nil {
+		return conn.subscribePattern(*sub.pattern, sub.opts)
+	}
+	if sub.handle != nil {
+		if err := sub.handle.reset(); err != nil {
+			// cancelled in the meantime
+			return nil
+		}
+		conn.adopt(sub.handle)
+	}
+	return conn.Subscribe(sub.name, sub.opts...)
+}
+
+// Session keeps a device connected. It connects to the StateMap and BeatInfo
+// services of the device, reconnects with backoff whenever the connection is
+// lost and replays subscriptions and the BeatInfo stream state after each
+// reconnect.
+//
+// States and beat info from all connections are delivered on the same
+// channels for the whole lifetime of the session.
+type Session struct {
+	config SessionConfiguration
+	token  Token
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// writeLock serializes writes to the connections so that they are sent
+	// in the order they were requested without holding lock while blocked on
+	// the network.
+	writeLock sync.Mutex
+
+	lock          sync.Mutex
+	device        *Device
+	state         SessionState
+	subscriptions []*sessionSubscription
+	// knownPaths are the paths added via AddKnownPaths
+	knownPaths   []string
+	beatInfo     bool
+	stateMapConn *StateMapConnection
+	beatInfoConn *BeatInfoConnection
+
+	stateC    chan *State
+	fanOut    stateFanOut
+	beatInfoC chan *BeatInfo
+	statusC   chan SessionStatus
+}
+
+// NewSession starts a session with the given device, which usually has been
+// found using Listener.Discover.
+func NewSession(device *Device, config *SessionConfiguration) *Session {
+	if device == nil {
+		panic("device must not be nil")
+	}
+	if config == nil {
+		config = new(SessionConfiguration)
+	}
+	c := *config
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = defaultSessionMinBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultSessionMaxBackoff
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = c.MinBackoff
+	}
+	if c.ConnectTimeout <= 0 {
+		c.ConnectTimeout = defaultSessionConnectTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Session{
+		config:    c,
+		token:     device.token,
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		device:    device,
+		state:     SessionConnecting,
+		stateC:    make(chan *State, 16),
+		beatInfoC: make(chan *BeatInfo, 16),
+		statusC:   make(chan SessionStatus, 16),
+	}
+	go s.run()
+	return s
+}
+
+// Token returns the token of the device this session is connected to.
+func (s *Session) Token() Token {
+	return s.token
+}
+
+// Device returns the device as it has last been seen on the network.
+func (s *Session) Device() *Device {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.device
+}
+
+// State returns the current state of the session.
+func (s *Session) State() SessionState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.state
+}
+
+// StateC returns the channel via which state changes are returned for all
+// connections made by this session. It is closed when the session is closed.
+func (s *Session) StateC() <-chan *State {
+	return s.stateC
+}
+
+// BeatInfoC returns the channel via which the BeatInfo data stream is
+// returned for all connections made by this session. It is closed when the
+// session is closed.
+func (s *Session) BeatInfoC() <-chan *BeatInfo {
+	return s.beatInfoC
+}
+
+// NewStateConsumer returns a consumer that receives all states accepted by
+// the given filter, or all states if filter is nil, for the whole lifetime of
+// the session. See StateMapConnection.NewStateConsumer.
+func (s *Session) NewStateConsumer(filter StateFilter, opts ...StateConsumerOption) *StateConsumer {
+	return s.fanOut.add(filter, opts)
+}
+
+// StatusC returns the channel via which state transitions of the session are
+// reported. Transitions are dropped if the channel is not read from quickly
+// enough, use State to get the current state instead. It is closed when the
+// session is closed.
+func (s *Session) StatusC() <-chan SessionStatus {
+	return s.statusC
+}
+
+// Subscribe tells the device to send us updates for the given state value
+// path. The subscription is remembered and sent again after reconnecting.
+// If the session is not connected right now, the subscription is sent once
+// it is.
+func (s *Session) Subscribe(event string, opts ...StateMapSubscriptionOption) (err error) {
+	return s.addSubscription(&sessionSubscription{name: event, opts: opts})
+}
+
+// SubscribeContext subscribes like Subscribe and waits for the device to
+// acknowledge the subscription until ctx is done, see
+// StateMapConnection.SubscribeContext. The returned handle stays valid across
+// reconnects, after which the device has to acknowledge the subscription
+// again. Cancelling it removes the subscription from the session.
+func (s *Session) SubscribeContext(ctx context.Context, event string, opts ...StateMapSubscriptionOption) (sub *StateSubscription, err error) {
+	s.lock.Lock()
+	for _, existing := range s.subscriptions {
+		if existing.name == event && existing.handle != nil {
+			sub = existing.handle
+			break
+		}
+	}
+	s.lock.Unlock()
+	if sub == nil {
+		sub = &StateSubscription{
+			owner: s,
+			name:  event,
+			acked: make(chan struct{}),
+		}
+	}
+
+	if err = sub.send(opts); err != nil {
+		return
+	}
+	err = sub.Wait(ctx)
+	return
+}
+
+func (s *Session) sendSubscription(sub *StateSubscription, opts []StateMapSubscriptionOption) error {
+	return s.addSubscription(&sessionSubscription{name: sub.name, opts: opts, handle: sub})
+}
+
+func (s *Session) cancelSubscription(sub *StateSubscription) {
+	s.lock.Lock()
+	for i, existing := range s.subscriptions {
+		if existing.handle == sub {
+			s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+			break
+		}
+	}
+	conn := s.stateMapConn
+	s.lock.Unlock()
+
+	if conn != nil {
+		conn.cancelSubscription(sub)
+	}
+}
+
+// SubscribeAll subscribes to all state value paths starting with the given
+// prefix, see StateMapConnection.SubscribeAll. The subscription is remembered
+// like with Subscribe.
+func (s *Session) SubscribeAll(prefix string, opts ...StateMapSubscriptionOption) (err error) {
+	return s.addSubscription(&sessionSubscription{
+		name:    "prefix:" + prefix,
+		pattern: &statePattern{prefix: prefix},
+		opts:    opts,
+	})
+}
+
+// SubscribeGlob subscribes to all state value paths matching the given glob
+// pattern, see StateMapConnection.SubscribeGlob. The subscription is
+// remembered like with Subscribe.
+func (s *Session) SubscribeGlob(pattern string, opts ...StateMapSubscriptionOption) (err error) {
+	p, err := newGlobStatePattern(pattern)
+	if err != nil {
+		return
+	}
+	return s.addSubscription(&sessionSubscription{
+		name:    "glob:" + pattern,
+		pattern: &p,
+		opts:    opts,
+	})
+}
+
+func (s *Session) addSubscription(sub *sessionSubscription) (err error) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	s.lock.Lock()
+	if s.state == SessionClosed {
+		s.lock.Unlock()
+		return ErrSessionClosed
+	}
+
+	replaced := false
+	for i, existing := range s.subscriptions {
+		if existing.name == sub.name {
+			if sub.handle == nil {
+				sub.handle = existing.handle
+			}
+			s.subscriptions[i] = sub
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		s.subscriptions = append(s.subscriptions, sub)
+	}
+	conn := s.stateMapConn
+	s.lock.Unlock()
+
+	if conn != nil {
+		err = sub.send(conn)
+	}
+	return
+}
+
+// AddKnownPaths makes the session aware of state value paths that are not part
+// of KnownStateValueNames, see StateMapConnection.AddKnownPaths. The paths are
+// remembered and added to every connection.
+func (s *Session) AddKnownPaths(names ...string) (err error) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	s.lock.Lock()
+	if s.state == SessionClosed {
+		s.lock.Unlock()
+		return ErrSessionClosed
+	}
+	s.knownPaths = append(s.knownPaths, names...)
+	conn := s.stateMapConn
+	s.lock.Unlock()
+
+	if conn != nil {
+		err = conn.AddKnownPaths(names...)
+	}
+	return
+}
+
+// Set writes a state value to the device, see StateMapConnection.Set. Unlike
+// subscriptions, values are not remembered across reconnects.
+func (s *Session) Set(name string, value interface{}) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	s.lock.Lock()
+	state, conn := s.state, s.stateMapConn
+	s.lock.Unlock()
+	if state == SessionClosed {
+		return ErrSessionClosed
+	}
+	if conn == nil {
+		return ErrSessionNotConnected
+	}
+	return conn.Set(name, value)
+}
+
+// SetContext writes a state value to the device and waits until the device
+// echoes it back or ctx is done, see StateMapConnection.SetContext. If the
+// path has to be subscribed to for that, the subscription is only made on the
+// current connection and not remembered by the session.
+func (s *Session) SetContext(ctx context.Context, name string, value interface{}) (err error) {
+	s.lock.Lock()
+	state, conn := s.state, s.stateMapConn
+	s.lock.Unlock()
+	if state == SessionClosed {
+		return ErrSessionClosed
+	}
+	if conn == nil {
+		return ErrSessionNotConnected
+	}
+	return conn.SetContext(ctx, name, value)
+}
+
+// StartBeatInfo tells the device to start publishing the BeatInfo data stream.
+// The stream is started again after reconnecting.
+func (s *Session) StartBeatInfo() (err error) {
+	return s.setBeatInfo(true)
+}
+
+// StopBeatInfo tells the device to stop publishing the BeatInfo data stream.
+func (s *Session) StopBeatInfo() (err error) {
+	return s.setBeatInfo(false)
+}
+
+func (s *Session) setBeatInfo(enabled bool) (err error) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	s.lock.Lock()
+	if s.state == SessionClosed {
+		s.lock.Unlock()
+		return ErrSessionClosed
+	}
+	s.beatInfo = enabled
+	conn := s.beatInfoConn
+	s.lock.Unlock()
+
+	if conn == nil {
+		return
+	}
+	if enabled {
+		return conn.StartStream()
+	}
+	return conn.StopStream()
+}
+
+// Close disconnects from the device and stops reconnecting.
+func (s *Session) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func (s *Session) setState(state SessionState, device *Device, err error, attempt int) {
+	s.lock.Lock()
+	s.state = state
+	s.device = device
+	s.lock.Unlock()
+
+	select {
+	case s.statusC <- SessionStatus{
+		State:   state,
+		Device:  device,
+		Err:     err,
+		Attempt: attempt,
+	}:
+	default:
+	}
+}
+
+func (s *Session) run() {
+	defer func() {
+		s.setState(SessionClosed, s.Device(), nil, 0)
+		close(s.stateC)
+		s.fanOut.close()
+		close(s.beatInfoC)
+		close(s.statusC)
+		close(s.done)
+	}()
+
+	device := s.Device()
+	resolver := s.config.Resolver
+	backoff := s.config.MinBackoff
+	attempt := 0
+	for {
+		attempt++
+		s.setState(SessionConnecting, device, nil, attempt)
+
+		err := s.connect(device)
+		if s.ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errConnectionLost) {
+			// we got connected at some point, start over with backoff
+			backoff = s.config.MinBackoff
+			attempt = 0
+		}
+		s.setState(SessionDisconnected, device, err, attempt)
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.config.MaxBackoff {
+			backoff = s.config.MaxBackoff
+		}
+
+		// the device may have come back under a different address
+		if resolver == nil {
+			resolver = s.listenForDevice()
+		}
+		if resolver != nil {
+			resolved, err := resolver.ResolveDevice(s.ctx, s.token)
+			if err != nil {
+				if s.ctx.Err() != nil {
+					return
+				}
+				continue
+			}
+			device = resolved
+		}
+	}
+}
+
+// listenForDevice opens a Listener to resolve the device with if none has
+// been configured. It returns nil if the discovery port can't be opened.
+func (s *Session) listenForDevice() DeviceResolver {
+	listener, err := ListenWithConfiguration(&ListenerConfiguration{
+		Context: s.ctx,
+		Token:   s.config.Token,
+	})
+	if err != nil {
+		return nil
+	}
+	// unblocks ResolveDevice when the session is closed
+	context.AfterFunc(s.ctx, func() { listener.Close() })
+	return listener
+}
+
+// errConnectionLost wraps errors that ended an established connection as
+// opposed to errors that occurred while connecting.
+var errConnectionLost = errors.New("connection lost")
+
+// connect connects to the device and forwards received data until the
+// connection is lost, in which case the returned error wraps
+// errConnectionLost.
+func (s *Session) connect(device *Device) (err error) {
+	var connsLock sync.Mutex
+	var conns []net.Conn
+	addConn := func(conn net.Conn) {
+		connsLock.Lock()
+		defer connsLock.Unlock()
+		conns = append(conns, conn)
+	}
+	closeConns := func() {
+		connsLock.Lock()
+		defer connsLock.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+	defer closeConns()
+
+	// abort connecting after the configured timeout
+	connectCtx, cancelConnect := context.WithTimeout(s.ctx, s.config.ConnectTimeout)
+	defer cancelConnect()
+	stopConnectTimeout := context.AfterFunc(connectCtx, closeConns)
+
+	mainTCPConn, err := device.DialContext(connectCtx, device.port)
+	if err != nil {
+		return
+	}
+	addConn(mainTCPConn)
+	mainConn, err := newMainConnection(mainTCPConn, s.config.Token, device.token, s.config.OfferedServices, s.config.ConnectionOptions...)
+	if err != nil {
+		return
+	}
+	services, err := mainConn.RequestServices()
+	if err != nil {
+		return
+	}
+
+	var stateMapConn *StateMapConnection
+	var beatInfoConn *BeatInfoConnection
+	defer func() {
+		// don't leave the connection goroutines blocked on their channels
+		if stateMapConn != nil {
+			go drain(stateMapConn.StateC())
+		}
+		if beatInfoConn != nil {
+			go drain(beatInfoConn.BeatInfoC())
+		}
+	}()
+	for _, service := range services {
+		switch service.Name {
+		case "StateMap":
+			if stateMapConn != nil {
+				continue
+			}
+			var conn net.Conn
+			if conn, err = device.DialContext(connectCtx, service.Port); err != nil {
+				return
+			}
+			addConn(conn)
+			if stateMapConn, err = NewStateMapConnection(conn, s.config.Token, s.config.ConnectionOptions...); err != nil {
+				return
+			}
+		case "BeatInfo":
+			if beatInfoConn != nil {
+				continue
+			}
+			var conn net.Conn
+			if conn, err = device.DialContext(connectCtx, service.Port); err != nil {
+				return
+			}
+			addConn(conn)
+			if beatInfoConn, err = NewBeatInfoConnection(conn, s.config.Token, s.config.ConnectionOptions...); err != nil {
+				return
+			}
+		}
+	}
+	if stateMapConn == nil && beatInfoConn == nil {
+		return errors.New("device offers neither StateMap nor BeatInfo")
+	}
+
+	// replay what the user asked for so far, holding writeLock keeps new
+	// subscriptions from overtaking the replay
+	s.writeLock.Lock()
+	s.lock.Lock()
+	knownPaths := s.knownPaths
+	subscriptions := append([]*sessionSubscription(nil), s.subscriptions...)
+	beatInfo := s.beatInfo
+	s.stateMapConn = stateMapConn
+	s.beatInfoConn = beatInfoConn
+	s.lock.Unlock()
+	if stateMapConn != nil {
+		err = stateMapConn.AddKnownPaths(knownPaths...)
+		for _, sub := range subscriptions {
+			if err != nil {
+				break
+			}
+			err = sub.send(stateMapConn)
+		}
+	}
+	if err == nil && beatInfoConn != nil && beatInfo {
+		err = beatInfoConn.StartStream()
+	}
+	s.writeLock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		s.stateMapConn = nil
+		s.beatInfoConn = nil
+		s.lock.Unlock()
+	}()
+	if err != nil {
+		return
+	}
+
+	// connected, from here on only the session context applies
+	if !stopConnectTimeout() {
+		if err = s.ctx.Err(); err == nil {
+			err = context.DeadlineExceeded
+		}
+		return
+	}
+	s.setState(SessionConnected, device, nil, 0)
+	if err = s.forward(mainConn, stateMapConn, beatInfoConn); err != nil {
+		err = fmt.Errorf("%w: %w", errConnectionLost, err)
+	} else {
+		err = errConnectionLost
+	}
+	return
+}
+
+func drain[T any](c <-chan T) {
+	for range c {
+	}
+}
+
+// forward passes on received data until any of the connections fails and
+// returns the error that made it fail, if any.
+func (s *Session) forward(mainConn *MainConnection, stateMapConn *StateMapConnection, beatInfoConn *BeatInfoConnection) (err error) {
+	var stateC <-chan *State
+	var beatInfoC <-chan *BeatInfo
+	if stateMapConn != nil {
+		stateC = stateMapConn.StateC()
+	}
+	if beatInfoConn != nil {
+		beatInfoC = beatInfoConn.BeatInfoC()
+	}
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case err = <-mainConn.errorC:
+			return
+		case state, ok := <-stateC:
+			if !ok {
+				select {
+				case err = <-stateMapConn.ErrorC():
+				default:
+				}
+				return
+			}
+			s.fanOut.publish(state)
+			select {
+			case s.stateC <- state:
+			case <-s.ctx.Done():
+				return
+			}
+		case beatInfo, ok := <-beatInfoC:
+			if !ok {
+				select {
+				case err = <-beatInfoConn.ErrorC():
+				default:
+				}
+				return
+			}
+			select {
+			case s.beatInfoC <- beatInfo:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package stagelinq
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/icedream/go-stagelinq/internal/messages"
+	"github.com/icedream/go-stagelinq/internal/socket"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDevice is a minimal StagelinQ device offering a StateMap service that
+// answers every subscription with a state emit.
+type fakeDevice struct {
+	t            *testing.T
+	token        Token
+	mainListener net.Listener
+	smListener   net.Listener
+
+	lock          sync.Mutex
+	conns         []net.Conn
+	subscriptions chan string
+}
+
+func newFakeDevice(t *testing.T) *fakeDevice {
+	mainListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	smListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	d := &fakeDevice{
+		t:             t,
+		token:         Token{0xf4, 0x05, 0xdc, 0x14},
+		mainListener:  mainListener,
+		smListener:    smListener,
+		subscriptions: make(chan string, 16),
+	}
+	go d.accept(mainListener, d.serveMain)
+	go d.accept(smListener, d.serveStateMap)
+	t.Cleanup(func() {
+		mainListener.Close()
+		smListener.Close()
+		d.disconnect()
+	})
+	return d
+}
+
+func (d *fakeDevice) device() *Device {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return &Device{
+		port:  socket.GetPort(d.mainListener.Addr()),
+		token: d.token,
+		IP:    net.IPv4(127, 0, 0, 1),
+		Name:  "fake",
+	}
+}
+
+// ResolveDevice implements DeviceResolver as if the device announced itself
+// right away.
+func (d *fakeDevice) ResolveDevice(ctx context.Context, token Token) (*Device, error) {
+	if token != d.token {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return d.device(), nil
+}
+
+// move drops all connections and serves the main connection on a new port as
+// if the device restarted.
+func (d *fakeDevice) move() {
+	mainListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(d.t, err)
+	d.lock.Lock()
+	oldListener := d.mainListener
+	d.mainListener = mainListener
+	d.lock.Unlock()
+	oldListener.Close()
+	d.disconnect()
+	go d.accept(mainListener, d.serveMain)
+	d.t.Cleanup(func() { mainListener.Close() })
+}
+
+func (d *fakeDevice) accept(l net.Listener, serve func(net.Conn)) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		d.lock.Lock()
+		d.conns = append(d.conns, conn)
+		d.lock.Unlock()
+		go serve(conn)
+	}
+}
+
+// disconnect drops all connections as if the device rebooted.
+func (d *fakeDevice) disconnect() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, conn := range d.conns {
+		conn.Close()
+	}
+	d.conns = nil
+}
+
+func (d *fakeDevice) serveMain(conn net.Conn) {
+	msgConn := newMessageConnection(conn, mainConnectionMessageSet)
+	for {
+		msg, err := msgConn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if _, ok := msg.(*servicesRequestMessage); !ok {
+			continue
+		}
+		_ = msgConn.WriteMessage(&serviceAnnouncementMessage{
+			TokenPrefixedMessage: messages.TokenPrefixedMessage{Token: messages.Token(d.token)},
+			Service:              "StateMap",
+			Port:                 socket.GetPort(d.smListener.Addr()),
+		})
+		_ = msgConn.WriteMessage(&referenceMessage{
+			TokenPrefixedMessage: messages.TokenPrefixedMessage{Token: messages.Token(d.token)},
+		})
+	}
+}
+
+func (d *fakeDevice) serveStateMap(conn net.Conn) {
+	msgConn := newMessageConnection(conn, decoderMessageSets["StateMap"])
+	for {
+		msg, err := msgConn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if emit, ok := msg.(*stateEmitMessage); ok {
+			// accept every value that is set
+			_ = msgConn.WriteMessage(emit)
+			continue
+		}
+		sub, ok := msg.(*stateSubscribeMessage)
+		if !ok {
+			continue
+		}
+		d.subscriptions <- sub.Name
+		_ = msgConn.WriteMessage(&stateEmitResponseMessage{
+			Name:     sub.Name,
+			Interval: sub.Interval,
+		})
+		_ = msgConn.WriteMessage(&stateEmitMessage{
+			Name: sub.Name,
+			JSON: `{"state":true,"type":1}`,
+		})
+	}
+}
+
+func waitForSessionState(t *testing.T, s *Session, state SessionState) SessionStatus {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case status := <-s.StatusC():
+			if status.State == state {
+				return status
+			}
+		case <-timeout:
+			t.Fatalf("session did not reach state %s", state)
+		}
+	}
+}
+
+func Test_Session_Resubscribe(t *testing.T) {
+	d := newFakeDevice(t)
+
+	s := NewSession(d.device(), &SessionConfiguration{
+		Resolver:   d,
+		MinBackoff: 10 * time.Millisecond,
+	})
+	defer s.Close()
+	require.Equal(t, d.token, s.Token())
+
+	require.NoError(t, s.Subscribe(EngineDeck1.Play()))
+	waitForSessionState(t, s, SessionConnected)
+	require.Equal(t, EngineDeck1.Play(), <-d.subscriptions)
+	state := <-s.StateC()
+	require.Equal(t, EngineDeck1.Play(), state.Name)
+
+	// lose the connection, the session must reconnect and subscribe again
+	d.disconnect()
+	status := waitForSessionState(t, s, SessionDisconnected)
+	require.ErrorIs(t, status.Err, errConnectionLost)
+	waitForSessionState(t, s, SessionConnected)
+	require.Equal(t, EngineDeck1.Play(), <-d.subscriptions)
+	state = <-s.StateC()
+	require.Equal(t, EngineDeck1.Play(), state.Name)
+
+	require.NoError(t, s.Close())
+	require.Equal(t, SessionClosed, s.State())
+	require.ErrorIs(t, s.Subscribe(EngineDeck2.Play()), ErrSessionClosed)
+}
+
+func Test_Session_Backoff(t *testing.T) {
+	d := newFakeDevice(t)
+	device := d.device()
+	d.mainListener.Close()
+
+	s := NewSession(device, &SessionConfiguration{
+		Resolver:   d,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+	})
+	defer s.Close()
+
+	status := waitForSessionState(t, s, SessionDisconnected)
+	require.Error(t, status.Err)
+	require.NotErrorIs(t, status.Err, errConnectionLost)
+	require.Equal(t, 1, status.Attempt)
+
+	status = waitForSessionState(t, s, SessionConnecting)
+	require.Equal(t, 2, status.Attempt)
+}
+
+func Test_Session_Moved(t *testing.T) {
+	d := newFakeDevice(t)
+	oldDevice := d.device()
+
+	s := NewSession(oldDevice, &SessionConfiguration{
+		Resolver:   d,
+		MinBackoff: 10 * time.Millisecond,
+	})
+	defer s.Close()
+
+	require.NoError(t, s.Subscribe(EngineDeck1.Play()))
+	waitForSessionState(t, s, SessionConnected)
+	require.Equal(t, EngineDeck1.Play(), <-d.subscriptions)
+
+	// the device comes back on another port
+	d.move()
+	waitForSessionState(t, s, SessionDisconnected)
+	status := waitForSessionState(t, s, SessionConnected)
+	require.NotEqual(t, oldDevice.port, status.Device.port)
+	require.Equal(t, EngineDeck1.Play(), <-d.subscriptions)
+}
+
+func Test_Session_SubscribeContext(t *testing.T) {
+	d := newFakeDevice(t)
+
+	s := NewSession(d.device(), &SessionConfiguration{
+		Resolver:   d,
+		MinBackoff: 10 * time.Millisecond,
+	})
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := s.SubscribeContext(ctx, EngineDeck1.Play(), WithInterval(100*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, EngineDeck1.Play(), <-d.subscriptions)
+	interval, ok := sub.Interval()
+	require.True(t, ok)
+	require.Equal(t, 100*time.Millisecond, interval)
+	require.NoError(t, s.Subscribe(EngineDeck2.Play()))
+	require.Equal(t, EngineDeck2.Play(), <-d.subscriptions)
+
+	// the handle survives reconnects and gets acknowledged again
+	d.disconnect()
+	waitForSessionState(t, s, SessionDisconnected)
+	waitForSessionState(t, s, SessionConnected)
+	require.ElementsMatch(t, []string{EngineDeck1.Play(), EngineDeck2.Play()}, []string{<-d.subscriptions, <-d.subscriptions})
+	require.NoError(t, sub.Wait(ctx))
+
+	// cancelled subscriptions are not replayed anymore
+	require.NoError(t, sub.Cancel())
+	d.disconnect()
+	waitForSessionState(t, s, SessionDisconnected)
+	waitForSessionState(t, s, SessionConnected)
+	require.Equal(t, EngineDeck2.Play(), <-d.subscriptions)
+	select {
+	case name := <-d.subscriptions:
+		t.Fatalf("unexpected subscription to %s", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func Test_Session_SetContext(t *testing.T) {
+	d := newFakeDevice(t)
+
+	s := NewSession(d.device(), &SessionConfiguration{
+		Resolver:   d,
+		MinBackoff: 10 * time.Millisecond,
+	})
+	defer s.Close()
+	waitForSessionState(t, s, SessionConnected)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.ErrorIs(t, s.SetContext(ctx, EngineDeck1.Play(), true), ErrStateNotWritable)
+	require.NoError(t, s.SetContext(ctx, ConfigurationComputerMode, true))
+	require.Equal(t, ConfigurationComputerMode, <-d.subscriptions)
+
+	// the subscription needed for the echo is not remembered by the session
+	s.lock.Lock()
+	require.Empty(t, s.subscriptions)
+	s.lock.Unlock()
+}
@@ -0,0 +1,202 @@
+package stagelinq
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// DefaultStateConsumerBufferSize is the number of states a StateConsumer
+// buffers unless configured otherwise with WithStateConsumerBufferSize.
+const DefaultStateConsumerBufferSize = 256
+
+// ErrStateConsumerOverflow is returned by StateConsumer.Err if the consumer has
+// been closed because it was not read from quickly enough.
+var ErrStateConsumerOverflow = errors.New("state consumer overflow")
+
+// StateFilter decides whether a state is passed on to a StateConsumer.
+type StateFilter func(state *State) bool
+
+// StateNameFilter accepts states with any of the given paths.
+func StateNameFilter(names ...string) StateFilter {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return func(state *State) bool {
+		_, ok := set[state.Name]
+		return ok
+	}
+}
+
+// StatePrefixFilter accepts states whose path starts with the given prefix.
+func StatePrefixFilter(prefix string) StateFilter {
+	return func(state *State) bool {
+		return strings.HasPrefix(state.Name, prefix)
+	}
+}
+
+// StateGlobFilter accepts states whose path matches the given glob pattern,
+// see MatchStatePath.
+func StateGlobFilter(pattern string) (StateFilter, error) {
+	p, err := newGlobStatePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return func(state *State) bool {
+		return p.Match(state.Name)
+	}, nil
+}
+
+type stateConsumerConfiguration struct {
+	bufferSize int
+}
+
+// StateConsumerOption represents an option for state consumers.
+type StateConsumerOption func(*stateConsumerConfiguration)
+
+// WithStateConsumerBufferSize sets how many states a consumer buffers before
+// it is closed for falling behind. Consumers of wide subscriptions like
+// SubscribeAll may need more than DefaultStateConsumerBufferSize to take the
+// burst of initial values. A value of 0 restores
+// DefaultStateConsumerBufferSize.
+func WithStateConsumerBufferSize(n int) StateConsumerOption {
+	return func(c *stateConsumerConfiguration) {
+		c.bufferSize = n
+	}
+}
+
+// StateConsumer receives a filtered copy of the states of a connection or
+// session, independent of other consumers and of StateC. A consumer that does
+// not keep up with the states sent to it is closed, see Err.
+type StateConsumer struct {
+	fanOut *stateFanOut
+	filter StateFilter
+
+	lock   sync.Mutex
+	c      chan *State
+	closed bool
+	err    error
+}
+
+// StateC returns the channel via which the states accepted by the filter of
+// this consumer are returned. It is closed when the consumer or its source is
+// closed, or when the consumer falls behind.
+func (c *StateConsumer) StateC() <-chan *State {
+	return c.c
+}
+
+// Err returns ErrStateConsumerOverflow if the consumer has been closed because
+// its buffer overflowed, nil otherwise.
+func (c *StateConsumer) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+// Close stops delivering states to this consumer.
+func (c *StateConsumer) Close() {
+	c.fanOut.remove(c)
+	c.close(nil)
+}
+
+func (c *StateConsumer) close(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.err = err
+	close(c.c)
+}
+
+// deliver passes a state on without blocking and tells whether the consumer
+// had to be closed because its buffer is full.
+func (c *StateConsumer) deliver(state *State) (overflow bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.c <- state:
+		return
+	default:
+	}
+	c.closed = true
+	c.err = ErrStateConsumerOverflow
+	close(c.c)
+	return true
+}
+
+// stateFanOut passes states on to any number of consumers. Deliveries never
+// block, each consumer has a buffer of its own.
+type stateFanOut struct {
+	lock      sync.Mutex
+	consumers map[*StateConsumer]struct{}
+	closed    bool
+}
+
+func (f *stateFanOut) add(filter StateFilter, opts []StateConsumerOption) *StateConsumer {
+	config := stateConsumerConfiguration{}
+	for _, o := range opts {
+		o(&config)
+	}
+	if config.bufferSize <= 0 {
+		config.bufferSize = DefaultStateConsumerBufferSize
+	}
+	c := &StateConsumer{
+		fanOut: f,
+		filter: filter,
+		c:      make(chan *State, config.bufferSize),
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.closed {
+		c.closed = true
+		close(c.c)
+		return c
+	}
+	if f.consumers == nil {
+		f.consumers = map[*StateConsumer]struct{}{}
+	}
+	f.consumers[c] = struct{}{}
+	return c
+}
+
+func (f *stateFanOut) remove(c *StateConsumer) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.consumers, c)
+}
+
+func (f *stateFanOut) publish(state *State) {
+	f.lock.Lock()
+	consumers := make([]*StateConsumer, 0, len(f.consumers))
+	for c := range f.consumers {
+		consumers = append(consumers, c)
+	}
+	f.lock.Unlock()
+
+	for _, c := range consumers {
+		if c.filter != nil && !c.filter(state) {
+			continue
+		}
+		if c.deliver(state) {
+			f.remove(c)
+		}
+	}
+}
+
+func (f *stateFanOut) close() {
+	f.lock.Lock()
+	f.closed = true
+	consumers := f.consumers
+	f.consumers = nil
+	f.lock.Unlock()
+
+	for c := range consumers {
+		c.close(nil)
+	}
+}
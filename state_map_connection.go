@@ -1,9 +1,12 @@
 package stagelinq
 
 import (
+	"context"
 	"encoding/json"
 	"net"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/icedream/go-stagelinq/internal/messages"
@@ -21,25 +24,49 @@ type StateMapConnection struct {
 	conn   *messageConnection
 	errC   chan error
 	stateC chan *State
+
+	lock       sync.Mutex
+	subscribed map[string]struct{}
+	cancelled  map[string]struct{}
+	handles    map[string]*StateSubscription
+	patterns   []*stateMapPatternSubscription
+	known      map[string]struct{}
+	fanOut     stateFanOut
+	writable   map[string]StateValueType
+}
+
+type stateMapPatternSubscription struct {
+	pattern statePattern
+	opts    []StateMapSubscriptionOption
+}
+
+type stateMapPendingSubscription struct {
+	name string
+	opts []StateMapSubscriptionOption
 }
 
-var stateMapConnectionMessageSet = newDeviceConnMessageSet([]messages.Message{
+var stateMapConnectionMessageSet = newServiceMessageSet("StateMap", []messages.Message{
 	&stateEmitMessage{},
 	&stateEmitResponseMessage{},
 })
 
 // NewStateMapConnection wraps an existing network connection and returns a StateMapConnection, providing the functionality to subscribe to and receive changes of state values.
 // You need to pass the token that you have announced for your own device on the network.
-func NewStateMapConnection(conn net.Conn, token Token) (smc *StateMapConnection, err error) {
-	msgConn := newMessageConnection(conn, stateMapConnectionMessageSet)
+func NewStateMapConnection(conn net.Conn, token Token, opts ...ConnectionOption) (smc *StateMapConnection, err error) {
+	msgConn := newMessageConnection(conn, stateMapConnectionMessageSet, opts...)
 
 	errC := make(chan error, 1)
 	stateC := make(chan *State, 1)
 
 	stateMapConn := &StateMapConnection{
-		conn:   msgConn,
-		errC:   errC,
-		stateC: stateC,
+		conn:       msgConn,
+		errC:       errC,
+		stateC:     stateC,
+		subscribed: map[string]struct{}{},
+		cancelled:  map[string]struct{}{},
+		handles:    map[string]*StateSubscription{},
+		known:      map[string]struct{}{},
+		writable:   newConnectionConfiguration(opts).writable,
 	}
 
 	// Announce our TCP source port to the device before subscribing. This
@@ -61,6 +88,7 @@ func NewStateMapConnection(conn net.Conn, token Token) (smc *StateMapConnection,
 				close(stateMapConn.errC)
 			}
 			close(stateMapConn.stateC)
+			stateMapConn.fanOut.close()
 		}()
 		for {
 			var msg messages.Message
@@ -78,7 +106,13 @@ func NewStateMapConnection(conn net.Conn, token Token) (smc *StateMapConnection,
 				if err != nil {
 					return
 				}
+				if !stateMapConn.wants(state.Name) {
+					continue
+				}
+				stateMapConn.fanOut.publish(state)
 				stateC <- state
+			case *stateEmitResponseMessage:
+				stateMapConn.acknowledge(v.Name, v.Interval)
 			}
 		}
 	}()
@@ -103,6 +137,85 @@ func WithInterval(d time.Duration) StateMapSubscriptionOption {
 // Subscribe tells the StagelinQ device to send us updates for the given state
 // value path.
 func (smc *StateMapConnection) Subscribe(event string, opts ...StateMapSubscriptionOption) error {
+	smc.lock.Lock()
+	smc.subscribed[event] = struct{}{}
+	delete(smc.cancelled, event)
+	smc.lock.Unlock()
+	return smc.subscribe(event, opts)
+}
+
+// SubscribeContext subscribes like Subscribe and waits for the device to
+// acknowledge the subscription until ctx is done. The returned handle can be
+// used to modify or cancel the subscription later on. It is also returned if
+// the acknowledgement did not arrive in time, in which case the error wraps
+// ErrSubscriptionTimeout.
+//
+// Subscribing to the same path again returns the same handle.
+func (smc *StateMapConnection) SubscribeContext(ctx context.Context, event string, opts ...StateMapSubscriptionOption) (sub *StateSubscription, err error) {
+	smc.lock.Lock()
+	sub, ok := smc.handles[event]
+	if !ok {
+		sub = &StateSubscription{
+			owner: smc,
+			name:  event,
+			acked: make(chan struct{}),
+		}
+		smc.handles[event] = sub
+	}
+	smc.subscribed[event] = struct{}{}
+	delete(smc.cancelled, event)
+	smc.lock.Unlock()
+
+	if err = sub.send(opts); err != nil {
+		return
+	}
+	err = sub.Wait(ctx)
+	return
+}
+
+func (smc *StateMapConnection) acknowledge(event string, interval uint32) {
+	smc.lock.Lock()
+	sub, ok := smc.handles[event]
+	smc.lock.Unlock()
+	if ok {
+		sub.acknowledge(interval)
+	}
+}
+
+func (smc *StateMapConnection) sendSubscription(sub *StateSubscription, opts []StateMapSubscriptionOption) error {
+	return smc.subscribe(sub.name, opts)
+}
+
+// adopt makes the connection deliver acknowledgements for the path of the
+// given handle to it, used for handles of a Session.
+func (smc *StateMapConnection) adopt(sub *StateSubscription) {
+	smc.lock.Lock()
+	defer smc.lock.Unlock()
+	smc.handles[sub.name] = sub
+	smc.subscribed[sub.name] = struct{}{}
+	delete(smc.cancelled, sub.name)
+}
+
+func (smc *StateMapConnection) cancelSubscription(sub *StateSubscription) {
+	smc.lock.Lock()
+	defer smc.lock.Unlock()
+	if smc.handles[sub.name] == sub {
+		delete(smc.handles, sub.name)
+	}
+	delete(smc.subscribed, sub.name)
+	smc.cancelled[sub.name] = struct{}{}
+}
+
+// NewStateConsumer returns a consumer that receives all states accepted by
+// the given filter, or all states if filter is nil. Consumers are independent
+// of each other but receive states in addition to StateC, which still has to
+// be read from. Deliveries to consumers never block, a consumer whose buffer
+// is full is closed instead, see StateConsumer.Err.
+func (smc *StateMapConnection) NewStateConsumer(filter StateFilter, opts ...StateConsumerOption) *StateConsumer {
+	return smc.fanOut.add(filter, opts)
+}
+
+func (smc *StateMapConnection) subscribe(event string, opts []StateMapSubscriptionOption) error {
 	m := &stateSubscribeMessage{
 		Name: event,
 	}
@@ -112,6 +225,128 @@ func (smc *StateMapConnection) Subscribe(event string, opts ...StateMapSubscript
 	return smc.conn.WriteMessage(m)
 }
 
+// SubscribeAll subscribes to all state value paths starting with the given
+// prefix, for example "/Engine/Deck1/". See SubscribeGlob for how paths are
+// found and filtered.
+func (smc *StateMapConnection) SubscribeAll(prefix string, opts ...StateMapSubscriptionOption) error {
+	return smc.subscribePattern(statePattern{prefix: prefix}, opts)
+}
+
+// SubscribeGlob subscribes to all state value paths matching the given glob
+// pattern, for example "/Engine/Deck*/Track/*". A * matches any sequence of
+// characters except slashes, see MatchStatePath.
+//
+// The device only understands exact paths and has no way of listing them, so
+// the pattern is expanded against KnownStateValueNames and all paths added via
+// AddKnownPaths. Paths added later on are subscribed to as soon as they are
+// added.
+//
+// Once a pattern subscription exists, StateC only returns states that have
+// been subscribed to, dropping anything else the device sends.
+func (smc *StateMapConnection) SubscribeGlob(pattern string, opts ...StateMapSubscriptionOption) error {
+	p, err := newGlobStatePattern(pattern)
+	if err != nil {
+		return err
+	}
+	return smc.subscribePattern(p, opts)
+}
+
+func (smc *StateMapConnection) subscribePattern(p statePattern, opts []StateMapSubscriptionOption) (err error) {
+	sub := &stateMapPatternSubscription{pattern: p, opts: opts}
+
+	smc.lock.Lock()
+	smc.patterns = append(smc.patterns, sub)
+	pending := []*stateMapPendingSubscription{}
+	match := func(name string) {
+		if _, ok := smc.subscribed[name]; ok || !p.Match(name) {
+			return
+		}
+		if _, ok := smc.cancelled[name]; ok {
+			return
+		}
+		smc.subscribed[name] = struct{}{}
+		pending = append(pending, &stateMapPendingSubscription{name: name, opts: opts})
+	}
+	for _, name := range KnownStateValueNames() {
+		match(name)
+	}
+	for _, name := range smc.knownPaths() {
+		match(name)
+	}
+	smc.lock.Unlock()
+
+	return smc.subscribePending(pending)
+}
+
+func (smc *StateMapConnection) subscribePending(pending []*stateMapPendingSubscription) (err error) {
+	for _, sub := range pending {
+		if err = smc.subscribe(sub.name, sub.opts); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// AddKnownPaths makes the connection aware of state value paths that are not
+// part of KnownStateValueNames, for example paths found by exploring another
+// device. Paths matching an existing pattern subscription are subscribed to.
+func (smc *StateMapConnection) AddKnownPaths(names ...string) error {
+	smc.lock.Lock()
+	pending := []*stateMapPendingSubscription{}
+	for _, name := range names {
+		if _, ok := smc.known[name]; ok {
+			continue
+		}
+		smc.known[name] = struct{}{}
+		if _, ok := smc.subscribed[name]; ok {
+			continue
+		}
+		if _, ok := smc.cancelled[name]; ok {
+			continue
+		}
+		for _, sub := range smc.patterns {
+			if sub.pattern.Match(name) {
+				smc.subscribed[name] = struct{}{}
+				pending = append(pending, &stateMapPendingSubscription{name: name, opts: sub.opts})
+				break
+			}
+		}
+	}
+	smc.lock.Unlock()
+
+	return smc.subscribePending(pending)
+}
+
+// KnownPaths returns the state value paths added via AddKnownPaths, sorted.
+func (smc *StateMapConnection) KnownPaths() []string {
+	smc.lock.Lock()
+	defer smc.lock.Unlock()
+	return smc.knownPaths()
+}
+
+func (smc *StateMapConnection) knownPaths() []string {
+	names := make([]string, 0, len(smc.known))
+	for name := range smc.known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// wants tells whether a received state should be passed on to StateC.
+func (smc *StateMapConnection) wants(name string) bool {
+	smc.lock.Lock()
+	defer smc.lock.Unlock()
+	if _, ok := smc.cancelled[name]; ok {
+		return false
+	}
+	if len(smc.patterns) == 0 {
+		return true
+	}
+	_, ok := smc.subscribed[name]
+	return ok
+}
+
 func (smc *StateMapConnection) Emit(state *State) error {
 	jsonBytes, err := json.Marshal(state.Value)
 	if err != nil {
@@ -123,6 +358,44 @@ func (smc *StateMapConnection) Emit(state *State) error {
 	})
 }
 
+// Set writes a state value to the device, encoded according to the type of
+// the path on the allowlist of writable paths. Paths that are not on the
+// allowlist fail with ErrStateNotWritable, see WithWritableStates.
+func (smc *StateMapConnection) Set(name string, value interface{}) error {
+	state, err := encodeWritableState(smc.writable, name, value)
+	if err != nil {
+		return err
+	}
+	return smc.Emit(state)
+}
+
+// SetContext writes a state value like Set and waits until the device echoes
+// the new value back or ctx is done. The path is subscribed to if needed.
+// StateC has to be read from while waiting.
+func (smc *StateMapConnection) SetContext(ctx context.Context, name string, value interface{}) (err error) {
+	state, err := encodeWritableState(smc.writable, name, value)
+	if err != nil {
+		return
+	}
+	// only the written path counts against the buffer of the consumer, so
+	// other traffic can't make it overflow
+	consumer := smc.NewStateConsumer(StateNameFilter(name))
+	smc.lock.Lock()
+	_, subscribed := smc.subscribed[name]
+	smc.lock.Unlock()
+	if !subscribed {
+		if err = smc.Subscribe(name); err != nil {
+			consumer.Close()
+			return
+		}
+	}
+	if err = smc.Emit(state); err != nil {
+		consumer.Close()
+		return
+	}
+	return waitForEcho(ctx, consumer, state)
+}
+
 // StateC returns the channel via which state changes will be returned for this connection.
 func (smc *StateMapConnection) StateC() <-chan *State {
 	return smc.stateC
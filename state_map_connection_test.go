@@ -0,0 +1,249 @@
+package stagelinq
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_KnownStateValueNames(t *testing.T) {
+	names := KnownStateValueNames()
+	require.Contains(t, names, MixerCrossfaderPosition)
+	require.Contains(t, names, EngineDeck4.TrackSongName())
+	require.Contains(t, names, EngineDeck2.TrackAutoLoopLabel(8))
+	require.Contains(t, names, EngineMixerChannel3.PFL())
+	require.Contains(t, names, GUIDecksSideActiveDeck("Right"))
+	require.IsIncreasing(t, names)
+}
+
+func Test_MatchStatePath(t *testing.T) {
+	ok, err := MatchStatePath("/Engine/Deck*/Track/*", EngineDeck3.TrackArtistName())
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = MatchStatePath("/Engine/Deck*/Track/*", EngineDeck3.TrackLoopActive())
+	require.NoError(t, err)
+	require.False(t, ok)
+	_, err = MatchStatePath("/Engine/[", "/Engine/Deck1")
+	require.Error(t, err)
+}
+
+// stateMapTestPeer is the device side of a StateMapConnection under test.
+type stateMapTestPeer struct {
+	t    *testing.T
+	conn *messageConnection
+}
+
+func newTestStateMapConnection(t *testing.T) (*StateMapConnection, *stateMapTestPeer) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	peerC := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			peerC <- conn
+		}
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	smc, err := NewStateMapConnection(conn, Token{1})
+	require.NoError(t, err)
+	peerConn := <-peerC
+	t.Cleanup(func() { peerConn.Close() })
+	return smc, &stateMapTestPeer{
+		t:    t,
+		conn: newMessageConnection(peerConn, decoderMessageSets["StateMap"]),
+	}
+}
+
+func (p *stateMapTestPeer) readSubscription() *stateSubscribeMessage {
+	for {
+		msg, err := p.conn.ReadMessage()
+		require.NoError(p.t, err)
+		if sub, ok := msg.(*stateSubscribeMessage); ok {
+			return sub
+		}
+	}
+}
+
+func (p *stateMapTestPeer) emit(name string) {
+	require.NoError(p.t, p.conn.WriteMessage(&stateEmitMessage{
+		Name: name,
+		JSON: `{"state":true,"type":1}`,
+	}))
+}
+
+func Test_StateMapConnection_PatternSubscriptions(t *testing.T) {
+	smc, peer := newTestStateMapConnection(t)
+	readSubscription := func() string {
+		return peer.readSubscription().Name
+	}
+	emit := peer.emit
+
+	// globs expand against the catalog
+	require.Error(t, smc.SubscribeGlob("/Engine/["))
+	require.NoError(t, smc.SubscribeGlob("/Engine/Deck*/Track/SongName"))
+	subscribed := []string{}
+	for i := 0; i < 4; i++ {
+		subscribed = append(subscribed, readSubscription())
+	}
+	require.ElementsMatch(t, []string{
+		EngineDeck1.TrackSongName(),
+		EngineDeck2.TrackSongName(),
+		EngineDeck3.TrackSongName(),
+		EngineDeck4.TrackSongName(),
+	}, subscribed)
+
+	// states nobody subscribed to are filtered out
+	emit(EngineMasterMasterTempo)
+	emit(EngineDeck2.TrackSongName())
+	state := <-smc.StateC()
+	require.Equal(t, EngineDeck2.TrackSongName(), state.Name)
+
+	// unknown paths are subscribed to once they are added
+	require.NoError(t, smc.SubscribeAll("/Custom/"))
+	require.NoError(t, smc.AddKnownPaths("/Custom/Value", "/Unrelated"))
+	require.Equal(t, "/Custom/Value", readSubscription())
+	emit("/Custom/Value")
+	state = <-smc.StateC()
+	require.Equal(t, "/Custom/Value", state.Name)
+	require.Equal(t, []string{"/Custom/Value", "/Unrelated"}, smc.KnownPaths())
+}
+
+func Test_StateMapConnection_SubscribeContext(t *testing.T) {
+	smc, peer := newTestStateMapConnection(t)
+
+	// acknowledge subscriptions with the requested interval
+	go func() {
+		for {
+			msg, err := peer.conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			sub, ok := msg.(*stateSubscribeMessage)
+			if !ok || sub.Name == EngineDeck2.Play() {
+				continue
+			}
+			_ = peer.conn.WriteMessage(&stateEmitResponseMessage{Name: sub.Name, Interval: sub.Interval})
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := smc.SubscribeContext(ctx, EngineDeck1.Play(), WithInterval(100*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, EngineDeck1.Play(), sub.Name())
+	interval, ok := sub.Interval()
+	require.True(t, ok)
+	require.Equal(t, 100*time.Millisecond, interval)
+
+	require.NoError(t, sub.Modify(ctx, WithInterval(time.Second)))
+	interval, _ = sub.Interval()
+	require.Equal(t, time.Second, interval)
+
+	// the peer never acknowledges this one
+	shortCtx, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer shortCancel()
+	unacked, err := smc.SubscribeContext(shortCtx, EngineDeck2.Play())
+	require.ErrorIs(t, err, ErrSubscriptionTimeout)
+	_, ok = unacked.Interval()
+	require.False(t, ok)
+
+	// StateC loses nothing, the connection waits for it to be read instead
+	for range DefaultStateConsumerBufferSize + 10 {
+		peer.emit(EngineDeck2.Play())
+	}
+	for range DefaultStateConsumerBufferSize + 10 {
+		require.Equal(t, EngineDeck2.Play(), (<-smc.StateC()).Name)
+	}
+
+	// consumers see states independently and filtered
+	deck1 := smc.NewStateConsumer(StateNameFilter(EngineDeck1.Play()))
+	all := smc.NewStateConsumer(nil)
+	peer.emit(EngineDeck2.Play())
+	peer.emit(EngineDeck1.Play())
+	require.Equal(t, EngineDeck2.Play(), (<-smc.StateC()).Name)
+	require.Equal(t, EngineDeck1.Play(), (<-smc.StateC()).Name)
+	require.Equal(t, EngineDeck1.Play(), (<-deck1.StateC()).Name)
+	require.Equal(t, EngineDeck2.Play(), (<-all.StateC()).Name)
+	require.Equal(t, EngineDeck1.Play(), (<-all.StateC()).Name)
+	deck1.Close()
+	_, ok = <-deck1.StateC()
+	require.False(t, ok)
+
+	// cancelled subscriptions are dropped
+	require.NoError(t, sub.Cancel())
+	require.ErrorIs(t, sub.Cancel(), ErrSubscriptionCancelled)
+	require.ErrorIs(t, sub.Modify(ctx), ErrSubscriptionCancelled)
+	peer.emit(EngineDeck1.Play())
+	peer.emit(EngineDeck2.Play())
+	require.Equal(t, EngineDeck2.Play(), (<-smc.StateC()).Name)
+	require.Equal(t, EngineDeck2.Play(), (<-all.StateC()).Name)
+
+	// closing the connection closes remaining consumers
+	require.NoError(t, peer.conn.conn.Close())
+	for range smc.StateC() {
+	}
+	_, ok = <-all.StateC()
+	require.False(t, ok)
+}
+
+func Test_LookupStateValue(t *testing.T) {
+	info, ok := LookupStateValue(EngineDeck2.TrackCurrentBPM())
+	require.True(t, ok)
+	require.Equal(t, "DeckValueNames.TrackCurrentBPM", info.Accessor)
+	require.Equal(t, StateValueTypeNumber, info.Type)
+	require.Equal(t, "BPM", info.Unit)
+
+	info, ok = LookupStateValue(ConfigurationComputerMode)
+	require.True(t, ok)
+	require.True(t, info.Writable)
+	require.Equal(t, StateValueTypeBoolean, DefaultWritableStates[ConfigurationComputerMode])
+
+	_, ok = LookupStateValue("/Unknown")
+	require.False(t, ok)
+	verified := 0
+	for _, info := range StateValueCatalog() {
+		if !info.Unverified {
+			verified++
+		}
+	}
+	require.Len(t, KnownStateValueNames(), verified)
+
+	// unverified paths are not subscribed to by patterns
+	info, ok = LookupStateValue(ClientPreferencesQuantize)
+	require.True(t, ok)
+	require.True(t, info.Unverified)
+	require.NotContains(t, KnownStateValueNames(), ClientPreferencesQuantize)
+}
+
+func Test_StateFanOut_Overflow(t *testing.T) {
+	var f stateFanOut
+	slow := f.add(nil, []StateConsumerOption{WithStateConsumerBufferSize(2)})
+	fast := f.add(nil, []StateConsumerOption{WithStateConsumerBufferSize(2)})
+	large := f.add(nil, nil)
+	require.Equal(t, DefaultStateConsumerBufferSize, cap(large.StateC()))
+	for _, name := range []string{"a", "b", "c"} {
+		f.publish(&State{Name: name})
+		require.Equal(t, name, (<-fast.StateC()).Name)
+	}
+
+	// consumers that fall behind are closed
+	require.Equal(t, "a", (<-slow.StateC()).Name)
+	require.Equal(t, "b", (<-slow.StateC()).Name)
+	_, ok := <-slow.StateC()
+	require.False(t, ok)
+	require.ErrorIs(t, slow.Err(), ErrStateConsumerOverflow)
+	require.NoError(t, fast.Err())
+	require.Len(t, large.StateC(), 3)
+
+	f.close()
+	_, ok = <-fast.StateC()
+	require.False(t, ok)
+	require.NoError(t, fast.Err())
+}
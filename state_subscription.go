@@ -0,0 +1,136 @@
+package stagelinq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSubscriptionTimeout is returned if a device does not acknowledge a
+// subscription in time.
+var ErrSubscriptionTimeout = errors.New("subscription not acknowledged")
+
+// ErrSubscriptionCancelled is returned by StateSubscription methods after the
+// subscription has been cancelled.
+var ErrSubscriptionCancelled = errors.New("subscription cancelled")
+
+// StateSubscription is a handle for a subscription to a single state value
+// path, as returned by StateMapConnection.SubscribeContext and
+// Session.SubscribeContext.
+type StateSubscription struct {
+	owner stateSubscriptionOwner
+	name  string
+
+	lock      sync.Mutex
+	interval  time.Duration
+	acked     chan struct{}
+	cancelled bool
+}
+
+// stateSubscriptionOwner sends and cancels subscriptions on behalf of their
+// handles, it is either a StateMapConnection or a Session.
+type stateSubscriptionOwner interface {
+	sendSubscription(sub *StateSubscription, opts []StateMapSubscriptionOption) error
+	cancelSubscription(sub *StateSubscription)
+}
+
+// Name returns the state value path subscribed to.
+func (sub *StateSubscription) Name() string {
+	return sub.name
+}
+
+// Interval returns the update interval the device confirmed when it
+// acknowledged the subscription. ok is false as long as the subscription has
+// not been acknowledged.
+func (sub *StateSubscription) Interval() (interval time.Duration, ok bool) {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	select {
+	case <-sub.acked:
+		return sub.interval, true
+	default:
+		return
+	}
+}
+
+// Wait blocks until the device acknowledged the subscription or ctx is done,
+// in which case the error wraps ErrSubscriptionTimeout.
+func (sub *StateSubscription) Wait(ctx context.Context) error {
+	sub.lock.Lock()
+	acked := sub.acked
+	cancelled := sub.cancelled
+	sub.lock.Unlock()
+	if cancelled {
+		return ErrSubscriptionCancelled
+	}
+
+	select {
+	case <-acked:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %s: %w", ErrSubscriptionTimeout, sub.name, ctx.Err())
+	}
+}
+
+// Modify sends the subscription again with the given options, for example a
+// different interval, and waits for the device to acknowledge it.
+func (sub *StateSubscription) Modify(ctx context.Context, opts ...StateMapSubscriptionOption) (err error) {
+	if err = sub.send(opts); err != nil {
+		return
+	}
+	return sub.Wait(ctx)
+}
+
+// Cancel stops delivering states of this subscription. The StateMap protocol
+// has no known way to unsubscribe, so the device keeps sending the value and
+// the connection drops it.
+func (sub *StateSubscription) Cancel() error {
+	sub.lock.Lock()
+	if sub.cancelled {
+		sub.lock.Unlock()
+		return ErrSubscriptionCancelled
+	}
+	sub.cancelled = true
+	sub.lock.Unlock()
+
+	sub.owner.cancelSubscription(sub)
+	return nil
+}
+
+func (sub *StateSubscription) send(opts []StateMapSubscriptionOption) error {
+	if err := sub.reset(); err != nil {
+		return err
+	}
+	return sub.owner.sendSubscription(sub, opts)
+}
+
+// reset forgets about the last acknowledgement before the subscription is
+// sent again. A pending acknowledgement is kept waiting for.
+func (sub *StateSubscription) reset() error {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	if sub.cancelled {
+		return ErrSubscriptionCancelled
+	}
+	select {
+	case <-sub.acked:
+		sub.acked = make(chan struct{})
+	default:
+	}
+	return nil
+}
+
+// acknowledge marks the subscription as confirmed by the device.
+func (sub *StateSubscription) acknowledge(interval uint32) {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	select {
+	case <-sub.acked:
+		// duplicate acknowledgement
+	default:
+		sub.interval = time.Duration(interval) * time.Millisecond
+		close(sub.acked)
+	}
+}
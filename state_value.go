@@ -0,0 +1,217 @@
+package stagelinq
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// ErrStateNotWritable is returned when trying to set a state value path that
+// is not on the allowlist of writable paths, see WithWritableStates.
+var ErrStateNotWritable = errors.New("state value is not writable")
+
+// StateValueType is the type of a state value as given in the "type" field of
+// its JSON representation.
+type StateValueType int
+
+const (
+	// StateValueTypeNumber is a floating point number, {"type":0,"value":1.5}.
+	StateValueTypeNumber StateValueType = 0
+
+	// StateValueTypeBoolean is a boolean, {"state":true,"type":1}.
+	StateValueTypeBoolean StateValueType = 1
+
+	// StateValueTypeEnum is one of a fixed set of strings,
+	// {"string":"High","type":4}.
+	StateValueTypeEnum StateValueType = 4
+
+	// StateValueTypeString is a free-form string, {"string":"Title","type":8}.
+	StateValueTypeString StateValueType = 8
+
+	// StateValueTypeInteger is an integer, {"type":10,"value":3}.
+	StateValueTypeInteger StateValueType = 10
+
+	// StateValueTypeColor is an ARGB color, {"color":"#ffff0000","type":16}.
+	StateValueTypeColor StateValueType = 16
+)
+
+func (t StateValueType) String() string {
+	switch t {
+	case StateValueTypeNumber:
+		return "number"
+	case StateValueTypeBoolean:
+		return "boolean"
+	case StateValueTypeEnum:
+		return "enum"
+	case StateValueTypeString:
+		return "string"
+	case StateValueTypeInteger:
+		return "integer"
+	case StateValueTypeColor:
+		return "color"
+	default:
+		return fmt.Sprintf("StateValueType(%d)", int(t))
+	}
+}
+
+// key returns the name of the JSON field holding the actual value.
+func (t StateValueType) key() string {
+	switch t {
+	case StateValueTypeBoolean:
+		return "state"
+	case StateValueTypeEnum, StateValueTypeString:
+		return "string"
+	case StateValueTypeColor:
+		return "color"
+	default:
+		return "value"
+	}
+}
+
+// DefaultWritableStates lists the state value paths devices are known to
+// accept from peers, along with their types. It is taken from the writable
+// entries of StateValueCatalog.
+var DefaultWritableStates = catalogWritableStates()
+
+// EncodeStateValue converts a Go value into the JSON representation of a
+// state value of the given type. Numbers accept any numeric Go type, integers
+// any integral value, booleans a bool, enums and strings a string, and colors
+// either a color.Color or a string formatted as #AARRGGBB or #RRGGBB, for
+// example "#ffff0000". Colors without alpha are sent as opaque.
+func EncodeStateValue(t StateValueType, v interface{}) (value map[string]interface{}, err error) {
+	var encoded interface{}
+	switch t {
+	case StateValueTypeBoolean:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s value must be a bool, got %T", t, v)
+		}
+		encoded = b
+	case StateValueTypeEnum, StateValueTypeString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s value must be a string, got %T", t, v)
+		}
+		encoded = s
+	case StateValueTypeNumber:
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("%s value must be numeric, got %T", t, v)
+		}
+		encoded = f
+	case StateValueTypeInteger:
+		f, ok := toFloat(v)
+		if !ok || f != math.Trunc(f) {
+			return nil, fmt.Errorf("%s value must be integral, got %v", t, v)
+		}
+		encoded = int64(f)
+	case StateValueTypeColor:
+		switch c := v.(type) {
+		case color.Color:
+			nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+			encoded = fmt.Sprintf("#%02x%02x%02x%02x", nrgba.A, nrgba.R, nrgba.G, nrgba.B)
+		case string:
+			s, ok := parseColorString(c)
+			if !ok {
+				return nil, fmt.Errorf("%s value must be formatted as #AARRGGBB or #RRGGBB, got %q", t, c)
+			}
+			encoded = s
+		default:
+			return nil, fmt.Errorf("%s value must be a color.Color or string, got %T", t, v)
+		}
+	default:
+		return nil, fmt.Errorf("can't encode values of type %s", t)
+	}
+	return map[string]interface{}{
+		"type":  int(t),
+		t.key(): encoded,
+	}, nil
+}
+
+// parseColorString checks a color formatted as #AARRGGBB or #RRGGBB and
+// returns it as lower case #aarrggbb.
+func parseColorString(s string) (color string, ok bool) {
+	digits, ok := strings.CutPrefix(s, "#")
+	if !ok || (len(digits) != 6 && len(digits) != 8) {
+		return "", false
+	}
+	if _, err := hex.DecodeString(digits); err != nil {
+		return "", false
+	}
+	if len(digits) == 6 {
+		digits = "ff" + digits
+	}
+	return "#" + strings.ToLower(digits), true
+}
+
+func toFloat(v interface{}) (f float64, ok bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return
+}
+
+// encodeWritableState checks a path against the allowlist and encodes the
+// value for it.
+func encodeWritableState(writable map[string]StateValueType, name string, v interface{}) (state *State, err error) {
+	t, ok := writable[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrStateNotWritable, name)
+	}
+	value, err := EncodeStateValue(t, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &State{Name: name, Value: value}, nil
+}
+
+// stateValueEqual compares the value a device echoed with the value we sent.
+// Only the field holding the actual value is compared, as received values
+// went through JSON decoding and may carry additional fields.
+func stateValueEqual(sent, received map[string]interface{}) bool {
+	t, _ := toFloat(sent["type"])
+	key := StateValueType(t).key()
+	b, err := json.Marshal(sent[key])
+	if err != nil {
+		return false
+	}
+	var want interface{}
+	if err = json.Unmarshal(b, &want); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(want, received[key])
+}
+
+// waitForEcho waits until the consumer receives the given state or ctx is
+// done.
+func waitForEcho(ctx context.Context, consumer *StateConsumer, state *State) error {
+	defer consumer.Close()
+	for {
+		select {
+		case received, ok := <-consumer.StateC():
+			if !ok {
+				if err := consumer.Err(); err != nil {
+					return fmt.Errorf("%s was not echoed: %w", state.Name, err)
+				}
+				return errors.New("connection closed before value was echoed")
+			}
+			if stateValueEqual(state.Value, received.Value) {
+				return nil
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("%s was not echoed: %w", state.Name, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package stagelinq
+
+//go:generate go run ./internal/generate/valuenames -catalog state_values.json -output value_names_gen.go
+
+import (
+	"bytes"
+	_ "embed"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/icedream/go-stagelinq/internal/catalog"
+)
+
+// stateValuesJSON is the catalog of known state value paths. The accessors in
+// value_names_gen.go are generated from it.
+//
+//go:embed state_values.json
+var stateValuesJSON []byte
+
+// StateValueInfo describes a known state value path.
+type StateValueInfo struct {
+	Path string
+
+	// Accessor is the name of the Go constant, function or method returning
+	// the path, for example "DeckValueNames.TrackArtistName".
+	Accessor string
+
+	Type        StateValueType
+	Unit        string
+	Description string
+
+	// Writable tells whether devices accept the value from peers, see
+	// StateMapConnection.Set.
+	Writable bool
+
+	// Devices lists the devices the value has been observed on. Empty if
+	// that is not known.
+	Devices []string
+
+	// Unverified marks paths that have not been observed on a device yet.
+	// They are left out of KnownStateValueNames.
+	Unverified bool
+}
+
+var (
+	stateValueCatalogOnce sync.Once
+	stateValueCatalog     []*StateValueInfo
+)
+
+func loadStateValueCatalog() []*StateValueInfo {
+	stateValueCatalogOnce.Do(func() {
+		c, err := catalog.Parse(bytes.NewReader(stateValuesJSON))
+		if err != nil {
+			// the catalog is validated when generating code
+			panic("invalid state value catalog: " + err.Error())
+		}
+		types := map[string]StateValueType{}
+		for _, t := range []StateValueType{
+			StateValueTypeNumber,
+			StateValueTypeBoolean,
+			StateValueTypeEnum,
+			StateValueTypeString,
+			StateValueTypeInteger,
+			StateValueTypeColor,
+		} {
+			types[t.String()] = t
+		}
+		for _, p := range c.Paths() {
+			accessor := p.Value.Name
+			if p.Group != nil {
+				accessor = p.Group.Type + "." + accessor
+			}
+			stateValueCatalog = append(stateValueCatalog, &StateValueInfo{
+				Path:        p.Path,
+				Accessor:    accessor,
+				Type:        types[p.Value.Type],
+				Unit:        p.Value.Unit,
+				Description: strings.TrimSuffix(p.Value.Description, "."),
+				Writable:    p.Value.Writable,
+				Devices:     p.Value.Devices,
+				Unverified:  p.Value.Unverified,
+			})
+		}
+		sort.Slice(stateValueCatalog, func(i, j int) bool {
+			return stateValueCatalog[i].Path < stateValueCatalog[j].Path
+		})
+	})
+	return stateValueCatalog
+}
+
+// StateValueCatalog returns descriptions of all state value paths this library
+// knows of, sorted by path. Paths of decks, mixer channels and other groups
+// are listed for every index.
+func StateValueCatalog() []StateValueInfo {
+	c := loadStateValueCatalog()
+	infos := make([]StateValueInfo, len(c))
+	for i, info := range c {
+		infos[i] = *info
+	}
+	return infos
+}
+
+// LookupStateValue returns the catalog entry of a state value path.
+func LookupStateValue(name string) (info StateValueInfo, ok bool) {
+	c := loadStateValueCatalog()
+	i := sort.Search(len(c), func(i int) bool {
+		return c[i].Path >= name
+	})
+	if i < len(c) && c[i].Path == name {
+		return *c[i], true
+	}
+	return
+}
+
+// KnownStateValueNames returns all state value paths this library knows of,
+// sorted. This includes the paths of all decks and mixer channels but not
+// unverified paths, which are only listed by StateValueCatalog.
+func KnownStateValueNames() []string {
+	c := loadStateValueCatalog()
+	names := make([]string, 0, len(c))
+	for _, info := range c {
+		if !info.Unverified {
+			names = append(names, info.Path)
+		}
+	}
+	return names
+}
+
+// catalogWritableStates returns the writable paths of the catalog.
+func catalogWritableStates() map[string]StateValueType {
+	writable := map[string]StateValueType{}
+	for _, info := range loadStateValueCatalog() {
+		if info.Writable {
+			writable[info.Path] = info.Type
+		}
+	}
+	return writable
+}
+
+// statePattern matches state value paths either by prefix or by a glob
+// pattern as understood by path.Match, in which * does not match across
+// slashes.
+type statePattern struct {
+	prefix string
+	glob   string
+}
+
+func newGlobStatePattern(glob string) (p statePattern, err error) {
+	// validate the pattern once so matching never fails later on
+	if _, err = path.Match(glob, ""); err != nil {
+		return
+	}
+	p.glob = glob
+	return
+}
+
+func (p statePattern) isGlob() bool {
+	return len(p.glob) > 0
+}
+
+func (p statePattern) Match(name string) bool {
+	if p.isGlob() {
+		ok, _ := path.Match(p.glob, name)
+		return ok
+	}
+	return strings.HasPrefix(name, p.prefix)
+}
+
+// MatchStatePath reports whether a state value path matches a glob pattern
+// such as "/Engine/Deck*/Track/*". A * matches any sequence of characters
+// except slashes.
+func MatchStatePath(pattern, name string) (bool, error) {
+	return path.Match(pattern, name)
+}
@@ -0,0 +1,119 @@
+package stagelinq
+
+import (
+	"context"
+	"encoding/json"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EncodeStateValue(t *testing.T) {
+	for _, test := range []struct {
+		t     StateValueType
+		value interface{}
+		json  string
+	}{
+		{StateValueTypeBoolean, true, `{"state":true,"type":1}`},
+		{StateValueTypeEnum, "High", `{"string":"High","type":4}`},
+		{StateValueTypeString, "Title", `{"string":"Title","type":8}`},
+		{StateValueTypeNumber, 1.5, `{"type":0,"value":1.5}`},
+		{StateValueTypeNumber, 2, `{"type":0,"value":2}`},
+		{StateValueTypeInteger, uint8(3), `{"type":10,"value":3}`},
+		{StateValueTypeInteger, 4.0, `{"type":10,"value":4}`},
+		{StateValueTypeColor, color.RGBA{R: 0xff, A: 0xff}, `{"color":"#ffff0000","type":16}`},
+		{StateValueTypeColor, "#ff00ff00", `{"color":"#ff00ff00","type":16}`},
+		{StateValueTypeColor, "#00FF00", `{"color":"#ff00ff00","type":16}`},
+	} {
+		value, err := EncodeStateValue(test.t, test.value)
+		require.NoError(t, err, test.t.String())
+		b, err := json.Marshal(value)
+		require.NoError(t, err)
+		require.JSONEq(t, test.json, string(b))
+	}
+
+	for _, test := range []struct {
+		t     StateValueType
+		value interface{}
+	}{
+		{StateValueTypeBoolean, "true"},
+		{StateValueTypeString, 1},
+		{StateValueTypeNumber, "1"},
+		{StateValueTypeInteger, 1.5},
+		{StateValueTypeColor, "red"},
+		{StateValueTypeColor, "#"},
+		{StateValueTypeColor, "#f00"},
+		{StateValueTypeColor, "#ff00ff0g"},
+		{StateValueTypeColor, "#ff00ff00ff"},
+		{StateValueType(3), 1},
+	} {
+		_, err := EncodeStateValue(test.t, test.value)
+		require.Error(t, err, test.t.String())
+	}
+}
+
+func Test_StateMapConnection_Set(t *testing.T) {
+	smc, peer := newTestStateMapConnection(t)
+
+	require.ErrorIs(t, smc.Set(EngineDeck1.Play(), true), ErrStateNotWritable)
+	require.Error(t, smc.Set(ConfigurationComputerMode, "yes"))
+
+	require.NoError(t, smc.Set(ConfigurationComputerMode, true))
+	msg, err := peer.conn.ReadMessage()
+	require.NoError(t, err)
+	for {
+		if _, ok := msg.(*stateEmitMessage); ok {
+			break
+		}
+		msg, err = peer.conn.ReadMessage()
+		require.NoError(t, err)
+	}
+	emit := msg.(*stateEmitMessage)
+	require.Equal(t, ConfigurationComputerMode, emit.Name)
+	require.JSONEq(t, `{"state":true,"type":1}`, emit.JSON)
+
+	// echo values back like a device would
+	go func() {
+		for {
+			msg, err := peer.conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if emit, ok := msg.(*stateEmitMessage); ok {
+				_ = peer.conn.WriteMessage(&stateEmitMessage{Name: emit.Name, JSON: `{"type":4,"string":"Low"}`})
+				_ = peer.conn.WriteMessage(emit)
+			}
+		}
+	}()
+	go drain(smc.StateC())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, smc.SetContext(ctx, ClientPreferencesScreenBrightnessPluggedIn, "Max"))
+}
+
+func Test_WithWritableStates(t *testing.T) {
+	c := newConnectionConfiguration([]ConnectionOption{
+		WithWritableStates(map[string]StateValueType{EngineDeck1.Play(): StateValueTypeBoolean}),
+	})
+	require.Equal(t, StateValueTypeBoolean, c.writable[EngineDeck1.Play()])
+	require.Equal(t, StateValueTypeBoolean, c.writable[ConfigurationComputerMode])
+	require.NotContains(t, DefaultWritableStates, EngineDeck1.Play())
+}
+
+func Test_waitForEcho_Overflow(t *testing.T) {
+	var f stateFanOut
+	consumer := f.add(StateNameFilter(EngineDeck1.Play()), []StateConsumerOption{WithStateConsumerBufferSize(1)})
+	// other paths don't count against the buffer
+	f.publish(&State{Name: EngineDeck2.Play(), Value: map[string]interface{}{"state": true, "type": 1}})
+	f.publish(&State{Name: EngineDeck1.Play(), Value: map[string]interface{}{"state": false, "type": 1}})
+	f.publish(&State{Name: EngineDeck1.Play(), Value: map[string]interface{}{"state": false, "type": 1}})
+
+	err := waitForEcho(context.Background(), consumer, &State{
+		Name:  EngineDeck1.Play(),
+		Value: map[string]interface{}{"state": true, "type": 1},
+	})
+	require.ErrorIs(t, err, ErrStateConsumerOverflow)
+}
@@ -0,0 +1,28 @@
+package stagelinq
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// formatToken formats a token like a UUID, which is also how devices refer to
+// each other in StateMap values.
+func formatToken(t Token) string {
+	s := hex.EncodeToString(t[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// ParseToken parses a token formatted as UUID, with or without dashes and
+// surrounding braces.
+func ParseToken(s string) (token Token, err error) {
+	raw := strings.ReplaceAll(strings.Trim(s, "{}"), "-", "")
+	if len(raw) != 2*len(token) {
+		err = fmt.Errorf("invalid token %q", s)
+		return
+	}
+	if _, err = hex.Decode(token[:], []byte(raw)); err != nil {
+		err = fmt.Errorf("invalid token %q: %w", s, err)
+	}
+	return
+}
@@ -1,57 +1,5 @@
 package stagelinq
 
-import "fmt"
-
-const (
-	ClientLibrarianDevicesControllerCurrentDevice         = "/Client/Librarian/DevicesController/CurrentDevice"
-	ClientLibrarianDevicesControllerCurrentDeviceArtwork  = "/Client/Librarian/DevicesController/CurrentDeviceArtwork"
-	ClientLibrarianDevicesControllerHasSDCardConnected    = "/Client/Librarian/DevicesController/HasSDCardConnected"
-	ClientLibrarianDevicesControllerHasUsbDeviceConnected = "/Client/Librarian/DevicesController/HasUsbDeviceConnected"
-	ClientPreferencesLayerA                               = "/Client/Preferences/LayerA"
-	ClientPreferencesLayerB                               = "/Client/Preferences/LayerB"
-	ClientPreferencesPlayer                               = "/Client/Preferences/Player"
-	ClientPreferencesPlayerJogColorA                      = "/Client/Preferences/PlayerJogColorA"
-	ClientPreferencesPlayerJogColorB                      = "/Client/Preferences/PlayerJogColorB"
-	ClientPreferencesProfileApplicationPlayerColor1       = "/Client/Preferences/Profile/Application/PlayerColor1"
-	ClientPreferencesProfileApplicationPlayerColor1A      = "/Client/Preferences/Profile/Application/PlayerColor1A"
-	ClientPreferencesProfileApplicationPlayerColor1B      = "/Client/Preferences/Profile/Application/PlayerColor1B"
-	ClientPreferencesProfileApplicationPlayerColor2       = "/Client/Preferences/Profile/Application/PlayerColor2"
-	ClientPreferencesProfileApplicationPlayerColor2A      = "/Client/Preferences/Profile/Application/PlayerColor2A"
-	ClientPreferencesProfileApplicationPlayerColor2B      = "/Client/Preferences/Profile/Application/PlayerColor2B"
-	ClientPreferencesProfileApplicationPlayerColor3       = "/Client/Preferences/Profile/Application/PlayerColor3"
-	ClientPreferencesProfileApplicationPlayerColor3A      = "/Client/Preferences/Profile/Application/PlayerColor3A"
-	ClientPreferencesProfileApplicationPlayerColor3B      = "/Client/Preferences/Profile/Application/PlayerColor3B"
-	ClientPreferencesProfileApplicationPlayerColor4       = "/Client/Preferences/Profile/Application/PlayerColor4"
-	ClientPreferencesProfileApplicationPlayerColor4A      = "/Client/Preferences/Profile/Application/PlayerColor4A"
-	ClientPreferencesProfileApplicationPlayerColor4B      = "/Client/Preferences/Profile/Application/PlayerColor4B"
-	ClientPreferencesProfileApplicationSyncMode           = "/Client/Preferences/Profile/Application/SyncMode"
-	// ClientPreferencesScreenBrightnessPluggedIn is the screen brightness
-	// setting when the device is connected to power. Values: "Low", "Mid",
-	// "High", "Max".
-	ClientPreferencesScreenBrightnessPluggedIn = "/Client/Preferences/ScreenBrightnessPluggedIn"
-	// ConfigurationComputerMode indicates that the device is in computer
-	// (controller) mode. Set to true by the host to activate the computer
-	// mode UI on the device.
-	ConfigurationComputerMode             = "/Configuration/ComputerMode"
-	EngineDeckCount                       = "/Engine/DeckCount"
-	EngineMasterMasterTempo               = "/Engine/Master/MasterTempo"
-	EngineMixerAutoPFLDeckIndex           = "/Engine/Mixer/AutoPFLDeckIndex"
-	EngineSyncNetworkMasterStatus         = "/Engine/Sync/Network/MasterStatus"
-	GUIDecksDeckActiveDeck                = "/GUI/Decks/Deck/ActiveDeck"
-	GUIScriptedRunningDark                = "/GUI/Scripted/RunningDark"
-	GUIViewLayerLayerB                    = "/GUI/ViewLayer/LayerB"
-	MixerCH1faderPosition                 = "/Mixer/CH1faderPosition"
-	MixerCH2faderPosition                 = "/Mixer/CH2faderPosition"
-	MixerCH3faderPosition                 = "/Mixer/CH3faderPosition"
-	MixerCH4faderPosition                 = "/Mixer/CH4faderPosition"
-	MixerChannelAssignment1               = "/Mixer/ChannelAssignment1"
-	MixerChannelAssignment2               = "/Mixer/ChannelAssignment2"
-	MixerChannelAssignment3               = "/Mixer/ChannelAssignment3"
-	MixerChannelAssignment4               = "/Mixer/ChannelAssignment4"
-	MixerCrossfaderPosition               = "/Mixer/CrossfaderPosition"
-	MixerNumberOfChannels                 = "/Mixer/NumberOfChannels"
-)
-
 // Deck 1 legacy variables
 //
 // Deprecated: Use values from [EngineDeck1] instead.
@@ -443,307 +391,3 @@ var (
 	// Deprecated: Use [EngineDeck4] and its [DeckValueNames.TrackTrackWasPlayed] method instead.
 	EngineDeck4TrackTrackWasPlayed = EngineDeck4.TrackTrackWasPlayed()
 )
-
-var (
-	EngineDeck1 = DeckValueNames{DeckIndex: 1}
-	EngineDeck2 = DeckValueNames{DeckIndex: 2}
-	EngineDeck3 = DeckValueNames{DeckIndex: 3}
-	EngineDeck4 = DeckValueNames{DeckIndex: 4}
-)
-
-type DeckValueNames struct {
-	DeckIndex int
-}
-
-func (n *DeckValueNames) PadsView() string {
-	return fmt.Sprintf("/Engine/Deck%d/Pads/View", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackArtistName() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/ArtistName", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackBleep() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Bleep", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackCuePosition() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/CuePosition", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackCurrentBPM() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/CurrentBPM", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackCurrentKeyIndex() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/CurrentKeyIndex", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackCurrentLoopInPosition() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/CurrentLoopInPosition", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackCurrentLoopOutPosition() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/CurrentLoopOutPosition", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackCurrentLoopSizeInBeats() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/CurrentLoopSizeInBeats", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackKeyLock() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/KeyLock", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackLoopEnableState() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/LoopEnableState", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackPlayPauseLEDState() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/PlayPauseLEDState", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackSampleRate() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/SampleRate", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackSongAnalyzed() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/SongAnalyzed", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackSongLoaded() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/SongLoaded", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackSongName() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/SongName", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackSoundSwitchGuid() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/SoundSwitchGuid", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackTrackBytes() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/TrackBytes", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackTrackData() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/TrackData", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackTrackLength() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/TrackLength", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackTrackName() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/TrackName", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackTrackNetworkPath() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/TrackNetworkPath", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackTrackUri() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/TrackUri", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackTrackWasPlayed() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/TrackWasPlayed", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackLoopQuickLoop1() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop1", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackLoopQuickLoop2() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop2", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackLoopQuickLoop3() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop3", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackLoopQuickLoop4() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop4", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackLoopQuickLoop5() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop5", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackLoopQuickLoop6() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop6", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackLoopQuickLoop7() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop7", n.DeckIndex)
-}
-
-func (n *DeckValueNames) TrackLoopQuickLoop8() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop8", n.DeckIndex)
-}
-
-func (n *DeckValueNames) CurrentBPM() string {
-	return fmt.Sprintf("/Engine/Deck%d/CurrentBPM", n.DeckIndex)
-}
-
-func (n *DeckValueNames) DeckIsMaster() string {
-	return fmt.Sprintf("/Engine/Deck%d/DeckIsMaster", n.DeckIndex)
-}
-
-func (n *DeckValueNames) ExternalMixerVolume() string {
-	return fmt.Sprintf("/Engine/Deck%d/ExternalMixerVolume", n.DeckIndex)
-}
-
-func (n *DeckValueNames) ExternalScratchWheelTouch() string {
-	return fmt.Sprintf("/Engine/Deck%d/ExternalScratchWheelTouch", n.DeckIndex)
-}
-
-func (n *DeckValueNames) Play() string {
-	return fmt.Sprintf("/Engine/Deck%d/Play", n.DeckIndex)
-}
-
-func (n *DeckValueNames) PlayState() string {
-	return fmt.Sprintf("/Engine/Deck%d/PlayState", n.DeckIndex)
-}
-
-func (n *DeckValueNames) PlayStatePath() string {
-	return fmt.Sprintf("/Engine/Deck%d/PlayStatePath", n.DeckIndex)
-}
-
-func (n *DeckValueNames) Speed() string {
-	return fmt.Sprintf("/Engine/Deck%d/Speed", n.DeckIndex)
-}
-
-func (n *DeckValueNames) SpeedNeutral() string {
-	return fmt.Sprintf("/Engine/Deck%d/SpeedNeutral", n.DeckIndex)
-}
-
-func (n *DeckValueNames) SpeedOffsetDown() string {
-	return fmt.Sprintf("/Engine/Deck%d/SpeedOffsetDown", n.DeckIndex)
-}
-
-func (n *DeckValueNames) SpeedOffsetUp() string {
-	return fmt.Sprintf("/Engine/Deck%d/SpeedOffsetUp", n.DeckIndex)
-}
-
-func (n *DeckValueNames) SpeedRange() string {
-	return fmt.Sprintf("/Engine/Deck%d/SpeedRange", n.DeckIndex)
-}
-
-func (n *DeckValueNames) SpeedState() string {
-	return fmt.Sprintf("/Engine/Deck%d/SpeedState", n.DeckIndex)
-}
-
-func (n *DeckValueNames) SyncMode() string {
-	return fmt.Sprintf("/Engine/Deck%d/SyncMode", n.DeckIndex)
-}
-
-// AlbumArt returns the StateMap path for the album artwork bytes of the loaded
-// track. The value is a binary blob (JPEG or PNG).
-func (n *DeckValueNames) AlbumArt() string {
-	return fmt.Sprintf("/Engine/Deck%d/AlbumArt", n.DeckIndex)
-}
-
-// JogColor returns the StateMap path for the deck's jog wheel accent color.
-func (n *DeckValueNames) JogColor() string {
-	return fmt.Sprintf("/Engine/Deck%d/JogColor", n.DeckIndex)
-}
-
-// TrackSlipModeActive returns the StateMap path for whether slip mode is active
-// on this deck.
-func (n *DeckValueNames) TrackSlipModeActive() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/SlipModeActive", n.DeckIndex)
-}
-
-// TrackAutoLoopIndex returns the StateMap path for the current auto-loop size
-// index (into the device's loop size list).
-func (n *DeckValueNames) TrackAutoLoopIndex() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/AutoLoopIndex", n.DeckIndex)
-}
-
-// TrackAutoLoopLabel returns the StateMap path for the human-readable label of
-// auto-loop slot n (1-based). E.g. "1/4", "1", "8".
-func (n *DeckValueNames) TrackAutoLoopLabel(slot int) string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/AutoLoopLabel%d", n.DeckIndex, slot)
-}
-
-// TrackBeatJumpIndex returns the StateMap path for the current beat-jump size
-// index.
-func (n *DeckValueNames) TrackBeatJumpIndex() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/BeatJump/BeatJumpIndex", n.DeckIndex)
-}
-
-// TrackBeatJumpLabel returns the StateMap path for the human-readable label of
-// beat-jump slot n (1-based). E.g. "1", "2", "4".
-func (n *DeckValueNames) TrackBeatJumpLabel(slot int) string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/BeatJump/BeatJumpLabel%d", n.DeckIndex, slot)
-}
-
-// TrackLoopActive returns the StateMap path for whether a loop is currently
-// active (playing) on this deck.
-func (n *DeckValueNames) TrackLoopActive() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/Active", n.DeckIndex)
-}
-
-// TrackLoopLoopEnabledPosition returns the StateMap path for the loop-in
-// position when a loop is armed or active.
-func (n *DeckValueNames) TrackLoopLoopEnabledPosition() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/LoopEnabledPosition", n.DeckIndex)
-}
-
-// TrackLoopLoopOutPosition returns the StateMap path for the loop-out point
-// position.
-func (n *DeckValueNames) TrackLoopLoopOutPosition() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/LoopOutPosition", n.DeckIndex)
-}
-
-// TrackTrackDataPlayheadPosition returns the StateMap path for the current
-// playhead position in seconds.
-func (n *DeckValueNames) TrackTrackDataPlayheadPosition() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/TrackData/PlayheadPosition", n.DeckIndex)
-}
-
-// TrackTrackDataTrackLength returns the StateMap path for the total track
-// length in seconds (from TrackData, more precise than TrackTrackLength).
-func (n *DeckValueNames) TrackTrackDataTrackLength() string {
-	return fmt.Sprintf("/Engine/Deck%d/Track/TrackData/TrackLength", n.DeckIndex)
-}
-
-// MixerChannelValueNames provides StateMap path helpers for a specific mixer
-// channel (1-based).
-type MixerChannelValueNames struct {
-	ChannelIndex int
-}
-
-var (
-	EngineMixerChannel1 = MixerChannelValueNames{ChannelIndex: 1}
-	EngineMixerChannel2 = MixerChannelValueNames{ChannelIndex: 2}
-	EngineMixerChannel3 = MixerChannelValueNames{ChannelIndex: 3}
-	EngineMixerChannel4 = MixerChannelValueNames{ChannelIndex: 4}
-)
-
-// PFL returns the StateMap path for the pre-fader listen (cue) state of this
-// mixer channel.
-func (m *MixerChannelValueNames) PFL() string {
-	return fmt.Sprintf("/Engine/Mixer/Channel%d/PFL", m.ChannelIndex)
-}
-
-// Line returns the StateMap path for whether this mixer channel is in line-in
-// mode (as opposed to USB/track playback).
-func (m *MixerChannelValueNames) Line() string {
-	return fmt.Sprintf("/Engine/Mixer/Channel%d/Line", m.ChannelIndex)
-}
-
-// AutoGain returns the StateMap path for the auto-gain value of this mixer
-// channel.
-func (m *MixerChannelValueNames) AutoGain() string {
-	return fmt.Sprintf("/Engine/Mixer/Channel%d/AutoGain", m.ChannelIndex)
-}
-
-// GUIDecksSideActiveDeck returns the StateMap path for the active deck index
-// on a given side ("Left" or "Right"). This path resolves which deck is
-// currently displayed on the left or right side of the device UI.
-func GUIDecksSideActiveDeck(side string) string {
-	return fmt.Sprintf("/GUI/Decks/Deck%s/ActiveDeck", side)
-}
@@ -0,0 +1,763 @@
+// Code generated by go run ./internal/generate/valuenames; DO NOT EDIT.
+
+package stagelinq
+
+import "fmt"
+
+const (
+	// ClientLibrarianDevicesControllerCurrentDevice is the StateMap path for
+	// the library source currently selected in the browser.
+	ClientLibrarianDevicesControllerCurrentDevice = "/Client/Librarian/DevicesController/CurrentDevice"
+	// ClientLibrarianDevicesControllerCurrentDeviceArtwork is the StateMap path
+	// for the artwork of the library source currently selected in the browser.
+	ClientLibrarianDevicesControllerCurrentDeviceArtwork = "/Client/Librarian/DevicesController/CurrentDeviceArtwork"
+	// ClientLibrarianDevicesControllerHasSDCardConnected is the StateMap path
+	// for whether an SD card is connected to the device.
+	ClientLibrarianDevicesControllerHasSDCardConnected = "/Client/Librarian/DevicesController/HasSDCardConnected"
+	// ClientLibrarianDevicesControllerHasUsbDeviceConnected is the StateMap
+	// path for whether a USB storage device is connected to the device.
+	ClientLibrarianDevicesControllerHasUsbDeviceConnected = "/Client/Librarian/DevicesController/HasUsbDeviceConnected"
+	// ClientPreferencesLayerA is the StateMap path for whether layer A is shown
+	// on a dual layer player.
+	ClientPreferencesLayerA = "/Client/Preferences/LayerA"
+	// ClientPreferencesLayerB is the StateMap path for whether layer B is shown
+	// on a dual layer player.
+	ClientPreferencesLayerB = "/Client/Preferences/LayerB"
+	// ClientPreferencesPlayer is the StateMap path for the player number
+	// configured on the device.
+	ClientPreferencesPlayer = "/Client/Preferences/Player"
+	// ClientPreferencesPlayerJogColorA is the StateMap path for the jog wheel
+	// color of layer A. It can be written using StateMapConnection.Set.
+	ClientPreferencesPlayerJogColorA = "/Client/Preferences/PlayerJogColorA"
+	// ClientPreferencesPlayerJogColorB is the StateMap path for the jog wheel
+	// color of layer B. It can be written using StateMapConnection.Set.
+	ClientPreferencesPlayerJogColorB = "/Client/Preferences/PlayerJogColorB"
+	// ClientPreferencesProfileApplicationPlayerColor1 is the StateMap path for
+	// the color of player 1 in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor1 = "/Client/Preferences/Profile/Application/PlayerColor1"
+	// ClientPreferencesProfileApplicationPlayerColor1A is the StateMap path for
+	// the color of player 1 layer A in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor1A = "/Client/Preferences/Profile/Application/PlayerColor1A"
+	// ClientPreferencesProfileApplicationPlayerColor1B is the StateMap path for
+	// the color of player 1 layer B in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor1B = "/Client/Preferences/Profile/Application/PlayerColor1B"
+	// ClientPreferencesProfileApplicationPlayerColor2 is the StateMap path for
+	// the color of player 2 in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor2 = "/Client/Preferences/Profile/Application/PlayerColor2"
+	// ClientPreferencesProfileApplicationPlayerColor2A is the StateMap path for
+	// the color of player 2 layer A in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor2A = "/Client/Preferences/Profile/Application/PlayerColor2A"
+	// ClientPreferencesProfileApplicationPlayerColor2B is the StateMap path for
+	// the color of player 2 layer B in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor2B = "/Client/Preferences/Profile/Application/PlayerColor2B"
+	// ClientPreferencesProfileApplicationPlayerColor3 is the StateMap path for
+	// the color of player 3 in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor3 = "/Client/Preferences/Profile/Application/PlayerColor3"
+	// ClientPreferencesProfileApplicationPlayerColor3A is the StateMap path for
+	// the color of player 3 layer A in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor3A = "/Client/Preferences/Profile/Application/PlayerColor3A"
+	// ClientPreferencesProfileApplicationPlayerColor3B is the StateMap path for
+	// the color of player 3 layer B in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor3B = "/Client/Preferences/Profile/Application/PlayerColor3B"
+	// ClientPreferencesProfileApplicationPlayerColor4 is the StateMap path for
+	// the color of player 4 in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor4 = "/Client/Preferences/Profile/Application/PlayerColor4"
+	// ClientPreferencesProfileApplicationPlayerColor4A is the StateMap path for
+	// the color of player 4 layer A in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor4A = "/Client/Preferences/Profile/Application/PlayerColor4A"
+	// ClientPreferencesProfileApplicationPlayerColor4B is the StateMap path for
+	// the color of player 4 layer B in the user profile.
+	ClientPreferencesProfileApplicationPlayerColor4B = "/Client/Preferences/Profile/Application/PlayerColor4B"
+	// ClientPreferencesProfileApplicationSyncMode is the StateMap path for the
+	// sync mode set in the user profile, for example "Tempo" or "TempoSync".
+	ClientPreferencesProfileApplicationSyncMode = "/Client/Preferences/Profile/Application/SyncMode"
+	// ClientPreferencesScreenBrightnessPluggedIn is the StateMap path for the
+	// screen brightness setting when the device is connected to power. Values:
+	// "Low", "Mid", "High", "Max". It can be written using
+	// StateMapConnection.Set.
+	ClientPreferencesScreenBrightnessPluggedIn = "/Client/Preferences/ScreenBrightnessPluggedIn"
+	// ClientPreferencesScreenBrightnessBattery is the StateMap path for the
+	// screen brightness setting when the device runs on battery. Values: "Low",
+	// "Mid", "High", "Max". Sent by Prime Go. This path has not been verified
+	// on a device yet.
+	ClientPreferencesScreenBrightnessBattery = "/Client/Preferences/ScreenBrightnessBattery"
+	// ClientPreferencesTrackEndWarningTime is the StateMap path for how long
+	// before the end of a track the device starts warning. The value is given
+	// in s. This path has not been verified on a device yet.
+	ClientPreferencesTrackEndWarningTime = "/Client/Preferences/TrackEndWarningTime"
+	// ClientPreferencesNeedleLock is the StateMap path for whether needle
+	// search is locked while a deck is playing. This path has not been verified
+	// on a device yet.
+	ClientPreferencesNeedleLock = "/Client/Preferences/NeedleLock"
+	// ClientPreferencesQuantize is the StateMap path for whether cue points and
+	// loops snap to the beat grid. This path has not been verified on a device
+	// yet.
+	ClientPreferencesQuantize = "/Client/Preferences/Quantize"
+	// ClientPreferencesQuantizeBeatLength is the StateMap path for the beat
+	// length cue points and loops snap to while quantizing. The value is given
+	// in beats. This path has not been verified on a device yet.
+	ClientPreferencesQuantizeBeatLength = "/Client/Preferences/QuantizeBeatLength"
+	// ConfigurationComputerMode is the StateMap path for whether the device is
+	// in computer (controller) mode. Set to true by the host to activate the
+	// computer mode UI on the device. It can be written using
+	// StateMapConnection.Set.
+	ConfigurationComputerMode = "/Configuration/ComputerMode"
+	// EngineDeckCount is the StateMap path for the number of decks the device
+	// has.
+	EngineDeckCount = "/Engine/DeckCount"
+	// EngineMasterMasterTempo is the StateMap path for the tempo of the sync
+	// master. The value is given in BPM.
+	EngineMasterMasterTempo = "/Engine/Master/MasterTempo"
+	// EngineMixerAutoPFLDeckIndex is the StateMap path for the deck
+	// automatically routed to the headphones.
+	EngineMixerAutoPFLDeckIndex = "/Engine/Mixer/AutoPFLDeckIndex"
+	// EngineSyncNetworkMasterStatus is the StateMap path for whether this
+	// device is the sync master on the network.
+	EngineSyncNetworkMasterStatus = "/Engine/Sync/Network/MasterStatus"
+	// GUIDecksDeckActiveDeck is the StateMap path for the deck currently shown
+	// on the device screen.
+	GUIDecksDeckActiveDeck = "/GUI/Decks/Deck/ActiveDeck"
+	// GUIScriptedRunningDark is the StateMap path for whether the dark UI theme
+	// is active.
+	GUIScriptedRunningDark = "/GUI/Scripted/RunningDark"
+	// GUIViewLayerLayerB is the StateMap path for whether the UI shows layer B.
+	GUIViewLayerLayerB = "/GUI/ViewLayer/LayerB"
+	// MixerCH1faderPosition is the StateMap path for the position of the
+	// channel 1 fader, from 0 (closed) to 1 (open). Sent by X1800, X1850, Prime
+	// 4, Prime 4+, Prime 2, Prime Go, SC Live 4, SC Live 2.
+	MixerCH1faderPosition = "/Mixer/CH1faderPosition"
+	// MixerCH2faderPosition is the StateMap path for the position of the
+	// channel 2 fader, from 0 (closed) to 1 (open). Sent by X1800, X1850, Prime
+	// 4, Prime 4+, Prime 2, Prime Go, SC Live 4, SC Live 2.
+	MixerCH2faderPosition = "/Mixer/CH2faderPosition"
+	// MixerCH3faderPosition is the StateMap path for the position of the
+	// channel 3 fader, from 0 (closed) to 1 (open). Sent by X1800, X1850, Prime
+	// 4, Prime 4+, Prime 2, Prime Go, SC Live 4, SC Live 2.
+	MixerCH3faderPosition = "/Mixer/CH3faderPosition"
+	// MixerCH4faderPosition is the StateMap path for the position of the
+	// channel 4 fader, from 0 (closed) to 1 (open). Sent by X1800, X1850, Prime
+	// 4, Prime 4+, Prime 2, Prime Go, SC Live 4, SC Live 2.
+	MixerCH4faderPosition = "/Mixer/CH4faderPosition"
+	// MixerChannelAssignment1 is the StateMap path for the deck routed to mixer
+	// channel 1, given as the token of the player in braces followed by a comma
+	// and the deck number. Sent by X1800, X1850, Prime 4, Prime 4+, Prime 2,
+	// Prime Go, SC Live 4, SC Live 2.
+	MixerChannelAssignment1 = "/Mixer/ChannelAssignment1"
+	// MixerChannelAssignment2 is the StateMap path for the deck routed to mixer
+	// channel 2, given as the token of the player in braces followed by a comma
+	// and the deck number. Sent by X1800, X1850, Prime 4, Prime 4+, Prime 2,
+	// Prime Go, SC Live 4, SC Live 2.
+	MixerChannelAssignment2 = "/Mixer/ChannelAssignment2"
+	// MixerChannelAssignment3 is the StateMap path for the deck routed to mixer
+	// channel 3, given as the token of the player in braces followed by a comma
+	// and the deck number. Sent by X1800, X1850, Prime 4, Prime 4+, Prime 2,
+	// Prime Go, SC Live 4, SC Live 2.
+	MixerChannelAssignment3 = "/Mixer/ChannelAssignment3"
+	// MixerChannelAssignment4 is the StateMap path for the deck routed to mixer
+	// channel 4, given as the token of the player in braces followed by a comma
+	// and the deck number. Sent by X1800, X1850, Prime 4, Prime 4+, Prime 2,
+	// Prime Go, SC Live 4, SC Live 2.
+	MixerChannelAssignment4 = "/Mixer/ChannelAssignment4"
+	// MixerCrossfaderPosition is the StateMap path for the position of the
+	// crossfader, from 0 (left) to 1 (right). Sent by X1800, X1850, Prime 4,
+	// Prime 4+, Prime 2, Prime Go, SC Live 4, SC Live 2.
+	MixerCrossfaderPosition = "/Mixer/CrossfaderPosition"
+	// MixerNumberOfChannels is the StateMap path for the number of channels of
+	// the mixer. Sent by X1800, X1850, Prime 4, Prime 4+, Prime 2, Prime Go, SC
+	// Live 4, SC Live 2.
+	MixerNumberOfChannels = "/Mixer/NumberOfChannels"
+	// MixerMasterVolume is the StateMap path for the position of the master
+	// volume knob, from 0 to 1. Sent by X1800, X1850, Prime 4, Prime 4+, Prime
+	// 2, Prime Go, SC Live 4, SC Live 2. This path has not been verified on a
+	// device yet.
+	MixerMasterVolume = "/Mixer/MasterVolume"
+	// MixerBoothVolume is the StateMap path for the position of the booth
+	// volume knob, from 0 to 1. Sent by X1800, X1850, Prime 4, Prime 4+, Prime
+	// 2, Prime Go, SC Live 4, SC Live 2. This path has not been verified on a
+	// device yet.
+	MixerBoothVolume = "/Mixer/BoothVolume"
+	// MixerCueMix is the StateMap path for the headphone cue/master mix, from 0
+	// (cue) to 1 (master). Sent by X1800, X1850, Prime 4, Prime 4+, Prime 2,
+	// Prime Go, SC Live 4, SC Live 2. This path has not been verified on a
+	// device yet.
+	MixerCueMix = "/Mixer/CueMix"
+	// MixerCueVolume is the StateMap path for the headphone volume, from 0 to
+	// 1. Sent by X1800, X1850, Prime 4, Prime 4+, Prime 2, Prime Go, SC Live 4,
+	// SC Live 2. This path has not been verified on a device yet.
+	MixerCueVolume = "/Mixer/CueVolume"
+	// MixerSweepFXSelection is the StateMap path for the selected sweep effect,
+	// for example "Filter" or "Echo". Sent by X1800, X1850, Prime 4, Prime 4+,
+	// Prime 2, Prime Go, SC Live 4, SC Live 2. This path has not been verified
+	// on a device yet.
+	MixerSweepFXSelection = "/Mixer/SweepFXSelection"
+	// EngineSamplerVolume is the StateMap path for the sampler master volume,
+	// from 0 to 1. This path has not been verified on a device yet.
+	EngineSamplerVolume = "/Engine/Sampler/Volume"
+)
+
+// GUIDecksSideActiveDeck returns the StateMap path for the active deck index
+// on a given side ("Left" or "Right"). This path resolves which deck is
+// currently displayed on the left or right side of the device UI.
+func GUIDecksSideActiveDeck(side string) string {
+	return fmt.Sprintf("/GUI/Decks/Deck%s/ActiveDeck", side)
+}
+
+// DeckValueNames provides StateMap path helpers for a specific deck
+// (1-based).
+type DeckValueNames struct {
+	DeckIndex int
+}
+
+var (
+	EngineDeck1 = DeckValueNames{DeckIndex: 1}
+	EngineDeck2 = DeckValueNames{DeckIndex: 2}
+	EngineDeck3 = DeckValueNames{DeckIndex: 3}
+	EngineDeck4 = DeckValueNames{DeckIndex: 4}
+)
+
+// PadsView returns the StateMap path for the performance pad mode shown on
+// this deck, for example "HotCue" or "Loop".
+func (n *DeckValueNames) PadsView() string {
+	return fmt.Sprintf("/Engine/Deck%d/Pads/View", n.DeckIndex)
+}
+
+// TrackArtistName returns the StateMap path for the artist of the loaded
+// track.
+func (n *DeckValueNames) TrackArtistName() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/ArtistName", n.DeckIndex)
+}
+
+// TrackBleep returns the StateMap path for whether bleep (censor) is
+// engaged.
+func (n *DeckValueNames) TrackBleep() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Bleep", n.DeckIndex)
+}
+
+// TrackCuePosition returns the StateMap path for the position of the main
+// cue point. The value is given in samples.
+func (n *DeckValueNames) TrackCuePosition() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/CuePosition", n.DeckIndex)
+}
+
+// TrackCurrentBPM returns the StateMap path for the tempo of the loaded
+// track at the current speed. The value is given in BPM.
+func (n *DeckValueNames) TrackCurrentBPM() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/CurrentBPM", n.DeckIndex)
+}
+
+// TrackCurrentKeyIndex returns the StateMap path for the musical key of the
+// loaded track as an index into the device's key list.
+func (n *DeckValueNames) TrackCurrentKeyIndex() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/CurrentKeyIndex", n.DeckIndex)
+}
+
+// TrackCurrentLoopInPosition returns the StateMap path for the loop-in point
+// of the current loop. The value is given in samples.
+func (n *DeckValueNames) TrackCurrentLoopInPosition() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/CurrentLoopInPosition", n.DeckIndex)
+}
+
+// TrackCurrentLoopOutPosition returns the StateMap path for the loop-out
+// point of the current loop. The value is given in samples.
+func (n *DeckValueNames) TrackCurrentLoopOutPosition() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/CurrentLoopOutPosition", n.DeckIndex)
+}
+
+// TrackCurrentLoopSizeInBeats returns the StateMap path for the size of the
+// current loop. The value is given in beats.
+func (n *DeckValueNames) TrackCurrentLoopSizeInBeats() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/CurrentLoopSizeInBeats", n.DeckIndex)
+}
+
+// TrackKeyLock returns the StateMap path for whether key lock is enabled.
+func (n *DeckValueNames) TrackKeyLock() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/KeyLock", n.DeckIndex)
+}
+
+// TrackLoopEnableState returns the StateMap path for whether a loop is
+// enabled.
+func (n *DeckValueNames) TrackLoopEnableState() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/LoopEnableState", n.DeckIndex)
+}
+
+// TrackPlayPauseLEDState returns the StateMap path for the state of the
+// play/pause button LED.
+func (n *DeckValueNames) TrackPlayPauseLEDState() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/PlayPauseLEDState", n.DeckIndex)
+}
+
+// TrackSampleRate returns the StateMap path for the sample rate of the
+// loaded track. The value is given in Hz.
+func (n *DeckValueNames) TrackSampleRate() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/SampleRate", n.DeckIndex)
+}
+
+// TrackSongAnalyzed returns the StateMap path for whether the loaded track
+// has been analyzed.
+func (n *DeckValueNames) TrackSongAnalyzed() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/SongAnalyzed", n.DeckIndex)
+}
+
+// TrackSongLoaded returns the StateMap path for whether a track is loaded.
+func (n *DeckValueNames) TrackSongLoaded() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/SongLoaded", n.DeckIndex)
+}
+
+// TrackSongName returns the StateMap path for the title of the loaded track.
+func (n *DeckValueNames) TrackSongName() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/SongName", n.DeckIndex)
+}
+
+// TrackSoundSwitchGuid returns the StateMap path for the SoundSwitch
+// identifier of the loaded track.
+func (n *DeckValueNames) TrackSoundSwitchGuid() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/SoundSwitchGuid", n.DeckIndex)
+}
+
+// TrackTrackBytes returns the StateMap path for the file size of the loaded
+// track. The value is given in bytes.
+func (n *DeckValueNames) TrackTrackBytes() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/TrackBytes", n.DeckIndex)
+}
+
+// TrackTrackData returns the StateMap path for whether track data of the
+// loaded track is available.
+func (n *DeckValueNames) TrackTrackData() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/TrackData", n.DeckIndex)
+}
+
+// TrackTrackLength returns the StateMap path for the length of the loaded
+// track. The value is given in samples.
+func (n *DeckValueNames) TrackTrackLength() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/TrackLength", n.DeckIndex)
+}
+
+// TrackTrackName returns the StateMap path for the file path of the loaded
+// track on its source.
+func (n *DeckValueNames) TrackTrackName() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/TrackName", n.DeckIndex)
+}
+
+// TrackTrackNetworkPath returns the StateMap path for the network path of
+// the loaded track, naming the device and library it was loaded from.
+func (n *DeckValueNames) TrackTrackNetworkPath() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/TrackNetworkPath", n.DeckIndex)
+}
+
+// TrackTrackUri returns the StateMap path for the URI of the loaded track.
+func (n *DeckValueNames) TrackTrackUri() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/TrackUri", n.DeckIndex)
+}
+
+// TrackTrackWasPlayed returns the StateMap path for whether the loaded track
+// has been played.
+func (n *DeckValueNames) TrackTrackWasPlayed() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/TrackWasPlayed", n.DeckIndex)
+}
+
+// TrackLoopQuickLoop1 returns the StateMap path for whether quick loop 1 is
+// set.
+func (n *DeckValueNames) TrackLoopQuickLoop1() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop1", n.DeckIndex)
+}
+
+// TrackLoopQuickLoop2 returns the StateMap path for whether quick loop 2 is
+// set.
+func (n *DeckValueNames) TrackLoopQuickLoop2() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop2", n.DeckIndex)
+}
+
+// TrackLoopQuickLoop3 returns the StateMap path for whether quick loop 3 is
+// set.
+func (n *DeckValueNames) TrackLoopQuickLoop3() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop3", n.DeckIndex)
+}
+
+// TrackLoopQuickLoop4 returns the StateMap path for whether quick loop 4 is
+// set.
+func (n *DeckValueNames) TrackLoopQuickLoop4() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop4", n.DeckIndex)
+}
+
+// TrackLoopQuickLoop5 returns the StateMap path for whether quick loop 5 is
+// set.
+func (n *DeckValueNames) TrackLoopQuickLoop5() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop5", n.DeckIndex)
+}
+
+// TrackLoopQuickLoop6 returns the StateMap path for whether quick loop 6 is
+// set.
+func (n *DeckValueNames) TrackLoopQuickLoop6() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop6", n.DeckIndex)
+}
+
+// TrackLoopQuickLoop7 returns the StateMap path for whether quick loop 7 is
+// set.
+func (n *DeckValueNames) TrackLoopQuickLoop7() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop7", n.DeckIndex)
+}
+
+// TrackLoopQuickLoop8 returns the StateMap path for whether quick loop 8 is
+// set.
+func (n *DeckValueNames) TrackLoopQuickLoop8() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/QuickLoop8", n.DeckIndex)
+}
+
+// CurrentBPM returns the StateMap path for the current tempo of the deck.
+// The value is given in BPM.
+func (n *DeckValueNames) CurrentBPM() string {
+	return fmt.Sprintf("/Engine/Deck%d/CurrentBPM", n.DeckIndex)
+}
+
+// DeckIsMaster returns the StateMap path for whether this deck is the sync
+// master.
+func (n *DeckValueNames) DeckIsMaster() string {
+	return fmt.Sprintf("/Engine/Deck%d/DeckIsMaster", n.DeckIndex)
+}
+
+// ExternalMixerVolume returns the StateMap path for the volume of the mixer
+// channel this deck is routed to, from 0 to 1.
+func (n *DeckValueNames) ExternalMixerVolume() string {
+	return fmt.Sprintf("/Engine/Deck%d/ExternalMixerVolume", n.DeckIndex)
+}
+
+// ExternalScratchWheelTouch returns the StateMap path for whether the jog
+// wheel is touched.
+func (n *DeckValueNames) ExternalScratchWheelTouch() string {
+	return fmt.Sprintf("/Engine/Deck%d/ExternalScratchWheelTouch", n.DeckIndex)
+}
+
+// Play returns the StateMap path for whether the deck is playing.
+func (n *DeckValueNames) Play() string {
+	return fmt.Sprintf("/Engine/Deck%d/Play", n.DeckIndex)
+}
+
+// PlayState returns the StateMap path for whether the deck is playing,
+// including while cueing.
+func (n *DeckValueNames) PlayState() string {
+	return fmt.Sprintf("/Engine/Deck%d/PlayState", n.DeckIndex)
+}
+
+// PlayStatePath returns the StateMap path for the path of the track whose
+// play state is reported.
+func (n *DeckValueNames) PlayStatePath() string {
+	return fmt.Sprintf("/Engine/Deck%d/PlayStatePath", n.DeckIndex)
+}
+
+// Speed returns the StateMap path for the playback speed, where 1 is the
+// original tempo.
+func (n *DeckValueNames) Speed() string {
+	return fmt.Sprintf("/Engine/Deck%d/Speed", n.DeckIndex)
+}
+
+// SpeedNeutral returns the StateMap path for whether the pitch fader is at
+// its neutral position.
+func (n *DeckValueNames) SpeedNeutral() string {
+	return fmt.Sprintf("/Engine/Deck%d/SpeedNeutral", n.DeckIndex)
+}
+
+// SpeedOffsetDown returns the StateMap path for whether pitch bend down is
+// engaged.
+func (n *DeckValueNames) SpeedOffsetDown() string {
+	return fmt.Sprintf("/Engine/Deck%d/SpeedOffsetDown", n.DeckIndex)
+}
+
+// SpeedOffsetUp returns the StateMap path for whether pitch bend up is
+// engaged.
+func (n *DeckValueNames) SpeedOffsetUp() string {
+	return fmt.Sprintf("/Engine/Deck%d/SpeedOffsetUp", n.DeckIndex)
+}
+
+// SpeedRange returns the StateMap path for the pitch fader range, for
+// example "8" for ±8 %.
+func (n *DeckValueNames) SpeedRange() string {
+	return fmt.Sprintf("/Engine/Deck%d/SpeedRange", n.DeckIndex)
+}
+
+// SpeedState returns the StateMap path for the pitch fader position.
+func (n *DeckValueNames) SpeedState() string {
+	return fmt.Sprintf("/Engine/Deck%d/SpeedState", n.DeckIndex)
+}
+
+// SyncMode returns the StateMap path for the sync mode of the deck.
+func (n *DeckValueNames) SyncMode() string {
+	return fmt.Sprintf("/Engine/Deck%d/SyncMode", n.DeckIndex)
+}
+
+// AlbumArt returns the StateMap path for the album artwork bytes of the
+// loaded track. The value is a binary blob (JPEG or PNG).
+func (n *DeckValueNames) AlbumArt() string {
+	return fmt.Sprintf("/Engine/Deck%d/AlbumArt", n.DeckIndex)
+}
+
+// JogColor returns the StateMap path for the deck's jog wheel accent color.
+func (n *DeckValueNames) JogColor() string {
+	return fmt.Sprintf("/Engine/Deck%d/JogColor", n.DeckIndex)
+}
+
+// TrackSlipModeActive returns the StateMap path for whether slip mode is
+// active on this deck.
+func (n *DeckValueNames) TrackSlipModeActive() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/SlipModeActive", n.DeckIndex)
+}
+
+// TrackAutoLoopIndex returns the StateMap path for the current auto-loop
+// size index (into the device's loop size list).
+func (n *DeckValueNames) TrackAutoLoopIndex() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/AutoLoopIndex", n.DeckIndex)
+}
+
+// TrackAutoLoopLabel returns the StateMap path for the human-readable label
+// of auto-loop slot n (1-based). E.g. "1/4", "1", "8".
+func (n *DeckValueNames) TrackAutoLoopLabel(slot int) string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/AutoLoopLabel%d", n.DeckIndex, slot)
+}
+
+// TrackBeatJumpIndex returns the StateMap path for the current beat-jump
+// size index.
+func (n *DeckValueNames) TrackBeatJumpIndex() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/BeatJump/BeatJumpIndex", n.DeckIndex)
+}
+
+// TrackBeatJumpLabel returns the StateMap path for the human-readable label
+// of beat-jump slot n (1-based). E.g. "1", "2", "4".
+func (n *DeckValueNames) TrackBeatJumpLabel(slot int) string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/BeatJump/BeatJumpLabel%d", n.DeckIndex, slot)
+}
+
+// TrackLoopActive returns the StateMap path for whether a loop is currently
+// active (playing) on this deck.
+func (n *DeckValueNames) TrackLoopActive() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/Active", n.DeckIndex)
+}
+
+// TrackLoopLoopEnabledPosition returns the StateMap path for the loop-in
+// position when a loop is armed or active.
+func (n *DeckValueNames) TrackLoopLoopEnabledPosition() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/LoopEnabledPosition", n.DeckIndex)
+}
+
+// TrackLoopLoopOutPosition returns the StateMap path for the loop-out point
+// position.
+func (n *DeckValueNames) TrackLoopLoopOutPosition() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Loop/LoopOutPosition", n.DeckIndex)
+}
+
+// TrackTrackDataPlayheadPosition returns the StateMap path for the current
+// playhead position in seconds. The value is given in s.
+func (n *DeckValueNames) TrackTrackDataPlayheadPosition() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/TrackData/PlayheadPosition", n.DeckIndex)
+}
+
+// TrackTrackDataTrackLength returns the StateMap path for the total track
+// length in seconds (from TrackData, more precise than TrackTrackLength).
+// The value is given in s.
+func (n *DeckValueNames) TrackTrackDataTrackLength() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/TrackData/TrackLength", n.DeckIndex)
+}
+
+// HotCueSet returns the StateMap path for whether hot cue n (1-based) is
+// set. This path has not been verified on a device yet.
+func (n *DeckValueNames) HotCueSet(pad int) string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/HotCues/HotCue%d/Set", n.DeckIndex, pad)
+}
+
+// HotCuePosition returns the StateMap path for the position of hot cue n
+// (1-based). The value is given in samples. This path has not been verified
+// on a device yet.
+func (n *DeckValueNames) HotCuePosition(pad int) string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/HotCues/HotCue%d/Position", n.DeckIndex, pad)
+}
+
+// HotCueLabel returns the StateMap path for the label of hot cue n
+// (1-based). This path has not been verified on a device yet.
+func (n *DeckValueNames) HotCueLabel(pad int) string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/HotCues/HotCue%d/Label", n.DeckIndex, pad)
+}
+
+// HotCueColor returns the StateMap path for the color of hot cue n
+// (1-based). This path has not been verified on a device yet.
+func (n *DeckValueNames) HotCueColor(pad int) string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/HotCues/HotCue%d/Color", n.DeckIndex, pad)
+}
+
+// RollActive returns the StateMap path for whether a roll pad is held. This
+// path has not been verified on a device yet.
+func (n *DeckValueNames) RollActive() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Roll/Active", n.DeckIndex)
+}
+
+// RollSizeInBeats returns the StateMap path for the size of the active roll.
+// The value is given in beats. This path has not been verified on a device
+// yet.
+func (n *DeckValueNames) RollSizeInBeats() string {
+	return fmt.Sprintf("/Engine/Deck%d/Track/Roll/SizeInBeats", n.DeckIndex)
+}
+
+// MixerChannelValueNames provides StateMap path helpers for a specific mixer
+// channel (1-based).
+type MixerChannelValueNames struct {
+	ChannelIndex int
+}
+
+var (
+	EngineMixerChannel1 = MixerChannelValueNames{ChannelIndex: 1}
+	EngineMixerChannel2 = MixerChannelValueNames{ChannelIndex: 2}
+	EngineMixerChannel3 = MixerChannelValueNames{ChannelIndex: 3}
+	EngineMixerChannel4 = MixerChannelValueNames{ChannelIndex: 4}
+)
+
+// PFL returns the StateMap path for the pre-fader listen (cue) state of this
+// mixer channel.
+func (n *MixerChannelValueNames) PFL() string {
+	return fmt.Sprintf("/Engine/Mixer/Channel%d/PFL", n.ChannelIndex)
+}
+
+// Line returns the StateMap path for whether this mixer channel is in
+// line-in mode (as opposed to USB/track playback).
+func (n *MixerChannelValueNames) Line() string {
+	return fmt.Sprintf("/Engine/Mixer/Channel%d/Line", n.ChannelIndex)
+}
+
+// AutoGain returns the StateMap path for the auto-gain value of this mixer
+// channel.
+func (n *MixerChannelValueNames) AutoGain() string {
+	return fmt.Sprintf("/Engine/Mixer/Channel%d/AutoGain", n.ChannelIndex)
+}
+
+// Trim returns the StateMap path for the trim knob position of this mixer
+// channel, from 0 to 1. This path has not been verified on a device yet.
+func (n *MixerChannelValueNames) Trim() string {
+	return fmt.Sprintf("/Engine/Mixer/Channel%d/Trim", n.ChannelIndex)
+}
+
+// EQHigh returns the StateMap path for the high EQ knob position of this
+// mixer channel, from 0 to 1. This path has not been verified on a device
+// yet.
+func (n *MixerChannelValueNames) EQHigh() string {
+	return fmt.Sprintf("/Engine/Mixer/Channel%d/EQHigh", n.ChannelIndex)
+}
+
+// EQMid returns the StateMap path for the mid EQ knob position of this mixer
+// channel, from 0 to 1. This path has not been verified on a device yet.
+func (n *MixerChannelValueNames) EQMid() string {
+	return fmt.Sprintf("/Engine/Mixer/Channel%d/EQMid", n.ChannelIndex)
+}
+
+// EQLow returns the StateMap path for the low EQ knob position of this mixer
+// channel, from 0 to 1. This path has not been verified on a device yet.
+func (n *MixerChannelValueNames) EQLow() string {
+	return fmt.Sprintf("/Engine/Mixer/Channel%d/EQLow", n.ChannelIndex)
+}
+
+// Filter returns the StateMap path for the filter knob position of this
+// mixer channel, from 0 (low pass) to 1 (high pass). This path has not been
+// verified on a device yet.
+func (n *MixerChannelValueNames) Filter() string {
+	return fmt.Sprintf("/Engine/Mixer/Channel%d/Filter", n.ChannelIndex)
+}
+
+// SweepFXActive returns the StateMap path for whether the sweep effect is
+// engaged on this mixer channel. This path has not been verified on a device
+// yet.
+func (n *MixerChannelValueNames) SweepFXActive() string {
+	return fmt.Sprintf("/Engine/Mixer/Channel%d/SweepFXActive", n.ChannelIndex)
+}
+
+// FXUnitValueNames provides StateMap path helpers for a specific effect unit
+// (1-based).
+type FXUnitValueNames struct {
+	UnitIndex int
+}
+
+var (
+	EngineFXUnit1 = FXUnitValueNames{UnitIndex: 1}
+	EngineFXUnit2 = FXUnitValueNames{UnitIndex: 2}
+)
+
+// Enabled returns the StateMap path for whether this effect unit is engaged.
+// This path has not been verified on a device yet.
+func (n *FXUnitValueNames) Enabled() string {
+	return fmt.Sprintf("/Engine/FX/Unit%d/Enabled", n.UnitIndex)
+}
+
+// EffectName returns the StateMap path for the name of the effect selected
+// on this effect unit. This path has not been verified on a device yet.
+func (n *FXUnitValueNames) EffectName() string {
+	return fmt.Sprintf("/Engine/FX/Unit%d/EffectName", n.UnitIndex)
+}
+
+// WetDry returns the StateMap path for the wet/dry mix of this effect unit,
+// from 0 to 1. This path has not been verified on a device yet.
+func (n *FXUnitValueNames) WetDry() string {
+	return fmt.Sprintf("/Engine/FX/Unit%d/WetDry", n.UnitIndex)
+}
+
+// Parameter returns the StateMap path for the parameter knob position of
+// this effect unit, from 0 to 1. This path has not been verified on a device
+// yet.
+func (n *FXUnitValueNames) Parameter() string {
+	return fmt.Sprintf("/Engine/FX/Unit%d/Parameter", n.UnitIndex)
+}
+
+// BeatsIndex returns the StateMap path for the selected beat division of
+// this effect unit as an index into the device's list. This path has not
+// been verified on a device yet.
+func (n *FXUnitValueNames) BeatsIndex() string {
+	return fmt.Sprintf("/Engine/FX/Unit%d/BeatsIndex", n.UnitIndex)
+}
+
+// ChannelAssigned returns the StateMap path for whether mixer channel n
+// (1-based) is routed through this effect unit. This path has not been
+// verified on a device yet.
+func (n *FXUnitValueNames) ChannelAssigned(channel int) string {
+	return fmt.Sprintf("/Engine/FX/Unit%d/Channel%dAssigned", n.UnitIndex, channel)
+}
+
+// SamplerSlotValueNames provides StateMap path helpers for a specific
+// sampler slot (1-based).
+type SamplerSlotValueNames struct {
+	SlotIndex int
+}
+
+var (
+	EngineSamplerSlot1 = SamplerSlotValueNames{SlotIndex: 1}
+	EngineSamplerSlot2 = SamplerSlotValueNames{SlotIndex: 2}
+	EngineSamplerSlot3 = SamplerSlotValueNames{SlotIndex: 3}
+	EngineSamplerSlot4 = SamplerSlotValueNames{SlotIndex: 4}
+	EngineSamplerSlot5 = SamplerSlotValueNames{SlotIndex: 5}
+	EngineSamplerSlot6 = SamplerSlotValueNames{SlotIndex: 6}
+	EngineSamplerSlot7 = SamplerSlotValueNames{SlotIndex: 7}
+	EngineSamplerSlot8 = SamplerSlotValueNames{SlotIndex: 8}
+)
+
+// Loaded returns the StateMap path for whether a sample is loaded into this
+// sampler slot. This path has not been verified on a device yet.
+func (n *SamplerSlotValueNames) Loaded() string {
+	return fmt.Sprintf("/Engine/Sampler/Slot%d/Loaded", n.SlotIndex)
+}
+
+// Playing returns the StateMap path for whether this sampler slot is
+// playing. This path has not been verified on a device yet.
+func (n *SamplerSlotValueNames) Playing() string {
+	return fmt.Sprintf("/Engine/Sampler/Slot%d/Playing", n.SlotIndex)
+}
+
+// SampleName returns the StateMap path for the name of the sample loaded
+// into this sampler slot. This path has not been verified on a device yet.
+func (n *SamplerSlotValueNames) SampleName() string {
+	return fmt.Sprintf("/Engine/Sampler/Slot%d/SampleName", n.SlotIndex)
+}
+
+// Volume returns the StateMap path for the volume of this sampler slot, from
+// 0 to 1. This path has not been verified on a device yet.
+func (n *SamplerSlotValueNames) Volume() string {
+	return fmt.Sprintf("/Engine/Sampler/Slot%d/Volume", n.SlotIndex)
+}
+
+// TriggerMode returns the StateMap path for how this sampler slot is
+// triggered, for example "Trigger" or "Gate". This path has not been
+// verified on a device yet.
+func (n *SamplerSlotValueNames) TriggerMode() string {
+	return fmt.Sprintf("/Engine/Sampler/Slot%d/TriggerMode", n.SlotIndex)
+}